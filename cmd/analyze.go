@@ -0,0 +1,276 @@
+package cmd
+
+import (
+	"crowsnest/internal/analyze"
+	"crowsnest/internal/export"
+	"crowsnest/internal/files"
+	"crowsnest/internal/pretty"
+	"crowsnest/internal/spray"
+	"crowsnest/internal/sqlite"
+	"fmt"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	// Add analyze command to root command
+	rootCmd.AddCommand(analyzeCmd)
+	analyzeCmd.AddCommand(analyzePasswordsCmd)
+	analyzeCmd.AddCommand(analyzeEmailsCmd)
+
+	// Add flags specific to analyze passwords command
+	analyzePasswordsCmd.Flags().StringVarP(&analyzeDomain, "domain", "d", "", "Only analyze email accounts of this domain")
+	analyzePasswordsCmd.Flags().StringVarP(&analyzeCompany, "company", "c", "", "Company name searched for in passwords (default first label of --domain)")
+	analyzePasswordsCmd.Flags().IntVarP(&analyzeTop, "top", "n", 10, "Number of entries listed per table (0 for all)")
+	analyzePasswordsCmd.Flags().StringVarP(&analyzeOutputFile, "output", "o", "", "File to write the report to, without extension")
+	analyzePasswordsCmd.Flags().StringVarP(&analyzeOutputFormat, "format", "f", "json", "Output format (json, yaml, xml, txt)")
+
+	// Add flags specific to analyze emails command
+	analyzeEmailsCmd.Flags().StringVarP(&analyzeDomain, "domain", "d", "", "Domain whose email format is inferred (required)")
+	analyzeEmailsCmd.Flags().StringVarP(&analyzeRoster, "roster", "r", "", "File of employee names to build addresses for, one \"First Last\" per line")
+	analyzeEmailsCmd.Flags().StringVarP(&analyzePattern, "pattern", "p", "", "Pattern addresses are built with (default inferred format)")
+	analyzeEmailsCmd.Flags().StringVarP(&analyzeOutputFile, "output", "o", "", "File to write the report to, without extension")
+	analyzeEmailsCmd.Flags().StringVarP(&analyzeOutputFormat, "format", "f", "json", "Output format (json, yaml, xml, txt)")
+	analyzeEmailsCmd.MarkFlagRequired("domain")
+}
+
+var (
+	// Analyze command flags
+	analyzeDomain       string
+	analyzeCompany      string
+	analyzeTop          int
+	analyzeOutputFile   string
+	analyzeOutputFormat string
+	analyzeRoster       string
+	analyzePattern      string
+
+	analyzeCmd = &cobra.Command{
+		Use:   "analyze",
+		Short: "Analyze the stored data",
+	}
+
+	analyzePasswordsCmd = &cobra.Command{
+		Use:   "passwords",
+		Short: "Report password statistics over the stored credentials",
+		Long: `Report statistics over the plaintext passwords in the creds and dehashed tables: length distribution,
+character classes, patterns such as years, seasons and the company name, common base words and suffixes,
+passwords shared between accounts, passwords reused across breaches, and candidate passwords for spraying.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if files.GetFileType(analyzeOutputFormat) == files.UNKNOWN {
+				fmt.Println("[!] Error: Invalid output format. Must be 'json', 'xml', 'yaml', or 'txt'.")
+				return
+			}
+
+			domain := strings.ToLower(strings.TrimPrefix(analyzeDomain, "@"))
+			company := analyzeCompany
+			if company == "" && domain != "" {
+				company = strings.Split(domain, ".")[0]
+			}
+
+			creds, err := analyze.LoadCredentials(domain)
+			if err != nil {
+				zap.L().Error("load_credentials",
+					zap.String("message", "failed to load credentials"),
+					zap.Error(err),
+				)
+				fmt.Printf("[!] Error loading credentials: %v\n", err)
+				return
+			}
+			if len(creds) == 0 {
+				fmt.Println("[-] No plaintext credentials found")
+				return
+			}
+
+			report := analyze.Passwords(creds, company, analyzeTop)
+			report.Domain = domain
+			printPasswordReport(report)
+
+			writeReport(report, "write_password_report")
+		},
+	}
+
+	analyzeEmailsCmd = &cobra.Command{
+		Use:   "emails",
+		Short: "Infer the email address format of a domain",
+		Long: `Infer the local part conventions of a domain (first.last, flast, firstl...) from the emails stored in the
+creds, dehashed and hunter_email tables. Addresses whose owner's name is known confirm a format, the others count
+for the formats their structure fits. The result is compared with the Hunter.io pattern, and addresses are built
+for the stored names, or for the names of a roster file, with the dominant format.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if files.GetFileType(analyzeOutputFormat) == files.UNKNOWN {
+				fmt.Println("[!] Error: Invalid output format. Must be 'json', 'xml', 'yaml', or 'txt'.")
+				return
+			}
+
+			domain := strings.ToLower(strings.TrimPrefix(analyzeDomain, "@"))
+
+			emails, err := spray.LoadEmails(domain)
+			if err == nil && len(emails) == 0 {
+				fmt.Printf("[-] No emails stored for %s\n", domain)
+				return
+			}
+			var named map[string]spray.Person
+			if err == nil {
+				named, err = spray.LoadNamedEmails(domain)
+			}
+			var data sqlite.HunterDomainData
+			if err == nil {
+				err = sqlite.GetDB().Where("domain = ?", domain).Limit(1).Find(&data).Error
+			}
+			if err != nil {
+				zap.L().Error("load_emails",
+					zap.String("message", "failed to load emails"),
+					zap.Error(err),
+				)
+				fmt.Printf("[!] Error loading emails: %v\n", err)
+				return
+			}
+
+			report := analyze.InferEmailFormat(domain, emails, named)
+			report.CompareHunter(data.Pattern)
+
+			var people []spray.Person
+			if analyzeRoster != "" {
+				people, err = spray.ReadRoster(analyzeRoster)
+			} else {
+				people, err = spray.LoadPeople(domain)
+			}
+			if err != nil {
+				zap.L().Error("load_names",
+					zap.String("message", "failed to load names"),
+					zap.Error(err),
+				)
+				fmt.Printf("[!] Error loading names: %v\n", err)
+				return
+			}
+			pattern := analyzePattern
+			if pattern == "" {
+				pattern = report.Pattern
+			}
+			if pattern != "" {
+				report.Synthesize(pattern, people, emails)
+			}
+
+			printEmailFormatReport(report)
+			writeReport(report, "write_email_format_report")
+		},
+	}
+)
+
+// writeReport writes an analysis report to the --output file when one is given
+func writeReport(report sqlite.IString, logKey string) {
+	if analyzeOutputFile == "" {
+		return
+	}
+	fType := files.GetFileType(analyzeOutputFormat)
+	fmt.Printf("[*] Writing report to file: %s%s\n", export.Path(analyzeOutputFile), fType.Extension())
+	if err := export.WriteIStringToFile(report, analyzeOutputFile, fType); err != nil {
+		zap.L().Error(logKey,
+			zap.String("message", "failed to write report to file"),
+			zap.Error(err),
+		)
+		fmt.Printf("[!] Error writing report: %v\n", err)
+		return
+	}
+	fmt.Println("   [*] Success")
+}
+
+// printEmailFormatReport prints the scored formats and the synthesized addresses
+func printEmailFormatReport(report *analyze.EmailFormatReport) {
+	fmt.Printf("[*] %d emails for %s, %d with a known name, %d classified\n", report.Emails, report.Domain, report.Named, report.Classified)
+	if report.Pattern == "" {
+		fmt.Println("[-] No email format could be inferred")
+	} else {
+		fmt.Printf("[+] Dominant format: %s (%.1f%% confidence)\n", report.Pattern, report.Confidence)
+	}
+	switch {
+	case report.HunterPattern == "":
+		fmt.Println("   [*] No Hunter.io pattern stored for the domain")
+	case report.MatchesHunter:
+		fmt.Printf("   [+] Matches the Hunter.io pattern %s\n", report.HunterPattern)
+	default:
+		fmt.Printf("   [!] Differs from the Hunter.io pattern %s\n", report.HunterPattern)
+	}
+
+	if len(report.Formats) > 0 {
+		var rows [][]string
+		for _, f := range report.Formats {
+			rows = append(rows, []string{f.Pattern, strconv.Itoa(f.Confirmed), strconv.Itoa(f.Structural), fmt.Sprintf("%.1f%%", f.Confidence)})
+		}
+		pretty.Table([]string{"Format", "Confirmed", "Structural", "Confidence"}, rows)
+	}
+
+	if len(report.Synthesized) > 0 {
+		var rows [][]string
+		for i, s := range report.Synthesized {
+			if i == 50 {
+				fmt.Println("   [-] Large number of addresses synthesized, displaying first 50...")
+				break
+			}
+			status := "new"
+			if s.Known {
+				status = "stored"
+			}
+			rows = append(rows, []string{s.Name, s.Email, status})
+		}
+		fmt.Printf("\n[*] Synthesized %d addresses\n", len(report.Synthesized))
+		pretty.Table([]string{"Name", "Email", "Status"}, rows)
+	}
+}
+
+// printPasswordReport prints each section of the report as a table
+func printPasswordReport(report *analyze.PasswordReport) {
+	fmt.Printf("[*] %d credentials, %d accounts, %d unique passwords\n", report.Credentials, report.Accounts, report.UniquePasswords)
+
+	countRows := func(counts []analyze.Count) [][]string {
+		var rows [][]string
+		for _, c := range counts {
+			rows = append(rows, []string{c.Value, strconv.Itoa(c.Count), fmt.Sprintf("%.1f%%", c.Percent)})
+		}
+		return rows
+	}
+	for _, section := range []struct {
+		title  string
+		header string
+		counts []analyze.Count
+	}{
+		{"Length Distribution", "Length", report.Lengths},
+		{"Character Classes", "Classes", report.CharacterClasses},
+		{"Patterns", "Pattern", report.Patterns},
+		{"Base Words", "Word", report.BaseWords},
+		{"Suffixes", "Suffix", report.Suffixes},
+	} {
+		if len(section.counts) == 0 {
+			continue
+		}
+		fmt.Printf("\n[*] %s\n", section.title)
+		pretty.Table([]string{section.header, "Count", "Percent"}, countRows(section.counts))
+	}
+
+	if len(report.SharedPasswords) > 0 {
+		var rows [][]string
+		for _, s := range report.SharedPasswords {
+			rows = append(rows, []string{s.Password, strconv.Itoa(s.Accounts)})
+		}
+		fmt.Println("\n[*] Passwords Shared Between Accounts")
+		pretty.Table([]string{"Password", "Accounts"}, rows)
+	}
+
+	if len(report.BreachReuse) > 0 {
+		var rows [][]string
+		for _, b := range report.BreachReuse {
+			rows = append(rows, []string{b.Account, b.Password, strings.Join(b.Breaches, ", ")})
+		}
+		fmt.Println("\n[*] Passwords Reused Across Breaches")
+		pretty.Table([]string{"Account", "Password", "Breaches"}, rows)
+	}
+
+	if len(report.SprayCandidates) > 0 {
+		fmt.Println("\n[*] Spray Candidates")
+		for _, c := range report.SprayCandidates {
+			fmt.Printf("   %s\n", c)
+		}
+	}
+}
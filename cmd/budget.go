@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"crowsnest/internal/budget"
+	"crowsnest/internal/httpclient"
+	"crowsnest/internal/pretty"
+	"crowsnest/internal/sqlite"
+	"fmt"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"strconv"
+)
+
+func init() {
+	// Add global budget flag
+	rootCmd.PersistentFlags().IntVar(&maxCredits, "max-credits", 0, "Maximum credits to spend per provider in this run (0 for no limit)")
+
+	// Add budget command to root command
+	rootCmd.AddCommand(budgetCmd)
+	budgetCmd.AddCommand(budgetSetCmd)
+	budgetCmd.AddCommand(budgetShowCmd)
+	budgetCmd.AddCommand(budgetResetCmd)
+}
+
+var (
+	// Global budget flag
+	maxCredits int
+
+	// Budget shared by every client in this run
+	creditBudget *budget.Budget
+
+	budgetCmd = &cobra.Command{
+		Use:   "budget",
+		Short: "Manage the persistent credit budget",
+		Long: `Manage the persistent credit budget for each provider (dehashed, whois, hunter).
+Every request is checked against the budget before it is sent. When the next request would exceed it,
+the run stops and stores what it has retrieved so far.`,
+	}
+
+	budgetSetCmd = &cobra.Command{
+		Use:   "set [dehashed|whois|hunter] [credits]",
+		Short: "Set the credit limit for a provider (0 removes the limit)",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			provider, err := httpclient.GetProvider(args[0])
+			if err != nil {
+				fmt.Printf("[!] %v\n", err)
+				return
+			}
+			limit, err := strconv.Atoi(args[1])
+			if err != nil || limit < 0 {
+				fmt.Println("[!] Credits must be zero or a positive number.")
+				return
+			}
+
+			err = sqlite.SetBudgetLimit(string(provider), limit)
+			if err != nil {
+				zap.L().Error("set_budget",
+					zap.String("message", "failed to store budget"),
+					zap.Error(err),
+				)
+				fmt.Printf("Error storing budget: %v\n", err)
+				return
+			}
+			fmt.Printf("Budget for %s set to %d credits\n", provider, limit)
+		},
+	}
+
+	budgetShowCmd = &cobra.Command{
+		Use:   "show",
+		Short: "Show the credit budget for each provider",
+		Run: func(cmd *cobra.Command, args []string) {
+			budgets, err := sqlite.GetBudgets()
+			if err != nil {
+				zap.L().Error("get_budgets",
+					zap.String("message", "failed to load budgets"),
+					zap.Error(err),
+				)
+				fmt.Printf("Error loading budgets: %v\n", err)
+				return
+			}
+			if len(budgets) == 0 {
+				fmt.Println("[-] No budgets set")
+				return
+			}
+
+			var (
+				headers = []string{"Provider", "Limit", "Spent", "Remaining"}
+				rows    [][]string
+			)
+			for _, b := range budgets {
+				limit, remaining := "none", "unlimited"
+				if b.CreditLimit > 0 {
+					limit = strconv.Itoa(b.CreditLimit)
+					remaining = strconv.Itoa(b.Remaining())
+				}
+				rows = append(rows, []string{b.Provider, limit, strconv.Itoa(b.CreditsSpent), remaining})
+			}
+			pretty.Table(headers, rows)
+		},
+	}
+
+	budgetResetCmd = &cobra.Command{
+		Use:   "reset [dehashed|whois|hunter]",
+		Short: "Reset the credits spent against a provider budget",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			provider, err := httpclient.GetProvider(args[0])
+			if err != nil {
+				fmt.Printf("[!] %v\n", err)
+				return
+			}
+
+			err = sqlite.ResetBudget(string(provider))
+			if err != nil {
+				zap.L().Error("reset_budget",
+					zap.String("message", "failed to reset budget"),
+					zap.Error(err),
+				)
+				fmt.Printf("Error resetting budget: %v\n", err)
+				return
+			}
+			fmt.Printf("Budget for %s reset\n", provider)
+		},
+	}
+)
+
+// getCreditBudget returns the budget shared by every client in this run
+func getCreditBudget() *budget.Budget {
+	if creditBudget == nil {
+		creditBudget = budget.NewBudget(maxCredits, debugGlobal)
+	}
+	return creditBudget
+}
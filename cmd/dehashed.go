@@ -1,13 +1,23 @@
 package cmd
 
 import (
+	"context"
 	"crowsnest/internal/badger"
 	"crowsnest/internal/debug"
 	"crowsnest/internal/dehashed"
+	"crowsnest/internal/export"
+	"crowsnest/internal/files"
+	"crowsnest/internal/httpclient"
+	"crowsnest/internal/pretty"
 	"crowsnest/internal/sqlite"
+	"crowsnest/internal/workers"
+	"errors"
 	"fmt"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
+	"os"
+	"slices"
+	"sync"
 )
 
 func init() {
@@ -37,9 +47,22 @@ func init() {
 	dehashedCmd.Flags().StringVarP(&cryptoCurrencyAddressQuery, "crypto", "B", "", "Crypto currency address query")
 	dehashedCmd.Flags().StringVarP(&hashQuery, "hash", "Q", "", "Hashed password query")
 	dehashedCmd.Flags().StringVarP(&nameQuery, "name", "N", "", "Name query")
+	dehashedCmd.Flags().BoolVarP(&fetchAll, "all", "a", false, "Page through every result reported by the API (use --max-requests to cap, --max-records for smaller pages)")
+	dehashedCmd.Flags().BoolVar(&dehashedDryRun, "dry-run", false, "Print the request, pages and estimated cost without contacting the API")
+	dehashedCmd.Flags().StringVarP(&dehashedInput, "input", "i", "", "File of targets to query, one per line (or CSV with --csv)")
+	dehashedCmd.Flags().StringVarP(&dehashedField, "field", "F", "email", "Field searched for each line of the input file (email, username, domain, ip, phone, name...)")
+	dehashedCmd.Flags().BoolVarP(&dehashedCSV, "csv", "c", false, "Read the input file as CSV with a header row naming the field of each column")
+	dehashedCmd.Flags().StringVarP(&queryExpression, "query", "q", "", "Boolean query expression, e.g. '(email:@acme.com OR domain:acme.com) AND NOT username:test'")
+	dehashedCmd.Flags().UintVar(&resumeRunID, "resume", 0, "Resume an interrupted run from its last completed page (see 'runs' table)")
 
 	// Add mutually exclusive flags to wildcard match and regex match
 	dehashedCmd.MarkFlagsMutuallyExclusive("regex-match", "wildcard-match")
+	// A batch creates its own runs and cannot be resumed as one
+	dehashedCmd.MarkFlagsMutuallyExclusive("input", "resume")
+	// A query expression replaces the individual field flags
+	for _, field := range []string{"username", "email-query", "ip", "domain", "password", "vin", "license", "address", "phone", "social", "crypto", "hash", "name", "input"} {
+		dehashedCmd.MarkFlagsMutuallyExclusive("query", field)
+	}
 }
 
 var (
@@ -66,6 +89,13 @@ var (
 	phoneQuery                 string
 	socialQuery                string
 	cryptoCurrencyAddressQuery string
+	fetchAll                   bool
+	resumeRunID                uint
+	dehashedDryRun             bool
+	dehashedInput              string
+	dehashedField              string
+	dehashedCSV                bool
+	queryExpression            string
 
 	// Query command
 	dehashedCmd = &cobra.Command{
@@ -76,60 +106,141 @@ var (
 			key := getDehashedApiKey()
 
 			// Validate credentials
-			if key == "" {
+			if key == "" && !dehashedDryRun {
 				fmt.Println("API key is required. Set the key with the \"set-key\" command. [crowsnest set-key <api_key>]")
 				return
 			}
 
-			// Create new QueryOptions
-			queryOptions := sqlite.NewQueryOptions(
-				maxRecords,
-				maxRequests,
-				startingPage,
-				outputFormat,
-				outputFile,
-				usernameQuery,
-				emailQuery,
-				ipQuery,
-				passwordQuery,
-				hashQuery,
-				nameQuery,
-				domainQuery,
-				vinQuery,
-				licensePlateQuery,
-				addressQuery,
-				phoneQuery,
-				socialQuery,
-				cryptoCurrencyAddressQuery,
-				regexMatch,
-				wildcardMatch,
-				printBalance,
-				credsOnly,
-				debugGlobal,
+			if dehashedInput != "" {
+				runDehashedBatch(key)
+				return
+			}
+
+			// Validate the query expression before creating a run
+			if queryExpression != "" {
+				query, err := dehashed.CompileQuery(queryExpression, wildcardMatch, regexMatch)
+				if err != nil {
+					zap.L().Error("compile_query",
+						zap.String("message", "invalid query expression"),
+						zap.String("expression", queryExpression),
+						zap.Error(err),
+					)
+					fmt.Printf("[!] Invalid query: %v\n", err)
+					var queryErr *dehashed.QueryError
+					if errors.As(err, &queryErr) {
+						fmt.Println(queryErr.Caret(queryExpression))
+					}
+					return
+				}
+				if debugGlobal {
+					debug.PrintInfo("compiled query: " + query)
+				}
+			}
+
+			var (
+				queryOptions *sqlite.QueryOptions
+				err          error
 			)
+			if resumeRunID > 0 {
+				// Load the interrupted run
+				queryOptions, err = sqlite.GetDehashedQueryOptions(resumeRunID)
+				if err != nil {
+					zap.L().Error("get_query_options",
+						zap.String("message", "failed to load run"),
+						zap.Uint("run_id", resumeRunID),
+						zap.Error(err),
+					)
+					fmt.Printf("[!] Run %d not found: %v\n", resumeRunID, err)
+					return
+				}
+				if queryOptions.Completed {
+					fmt.Printf("[*] Run %d already completed at page %d\n", resumeRunID, queryOptions.LastPage)
+					return
+				}
+				queryOptions.Debug = debugGlobal
+			} else {
+				// Create new QueryOptions
+				queryOptions = sqlite.NewQueryOptions(
+					maxRecords,
+					maxRequests,
+					startingPage,
+					outputFormat,
+					outputFile,
+					usernameQuery,
+					emailQuery,
+					ipQuery,
+					passwordQuery,
+					hashQuery,
+					nameQuery,
+					domainQuery,
+					vinQuery,
+					licensePlateQuery,
+					addressQuery,
+					phoneQuery,
+					socialQuery,
+					cryptoCurrencyAddressQuery,
+					regexMatch,
+					wildcardMatch,
+					printBalance,
+					credsOnly,
+					debugGlobal,
+				)
+				queryOptions.FetchAll = fetchAll
+				queryOptions.Expression = queryExpression
+			}
+
+			// Refuse queries for targets outside the project scope
+			if !checkScope(dehashed.ScopeTargets(queryOptions), dehashedDryRun) {
+				return
+			}
 
 			// Create new Dehasher
-			dehasher := dehashed.NewDehasher(queryOptions)
+			dehasher, err := dehashed.NewDehasher(queryOptions)
+			if err != nil {
+				zap.L().Error("new_dehasher",
+					zap.String("message", "failed to create dehasher"),
+					zap.Error(err),
+				)
+				fmt.Printf("[!] %v\n", err)
+				return
+			}
 			dehasher.SetClientCredentials(
 				key,
+				newHTTPClient(httpclient.Dehashed),
 			)
 
+			if dehashedDryRun {
+				dehasher.DryRun()
+				return
+			}
+
+			// Store query options so the run can be checkpointed and resumed
+			if queryOptions.ID == 0 {
+				err = sqlite.StoreDehashedQueryOptions(queryOptions)
+				if err != nil {
+					if debugGlobal {
+						debug.PrintInfo("failed to store query options")
+						debug.PrintError(err)
+					}
+					zap.L().Error("store_query_options",
+						zap.String("message", "failed to store query options"),
+						zap.Error(err),
+					)
+					fmt.Printf("Error storing query options: %v\n", err)
+				}
+			}
+			if queryOptions.ID > 0 {
+				fmt.Printf("[*] Run ID: %d\n", queryOptions.ID)
+			}
+
 			// Start querying
-			dehasher.Start()
+			err = dehasher.Start()
 			fmt.Println("\n[*] Completing Process")
-
-			// Store query options
-			err := sqlite.StoreDehashedQueryOptions(queryOptions)
 			if err != nil {
-				if debugGlobal {
-					debug.PrintInfo("failed to store query options")
-					debug.PrintError(err)
+				if queryOptions.ID > 0 {
+					fmt.Printf("[*] Run interrupted after page %d. Resume with: crowsnest dehashed --resume %d\n", queryOptions.LastPage, queryOptions.ID)
 				}
-				zap.L().Error("store_query_options",
-					zap.String("message", "failed to store query options"),
-					zap.Error(err),
-				)
-				fmt.Printf("Error storing query options: %v\n", err)
+				os.Exit(-1)
 			}
 		},
 	}
@@ -139,3 +250,164 @@ var (
 func getDehashedApiKey() string {
 	return badger.GetDehashedKey()
 }
+
+// runDehashedBatch runs one query per line of the input file and writes a consolidated output file
+func runDehashedBatch(key string) {
+	var (
+		queries []dehashed.BatchQuery
+		err     error
+	)
+	if dehashedCSV {
+		queries, err = dehashed.ReadBatchCSV(dehashedInput)
+	} else {
+		queries, err = dehashed.ReadBatchInput(dehashedInput, dehashedField)
+	}
+	if err != nil {
+		zap.L().Error("read_batch_input",
+			zap.String("message", "failed to read batch input"),
+			zap.String("file", dehashedInput),
+			zap.Error(err),
+		)
+		fmt.Printf("[!] Error reading input file: %v\n", err)
+		return
+	}
+	fmt.Printf("[*] Loaded %d queries from %s\n", len(queries), dehashedInput)
+
+	// Drop the queries for targets outside the project scope
+	queries = slices.DeleteFunc(queries, func(query dehashed.BatchQuery) bool {
+		options := &sqlite.QueryOptions{}
+		query.Apply(options)
+		return !checkScope(dehashed.ScopeTargets(options), dehashedDryRun)
+	})
+	if len(queries) == 0 {
+		fmt.Println("[-] No queries in scope")
+		return
+	}
+
+	client := newHTTPClient(httpclient.Dehashed)
+
+	// Build every run up front so the progress numbering follows the input file
+	dehashers := make([]*dehashed.Dehasher, len(queries))
+	runs := make([]*sqlite.QueryOptions, len(queries))
+	for i, query := range queries {
+		queryOptions := sqlite.NewQueryOptions(
+			maxRecords,
+			maxRequests,
+			startingPage,
+			outputFormat,
+			outputFile,
+			"", "", "", "", "", "", "", "", "", "", "", "", "",
+			regexMatch,
+			wildcardMatch,
+			printBalance,
+			credsOnly,
+			debugGlobal,
+		)
+		queryOptions.FetchAll = fetchAll
+		query.Apply(queryOptions)
+
+		dehasher, err := dehashed.NewDehasher(queryOptions)
+		if err != nil {
+			zap.L().Error("new_dehasher",
+				zap.String("message", "failed to create dehasher"),
+				zap.Int("line", query.Line),
+				zap.Error(err),
+			)
+			fmt.Printf("[!] Line %d: %v\n", query.Line, err)
+			return
+		}
+		dehasher.SetClientCredentials(key, client)
+		dehasher.SetBatchMode(true)
+
+		if dehashedDryRun {
+			fmt.Printf("\n[*] [%d/%d] %s\n", i+1, len(queries), query)
+			dehasher.DryRun()
+			continue
+		}
+		dehashers[i] = dehasher
+		runs[i] = queryOptions
+	}
+
+	if dehashedDryRun {
+		return
+	}
+
+	var (
+		mu         sync.Mutex
+		results    sqlite.DehashedResults
+		incomplete []uint
+		headers    = []string{"Line", "Query", "Results", "Run ID"}
+		rows       = make([][]string, len(queries))
+	)
+	// Each query prints to its own buffer, written in order once the earlier queries finish
+	output := workers.NewOutput(os.Stdout, len(queries), workerCount)
+	err = workers.Run(rootCmd.Context(), workerCount, len(queries), func(_ context.Context, i int) error {
+		defer output.Done(i)
+		out := output.Job(i)
+		query, queryOptions, dehasher := queries[i], runs[i], dehashers[i]
+		fmt.Fprintf(out, "[*] [%d/%d] %s\n", i+1, len(queries), query)
+
+		// Store query options so each query can be checkpointed and resumed
+		err := sqlite.StoreDehashedQueryOptions(queryOptions)
+		if err != nil {
+			zap.L().Error("store_query_options",
+				zap.String("message", "failed to store query options"),
+				zap.Error(err),
+			)
+			fmt.Fprintf(out, "Error storing query options: %v\n", err)
+		}
+
+		dehasher.SetOutput(out)
+		err = dehasher.Start()
+		found := dehasher.GetResults().Results
+
+		mu.Lock()
+		defer mu.Unlock()
+		results.Results = append(results.Results, found...)
+		rows[i] = []string{fmt.Sprintf("%d", query.Line), query.String(), fmt.Sprintf("%d", len(found)), fmt.Sprintf("%d", queryOptions.ID)}
+		if err != nil {
+			incomplete = append(incomplete, queryOptions.ID)
+			if errors.Is(err, httpclient.ErrBudgetExceeded) {
+				return err
+			}
+		}
+		return nil
+	})
+	output.Flush()
+	if errors.Is(err, httpclient.ErrBudgetExceeded) {
+		fmt.Println("[!] Credit budget reached, stopping batch")
+	} else if errors.Is(err, context.Canceled) {
+		fmt.Println("[!] Interrupted, stopping batch")
+	}
+
+	// Drop the queries that never started
+	rows = slices.DeleteFunc(rows, func(row []string) bool { return row == nil })
+	slices.Sort(incomplete)
+
+	fmt.Println("\n[*] Completing Process")
+	pretty.Table(headers, rows)
+
+	if len(results.Results) > 0 {
+		fmt.Printf("[*] Writing %d entries to file: %s.%s\n", len(results.Results), outputFile, files.GetFileType(outputFormat).String())
+		if credsOnly {
+			err = export.WriteCredsToFile(results.ExtractUsers(), outputFile, files.GetFileType(outputFormat))
+		} else {
+			err = export.WriteToFile(results, outputFile, files.GetFileType(outputFormat))
+		}
+		if err != nil {
+			zap.L().Error("write_results",
+				zap.String("message", "failed to write batch results to file"),
+				zap.Error(err),
+			)
+			fmt.Printf("[!] Error Writing to file: %v\n", err)
+		} else {
+			fmt.Println("   [*] Success")
+		}
+	} else {
+		fmt.Println("[-] No results found")
+	}
+
+	for _, id := range incomplete {
+		fmt.Printf("[*] Run %d interrupted. Resume with: crowsnest dehashed --resume %d\n", id, id)
+	}
+}
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"crowsnest/internal/debug"
+	"crowsnest/internal/export"
+	"crowsnest/internal/graph"
+	"crowsnest/internal/pretty"
+	"fmt"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	// Add graph command to root command
+	rootCmd.AddCommand(graphCmd)
+	graphCmd.AddCommand(graphExportCmd)
+
+	// Add flags specific to graph export command
+	graphExportCmd.Flags().StringVarP(&graphFormat, "format", "f", "graphml", "Graph format (graphml, gexf, neo4j, dot)")
+	graphExportCmd.Flags().StringVarP(&graphOutputFile, "output", "o", "crowsnest_graph", "File to write the graph to, without extension")
+}
+
+var (
+	// Graph command flags
+	graphFormat     string
+	graphOutputFile string
+
+	graphCmd = &cobra.Command{
+		Use:   "graph",
+		Short: "Work with the stored data as a graph",
+	}
+
+	graphExportCmd = &cobra.Command{
+		Use:   "export",
+		Short: "Export the database as a node and edge graph",
+		Long: `Export the database as a graph for Maltego, Gephi, Neo4j or Graphviz. Nodes are the domains, subdomains,
+IPs, MX and NS hosts, registrants, emails, usernames, passwords and breaches stored, and typed edges link them:
+HAS_SUBDOMAIN, RESOLVES_TO, USES_MX, USES_NS, REGISTERED_BY, HAS_CONTACT, HAS_EMAIL, HAS_USERNAME, USES_PASSWORD,
+EXPOSED_IN and SEEN_FROM. Edges are weighted by the number of records they were found in.
+
+Formats:
+  graphml: GraphML for Gephi, yEd, Maltego and networkx
+  gexf:    GEXF 1.3 for Gephi
+  neo4j:   <output>_nodes.csv and <output>_relationships.csv for neo4j-admin database import
+  dot:     a Graphviz digraph`,
+		Run: func(cmd *cobra.Command, args []string) {
+			format := strings.ToLower(graphFormat)
+			if !slices.Contains(graph.Formats, format) {
+				fmt.Printf("[!] Unknown format %q (%s)\n", graphFormat, strings.Join(graph.Formats, ", "))
+				return
+			}
+
+			fmt.Println("[*] Building graph from the database...")
+			g, err := graph.Build()
+			if err != nil {
+				if debugGlobal {
+					debug.PrintInfo("failed to build graph")
+					debug.PrintError(err)
+				}
+				zap.L().Error("build_graph",
+					zap.String("message", "failed to build graph"),
+					zap.Error(err),
+				)
+				fmt.Printf("[!] Error building graph: %v\n", err)
+				return
+			}
+			if len(g.Nodes) == 0 {
+				fmt.Println("[-] Nothing stored to build a graph from")
+				return
+			}
+			fmt.Printf("[+] Graph of %d nodes and %d edges\n", len(g.Nodes), len(g.Edges))
+			printGraphCounts(g)
+
+			fmt.Printf("[*] Writing %s graph...\n", format)
+			written, err := graph.Write(g, format, export.Path(graphOutputFile))
+			if err != nil {
+				zap.L().Error("write_graph",
+					zap.String("message", "failed to write graph to file"),
+					zap.Error(err),
+				)
+				fmt.Printf("[!] Error writing graph: %v\n", err)
+				return
+			}
+			for _, file := range written {
+				fmt.Printf("   [*] %s\n", file)
+			}
+		},
+	}
+)
+
+// printGraphCounts prints the number of nodes of each type and edges of each type
+func printGraphCounts(g *graph.Graph) {
+	var (
+		types  []string
+		counts = make(map[string]int)
+	)
+	count := func(kind, name string) {
+		key := kind + "\x00" + name
+		if counts[key] == 0 {
+			types = append(types, key)
+		}
+		counts[key]++
+	}
+	for _, n := range g.Nodes {
+		count("Node", n.Type)
+	}
+	for _, e := range g.Edges {
+		count("Edge", e.Type)
+	}
+
+	var rows [][]string
+	for _, key := range types {
+		kind, name, _ := strings.Cut(key, "\x00")
+		rows = append(rows, []string{kind, name, strconv.Itoa(counts[key])})
+	}
+	pretty.Table([]string{"Kind", "Type", "Count"}, rows)
+}
@@ -0,0 +1,251 @@
+package cmd
+
+import (
+	"crowsnest/internal/badger"
+	"crowsnest/internal/debug"
+	"crowsnest/internal/httpclient"
+	"crowsnest/internal/sqlite"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	// Add global transport flags
+	rootCmd.PersistentFlags().StringVar(&httpDehashedURL, "dehashed-url", "", "Override the Dehashed API base URL (search and WHOIS)")
+	rootCmd.PersistentFlags().StringVar(&httpHunterURL, "hunter-url", "", "Override the Hunter.io API base URL")
+	rootCmd.PersistentFlags().StringVar(&httpProxy, "proxy", "", "Proxy URL for all API traffic (http, https, socks5, socks5h)")
+	rootCmd.PersistentFlags().IntVar(&httpTimeout, "timeout", 0, "HTTP timeout in seconds for all API traffic")
+	rootCmd.PersistentFlags().BoolVar(&httpInsecure, "insecure", false, "Skip TLS certificate verification")
+	rootCmd.PersistentFlags().StringVar(&httpCACert, "ca-cert", "", "PEM CA certificate to trust for API traffic")
+	rootCmd.PersistentFlags().IntVar(&httpRetries, "retries", httpclient.DefaultMaxRetries, "Maximum retries for rate limited (429), 5xx or failed requests (0 disables)")
+	rootCmd.PersistentFlags().IntVar(&httpRetryWait, "retry-wait", int(httpclient.DefaultRetryWait.Seconds()), "Initial retry wait in seconds, doubled on each retry unless Retry-After is sent")
+	rootCmd.PersistentFlags().Float64Var(&httpRateLimit, "rate", 0, "Maximum requests per second to each provider (default dehashed 5, hunter 10)")
+	rootCmd.PersistentFlags().StringVar(&httpRecordDir, "record", "", "Record every API request and response to fixtures in this directory")
+	rootCmd.PersistentFlags().StringVar(&httpReplayDir, "replay", "", "Replay API responses from fixtures in this directory without network access")
+	rootCmd.MarkFlagsMutuallyExclusive("record", "replay")
+
+	rootCmd.AddCommand(setHTTPCmd)
+}
+
+var (
+	// Global transport flags
+	httpDehashedURL string
+	httpHunterURL   string
+	httpProxy       string
+	httpTimeout     int
+	httpInsecure    bool
+	httpCACert      string
+	httpRetries     int
+	httpRetryWait   int
+	httpRateLimit   float64
+	httpRecordDir   string
+	httpReplayDir   string
+
+	setHTTPCmd = &cobra.Command{
+		Use:   "set-http [dehashed|hunter] [url|proxy|timeout|insecure|ca-cert|retries|retry-wait|rate|burst] [value]",
+		Short: "Set and store HTTP transport settings for a provider",
+		Long: `Set and store HTTP transport settings for a provider.
+Stored settings are used by every command and can be overridden per run with the global flags.
+Omit the value to clear a setting.
+
+Examples:
+  # Route all Dehashed traffic through a SOCKS proxy
+  crowsnest set-http dehashed proxy socks5://127.0.0.1:1080
+
+  # Point Hunter.io at a local mock server
+  crowsnest set-http hunter url http://127.0.0.1:8080
+
+  # Retry Dehashed requests up to 5 times
+  crowsnest set-http dehashed retries 5
+
+  # Send at most 2 Hunter.io requests per second
+  crowsnest set-http hunter rate 2
+
+  # Clear the stored Dehashed timeout
+  crowsnest set-http dehashed timeout`,
+		Args: cobra.RangeArgs(2, 3),
+		Run: func(cmd *cobra.Command, args []string) {
+			provider, err := httpclient.GetProvider(args[0])
+			if err != nil {
+				fmt.Printf("[!] %v\n", err)
+				return
+			}
+			provider = provider.ConfigProvider()
+
+			value := ""
+			if len(args) == 3 {
+				value = strings.TrimSpace(args[2])
+			}
+
+			cfg := getStoredHTTPConfig(provider)
+			switch strings.ToLower(args[1]) {
+			case "url":
+				cfg.BaseURL = value
+			case "proxy":
+				cfg.Proxy = value
+			case "timeout":
+				cfg.Timeout = 0
+				if value != "" {
+					cfg.Timeout, err = strconv.Atoi(value)
+					if err != nil || cfg.Timeout < 0 {
+						fmt.Println("[!] Timeout must be a positive number of seconds.")
+						return
+					}
+				}
+			case "retries":
+				cfg.MaxRetries = nil
+				if value != "" {
+					retries, convErr := strconv.Atoi(value)
+					if convErr != nil || retries < 0 {
+						fmt.Println("[!] Retries must be zero or a positive number.")
+						return
+					}
+					cfg.MaxRetries = &retries
+				}
+			case "retry-wait":
+				cfg.RetryWait = nil
+				if value != "" {
+					wait, convErr := strconv.Atoi(value)
+					if convErr != nil || wait < 0 {
+						fmt.Println("[!] Retry wait must be zero or a positive number of seconds.")
+						return
+					}
+					cfg.RetryWait = &wait
+				}
+			case "rate":
+				cfg.RateLimit = 0
+				if value != "" {
+					cfg.RateLimit, err = strconv.ParseFloat(value, 64)
+					if err != nil || cfg.RateLimit <= 0 {
+						fmt.Println("[!] Rate must be a positive number of requests per second.")
+						return
+					}
+				}
+			case "burst":
+				cfg.Burst = 0
+				if value != "" {
+					cfg.Burst, err = strconv.Atoi(value)
+					if err != nil || cfg.Burst <= 0 {
+						fmt.Println("[!] Burst must be a positive number of requests.")
+						return
+					}
+				}
+			case "insecure":
+				cfg.InsecureSkipVerify = nil
+				if value != "" {
+					insecure, convErr := strconv.ParseBool(value)
+					if convErr != nil {
+						fmt.Println("[!] Insecure must be true or false.")
+						return
+					}
+					cfg.InsecureSkipVerify = &insecure
+				}
+			case "ca-cert":
+				cfg.CACertFile = value
+			default:
+				fmt.Printf("[!] Unknown setting '%s'. Use url, proxy, timeout, insecure, ca-cert, retries, retry-wait, rate or burst.\n", args[1])
+				return
+			}
+
+			// Validate before storing
+			if _, err = httpclient.New(provider, cfg, debugGlobal); err != nil {
+				fmt.Printf("[!] Invalid HTTP settings: %v\n", err)
+				return
+			}
+
+			data, err := json.Marshal(cfg)
+			if err != nil {
+				fmt.Printf("Error encoding HTTP settings: %v\n", err)
+				return
+			}
+			err = badger.StoreHTTPConfig(string(provider), data)
+			if err != nil {
+				fmt.Printf("Error storing HTTP settings: %v\n", err)
+				return
+			}
+			fmt.Printf("HTTP settings for %s stored successfully\n", provider)
+		},
+	}
+)
+
+// getStoredHTTPConfig returns the transport settings stored for the provider
+func getStoredHTTPConfig(provider httpclient.Provider) httpclient.Config {
+	var cfg httpclient.Config
+
+	data := badger.GetHTTPConfig(string(provider))
+	if len(data) == 0 {
+		return cfg
+	}
+
+	err := json.Unmarshal(data, &cfg)
+	if err != nil {
+		zap.L().Error("get_http_config",
+			zap.String("message", "failed to decode stored http config"),
+			zap.String("provider", string(provider)),
+			zap.Error(err),
+		)
+	}
+	return cfg
+}
+
+// newHTTPClient builds the provider client from stored settings overridden by global flags
+func newHTTPClient(provider httpclient.Provider) *httpclient.Client {
+	flagCfg := httpclient.Config{
+		Proxy:      httpProxy,
+		Timeout:    httpTimeout,
+		CACertFile: httpCACert,
+		RateLimit:  httpRateLimit,
+		RecordDir:  httpRecordDir,
+		ReplayDir:  httpReplayDir,
+	}
+	if rootCmd.PersistentFlags().Changed("insecure") {
+		flagCfg.InsecureSkipVerify = &httpInsecure
+	}
+	if rootCmd.PersistentFlags().Changed("retries") {
+		flagCfg.MaxRetries = &httpRetries
+	}
+	if rootCmd.PersistentFlags().Changed("retry-wait") {
+		flagCfg.RetryWait = &httpRetryWait
+	}
+	switch provider.ConfigProvider() {
+	case httpclient.Dehashed:
+		flagCfg.BaseURL = httpDehashedURL
+	case httpclient.Hunter:
+		flagCfg.BaseURL = httpHunterURL
+	}
+
+	cfg := getStoredHTTPConfig(provider.ConfigProvider()).Merge(flagCfg)
+
+	client, err := httpclient.New(provider, cfg, debugGlobal)
+	if err != nil {
+		if debugGlobal {
+			debug.PrintInfo("failed to create http client")
+			debug.PrintError(err)
+		}
+		zap.L().Error("new_http_client",
+			zap.String("message", "failed to create http client"),
+			zap.String("provider", string(provider)),
+			zap.Error(err),
+		)
+		fmt.Printf("[!] Invalid HTTP settings for %s: %v\n", provider, err)
+		os.Exit(1)
+	}
+	client.SetGuard(getCreditBudget())
+	sqlite.AddRunProvider(string(provider))
+	client.SetContext(rootCmd.Context())
+	if !noRawResponses {
+		client.SetRecorder(storeRawResponse)
+	}
+	return client
+}
+
+// isDryRun reports whether the error only means the request was printed instead of sent
+func isDryRun(err error) bool {
+	return errors.Is(err, httpclient.ErrDryRun)
+}
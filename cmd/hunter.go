@@ -1,16 +1,24 @@
 package cmd
 
 import (
+	"context"
 	"crowsnest/internal/badger"
 	"crowsnest/internal/debug"
 	"crowsnest/internal/export"
 	"crowsnest/internal/files"
+	"crowsnest/internal/httpclient"
 	hunter "crowsnest/internal/hunter.io"
 	"crowsnest/internal/pretty"
+	"crowsnest/internal/scope"
 	"crowsnest/internal/sqlite"
+	"crowsnest/internal/workers"
+	"errors"
 	"fmt"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
+	"os"
+	"slices"
+	"sync"
 	"time"
 )
 
@@ -31,6 +39,8 @@ func init() {
 	hunterCmd.Flags().BoolVarP(&hunterCombinedEnrichmentEmail, "combined-enrichment", "B", false, "Combined Company and Person enrichment for email")
 	hunterCmd.Flags().StringVarP(&hunterOutputFormat, "format", "f", "json", "Output format (json, yaml, xml, txt)")
 	hunterCmd.Flags().StringVarP(&hunterOutputFile, "output", "o", "hunter", "File to output results to including extension")
+	hunterCmd.Flags().BoolVar(&hunterDryRun, "dry-run", false, "Print the request and estimated cost without contacting the API")
+	hunterCmd.Flags().StringVarP(&hunterInput, "input", "i", "", "File of emails to verify with --email-verify, one per line (uses --workers)")
 
 	// Add mutually exclusive flags to hunter command
 	hunterCmd.MarkFlagsMutuallyExclusive("email-find")
@@ -51,6 +61,8 @@ var (
 	hunterCombinedEnrichmentEmail bool
 	hunterOutputFormat            string
 	hunterOutputFile              string
+	hunterDryRun                  bool
+	hunterInput                   string
 
 	hunterCmd = &cobra.Command{
 		Use:   "hunter",
@@ -94,12 +106,33 @@ var (
 
 			fmt.Println("[*] Hunter.io API interaction [Beta]")
 
-			h := hunter.NewHunterIO(getHunterApiKey(), debugGlobal)
+			// Refuse lookups of targets outside the project scope
+			if !checkScope(hunterScopeTargets(), hunterDryRun) {
+				return
+			}
+
+			client := newHTTPClient(httpclient.Hunter)
+			client.SetDryRun(hunterDryRun)
+			h := hunter.NewHunterIO(getHunterApiKey(), client, debugGlobal)
+
+			// Load the remaining quota so the budget can stop before it runs out
+			if !hunterDryRun && getCreditBudget().Active(httpclient.Hunter) {
+				_, err := h.Account()
+				if err != nil {
+					zap.L().Error("hunter_account",
+						zap.String("message", "failed to get account quota for budget"),
+						zap.Error(err),
+					)
+				}
+			}
 
 			if hunterDomainSearch {
 				fmt.Println("[*] Performing domain search search...")
 				result, err := h.DomainSearch(hunterDomain)
 				if err != nil {
+					if isDryRun(err) {
+						return
+					}
 					if debugGlobal {
 						debug.PrintInfo("failed to perform domain search")
 						debug.PrintError(err)
@@ -155,6 +188,9 @@ var (
 				fmt.Println("[*] Performing email find search...")
 				result, err := h.EmailFinder(hunterDomain, hunterFirstName, hunterLastName)
 				if err != nil {
+					if isDryRun(err) {
+						return
+					}
 					if debugGlobal {
 						debug.PrintInfo("failed to perform email find")
 						debug.PrintError(err)
@@ -207,10 +243,18 @@ var (
 				return
 			}
 
+			if hunterEmailVerify && hunterInput != "" {
+				runHunterVerifyBatch(client, fType)
+				return
+			}
+
 			if hunterEmailVerify {
 				fmt.Println("[*] Performing email verification search...")
 				result, err := h.EmailVerification(hunterEmail)
 				if err != nil {
+					if isDryRun(err) {
+						return
+					}
 					if debugGlobal {
 						debug.PrintInfo("failed to perform email verification")
 						debug.PrintError(err)
@@ -261,6 +305,9 @@ var (
 				fmt.Println("[*] Performing company enrichment search...")
 				result, err := h.CompanyEnrichment(hunterDomain)
 				if err != nil {
+					if isDryRun(err) {
+						return
+					}
 					if debugGlobal {
 						debug.PrintInfo("failed to perform company enrichment")
 						debug.PrintError(err)
@@ -299,6 +346,9 @@ var (
 				fmt.Println("[*] Performing person enrichment search...")
 				result, err := h.PersonEnrichment(hunterEmail)
 				if err != nil {
+					if isDryRun(err) {
+						return
+					}
 					if debugGlobal {
 						debug.PrintInfo("failed to perform person enrichment")
 						debug.PrintError(err)
@@ -336,6 +386,9 @@ var (
 				fmt.Println("[*] Performing combined enrichment search...")
 				result, err := h.CombinedEnrichment(hunterEmail)
 				if err != nil {
+					if isDryRun(err) {
+						return
+					}
 					if debugGlobal {
 						debug.PrintInfo("failed to perform combined enrichment")
 						debug.PrintError(err)
@@ -372,6 +425,147 @@ var (
 	}
 )
 
+// hunterScopeTargets returns the domain and email the lookup queries
+func hunterScopeTargets() []scope.Target {
+	var targets []scope.Target
+	if hunterDomain != "" {
+		targets = append(targets, scope.Target{Kind: scope.TargetDomain, Value: hunterDomain})
+	}
+	if hunterEmail != "" {
+		targets = append(targets, scope.Target{Kind: scope.TargetEmail, Value: hunterEmail})
+	}
+	return targets
+}
+
+// runHunterVerifyBatch verifies every email in the input file concurrently and writes one consolidated file
+func runHunterVerifyBatch(client *httpclient.Client, fType files.FileType) {
+	emails, err := files.ReadLines(hunterInput)
+	if err != nil {
+		zap.L().Error("read_hunter_input",
+			zap.String("message", "failed to read hunter input"),
+			zap.String("file", hunterInput),
+			zap.Error(err),
+		)
+		fmt.Printf("[!] Error reading input file: %v\n", err)
+		return
+	}
+	fmt.Printf("[*] Loaded %d emails from %s\n", len(emails), hunterInput)
+
+	// Drop the emails outside the project scope
+	emails = slices.DeleteFunc(emails, func(email string) bool {
+		return !checkScope([]scope.Target{{Kind: scope.TargetEmail, Value: email}}, hunterDryRun)
+	})
+	if len(emails) == 0 {
+		fmt.Println("[-] No emails in scope")
+		return
+	}
+
+	// Dry run output is only readable one request at a time
+	poolSize := workerCount
+	if hunterDryRun {
+		poolSize = 1
+	}
+
+	// One client for every worker so verifications are charged together
+	h := hunter.NewHunterIO(getHunterApiKey(), client, debugGlobal)
+
+	var (
+		mu      sync.Mutex
+		found   = make(sqlite.HunterEmailVerifyResults, len(emails))
+		headers = []string{"Email", "Result", "Score", "Disposable", "MX Records", "SMTP Server", "SMTP Check"}
+		rows    = make([][]string, len(emails))
+	)
+	// Each lookup prints to its own buffer, written in order once the earlier lookups finish
+	output := workers.NewOutput(os.Stdout, len(emails), poolSize)
+	err = workers.Run(rootCmd.Context(), poolSize, len(emails), func(_ context.Context, i int) error {
+		defer output.Done(i)
+		out := output.Job(i)
+		email := emails[i]
+		fmt.Fprintf(out, "[*] [%d/%d] Verifying %s...\n", i+1, len(emails), email)
+
+		result, err := h.EmailVerification(email)
+		if err != nil {
+			if isDryRun(err) {
+				return nil
+			}
+			if debugGlobal {
+				debug.PrintInfo("failed to perform email verification")
+				debug.PrintError(err)
+			}
+			zap.L().Error("hunter_email_verification",
+				zap.String("message", "failed to perform email verification"),
+				zap.String("email", email),
+				zap.Error(err),
+			)
+			fmt.Fprintf(out, "   [!] Error verifying %s: %v\n", email, err)
+
+			mu.Lock()
+			rows[i] = []string{email, "error", "", "", "", "", ""}
+			mu.Unlock()
+			if errors.Is(err, httpclient.ErrBudgetExceeded) {
+				return err
+			}
+			return nil
+		}
+
+		// Store the deliverable addresses as users
+		if result.Result == "deliverable" {
+			err = sqlite.StoreUsers([]sqlite.User{{Email: result.Email}})
+			if err != nil {
+				zap.L().Error("store_users",
+					zap.String("message", "failed to store verified email"),
+					zap.Error(err),
+				)
+				fmt.Fprintf(out, "   [!] Error storing %s: %v\n", email, err)
+			}
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		found[i] = result
+		rows[i] = []string{
+			result.Email,
+			result.Result,
+			fmt.Sprintf("%d", result.Score),
+			fmt.Sprintf("%t", result.Disposable),
+			fmt.Sprintf("%t", result.MXRecords),
+			fmt.Sprintf("%t", result.SMTPServer),
+			fmt.Sprintf("%t", result.SMTPCheck),
+		}
+		return nil
+	})
+	output.Flush()
+	if errors.Is(err, httpclient.ErrBudgetExceeded) {
+		fmt.Println("[!] Credit budget reached, stopping batch")
+	} else if errors.Is(err, context.Canceled) {
+		fmt.Println("[!] Interrupted, stopping batch")
+	}
+
+	if hunterDryRun {
+		return
+	}
+
+	// Drop the emails that never started or failed
+	rows = slices.DeleteFunc(rows, func(row []string) bool { return row == nil })
+	results := slices.DeleteFunc(found, func(result sqlite.HunterEmailVerifyData) bool { return result.Email == "" })
+	fmt.Println("\nEmail Verification Results:")
+	pretty.Table(headers, rows)
+
+	if len(results) == 0 {
+		fmt.Println("[-] No emails verified")
+		return
+	}
+	fmt.Printf("[*] Writing %d Hunter.io Email Verification Results to file: %s%s\n", len(results), hunterOutputFile, fType.Extension())
+	err = export.WriteIStringToFile(results, hunterOutputFile, fType)
+	if err != nil {
+		zap.L().Error("write_hunter_email_verification",
+			zap.String("message", "failed to write hunter email verifications to file"),
+			zap.Error(err),
+		)
+		fmt.Printf("Error writing Hunter.io Email Verification Results to file: %v\n", err)
+	}
+}
+
 func hunterFlagCheck() bool {
 	if debugGlobal {
 		debug.PrintInfo("checking flags")
@@ -386,9 +580,13 @@ func hunterFlagCheck() bool {
 		}
 		optionSet = true
 	}
+	if hunterInput != "" && !hunterEmailVerify {
+		fmt.Println("Input files are only supported for email verification")
+		return false
+	}
 	if hunterEmailVerify {
-		if hunterEmail == "" {
-			fmt.Println("Email is required for email verification")
+		if hunterEmail == "" && hunterInput == "" {
+			fmt.Println("Email or input file is required for email verification")
 			return false
 		}
 		optionSet = true
@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"crowsnest/internal/identity"
+	"crowsnest/internal/pretty"
+	"crowsnest/internal/sqlite"
+	"fmt"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	// Add identity command to root command
+	rootCmd.AddCommand(identityCmd)
+	identityCmd.AddCommand(identityResolveCmd)
+	identityCmd.AddCommand(identityListCmd)
+	identityCmd.AddCommand(identityShowCmd)
+
+	// Add flags specific to identity list command
+	identityListCmd.Flags().Float64VarP(&identityMinConfidence, "min-confidence", "c", 0, "Only list identities with at least this confidence (0 to 1)")
+	identityListCmd.Flags().IntVarP(&identityMinRecords, "min-records", "r", 1, "Only list identities resolved from at least this many records")
+}
+
+var (
+	// Identity command flags
+	identityMinConfidence float64
+	identityMinRecords    int
+
+	identityCmd = &cobra.Command{
+		Use:   "identity",
+		Short: "Resolve the stored records into the people they belong to",
+	}
+
+	identityResolveCmd = &cobra.Command{
+		Use:   "resolve",
+		Short: "Cluster the stored records into identities",
+		Long: `Cluster the records of the creds, dehashed, hunter_email and person tables into identities. Records are joined
+when they share a normalized email (weight 1.0), phone number (0.9), social handle (0.9), username (0.7), or a name
+with an email at the same domain (0.6). An identity's confidence is the weight of the weakest match that joined it.
+The identities and identity_records tables are rebuilt on every run.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			resolved, ok := resolveIdentities()
+			if !ok {
+				return
+			}
+
+			var joined, records int
+			for _, r := range resolved {
+				records += r.Identity.Records
+				if r.Identity.Records > 1 {
+					joined++
+				}
+			}
+			fmt.Printf("[+] Resolved %d records into %d identities, %d joined from several records\n", records, len(resolved), joined)
+			printIdentities(resolved)
+		},
+	}
+
+	identityListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the resolved identities",
+		Run: func(cmd *cobra.Command, args []string) {
+			var identities []sqlite.Identity
+			err := sqlite.GetDB().Where("confidence >= ? AND records >= ?", identityMinConfidence, identityMinRecords).
+				Order("records DESC, id").Find(&identities).Error
+			if err != nil {
+				zap.L().Error("list_identities",
+					zap.String("message", "failed to list identities"),
+					zap.Error(err),
+				)
+				fmt.Printf("[!] Error listing identities: %v\n", err)
+				return
+			}
+			if len(identities) == 0 {
+				fmt.Println("[-] No identities found, run 'crowsnest identity resolve' first")
+				return
+			}
+
+			resolved := make([]identity.Resolved, 0, len(identities))
+			for _, i := range identities {
+				resolved = append(resolved, identity.Resolved{Identity: i})
+			}
+			fmt.Printf("[*] %d identities\n", len(identities))
+			printIdentities(resolved)
+		},
+	}
+
+	identityShowCmd = &cobra.Command{
+		Use:   "show [email]",
+		Short: "Show everything stored for the person owning an email address",
+		Long: `Show the identity holding an email address as a tree: its emails, usernames, names and phones, every breach
+with the passwords and hashes found in it, the roles held and the social profiles. Identities are resolved again
+first when none are stored or records were stored since the last resolve.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			stale, err := sqlite.IdentitiesStale()
+			if err != nil {
+				zap.L().Error("identities_stale",
+					zap.String("message", "failed to check when identities were resolved"),
+					zap.Error(err),
+				)
+				fmt.Println("[!] Could not check whether the identities are up to date, run 'crowsnest identity resolve' if records changed")
+			}
+			if stale {
+				if _, ok := resolveIdentities(); !ok {
+					return
+				}
+			}
+
+			details, err := sqlite.FindIdentity(args[0])
+			if err != nil {
+				zap.L().Error("find_identity",
+					zap.String("message", "failed to find identity"),
+					zap.String("email", args[0]),
+					zap.Error(err),
+				)
+				fmt.Printf("[!] Error finding identity: %v\n", err)
+				return
+			}
+			if details == nil {
+				fmt.Printf("[-] No identity found for %s\n", args[0])
+				return
+			}
+
+			pretty.IdentityTree(strings.ToLower(args[0]), *details)
+		},
+	}
+)
+
+// resolveIdentities clusters the stored records and replaces the stored identities, reporting whether it succeeded
+func resolveIdentities() ([]identity.Resolved, bool) {
+	fmt.Println("[*] Resolving identities from creds, dehashed, hunter_email and person...")
+	records, err := identity.Load()
+	if err != nil {
+		zap.L().Error("load_identity_records",
+			zap.String("message", "failed to load records"),
+			zap.Error(err),
+		)
+		fmt.Printf("[!] Error loading records: %v\n", err)
+		return nil, false
+	}
+
+	resolved := identity.Resolve(records)
+	identities := make([]sqlite.Identity, 0, len(resolved))
+	links := make([][]sqlite.IdentityRecord, 0, len(resolved))
+	for _, r := range resolved {
+		identities = append(identities, r.Identity)
+		links = append(links, r.Records)
+	}
+	if err = sqlite.ReplaceIdentities(identities, links); err != nil {
+		zap.L().Error("store_identities",
+			zap.String("message", "failed to store identities"),
+			zap.Error(err),
+		)
+		fmt.Printf("[!] Error storing identities: %v\n", err)
+		return nil, false
+	}
+	return resolved, true
+}
+
+// printIdentities prints a table of the identities, the first 50 when there are more
+func printIdentities(resolved []identity.Resolved) {
+	headers := []string{"Name", "Primary Email", "Emails", "Usernames", "Records", "Confidence"}
+	var rows [][]string
+	for i, r := range resolved {
+		if i == 50 {
+			fmt.Println("   [-] Large number of identities, displaying first 50...")
+			break
+		}
+		rows = append(rows, []string{
+			r.Identity.Name,
+			r.Identity.PrimaryEmail,
+			strconv.Itoa(len(r.Identity.Emails)),
+			strings.Join(r.Identity.Usernames, ", "),
+			strconv.Itoa(r.Identity.Records),
+			fmt.Sprintf("%.0f%%", r.Identity.Confidence*100),
+		})
+	}
+	pretty.Table(headers, rows)
+}
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"crowsnest/internal/breach"
+	"crowsnest/internal/debug"
+	"crowsnest/internal/hashes"
+	"crowsnest/internal/pretty"
+	"crowsnest/internal/sqlite"
+	"fmt"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+func init() {
+	// Add import command to root command
+	rootCmd.AddCommand(importCmd)
+	importCmd.AddCommand(importPotfileCmd)
+	importCmd.AddCommand(importBreachCmd)
+
+	// Add flags specific to import potfile command
+	importPotfileCmd.Flags().StringVarP(&importSource, "source", "s", "", "Source recorded on cracked credentials (default potfile:<file name>)")
+
+	// Add flags specific to import breach command
+	importBreachCmd.Flags().StringVarP(&importBreachFormat, "format", "f", "combo", "Format of the dump (combo, csv, json)")
+	importBreachCmd.Flags().StringVarP(&importBreachSource, "source", "s", "", "Breach name stored as the database name of every record (required)")
+	importBreachCmd.MarkFlagRequired("source")
+}
+
+var (
+	// Import command flags
+	importSource       string
+	importBreachFormat string
+	importBreachSource string
+
+	importCmd = &cobra.Command{
+		Use:   "import",
+		Short: "Import data from other tools into the database",
+	}
+
+	importPotfileCmd = &cobra.Command{
+		Use:   "potfile [file]",
+		Short: "Fill in cracked passwords from a hashcat or john potfile",
+		Long: `Match the hash:plain lines of a hashcat or john potfile against the hashed passwords stored in creds.
+Matching credentials get the plaintext password and are marked cracked with the source, e.g. to crack hashes
+exported with 'crowsnest targets -H' and bring the results back. The cracks are kept in the cracks table, so
+credentials stored again with a cracked hash get the plaintext too.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			pot, err := hashes.ReadPotfile(args[0])
+			if err != nil {
+				zap.L().Error("read_potfile",
+					zap.String("message", "failed to read potfile"),
+					zap.Error(err),
+				)
+				fmt.Printf("[!] Error reading potfile: %v\n", err)
+				return
+			}
+
+			source := importSource
+			if source == "" {
+				source = "potfile:" + filepath.Base(args[0])
+			}
+
+			fmt.Printf("[*] Matching potfile %s against stored hashes...\n", args[0])
+			creds, err := sqlite.StoreCracked(pot, source)
+			if err != nil {
+				zap.L().Error("store_cracked",
+					zap.String("message", "failed to store cracked credentials"),
+					zap.Error(err),
+				)
+				fmt.Printf("[!] Error storing cracked credentials: %v\n", err)
+			}
+			if len(creds) == 0 {
+				fmt.Println("[-] No stored hashes were cracked")
+				return
+			}
+
+			var (
+				headers  = []string{"Email", "Username", "Password", "Hash Type"}
+				rows     [][]string
+				accounts = make(map[string]bool)
+			)
+			for _, c := range creds {
+				accounts[c.Email+"\x00"+c.Username] = true
+				if len(rows) < 50 {
+					rows = append(rows, []string{c.Email, c.Username, c.Password, c.HashType})
+				}
+			}
+
+			fmt.Printf("[+] Cracked %d credentials, %d accounts gained a password\n", len(creds), len(accounts))
+			if len(creds) > 50 {
+				fmt.Println("   [-] Large number of credentials cracked, displaying first 50...")
+			}
+			pretty.Table(headers, rows)
+		},
+	}
+
+	importBreachCmd = &cobra.Command{
+		Use:   "breach [file]",
+		Short: "Load a local breach dump or combolist into the dehashed and creds tables",
+		Long: `Load a local breach dump into the dehashed and creds tables so it can be queried together with API results.
+Every record gets the source as its database name. Files are streamed and stored in batches, and importing
+the same file again with the same source stores no duplicates.
+
+Formats:
+  combo: user:password or user;password lines, users with an @ are stored as emails
+  csv:   a header naming the columns (email, username, password, hash, hash_type, name, ip, phone...)
+  json:  an array of objects or one object per line, with the same keys as csv or as Dehashed results`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			format := strings.ToLower(importBreachFormat)
+			if !slices.Contains(breach.Formats, format) {
+				fmt.Printf("[!] Unknown format %q (%s)\n", importBreachFormat, strings.Join(breach.Formats, ", "))
+				return
+			}
+			sqlite.AddRunProvider("import")
+
+			fmt.Printf("[*] Importing %s as %s...\n", args[0], importBreachSource)
+			stats, err := breach.Import(args[0], format, importBreachSource)
+			if err != nil {
+				if debugGlobal {
+					debug.PrintInfo("failed to import breach")
+					debug.PrintError(err)
+				}
+				zap.L().Error("import_breach",
+					zap.String("message", "failed to import breach"),
+					zap.String("file", args[0]),
+					zap.Error(err),
+				)
+				fmt.Printf("[!] Error importing breach: %v\n", err)
+			}
+
+			fmt.Printf("[+] Imported %d records and %d credentials from %s\n", stats.Records, stats.Creds, args[0])
+			if stats.Skipped > 0 {
+				fmt.Printf("   [-] Skipped %d entries that could not be parsed\n", stats.Skipped)
+			}
+			if stats.Failed > 0 {
+				fmt.Printf("   [!] Failed to store %d records, see the log for the errors\n", stats.Failed)
+			}
+		},
+	}
+)
@@ -0,0 +1,231 @@
+package cmd
+
+import (
+	"crowsnest/internal/budget"
+	"crowsnest/internal/debug"
+	"crowsnest/internal/export"
+	"crowsnest/internal/files"
+	"crowsnest/internal/httpclient"
+	hunter "crowsnest/internal/hunter.io"
+	"crowsnest/internal/playbook"
+	"crowsnest/internal/pretty"
+	"crowsnest/internal/scope"
+	"crowsnest/internal/whois"
+	"fmt"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	// Add playbook command to root command
+	rootCmd.AddCommand(playbookCmd)
+	playbookCmd.AddCommand(playbookRunCmd)
+	playbookCmd.AddCommand(playbookValidateCmd)
+
+	// Add flags specific to playbook commands
+	for _, c := range []*cobra.Command{playbookRunCmd, playbookValidateCmd} {
+		c.Flags().StringArrayVarP(&playbookVars, "var", "v", nil, "Set a playbook variable, name=value (comma separate a list of values)")
+	}
+	playbookRunCmd.Flags().StringVarP(&playbookOutputFile, "output", "o", "", "File to write the report to, without extension (default playbook_<name>)")
+	playbookRunCmd.Flags().StringVarP(&playbookOutputFormat, "format", "f", "json", "Output format (json, yaml, xml, txt)")
+}
+
+var (
+	// Playbook command flags
+	playbookVars         []string
+	playbookOutputFile   string
+	playbookOutputFormat string
+
+	playbookCmd = &cobra.Command{
+		Use:   "playbook",
+		Short: "Run recon workflows described in YAML",
+		Long: `Run recon workflows described in YAML. A playbook declares variables and a list of steps, each running
+one operation with inputs templated from the variables and the outputs of earlier steps:
+
+  name: acme-external
+  vars:
+    domain: acme.com
+  steps:
+    - id: hunter
+      op: hunter.domain-search
+      with:
+        domain: "{{ vars.domain }}"
+    - id: breaches
+      op: dehashed.search
+      when: steps.hunter.emails.count > 0
+      for_each: "{{ steps.hunter.emails }}"
+      with:
+        email: "{{ item }}"
+      max_credits: 20
+
+Step fields:
+  id                 name later steps refer to the step's outputs by
+  op                 operation to run (see below)
+  with               inputs of the operation, templated with {{ vars.<name> }}, {{ steps.<id>.<output> }}
+                     and, in a for_each step, {{ item }}. Add .count for the number of values
+  for_each           run the step once per value of a template
+  when               run the step only if a value is set, or a comparison holds:
+                     [not] <value> [==|!=|>|>=|<|<=|contains <value>]
+  max_credits        credits the step may spend, it keeps what it retrieved when reached (0 for no cap)
+  continue_on_error  carry on with the next step when this one fails
+
+Operations, their inputs and outputs:
+  whois.lookup          domain -> domain, registrar, registrant, created_date, name_servers, contact_email
+  whois.subdomains      domain -> subdomains
+  hunter.domain-search  domain -> emails, pattern, organization
+  hunter.email-finder   domain, first_name, last_name -> email, position
+  hunter.company        domain -> name, industry, employees
+  hunter.verify         email -> result, deliverable
+  dehashed.search       any of username, email, ip, password, hash, name, domain, vin, license_plate, address,
+                        phone, social, crypto_address, tuned by max_records, max_requests, wildcard and regex
+                        -> records, emails, usernames, passwords, hashes, ips, names, phones, breaches`,
+	}
+
+	playbookRunCmd = &cobra.Command{
+		Use:   "run [file]",
+		Short: "Run a playbook, storing everything under one command run",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			fType := files.GetFileType(playbookOutputFormat)
+			if fType == files.UNKNOWN {
+				fmt.Println("[!] Error: Invalid output format. Must be 'json', 'xml', 'yaml', or 'txt'.")
+				return
+			}
+
+			pb := loadPlaybook(args[0])
+			if pb == nil {
+				return
+			}
+			name := pb.Name
+			if name == "" {
+				name = strings.TrimSuffix(filepath.Base(args[0]), filepath.Ext(args[0]))
+			}
+			if playbookOutputFile == "" {
+				playbookOutputFile = "playbook_" + name
+			}
+
+			// Every client shares the step cap, which sits in front of the run budget
+			stepCap := budget.NewCap(getCreditBudget())
+			runner := &playbook.Runner{
+				Cap:     stepCap,
+				InScope: func(targets []scope.Target) bool { return checkScope(targets, false) },
+				Workers: workerCount,
+				Debug:   debugGlobal,
+			}
+			providers := pb.Providers()
+			key := getDehashedApiKey()
+			if (slices.Contains(providers, httpclient.Dehashed) || slices.Contains(providers, httpclient.Whois)) && key == "" {
+				fmt.Println("Dehashed API key is required. Set the key with the \"set-dehashed\" command. [crowsnest set-dehashed <api_key>]")
+				return
+			}
+			if slices.Contains(providers, httpclient.Hunter) && getHunterApiKey() == "" {
+				fmt.Println("Hunter.io API key is required. Set the key with the \"set-hunter\" command. [crowsnest set-hunter <api_key>]")
+				return
+			}
+			for _, provider := range providers {
+				client := newHTTPClient(provider)
+				client.SetGuard(stepCap)
+				switch provider {
+				case httpclient.Whois:
+					runner.Whois = whois.NewWhoIs(key, client, debugGlobal)
+				case httpclient.Hunter:
+					runner.Hunter = hunter.NewHunterIO(getHunterApiKey(), client, debugGlobal)
+				case httpclient.Dehashed:
+					runner.DehashedKey = key
+					runner.DehashedClient = client
+				}
+			}
+
+			fmt.Printf("[*] Running playbook %s (%d steps)\n", name, len(pb.Steps))
+			report := runner.Run(rootCmd.Context(), pb)
+			printPlaybookReport(report)
+
+			fmt.Printf("[*] Writing playbook report to file: %s%s\n", export.Path(playbookOutputFile), fType.Extension())
+			if err := export.WriteIStringToFile(report, playbookOutputFile, fType); err != nil {
+				zap.L().Error("write_playbook_report",
+					zap.String("message", "failed to write playbook report to file"),
+					zap.Error(err),
+				)
+				fmt.Printf("[!] Error writing playbook report: %v\n", err)
+				return
+			}
+			fmt.Println("   [*] Success")
+		},
+	}
+
+	playbookValidateCmd = &cobra.Command{
+		Use:   "validate [file]",
+		Short: "Check a playbook without running it",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			pb := loadPlaybook(args[0])
+			if pb == nil {
+				return
+			}
+			fmt.Printf("[+] %s is valid\n", args[0])
+
+			var rows [][]string
+			for _, step := range pb.Steps {
+				credits := "-"
+				if step.MaxCredits > 0 {
+					credits = strconv.Itoa(step.MaxCredits)
+				}
+				rows = append(rows, []string{step.ID, step.Op, step.ForEach, step.When, credits})
+			}
+			pretty.Table([]string{"Step", "Op", "For Each", "When", "Max Credits"}, rows)
+		},
+	}
+)
+
+// loadPlaybook reads and validates a playbook with the --var overrides, printing why it is invalid
+func loadPlaybook(path string) *playbook.Playbook {
+	vars := make(map[string]playbook.Values)
+	for _, v := range playbookVars {
+		name, value, ok := strings.Cut(v, "=")
+		if !ok || name == "" {
+			fmt.Printf("[!] Invalid variable %q, expected name=value\n", v)
+			return nil
+		}
+		vars[name] = strings.Split(value, ",")
+	}
+
+	pb, err := playbook.Load(path, vars)
+	if err != nil {
+		if debugGlobal {
+			debug.PrintInfo("failed to load playbook")
+			debug.PrintError(err)
+		}
+		zap.L().Error("load_playbook",
+			zap.String("message", "failed to load playbook"),
+			zap.String("path", path),
+			zap.Error(err),
+		)
+		fmt.Printf("[!] Invalid playbook: %v\n", err)
+		return nil
+	}
+	return pb
+}
+
+// printPlaybookReport prints the outcome and credits spent of every step
+func printPlaybookReport(report *playbook.Report) {
+	fmt.Printf("\n[*] Playbook summary (command run %d)\n", report.CommandRun)
+
+	var (
+		rows  [][]string
+		total int
+	)
+	for _, step := range report.Steps {
+		detail := step.Detail
+		if step.Error != "" {
+			detail = strings.TrimPrefix(detail+": "+step.Error, ": ")
+		}
+		rows = append(rows, []string{step.ID, step.Op, step.Status, strconv.Itoa(step.Credits), detail})
+		total += step.Credits
+	}
+	pretty.Table([]string{"Step", "Op", "Status", "Credits", "Detail"}, rows)
+	fmt.Printf("[*] %d credits spent\n", total)
+}
@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"bufio"
+	"crowsnest/internal/pretty"
+	"crowsnest/internal/project"
+	"fmt"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"os"
+	"strings"
+)
+
+func init() {
+	// Add project command to root command
+	rootCmd.AddCommand(projectCmd)
+	projectCmd.AddCommand(projectCreateCmd)
+	projectCmd.AddCommand(projectUseCmd)
+	projectCmd.AddCommand(projectListCmd)
+	projectCmd.AddCommand(projectArchiveCmd)
+	projectCmd.AddCommand(projectDeleteCmd)
+
+	// Add flags specific to the project subcommands
+	projectCreateCmd.Flags().StringVarP(&projectDescription, "description", "d", "", "Description of the engagement")
+	projectCreateCmd.Flags().StringVarP(&projectOutputDir, "output-dir", "o", "", "Directory output files are written to (default <project>/output)")
+	projectCreateCmd.Flags().BoolVarP(&projectUseNow, "use", "u", false, "Make the new project active")
+	projectUseCmd.Flags().BoolVarP(&projectNone, "none", "n", false, "Stop using a project and return to the default database")
+	projectArchiveCmd.Flags().BoolVarP(&projectRestore, "restore", "r", false, "Restore an archived project")
+	projectDeleteCmd.Flags().BoolVarP(&projectYes, "yes", "y", false, "Delete without asking for confirmation")
+}
+
+var (
+	// Project command flags
+	projectDescription string
+	projectOutputDir   string
+	projectUseNow      bool
+	projectNone        bool
+	projectRestore     bool
+	projectYes         bool
+
+	// Project active for this run, if any
+	activeProject    project.Project
+	hasActiveProject bool
+	activeProjectErr error // Set when the active project was archived or removed
+
+	projectCmd = &cobra.Command{
+		Use:   "project",
+		Short: "Manage engagement workspaces",
+		Long: `Manage engagement workspaces.
+Each project has its own database, output directory, credit budget and scope so client data never mixes
+across engagements. The active project replaces the local-db setting and is recorded on every run.`,
+	}
+
+	projectCreateCmd = &cobra.Command{
+		Use:   "create [name]",
+		Short: "Create a project",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			p, err := project.Create(args[0], projectDescription, projectOutputDir)
+			if err != nil {
+				zap.L().Error("create_project",
+					zap.String("message", "failed to create project"),
+					zap.String("project", args[0]),
+					zap.Error(err),
+				)
+				fmt.Printf("[!] Error creating project: %v\n", err)
+				return
+			}
+			fmt.Printf("[+] Created project %s\n", p.Name)
+			fmt.Printf("   [*] Database: %s\n", p.DBPath())
+			fmt.Printf("   [*] Output: %s\n", p.OutputDir)
+
+			if projectUseNow {
+				useProject(p.Name)
+			}
+		},
+	}
+
+	projectUseCmd = &cobra.Command{
+		Use:   "use [name]",
+		Short: "Make a project active for every following command",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if projectNone {
+				if err := project.Use(""); err != nil {
+					fmt.Printf("[!] Error clearing active project: %v\n", err)
+					return
+				}
+				fmt.Println("[+] No project active, using the default database")
+				return
+			}
+			if len(args) == 0 {
+				fmt.Println("[!] Project name is required, or use --none to return to the default database.")
+				return
+			}
+			useProject(args[0])
+		},
+	}
+
+	projectListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List projects",
+		Run: func(cmd *cobra.Command, args []string) {
+			projects, err := project.List()
+			if err != nil {
+				zap.L().Error("list_projects",
+					zap.String("message", "failed to load projects"),
+					zap.Error(err),
+				)
+				fmt.Printf("[!] Error loading projects: %v\n", err)
+				return
+			}
+			if len(projects) == 0 {
+				fmt.Println("[-] No projects, create one with: crowsnest project create <name>")
+				return
+			}
+
+			var (
+				headers = []string{"Name", "Status", "Description", "Database", "Output", "Created"}
+				rows    [][]string
+			)
+			for _, p := range projects {
+				status := ""
+				switch {
+				case p.Archived:
+					status = "archived"
+				case hasActiveProject && p.Name == activeProject.Name:
+					status = "active"
+				}
+				rows = append(rows, []string{
+					p.Name,
+					status,
+					p.Description,
+					p.DBPath(),
+					p.OutputDir,
+					p.CreatedAt.Format("2006-01-02 15:04:05"),
+				})
+			}
+			pretty.Table(headers, rows)
+		},
+	}
+
+	projectArchiveCmd = &cobra.Command{
+		Use:   "archive [name]",
+		Short: "Archive a project so it can no longer be used, keeping its data",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := project.SetArchived(args[0], !projectRestore); err != nil {
+				zap.L().Error("archive_project",
+					zap.String("message", "failed to archive project"),
+					zap.String("project", args[0]),
+					zap.Error(err),
+				)
+				fmt.Printf("[!] Error archiving project: %v\n", err)
+				return
+			}
+			if projectRestore {
+				fmt.Printf("[+] Restored project %s\n", args[0])
+				return
+			}
+			fmt.Printf("[+] Archived project %s\n", args[0])
+		},
+	}
+
+	projectDeleteCmd = &cobra.Command{
+		Use:   "delete [name]",
+		Short: "Delete a project and its database",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			p, err := project.Get(args[0])
+			if err != nil {
+				fmt.Printf("[!] %v\n", err)
+				return
+			}
+
+			if !projectYes {
+				fmt.Printf("[!] This permanently deletes %s\n", p.Path)
+				fmt.Printf("[*] Type the project name to confirm: ")
+				answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+				if strings.TrimSpace(answer) != p.Name {
+					fmt.Println("[-] Aborted")
+					return
+				}
+			}
+
+			if err = project.Delete(p.Name); err != nil {
+				zap.L().Error("delete_project",
+					zap.String("message", "failed to delete project"),
+					zap.String("project", p.Name),
+					zap.Error(err),
+				)
+				fmt.Printf("[!] Error deleting project: %v\n", err)
+				return
+			}
+			fmt.Printf("[+] Deleted project %s\n", p.Name)
+			if !p.OutputInside() {
+				fmt.Printf("[*] Output directory %s was kept\n", p.OutputDir)
+			}
+		},
+	}
+)
+
+// isProjectCommand reports whether the command manages projects, which still runs when the active project cannot be used
+func isProjectCommand(cmd *cobra.Command) bool {
+	for c := cmd; c != nil; c = c.Parent() {
+		if c == projectCmd {
+			return true
+		}
+	}
+	return false
+}
+
+// useProject makes the project active and reports where its data is kept
+func useProject(name string) {
+	if err := project.Use(name); err != nil {
+		zap.L().Error("use_project",
+			zap.String("message", "failed to use project"),
+			zap.String("project", name),
+			zap.Error(err),
+		)
+		fmt.Printf("[!] Error using project: %v\n", err)
+		return
+	}
+	fmt.Printf("[+] Using project %s\n", name)
+}
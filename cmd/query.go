@@ -27,6 +27,7 @@ func init() {
 	queryCmd.Flags().BoolVarP(&dbQueryListAll, "list-all", "a", false, "List all tables and their columns")
 	queryCmd.Flags().StringVarP(&dbQueryFormat, "format", "f", "json", "Output format (json, yaml, xml, txt)")
 	queryCmd.Flags().StringVarP(&dbQueryFile, "file", "o", "query", "File to output results to")
+	queryCmd.Flags().BoolVarP(&dbQuerySources, "sources", "s", false, "Show the runs that found each record (run ID, command line, provider, time found)")
 
 	// Add mutually exclusive flags to query and raw-query
 	// Cannot use query and raw-query at the same time
@@ -35,6 +36,8 @@ func init() {
 	queryCmd.MarkFlagsMutuallyExclusive("raw-query", "table")
 	// List all columns does not require a query or raw-query
 	queryCmd.MarkFlagsMutuallyExclusive("raw-query", "list-all")
+	// Sources are joined onto a table query
+	queryCmd.MarkFlagsMutuallyExclusive("raw-query", "sources")
 }
 
 var (
@@ -47,6 +50,7 @@ var (
 	dbQueryListAll   bool
 	dbQueryFormat    string
 	dbQueryFile      string
+	dbQuerySources   bool
 
 	queryCmd = &cobra.Command{
 		Use:   "query",
@@ -170,6 +174,14 @@ func tableQuery(table sqlite.Table) {
 	// Query the database
 	db := sqlite.GetDB()
 	query := db.Model(object).Select(columns)
+	if dbQuerySources {
+		var err error
+		query, err = sqlite.JoinSources(db.Model(object), object, columns)
+		if err != nil {
+			fmt.Printf("[!] Error joining record sources: %v\n", err)
+			return
+		}
+	}
 	if len(notNullFields) > 0 {
 		for _, field := range notNullFields {
 			query = query.Where(fmt.Sprintf("%s IS NOT NULL", field))
@@ -0,0 +1,282 @@
+package cmd
+
+import (
+	"bytes"
+	"crowsnest/internal/debug"
+	"crowsnest/internal/httpclient"
+	"crowsnest/internal/pretty"
+	"crowsnest/internal/raw"
+	"crowsnest/internal/sqlite"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"sort"
+	"strconv"
+)
+
+func init() {
+	// Add global raw capture flag
+	rootCmd.PersistentFlags().BoolVar(&noRawResponses, "no-raw", false, "Do not store raw API responses in the raw_responses table")
+
+	// Add raw command to root command
+	rootCmd.AddCommand(rawCmd)
+	rawCmd.AddCommand(rawShowCmd)
+	rawCmd.AddCommand(rawReparseCmd)
+
+	// Add flags shared by the raw subcommands
+	for _, c := range []*cobra.Command{rawShowCmd, rawReparseCmd} {
+		c.Flags().UintVarP(&rawRunID, "run", "r", 0, "Only responses from this run ID")
+		c.Flags().StringVarP(&rawProvider, "provider", "p", "", "Only responses from this provider (dehashed, whois, hunter)")
+		c.Flags().StringVarP(&rawOperation, "operation", "O", "", "Only responses for this operation (e.g. dehashed_search, whois_history)")
+	}
+	rawShowCmd.Flags().IntVarP(&rawLimit, "limit", "l", 50, "Maximum number of responses to list (0 for all)")
+	rawShowCmd.Flags().BoolVarP(&rawShowRequest, "request", "R", false, "Print the request body instead of the response body")
+}
+
+var (
+	// Global raw capture flag
+	noRawResponses bool
+
+	// Raw command flags
+	rawRunID       uint
+	rawProvider    string
+	rawOperation   string
+	rawLimit       int
+	rawShowRequest bool
+
+	rawCmd = &cobra.Command{
+		Use:   "raw",
+		Short: "Inspect and reparse stored raw API responses",
+		Long: `Every API response is stored compressed in the raw_responses table with its run ID, provider,
+operation and endpoint, so fields the typed tables do not model are never lost.
+Use 'raw reparse' to parse stored responses into the typed tables again after the models change.`,
+	}
+
+	rawShowCmd = &cobra.Command{
+		Use:   "show [id]",
+		Short: "List stored raw responses, or print the body of one",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) == 1 {
+				id, err := strconv.ParseUint(args[0], 10, 64)
+				if err != nil {
+					fmt.Println("[!] ID must be a positive number.")
+					return
+				}
+				showRawResponse(uint(id))
+				return
+			}
+
+			filter, ok := getRawFilter()
+			if !ok {
+				return
+			}
+			filter.Limit = rawLimit
+			raws, err := sqlite.GetRawResponses(filter)
+			if err != nil {
+				zap.L().Error("get_raw_responses",
+					zap.String("message", "failed to load raw responses"),
+					zap.Error(err),
+				)
+				fmt.Printf("Error loading raw responses: %v\n", err)
+				return
+			}
+			if len(raws) == 0 {
+				fmt.Println("[-] No raw responses stored")
+				return
+			}
+
+			var (
+				headers = []string{"ID", "Run", "Provider", "Operation", "Method", "Endpoint", "Status", "Size", "Stored"}
+				rows    [][]string
+			)
+			for _, r := range raws {
+				rows = append(rows, []string{
+					strconv.Itoa(int(r.ID)),
+					strconv.Itoa(int(r.RunID)),
+					r.Provider,
+					r.Operation,
+					r.Method,
+					r.Endpoint,
+					strconv.Itoa(r.StatusCode),
+					strconv.Itoa(r.ResponseSize),
+					r.CreatedAt.Format("2006-01-02 15:04:05"),
+				})
+			}
+			pretty.Table(headers, rows)
+		},
+	}
+
+	rawReparseCmd = &cobra.Command{
+		Use:   "reparse [id]",
+		Short: "Parse stored raw responses into the typed tables again",
+		Long: `Parse stored raw responses into the typed tables again, overwriting the stored records so fields
+added to the models are filled in. Reparses a single response by ID, or every response matching the filters.`,
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var (
+				raws []sqlite.RawResponse
+				err  error
+			)
+			if len(args) == 1 {
+				id, convErr := strconv.ParseUint(args[0], 10, 64)
+				if convErr != nil {
+					fmt.Println("[!] ID must be a positive number.")
+					return
+				}
+				var r sqlite.RawResponse
+				r, err = sqlite.GetRawResponse(uint(id))
+				raws = append(raws, r)
+			} else {
+				filter, ok := getRawFilter()
+				if !ok {
+					return
+				}
+				raws, err = sqlite.GetRawResponses(filter)
+			}
+			if err != nil {
+				zap.L().Error("get_raw_responses",
+					zap.String("message", "failed to load raw responses"),
+					zap.Error(err),
+				)
+				fmt.Printf("Error loading raw responses: %v\n", err)
+				return
+			}
+			if len(raws) == 0 {
+				fmt.Println("[-] No raw responses stored")
+				return
+			}
+
+			// The responses were stored by earlier runs, which the records stay linked to
+			sqlite.SetLinkRecords(false)
+
+			fmt.Printf("[*] Reparsing %d raw responses...\n", len(raws))
+			var (
+				records = make(map[string]int)
+				parsed  = make(map[string]int)
+				skipped int
+				failed  int
+			)
+			for _, r := range raws {
+				sqlite.AddRunProvider(r.Provider)
+				count, err := raw.Reparse(r)
+				if err != nil {
+					if errors.Is(err, raw.ErrNotReparseable) {
+						skipped++
+						continue
+					}
+					failed++
+					if debugGlobal {
+						debug.PrintInfo(fmt.Sprintf("failed to reparse raw response %d", r.ID))
+						debug.PrintError(err)
+					}
+					zap.L().Error("reparse_raw_response",
+						zap.String("message", "failed to reparse raw response"),
+						zap.Uint("id", r.ID),
+						zap.Error(err),
+					)
+					fmt.Printf("   [!] Response %d (%s): %v\n", r.ID, r.Operation, err)
+					continue
+				}
+				parsed[r.Operation]++
+				records[r.Operation] += count
+			}
+
+			var (
+				headers    = []string{"Operation", "Responses", "Records"}
+				rows       [][]string
+				operations []string
+			)
+			for op := range parsed {
+				operations = append(operations, op)
+			}
+			sort.Strings(operations)
+			for _, op := range operations {
+				rows = append(rows, []string{op, strconv.Itoa(parsed[op]), strconv.Itoa(records[op])})
+			}
+			if len(rows) > 0 {
+				pretty.Table(headers, rows)
+			}
+			if skipped > 0 {
+				fmt.Printf("[*] Skipped %d responses with no typed table\n", skipped)
+			}
+			if failed > 0 {
+				fmt.Printf("[!] %d responses failed to reparse\n", failed)
+			}
+		},
+	}
+)
+
+// getRawFilter builds the raw response filter from the command flags
+func getRawFilter() (sqlite.RawResponseFilter, bool) {
+	filter := sqlite.RawResponseFilter{RunID: rawRunID, Operation: rawOperation}
+	if rawProvider != "" {
+		provider, err := httpclient.GetProvider(rawProvider)
+		if err != nil {
+			fmt.Printf("[!] %v\n", err)
+			return filter, false
+		}
+		filter.Provider = string(provider)
+	}
+	return filter, true
+}
+
+// showRawResponse prints a stored raw response, indenting JSON bodies
+func showRawResponse(id uint) {
+	r, err := sqlite.GetRawResponse(id)
+	if err != nil {
+		zap.L().Error("get_raw_response",
+			zap.String("message", "failed to load raw response"),
+			zap.Uint("id", id),
+			zap.Error(err),
+		)
+		fmt.Printf("[!] Raw response %d not found: %v\n", id, err)
+		return
+	}
+
+	body, err := r.ResponseBody()
+	if rawShowRequest {
+		body, err = r.RequestBody()
+	}
+	if err != nil {
+		fmt.Printf("[!] Error decompressing raw response: %v\n", err)
+		return
+	}
+
+	fmt.Printf("[*] Response %d (Run %d)\n", r.ID, r.RunID)
+	fmt.Printf("   [*] %s %s %s\n", r.Provider, r.Method, r.Endpoint)
+	fmt.Printf("   [*] Operation: %s\n", r.Operation)
+	if r.CommandRunID > 0 {
+		fmt.Printf("   [*] Command Run: %d\n", r.CommandRunID)
+	}
+	fmt.Printf("   [*] Status: %d\n", r.StatusCode)
+	fmt.Printf("   [*] Stored: %s\n", r.CreatedAt.Format("2006-01-02 15:04:05"))
+
+	var indented bytes.Buffer
+	if json.Indent(&indented, body, "", "  ") == nil {
+		body = indented.Bytes()
+	}
+	fmt.Println(string(body))
+}
+
+// storeRawResponse is the recorder that keeps every API response in the raw_responses table
+func storeRawResponse(capture httpclient.Capture) {
+	err := sqlite.StoreRawResponse(sqlite.RawResponse{
+		RunID:      capture.RunID,
+		Provider:   string(capture.Provider),
+		Operation:  capture.Operation,
+		Method:     capture.Method,
+		Endpoint:   capture.Endpoint,
+		StatusCode: capture.StatusCode,
+	}, capture.Request, capture.Response)
+	if err != nil {
+		zap.L().Error("store_raw_response",
+			zap.String("message", "failed to store raw response"),
+			zap.String("provider", string(capture.Provider)),
+			zap.String("endpoint", capture.Endpoint),
+			zap.Error(err),
+		)
+	}
+}
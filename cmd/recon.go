@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"crowsnest/internal/export"
+	"crowsnest/internal/files"
+	"crowsnest/internal/httpclient"
+	hunter "crowsnest/internal/hunter.io"
+	"crowsnest/internal/pretty"
+	"crowsnest/internal/recon"
+	"crowsnest/internal/scope"
+	"crowsnest/internal/whois"
+	"fmt"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"slices"
+	"strings"
+)
+
+func init() {
+	// Add recon command to root command
+	rootCmd.AddCommand(reconCmd)
+
+	// Add flags specific to recon command
+	reconCmd.Flags().StringSliceVarP(&reconSteps, "steps", "s", nil, "Steps to run, comma separated (default all: "+strings.Join(recon.Steps, ",")+")")
+	reconCmd.Flags().StringSliceVarP(&reconSkip, "skip", "S", nil, "Steps to leave out, comma separated")
+	reconCmd.Flags().IntVarP(&reconMaxEmails, "max-emails", "e", 25, "Maximum emails searched in Dehashed and verified (0 for all)")
+	reconCmd.Flags().IntVarP(&reconMaxRecords, "max-records", "m", 10000, "Maximum Dehashed records per request")
+	reconCmd.Flags().IntVarP(&reconMaxRequests, "max-requests", "r", 1, "Maximum Dehashed requests per search (-1 for every page)")
+	reconCmd.Flags().StringVarP(&reconOutputFile, "output", "o", "", "File to write the summary to, without extension (default recon_<domain>)")
+	reconCmd.Flags().StringVarP(&reconOutputFormat, "format", "f", "json", "Output format (json, yaml, xml, txt)")
+}
+
+var (
+	// Recon command flags
+	reconSteps        []string
+	reconSkip         []string
+	reconMaxEmails    int
+	reconMaxRecords   int
+	reconMaxRequests  int
+	reconOutputFile   string
+	reconOutputFormat string
+
+	reconCmd = &cobra.Command{
+		Use:   "recon [domain]",
+		Short: "Run the WHOIS, Hunter.io and Dehashed lookups of a domain as one pipeline",
+		Long: `Run the lookups of an engagement as one pipeline, each step feeding the next:
+
+  whois            WHOIS lookup of the domain
+  subdomains       WHOIS subdomain scan
+  hunter-domain    Hunter.io domain search
+  company          Hunter.io company enrichment
+  dehashed-domain  Dehashed search of the domain
+  dehashed-emails  Dehashed search of every email found by the domain searches
+  verify           Hunter.io verification of every email found
+
+Everything is stored under one command run, and a summary of the findings is printed and written to a file.
+Running out of credits (--max-credits) stops the pipeline, the remaining steps are reported as skipped.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			domain := strings.ToLower(strings.TrimSpace(args[0]))
+
+			for _, step := range append(slices.Clone(reconSteps), reconSkip...) {
+				if !slices.Contains(recon.Steps, step) {
+					fmt.Printf("[!] Unknown step %q (%s)\n", step, strings.Join(recon.Steps, ", "))
+					return
+				}
+			}
+			steps := reconSteps
+			if len(steps) == 0 {
+				steps = slices.Clone(recon.Steps)
+			}
+			steps = slices.DeleteFunc(steps, func(step string) bool { return slices.Contains(reconSkip, step) })
+			if len(steps) == 0 {
+				fmt.Println("[!] No steps left to run")
+				return
+			}
+
+			if reconMaxRequests == 0 {
+				fmt.Println("[!] Max Requests cannot be zero")
+				return
+			}
+
+			fType := files.GetFileType(reconOutputFormat)
+			if fType == files.UNKNOWN {
+				fmt.Println("[!] Error: Invalid output format. Must be 'json', 'xml', 'yaml', or 'txt'.")
+				return
+			}
+			if reconOutputFile == "" {
+				reconOutputFile = "recon_" + domain
+			}
+
+			// Refuse lookups of targets outside the project scope
+			if !checkScope([]scope.Target{{Kind: scope.TargetDomain, Value: domain}}, false) {
+				return
+			}
+
+			pipeline := &recon.Pipeline{
+				Domain:      domain,
+				Steps:       steps,
+				MaxEmails:   reconMaxEmails,
+				MaxRecords:  reconMaxRecords,
+				MaxRequests: reconMaxRequests,
+				Workers:     workerCount,
+				InScope:     func(targets []scope.Target) bool { return checkScope(targets, false) },
+				Debug:       debugGlobal,
+			}
+
+			// Only the providers of the selected steps need a key
+			key := getDehashedApiKey()
+			if usesProvider(steps, recon.StepWhois, recon.StepSubdomains, recon.StepDehashedDomain, recon.StepDehashedEmails) {
+				if key == "" {
+					fmt.Println("Dehashed API key is required. Set the key with the \"set-dehashed\" command. [crowsnest set-dehashed <api_key>]")
+					return
+				}
+			}
+			if usesProvider(steps, recon.StepHunterDomain, recon.StepCompany, recon.StepVerify) && getHunterApiKey() == "" {
+				fmt.Println("Hunter.io API key is required. Set the key with the \"set-hunter\" command. [crowsnest set-hunter <api_key>]")
+				return
+			}
+			if usesProvider(steps, recon.StepWhois, recon.StepSubdomains) {
+				pipeline.Whois = whois.NewWhoIs(key, newHTTPClient(httpclient.Whois), debugGlobal)
+			}
+			if usesProvider(steps, recon.StepHunterDomain, recon.StepCompany, recon.StepVerify) {
+				pipeline.Hunter = hunter.NewHunterIO(getHunterApiKey(), newHTTPClient(httpclient.Hunter), debugGlobal)
+			}
+			if usesProvider(steps, recon.StepDehashedDomain, recon.StepDehashedEmails) {
+				pipeline.DehashedKey = key
+				pipeline.DehashedClient = newHTTPClient(httpclient.Dehashed)
+			}
+
+			fmt.Printf("[*] Running recon on %s: %s\n", domain, strings.Join(steps, " -> "))
+			summary := pipeline.Run(rootCmd.Context())
+			printReconSummary(summary)
+
+			fmt.Printf("[*] Writing recon summary to file: %s%s\n", export.Path(reconOutputFile), fType.Extension())
+			if err := export.WriteIStringToFile(summary, reconOutputFile, fType); err != nil {
+				zap.L().Error("write_recon_summary",
+					zap.String("message", "failed to write recon summary to file"),
+					zap.Error(err),
+				)
+				fmt.Printf("[!] Error writing recon summary: %v\n", err)
+				return
+			}
+			fmt.Println("   [*] Success")
+		},
+	}
+)
+
+// usesProvider reports whether any of the provider's steps is selected
+func usesProvider(steps []string, providerSteps ...string) bool {
+	for _, step := range providerSteps {
+		if slices.Contains(steps, step) {
+			return true
+		}
+	}
+	return false
+}
+
+// printReconSummary prints the outcome of every step and the findings
+func printReconSummary(summary *recon.Summary) {
+	fmt.Printf("\n[*] Recon summary for %s (command run %d)\n", summary.Domain, summary.CommandRun)
+
+	var rows [][]string
+	for _, step := range summary.Steps {
+		detail := step.Detail
+		if step.Error != "" {
+			detail = strings.TrimPrefix(detail+": "+step.Error, ": ")
+		}
+		rows = append(rows, []string{step.Step, step.Status, detail})
+	}
+	pretty.Table([]string{"Step", "Status", "Detail"}, rows)
+
+	rows = nil
+	for _, field := range [][]string{
+		{"Registrar", summary.Registrar},
+		{"Registrant", summary.Registrant},
+		{"Organization", summary.Organization},
+		{"Industry", summary.Industry},
+		{"Email Pattern", summary.EmailPattern},
+		{"Name Servers", fmt.Sprintf("%d", len(summary.NameServers))},
+		{"Subdomains", fmt.Sprintf("%d", len(summary.Subdomains))},
+		{"Emails", fmt.Sprintf("%d", len(summary.Emails))},
+		{"Deliverable Emails", fmt.Sprintf("%d", len(summary.Deliverable))},
+		{"Dehashed Records", fmt.Sprintf("%d", summary.DehashedRecords)},
+		{"Credentials", fmt.Sprintf("%d", summary.Credentials)},
+		{"Breaches", strings.Join(summary.Breaches, ", ")},
+	} {
+		if field[1] != "" {
+			rows = append(rows, field)
+		}
+	}
+	pretty.Table([]string{"Finding", "Value"}, rows)
+}
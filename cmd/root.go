@@ -1,18 +1,26 @@
 package cmd
 
 import (
+	"context"
 	"crowsnest/internal/badger"
+	"crowsnest/internal/export"
+	"crowsnest/internal/project"
+	"crowsnest/internal/sqlite"
+	"crowsnest/internal/workers"
 	"fmt"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 )
 
 var (
 	// Global Flags
 	debugGlobal bool
+	workerCount int
 
 	// rootCmd is the base command for the CLI.
 	rootCmd = &cobra.Command{
@@ -30,12 +38,44 @@ var (
 `,
 		),
 		Version: "v1.2.1",
+		// Record the command line on the run every stored record is linked to and load the project scope
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			sqlite.SetCommand(cmd.CommandPath(), strings.Join(append([]string{cmd.Root().Name()}, os.Args[1:]...), " "))
+			// Refuse to store records in the default database while an engagement is active but unusable
+			if activeProjectErr != nil && !isProjectCommand(cmd) {
+				fmt.Printf("[!] Error: %v\n", activeProjectErr)
+				fmt.Println("[*] Restore it with 'crowsnest project archive --restore', or switch with 'crowsnest project use'")
+				os.Exit(1)
+			}
+			if !validScopeMode() {
+				fmt.Printf("[!] Invalid --scope-mode %q. Must be 'enforce', 'warn' or 'off'.\n", scopeMode)
+				os.Exit(1)
+			}
+			loadScope()
+		},
 	}
 )
 
 // Execute adds all child commands to the root command and sets flags appropriately.
+// Ctrl-C cancels the command context so lookups in flight stop and what was retrieved is kept,
+// a second Ctrl-C exits immediately.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	// The active project is shown in the banner, recorded on every run and receives the output files
+	activeProject, hasActiveProject, activeProjectErr = project.Active()
+	if hasActiveProject {
+		rootCmd.Long += fmt.Sprintf("   Project: %s\n", activeProject.Name)
+		sqlite.SetProject(activeProject.Name)
+		export.SetOutputDir(activeProject.OutputDir)
+	}
+
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		zap.L().Fatal("execute_root_command",
 			zap.String("message", "failed to execute root command"),
 			zap.Error(err),
@@ -51,6 +91,7 @@ func init() {
 
 	// Add global flags
 	rootCmd.PersistentFlags().BoolVar(&debugGlobal, "debug", false, "Show debug information")
+	rootCmd.PersistentFlags().IntVar(&workerCount, "workers", workers.DefaultWorkers, "Number of lookups to run at once for batch input")
 
 	// Add subcommands
 	rootCmd.AddCommand(setDehashedKeyCmd)
@@ -94,7 +135,7 @@ var setHunterKeyCmd = &cobra.Command{
 
 var setLocalDb = &cobra.Command{
 	Use:   "local-db [true|false]",
-	Short: "Set crowsnest to use a local database path instead of the default path",
+	Short: "Set crowsnest to use a local database path instead of the default path (ignored while a project is active)",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		var useLocalDatabase bool
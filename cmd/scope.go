@@ -0,0 +1,261 @@
+package cmd
+
+import (
+	"crowsnest/internal/pretty"
+	"crowsnest/internal/scope"
+	"crowsnest/internal/sqlite"
+	"fmt"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"slices"
+	"strings"
+)
+
+func init() {
+	// Add global scope flag
+	rootCmd.PersistentFlags().StringVar(&scopeMode, "scope-mode", "enforce", "How lookups outside the project scope are handled (enforce, warn, off)")
+
+	// Add scope command to root command
+	rootCmd.AddCommand(scopeCmd)
+	scopeCmd.AddCommand(scopeAddCmd)
+	scopeCmd.AddCommand(scopeRemoveCmd)
+	scopeCmd.AddCommand(scopeListCmd)
+	scopeCmd.AddCommand(scopeClearCmd)
+	scopeCmd.AddCommand(scopeRecheckCmd)
+}
+
+var (
+	// Global scope flag
+	scopeMode string
+
+	// Scope of the project database, loaded before every command
+	projectScope = &scope.Scope{}
+
+	scopeCmd = &cobra.Command{
+		Use:   "scope",
+		Short: "Manage the engagement scope of the active project",
+		Long: `Manage the engagement scope of the active project: allowed domains, email domains, CIDRs and company names.
+When a scope is defined, dehashed, whois and hunter refuse lookups of targets outside it (--scope-mode warn runs them
+and records the targets on the run). Stored records outside the scope are flagged in the out_of_scope column.
+Subdomains of an allowed domain are in scope, and email domains also accept the allowed domains. A kind without
+entries is unrestricted, e.g. a scope of only company names allows every domain. Wildcard and regex patterns, emails
+without a domain and partial IPs cannot be checked against a restricted kind and are refused unless --scope-mode warn.`,
+	}
+
+	scopeAddCmd = &cobra.Command{
+		Use:   "add [domain|email-domain|cidr|company] [value...]",
+		Short: "Add targets to the scope",
+		Args:  cobra.MinimumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			kind := strings.ToLower(args[0])
+			var entries []sqlite.ScopeEntry
+			for _, value := range args[1:] {
+				normalized, err := scope.Normalize(kind, value)
+				if err != nil {
+					fmt.Printf("[!] %v\n", err)
+					return
+				}
+				entries = append(entries, sqlite.ScopeEntry{Kind: kind, Value: normalized})
+			}
+
+			if err := sqlite.AddScopeEntries(entries); err != nil {
+				zap.L().Error("add_scope",
+					zap.String("message", "failed to store scope entries"),
+					zap.Error(err),
+				)
+				fmt.Printf("[!] Error storing scope: %v\n", err)
+				return
+			}
+			for _, e := range entries {
+				fmt.Printf("[+] Added %s %s\n", e.Kind, e.Value)
+			}
+			fmt.Println("[*] Run 'crowsnest scope recheck' to flag records stored before the change")
+		},
+	}
+
+	scopeRemoveCmd = &cobra.Command{
+		Use:   "remove [domain|email-domain|cidr|company] [value]",
+		Short: "Remove a target from the scope",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			kind := strings.ToLower(args[0])
+			value, err := scope.Normalize(kind, args[1])
+			if err != nil {
+				fmt.Printf("[!] %v\n", err)
+				return
+			}
+
+			removed, err := sqlite.RemoveScopeEntry(kind, value)
+			if err != nil {
+				zap.L().Error("remove_scope",
+					zap.String("message", "failed to remove scope entry"),
+					zap.Error(err),
+				)
+				fmt.Printf("[!] Error removing scope entry: %v\n", err)
+				return
+			}
+			if !removed {
+				fmt.Printf("[-] %s %s is not in scope\n", kind, value)
+				return
+			}
+			fmt.Printf("[+] Removed %s %s\n", kind, value)
+		},
+	}
+
+	scopeListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the scope",
+		Run: func(cmd *cobra.Command, args []string) {
+			entries, err := sqlite.GetScopeEntries()
+			if err != nil {
+				zap.L().Error("get_scope",
+					zap.String("message", "failed to load scope"),
+					zap.Error(err),
+				)
+				fmt.Printf("[!] Error loading scope: %v\n", err)
+				return
+			}
+			if len(entries) == 0 {
+				fmt.Println("[-] No scope defined, every target is allowed")
+				return
+			}
+
+			slices.SortStableFunc(entries, func(a, b sqlite.ScopeEntry) int {
+				return slices.Index(scope.Kinds, a.Kind) - slices.Index(scope.Kinds, b.Kind)
+			})
+			var (
+				headers = []string{"Kind", "Value", "Added"}
+				rows    [][]string
+			)
+			for _, e := range entries {
+				rows = append(rows, []string{e.Kind, e.Value, e.CreatedAt.Format("2006-01-02 15:04:05")})
+			}
+			pretty.Table(headers, rows)
+		},
+	}
+
+	scopeClearCmd = &cobra.Command{
+		Use:   "clear",
+		Short: "Remove every target from the scope",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := sqlite.ClearScope(); err != nil {
+				zap.L().Error("clear_scope",
+					zap.String("message", "failed to clear scope"),
+					zap.Error(err),
+				)
+				fmt.Printf("[!] Error clearing scope: %v\n", err)
+				return
+			}
+			fmt.Println("[+] Scope cleared, every target is allowed")
+		},
+	}
+
+	scopeRecheckCmd = &cobra.Command{
+		Use:   "recheck",
+		Short: "Flag every stored record against the current scope",
+		Run: func(cmd *cobra.Command, args []string) {
+			count, err := sqlite.RecheckScope()
+			if err != nil {
+				zap.L().Error("recheck_scope",
+					zap.String("message", "failed to recheck scope"),
+					zap.Error(err),
+				)
+				fmt.Printf("[!] Error checking records: %v\n", err)
+				return
+			}
+			fmt.Printf("[+] %d stored records are out of scope\n", count)
+		},
+	}
+)
+
+// loadScope loads the project scope and flags stored records against it
+func loadScope() {
+	s, err := scope.Load()
+	if err != nil {
+		zap.L().Error("load_scope",
+			zap.String("message", "failed to load scope"),
+			zap.Error(err),
+		)
+		fmt.Printf("[!] Error loading scope: %v\n", err)
+		return
+	}
+	projectScope = s
+	if s.Empty() {
+		sqlite.SetScopeChecker(nil)
+		return
+	}
+	sqlite.SetScopeChecker(s)
+}
+
+// checkScope reports whether a lookup of the targets may run.
+// Out of scope targets are refused, or with --scope-mode warn recorded on the run and allowed.
+func checkScope(targets []scope.Target, dryRun bool) bool {
+	if scopeMode == "off" {
+		return true
+	}
+	if !checkUnchecked(projectScope.Unchecked(targets)) {
+		return false
+	}
+	out := projectScope.Check(targets)
+	if len(out) == 0 {
+		return true
+	}
+
+	var values []string
+	for _, t := range out {
+		values = append(values, t.String())
+	}
+	fmt.Printf("[!] Out of scope: %s\n", strings.Join(values, ", "))
+	zap.L().Warn("out_of_scope",
+		zap.String("message", "lookup target outside the project scope"),
+		zap.Strings("targets", values),
+		zap.String("mode", scopeMode),
+	)
+
+	if scopeMode != "warn" {
+		fmt.Println("   [*] Refusing lookup. Add the target with 'crowsnest scope add' or pass --scope-mode warn")
+		return false
+	}
+
+	fmt.Println("   [*] Continuing, the targets are recorded on the run (--scope-mode warn)")
+	if !dryRun {
+		if err := sqlite.MarkOutOfScope(values); err != nil {
+			zap.L().Error("mark_out_of_scope",
+				zap.String("message", "failed to record out of scope targets"),
+				zap.Error(err),
+			)
+		}
+	}
+	return true
+}
+
+// checkUnchecked reports whether a lookup of targets that cannot be matched against the scope, such as wildcard
+// patterns or partial emails, may run. They are refused unless --scope-mode warn is passed.
+func checkUnchecked(targets []scope.Target) bool {
+	if len(targets) == 0 {
+		return true
+	}
+
+	var values []string
+	for _, t := range targets {
+		values = append(values, t.String())
+	}
+	fmt.Printf("[!] Cannot check against the scope: %s\n", strings.Join(values, ", "))
+	zap.L().Warn("unchecked_scope",
+		zap.String("message", "lookup target cannot be checked against the project scope"),
+		zap.Strings("targets", values),
+		zap.String("mode", scopeMode),
+	)
+
+	if scopeMode != "warn" {
+		fmt.Println("   [*] Refusing lookup. Query exact values or pass --scope-mode warn")
+		return false
+	}
+	fmt.Println("   [*] Continuing, the targets are not checked (--scope-mode warn)")
+	return true
+}
+
+// validScopeMode reports whether the --scope-mode value is known
+func validScopeMode() bool {
+	return slices.Contains([]string{"enforce", "warn", "off"}, scopeMode)
+}
@@ -8,8 +8,10 @@ import (
 
 // Map of available tables and their columns
 var availableTables = map[string][]string{
-	"users": {
-		"id", "created_at", "updated_at", "deleted_at", "email", "username", "password",
+	"creds": {
+		"id", "created_at", "updated_at", "deleted_at", "company", "position", "department", "phone_number",
+		"full_name", "phone", "linkedin", "twitter", "facebook", "instagram", "youtube", "gravatar", "email",
+		"username", "password", "hashed_password", "hash_type", "database_name", "dehashed_id", "cracked", "crack_source", "out_of_scope",
 	},
 	//"history": {
 	//	"id", "created_at", "updated_at", "deleted_at", "domain_name", "domain_type",
@@ -17,37 +19,69 @@ var availableTables = map[string][]string{
 	//},
 	"lookup": {
 		"id", "created_at", "updated_at", "deleted_at", "search_term", "type", "first_seen", "last_visit",
-		"name",
+		"name", "out_of_scope",
 	},
 	// Query Options
 	"runs": {
 		"id", "created_at", "updated_at", "deleted_at", "max_records", "max_requests", "starting_page",
 		"output_format", "output_file", "regex_match", "wildcard_match", "username_query", "email_query",
 		"ip_query", "pass_query", "hash_query", "name_query", "domain_query", "vin_query", "license_plate_query",
-		"address_query", "phone_query", "social_query", "crypto_address_query", "print_balance", "creds_only",
+		"address_query", "phone_query", "social_query", "crypto_address_query", "expression", "print_balance", "creds_only",
+		"fetch_all", "last_page", "total_results", "completed", "project",
 	},
-	"dehashed": {
+	"results": {
 		"id", "created_at", "updated_at", "deleted_at", "dehashed_id", "email", "ip_address", "username",
 		"password", "hashed_password", "hash_type", "name", "vin", "license_plate", "url", "social",
-		"cryptocurrency_address", "address", "phone", "company", "database_name",
+		"cryptocurrency_address", "address", "phone", "company", "database_name", "out_of_scope",
 	},
 	"subdomains": {
-		"id", "created_at", "updated_at", "deleted_at", "domain", "subdomain",
+		"id", "created_at", "updated_at", "deleted_at", "domain", "subdomain", "out_of_scope",
 	},
 	"whois": {
 		"id", "created_at", "updated_at", "deleted_at", "audit", "contact_email", "created_date", "created_date_normalized",
 		"domain_name", "domain_name_ext", "estimated_domain_age", "expires_date", "expires_date_normalized", "footer", "header",
 		"name_servers", "parse_code", "raw_text", "registrant", "registrar_iana_id", "registrar_name", "registry_data",
-		"status", "stripped_text", "updated_date", "updated_date_normalized",
+		"status", "stripped_text", "updated_date", "updated_date_normalized", "out_of_scope",
 	},
 	"hunter_domain": {
 		"id", "created_at", "updated_at", "deleted_at", "domain", "disposable", "webmail", "accept_all", "pattern",
 		"organization", "description", "industry", "twitter", "facebook", "linkedin", "instagram", "youtube",
-		"technologies", "country", "state", "city", "postal_code", "street", "headcount", "company_type", "emails", "linked_domains",
+		"technologies", "country", "state", "city", "postal_code", "street", "headcount", "company_type", "emails", "linked_domains", "out_of_scope",
+	},
+	"budgets": {
+		"id", "created_at", "updated_at", "deleted_at", "provider", "credit_limit", "credits_spent",
+	},
+	"raw_responses": {
+		"id", "created_at", "updated_at", "deleted_at", "run_id", "command_run_id", "provider", "operation", "method",
+		"endpoint", "status_code", "response_size",
+	},
+	"command_runs": {
+		"id", "created_at", "updated_at", "deleted_at", "command", "command_line", "provider", "project",
+		"out_of_scope",
+	},
+	"scope": {
+		"id", "created_at", "updated_at", "deleted_at", "kind", "value",
+	},
+	"record_sources": {
+		"id", "created_at", "command_run_id", "record_table", "record_id",
 	},
 	"hunter_email": {
 		"id", "created_at", "updated_at", "deleted_at", "value", "type", "confidence", "sources", "first_name", "last_name",
-		"position", "position_raw", "seniority", "department", "linkedin", "twitter", "phone_number", "verification_date", "verification_status",
+		"position", "position_raw", "seniority", "department", "linkedin", "twitter", "phone_number", "verification_date", "verification_status", "out_of_scope",
+	},
+	"identities": {
+		"id", "created_at", "updated_at", "deleted_at", "name", "primary_email", "emails", "usernames", "phones", "names",
+		"socials", "records", "confidence",
+	},
+	"identity_records": {
+		"id", "identity_id", "record_table", "record_key", "matched_on",
+	},
+	"watches": {
+		"id", "created_at", "updated_at", "deleted_at", "name", "kind", "field", "query", "interval", "max_records",
+		"max_requests", "last_run", "last_run_id", "last_new", "runs",
+	},
+	"cracks": {
+		"id", "created_at", "updated_at", "deleted_at", "hash", "password", "source",
 	},
 }
 
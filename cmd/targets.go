@@ -1,12 +1,22 @@
 package cmd
 
 import (
+	"cmp"
+	"crowsnest/internal/analyze"
+	"crowsnest/internal/export"
+	"crowsnest/internal/hashes"
+	"crowsnest/internal/pretty"
+	"crowsnest/internal/spray"
 	"crowsnest/internal/sqlite"
 	"fmt"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 	"os"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 func init() {
@@ -19,10 +29,17 @@ func init() {
 	targetsCmd.Flags().BoolVarP(&targetsInternal, "internal", "i", false, "Output internal format (username:password)")
 	targetsCmd.Flags().BoolVarP(&targetsSubdomains, "subdomains", "s", false, "Output subdomains")
 	targetsCmd.Flags().BoolVarP(&targetsEmails, "emails", "E", false, "Output emails only (no passwords)")
-	targetsCmd.Flags().StringVarP(&targetsDomain, "domain", "d", "", "Filter by domain (for emails and subdomains)")
+	targetsCmd.Flags().BoolVarP(&targetsHashes, "hashes", "H", false, "Output hashed passwords grouped by hash type (user:hash)")
+	targetsCmd.Flags().StringVarP(&targetsMode, "mode", "m", "hashcat", "Cracking tool the hash files are named for (hashcat, john)")
+	targetsCmd.Flags().BoolVarP(&targetsSpray, "spray", "S", false, "Generate user and password spray lists for --domain")
+	targetsCmd.Flags().StringVarP(&targetsPattern, "pattern", "p", "", "Email pattern for spray users, e.g. {first}.{last} (default Hunter.io pattern)")
+	targetsCmd.Flags().StringVarP(&targetsCompany, "company", "c", "", "Company name for spray passwords (default Hunter.io organization)")
+	targetsCmd.Flags().IntVarP(&targetsYears, "years", "y", 2, "Number of years, counting back from this one, used in spray passwords")
+	targetsCmd.Flags().IntVarP(&targetsMinLength, "min-length", "l", 8, "Minimum length of spray passwords")
+	targetsCmd.Flags().StringVarP(&targetsDomain, "domain", "d", "", "Filter by domain (for emails, hashes and subdomains)")
 
 	// Mark output flag as required
-	targetsCmd.MarkFlagsMutuallyExclusive("external", "internal", "subdomains", "emails")
+	targetsCmd.MarkFlagsMutuallyExclusive("external", "internal", "subdomains", "emails", "hashes", "spray")
 }
 
 var (
@@ -32,6 +49,13 @@ var (
 	targetsInternal   bool
 	targetsSubdomains bool
 	targetsEmails     bool
+	targetsHashes     bool
+	targetsMode       string
+	targetsSpray      bool
+	targetsPattern    string
+	targetsCompany    string
+	targetsYears      int
+	targetsMinLength  int
 	targetsDomain     string
 
 	// Targets command
@@ -45,10 +69,18 @@ Formats:
   --internal (-i): Output in username:password format
   --emails (-E): Output emails only (no passwords)
   --subdomains (-s): Output subdomains only
+  --hashes (-H): Output uncracked hashes in user:hash format, one file per hash type
+  --spray (-S): Generate spray lists for --domain: output_users.txt, output_passwords.txt and output_combos.txt
 
 Options:
-  --domain (-d): Filter results by domain (applies to emails and subdomains)
+  --domain (-d): Filter results by domain (applies to emails, hashes and subdomains)
   --output (-o): Specify output file name (required)
+  --mode (-m): Name hash files for hashcat (output_<mode>_<type>.txt) or john (output_<format>.txt)
+  --pattern (-p), --company (-c), --years (-y), --min-length (-l): Tune the spray lists
+
+Spray users are the stored emails of the domain plus addresses built from the email pattern and the names in
+hunter_email, person and dehashed. Passwords combine seasons and years, the company name with digits and the base
+words and suffixes observed in breached passwords, and the combos file pairs each user with their first name and a year.
 
 Examples:
   # Export all external credentials (email:password)
@@ -67,15 +99,26 @@ Examples:
   crowsnest targets -s -d example.com -o subdomains
 
   # Export all subdomains
-  crowsnest targets -s -o all_subdomains`,
+  crowsnest targets -s -o all_subdomains
+
+  # Export hashes for a domain, one file per hashcat mode
+  crowsnest targets -H -d example.com -o hashes
+
+  # Export hashes named for john formats
+  crowsnest targets -H -m john -o hashes
+
+  # Generate spray lists for example.com
+  crowsnest targets -S -d example.com -o spray`,
 		Run: func(cmd *cobra.Command, args []string) {
 			// Validate that at least one format is specified
-			if !targetsExternal && !targetsInternal && !targetsSubdomains && !targetsEmails {
+			if !targetsExternal && !targetsInternal && !targetsSubdomains && !targetsEmails && !targetsHashes && !targetsSpray {
 				fmt.Println("[!] Error: You must specify at least one output format:")
 				fmt.Println("    --external (-e) for email:password format")
 				fmt.Println("    --internal (-i) for username:password format")
 				fmt.Println("    --emails (-E) for emails only")
 				fmt.Println("    --subdomains (-s) for subdomains")
+				fmt.Println("    --hashes (-H) for user:hash files per hash type")
+				fmt.Println("    --spray (-S) for user and password spray lists")
 				return
 			}
 
@@ -86,11 +129,35 @@ Examples:
 					zap.Bool("internal", targetsInternal),
 					zap.Bool("subdomains", targetsSubdomains),
 					zap.Bool("emails", targetsEmails),
+					zap.Bool("hashes", targetsHashes),
+					zap.String("mode", targetsMode),
 					zap.String("domain", targetsDomain),
 					zap.String("output_file", targetsOutputFile),
 				)
 			}
 
+			if targetsSpray {
+				if targetsDomain == "" {
+					fmt.Println("[!] Error: --spray requires --domain")
+					return
+				}
+				if err := executeSprayExport(); err != nil {
+					fmt.Printf("[!] Error: %v\n", err)
+				}
+				return
+			}
+
+			if targetsHashes {
+				if targetsMode != "hashcat" && targetsMode != "john" {
+					fmt.Printf("[!] Error: unknown mode %q (hashcat, john)\n", targetsMode)
+					return
+				}
+				if err := executeHashesExport(); err != nil {
+					fmt.Printf("[!] Error: %v\n", err)
+				}
+				return
+			}
+
 			// Execute the targets export
 			err := executeTargetsExport()
 			if err != nil {
@@ -98,7 +165,7 @@ Examples:
 				return
 			}
 
-			fmt.Printf("[+] Successfully exported targets to: %s\n", targetsOutputFile)
+			fmt.Printf("[+] Successfully exported targets to: %s\n", export.Path(targetsOutputFile))
 		},
 	}
 )
@@ -203,18 +270,241 @@ func executeTargetsExport() error {
 	// Join all lines with newlines and add a single newline at the end
 	content := strings.Join(outputLines, "\n") + "\n"
 
-	err := os.WriteFile(targetsOutputFile, []byte(content), 0644)
+	err := os.WriteFile(export.Path(targetsOutputFile), []byte(content), 0644)
 	if err != nil {
 		return fmt.Errorf("failed to write to file: %v", err)
 	}
 
 	if debugGlobal {
-		fmt.Printf("[*] Wrote %d lines to %s\n", len(outputLines), targetsOutputFile)
+		fmt.Printf("[*] Wrote %d lines to %s\n", len(outputLines), export.Path(targetsOutputFile))
+	}
+
+	return nil
+}
+
+// executeHashesExport writes the uncracked hashes to one user:hash file per hash type
+func executeHashesExport() error {
+	creds, err := getHashedCredentials()
+	if err != nil {
+		return fmt.Errorf("failed to get hashed credentials: %v", err)
+	}
+
+	groups := make(map[string][]string)
+	types := make(map[string]hashes.Type)
+	seen := make(map[string]bool)
+	for _, cred := range creds {
+		user := cred.Email
+		if user == "" {
+			user = cred.Username
+		}
+		line := fmt.Sprintf("%s:%s", user, cred.HashedPassword)
+		if seen[line] {
+			continue
+		}
+		seen[line] = true
+
+		t := hashes.Classify(cred.HashedPassword, cred.HashType)
+		types[t.Name] = t
+		groups[t.Name] = append(groups[t.Name], line)
+	}
+
+	if len(groups) == 0 {
+		return fmt.Errorf("no data found to export")
+	}
+
+	var (
+		headers = []string{"Type", "Hashes", "File", "Command"}
+		rows    [][]string
+		names   = make([]string, 0, len(groups))
+	)
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		t := types[name]
+		file, command := hashFile(t)
+		content := strings.Join(groups[name], "\n") + "\n"
+		if err = os.WriteFile(export.Path(file), []byte(content), 0644); err != nil {
+			return fmt.Errorf("failed to write to file: %v", err)
+		}
+		rows = append(rows, []string{name, strconv.Itoa(len(groups[name])), export.Path(file), command})
+	}
+
+	fmt.Printf("[+] Exported %d hashes to %d files\n", len(seen), len(names))
+	pretty.Table(headers, rows)
+	if _, ok := groups[hashes.Unknown.Name]; ok {
+		fmt.Println("   [-] Hashes of unknown type need to be identified before cracking")
+	}
+	return nil
+}
+
+// hashFile returns the file name hashes of the type are written to and the command that cracks them
+func hashFile(t hashes.Type) (string, string) {
+	if t.Name == hashes.Unknown.Name {
+		return fmt.Sprintf("%s_unknown.txt", targetsOutputFile), ""
+	}
+	if targetsMode == "john" {
+		file := fmt.Sprintf("%s_%s.txt", targetsOutputFile, t.John)
+		return file, fmt.Sprintf("john --format=%s %s", t.John, file)
+	}
+	file := fmt.Sprintf("%s_%d_%s.txt", targetsOutputFile, t.Hashcat, t.Name)
+	return file, fmt.Sprintf("hashcat -m %d --username %s <wordlist>", t.Hashcat, file)
+}
+
+// executeSprayExport writes the user, password and user:password spray lists for the domain
+func executeSprayExport() error {
+	domain := strings.ToLower(strings.TrimPrefix(targetsDomain, "@"))
+
+	var data sqlite.HunterDomainData
+	err := sqlite.GetDB().Where("domain = ?", domain).Limit(1).Find(&data).Error
+	if err != nil {
+		return fmt.Errorf("failed to get hunter domain data: %v", err)
+	}
+	pattern := targetsPattern
+	if pattern == "" {
+		pattern = data.Pattern
+	}
+	company := targetsCompany
+	if company == "" {
+		company = data.Organization
+	}
+	if company == "" {
+		company = strings.Split(domain, ".")[0]
+	}
+
+	people, err := spray.LoadPeople(domain)
+	if err != nil {
+		return fmt.Errorf("failed to get names: %v", err)
+	}
+	users, err := spray.LoadEmails(domain)
+	if err != nil {
+		return fmt.Errorf("failed to get emails: %v", err)
+	}
+	if pattern == "" && len(people) > 0 {
+		// Without a Hunter.io pattern, fall back to the format the stored emails follow
+		named, err := spray.LoadNamedEmails(domain)
+		if err != nil {
+			return fmt.Errorf("failed to get names: %v", err)
+		}
+		if inferred := analyze.InferEmailFormat(domain, users, named); inferred.Pattern != "" {
+			pattern = inferred.Pattern
+			fmt.Printf("[*] No Hunter.io pattern for %s, using the inferred format %s (%.1f%% confidence)\n", domain, pattern, inferred.Confidence)
+		} else {
+			fmt.Printf("[-] No email pattern known for %s, pass --pattern to build addresses for %d names\n", domain, len(people))
+		}
+	}
+
+	// Addresses built from names are paired with the person for the per-user passwords
+	owners := make(map[string]spray.Person)
+	for _, p := range people {
+		local, ok := spray.ApplyPattern(pattern, p)
+		if !ok {
+			continue
+		}
+		email := local + "@" + domain
+		users = append(users, email)
+		owners[email] = p
+	}
+	sort.Strings(users)
+	users = slices.Compact(users)
+
+	opts := spray.Options{Company: company, MinLength: targetsMinLength}
+	for year, i := time.Now().Year(), 0; i < targetsYears; i++ {
+		opts.Years = append(opts.Years, year-i)
+	}
+	creds, err := analyze.LoadCredentials(domain)
+	if err != nil {
+		return fmt.Errorf("failed to get credentials: %v", err)
+	}
+	if len(creds) > 0 {
+		report := analyze.Passwords(creds, company, 5)
+		for _, shared := range report.SharedPasswords {
+			opts.Observed = append(opts.Observed, shared.Password)
+		}
+		for _, base := range report.BaseWords {
+			opts.BaseWords = append(opts.BaseWords, base.Value)
+		}
+		for _, suffix := range report.Suffixes {
+			opts.Suffixes = append(opts.Suffixes, suffix.Value)
+		}
 	}
+	passwords := spray.Passwords(opts)
 
+	var combos []string
+	for _, user := range users {
+		if p, ok := owners[user]; ok {
+			for _, password := range spray.UserPasswords(p, opts) {
+				combos = append(combos, fmt.Sprintf("%s:%s", user, password))
+			}
+		}
+	}
+
+	if len(users) == 0 && len(passwords) == 0 {
+		return fmt.Errorf("no data found to export")
+	}
+
+	var rows [][]string
+	for _, list := range []struct {
+		name  string
+		lines []string
+	}{{"users", users}, {"passwords", passwords}, {"combos", combos}} {
+		if len(list.lines) == 0 {
+			continue
+		}
+		file := export.Path(fmt.Sprintf("%s_%s.txt", targetsOutputFile, list.name))
+		if err = os.WriteFile(file, []byte(strings.Join(list.lines, "\n")+"\n"), 0644); err != nil {
+			return fmt.Errorf("failed to write to file: %v", err)
+		}
+		rows = append(rows, []string{list.name, strconv.Itoa(len(list.lines)), file})
+	}
+
+	fmt.Printf("[+] Generated spray lists for %s (pattern: %s, company: %s)\n", domain, cmp.Or(pattern, "none"), company)
+	pretty.Table([]string{"List", "Entries", "File"}, rows)
 	return nil
 }
 
+// getHashedCredentials retrieves credentials that have a hash but no plaintext password, skipping hashes
+// already cracked by an imported potfile
+func getHashedCredentials() ([]sqlite.User, error) {
+	db := sqlite.GetDB()
+	var users []sqlite.User
+
+	query := db.Where("hashed_password IS NOT NULL AND hashed_password != '' AND (password IS NULL OR password = '')")
+
+	// Apply domain filter if specified
+	if targetsDomain != "" {
+		query = query.Where("email LIKE ?", "%@"+targetsDomain)
+	}
+
+	err := query.Find(&users).Error
+	if err != nil {
+		zap.L().Error("get_hashed_credentials",
+			zap.String("message", "failed to query hashed credentials"),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+
+	cracks, err := sqlite.GetCracks()
+	if err != nil {
+		zap.L().Error("get_cracks",
+			zap.String("message", "failed to query cracked hashes"),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+	uncracked := users[:0]
+	for _, u := range users {
+		if _, ok := cracks[hashes.Key(u.HashedPassword)]; !ok {
+			uncracked = append(uncracked, u)
+		}
+	}
+
+	return uncracked, nil
+}
+
 // getExternalCredentials retrieves credentials for external format (email:password)
 func getExternalCredentials() ([]sqlite.User, error) {
 	db := sqlite.GetDB()
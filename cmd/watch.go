@@ -0,0 +1,386 @@
+package cmd
+
+import (
+	"crowsnest/internal/debug"
+	"crowsnest/internal/export"
+	"crowsnest/internal/files"
+	"crowsnest/internal/httpclient"
+	"crowsnest/internal/pretty"
+	"crowsnest/internal/scope"
+	"crowsnest/internal/sqlite"
+	"crowsnest/internal/watch"
+	"crowsnest/internal/whois"
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	// Add watch command to root command
+	rootCmd.AddCommand(watchCmd)
+	watchCmd.AddCommand(watchAddCmd)
+	watchCmd.AddCommand(watchListCmd)
+	watchCmd.AddCommand(watchRemoveCmd)
+	watchCmd.AddCommand(watchRunCmd)
+
+	// Add flags specific to watch add command
+	watchAddCmd.Flags().StringVarP(&watchKind, "type", "t", sqlite.WatchDehashed, "What to watch (dehashed, subdomains, whois)")
+	watchAddCmd.Flags().StringVarP(&watchField, "field", "F", "domain", "Dehashed field to search ("+strings.Join(sqlite.QueryFields, ", ")+")")
+	watchAddCmd.Flags().StringVarP(&watchInterval, "interval", "i", "30d", "How often the watch is due, e.g. 12h, 30d or 2w (0 for on demand only)")
+	watchAddCmd.Flags().IntVarP(&watchMaxRecords, "max-records", "m", 10000, "Maximum Dehashed records per request")
+	watchAddCmd.Flags().IntVarP(&watchMaxRequests, "max-requests", "r", -1, "Maximum Dehashed requests per run (-1 for every page)")
+
+	// Add flags specific to watch run command
+	watchRunCmd.Flags().BoolVarP(&watchRunAll, "all", "a", false, "Run every watch, due or not")
+	watchRunCmd.Flags().BoolVarP(&watchLoop, "loop", "l", false, "Keep running the due watches, checking every minute until interrupted")
+	watchRunCmd.Flags().StringVarP(&watchOutputFile, "output", "o", "watch_report", "File to write the new records to, without extension. The run time is appended")
+	watchRunCmd.Flags().StringVarP(&watchOutputFormat, "format", "f", "json", "Output format (json, yaml, xml, txt)")
+}
+
+var (
+	// Watch command flags
+	watchKind         string
+	watchField        string
+	watchInterval     string
+	watchMaxRecords   int
+	watchMaxRequests  int
+	watchRunAll       bool
+	watchLoop         bool
+	watchOutputFile   string
+	watchOutputFormat string
+
+	watchCmd = &cobra.Command{
+		Use:   "watch",
+		Short: "Re-run Dehashed searches, subdomain scans and WHOIS lookups, reporting only what is new",
+		Long: `Register recurring Dehashed searches, WHOIS subdomain scans and WHOIS lookups of client domains, and re-run them
+on demand or when their interval has passed. Only records that no earlier run stored are reported, going by the runs
+linked to each record in record_sources. WHOIS watches report the registration fields that changed since the
+stored record, such as the registrar, registrant or name servers.`,
+	}
+
+	watchAddCmd = &cobra.Command{
+		Use:   "add [name] [query]",
+		Short: "Register a watch",
+		Example: `  crowsnest watch add acme-breaches acme.com
+  crowsnest watch add acme-ceo ceo@acme.com -F email -i 7d
+  crowsnest watch add acme-subdomains acme.com -t subdomains -i 2w
+  crowsnest watch add acme-whois acme.com -t whois -i 7d`,
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			name, query := args[0], strings.TrimSpace(args[1])
+
+			interval, err := watch.ParseInterval(watchInterval)
+			if err != nil {
+				fmt.Printf("[!] %v\n", err)
+				return
+			}
+			if watchMaxRequests == 0 {
+				fmt.Println("[!] Max Requests cannot be zero")
+				return
+			}
+
+			w := &sqlite.Watch{
+				Name:        name,
+				Kind:        watchKind,
+				Query:       query,
+				Interval:    interval,
+				MaxRecords:  watchMaxRecords,
+				MaxRequests: watchMaxRequests,
+			}
+			switch watchKind {
+			case sqlite.WatchDehashed:
+				if !slices.Contains(sqlite.QueryFields, watchField) {
+					fmt.Printf("[!] Unknown field %q (%s)\n", watchField, strings.Join(sqlite.QueryFields, ", "))
+					return
+				}
+				w.Field = watchField
+			case sqlite.WatchSubdomains, sqlite.WatchWhois:
+			default:
+				fmt.Printf("[!] Unknown watch type %q (dehashed, subdomains, whois)\n", watchKind)
+				return
+			}
+
+			// Refuse watches of targets outside the project scope
+			if !checkScope(watchTargets(*w), false) {
+				return
+			}
+
+			if err = sqlite.AddWatch(w); err != nil {
+				zap.L().Error("add_watch",
+					zap.String("message", "failed to add watch"),
+					zap.String("name", name),
+					zap.Error(err),
+				)
+				fmt.Printf("[!] Error adding watch %s: %v\n", name, err)
+				return
+			}
+			due := "run on demand"
+			if interval > 0 {
+				due = "due every " + watch.FormatInterval(interval)
+			}
+			fmt.Printf("[+] Watching %s (%s), %s\n", name, watchDescription(*w), due)
+		},
+	}
+
+	watchListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the watches and when they are due",
+		Run: func(cmd *cobra.Command, args []string) {
+			watches, err := sqlite.GetWatches()
+			if err != nil {
+				zap.L().Error("get_watches",
+					zap.String("message", "failed to get watches"),
+					zap.Error(err),
+				)
+				fmt.Printf("[!] Error listing watches: %v\n", err)
+				return
+			}
+			if len(watches) == 0 {
+				fmt.Println("[-] No watches. Add one with 'crowsnest watch add'")
+				return
+			}
+
+			now := time.Now()
+			var rows [][]string
+			for _, w := range watches {
+				lastRun, lastNew, next := "never", "-", "now"
+				if !w.LastRun.IsZero() {
+					lastRun = w.LastRun.Format("2006-01-02 15:04")
+					lastNew = strconv.Itoa(w.LastNew)
+					next = w.LastRun.Add(w.Interval).Format("2006-01-02 15:04")
+				}
+				switch {
+				case w.Interval == 0:
+					next = "on demand"
+				case w.Due(now):
+					next = "now"
+				}
+				rows = append(rows, []string{w.Name, watchDescription(w), watch.FormatInterval(w.Interval), lastRun, lastNew, next, strconv.Itoa(w.Runs)})
+			}
+			pretty.Table([]string{"Name", "Query", "Interval", "Last Run", "New", "Next Due", "Runs"}, rows)
+		},
+	}
+
+	watchRemoveCmd = &cobra.Command{
+		Use:   "remove [name]",
+		Short: "Remove a watch, keeping the records it found",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			removed, err := sqlite.RemoveWatch(args[0])
+			if err != nil {
+				zap.L().Error("remove_watch",
+					zap.String("message", "failed to remove watch"),
+					zap.String("name", args[0]),
+					zap.Error(err),
+				)
+				fmt.Printf("[!] Error removing watch %s: %v\n", args[0], err)
+				return
+			}
+			if !removed {
+				fmt.Printf("[-] No watch named %s\n", args[0])
+				return
+			}
+			fmt.Printf("[+] Removed watch %s\n", args[0])
+		},
+	}
+
+	watchRunCmd = &cobra.Command{
+		Use:   "run [name...]",
+		Short: "Run the named watches, or every watch that is due",
+		Long: `Run the named watches, or every watch that is due, and report the records, credentials, subdomains and
+WHOIS changes that are new. Run it from cron, or keep it running with --loop, which runs each watch as it falls due.
+Every run writes its own report, named with the time it ran.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if watchLoop && (len(args) > 0 || watchRunAll) {
+				fmt.Println("[!] --loop runs the watches as they fall due and cannot be combined with watch names or --all")
+				return
+			}
+
+			fType := files.GetFileType(watchOutputFormat)
+			if fType == files.UNKNOWN {
+				fmt.Println("[!] Error: Invalid output format. Must be 'json', 'xml', 'yaml', or 'txt'.")
+				return
+			}
+
+			key := getDehashedApiKey()
+			if key == "" {
+				fmt.Println("Dehashed API key is required. Set the key with the \"set-dehashed\" command. [crowsnest set-dehashed <api_key>]")
+				return
+			}
+			runner := &watch.Runner{
+				Whois:          whois.NewWhoIs(key, newHTTPClient(httpclient.Whois), debugGlobal),
+				DehashedKey:    key,
+				DehashedClient: newHTTPClient(httpclient.Dehashed),
+				Debug:          debugGlobal,
+			}
+
+			ctx := rootCmd.Context()
+			for {
+				if !runWatches(runner, args, fType) || !watchLoop {
+					return
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(time.Minute):
+				}
+			}
+		},
+	}
+)
+
+// runWatches runs the named or due watches and reports what is new, returning false when the run should stop
+func runWatches(runner *watch.Runner, names []string, fType files.FileType) bool {
+	watches, err := sqlite.GetWatches(names...)
+	if err != nil {
+		zap.L().Error("get_watches",
+			zap.String("message", "failed to get watches"),
+			zap.Error(err),
+		)
+		fmt.Printf("[!] Error loading watches: %v\n", err)
+		return false
+	}
+	for _, name := range names {
+		if !slices.ContainsFunc(watches, func(w sqlite.Watch) bool { return w.Name == name }) {
+			fmt.Printf("[-] No watch named %s\n", name)
+		}
+	}
+
+	now := time.Now()
+	if len(names) == 0 && !watchRunAll {
+		watches = slices.DeleteFunc(watches, func(w sqlite.Watch) bool { return !w.Due(now) })
+	}
+	if len(watches) == 0 {
+		if !watchLoop {
+			fmt.Println("[-] No watches due")
+		}
+		return true
+	}
+
+	report := &watch.Report{}
+	for i := range watches {
+		w := &watches[i]
+		fmt.Printf("\n[*] [%d/%d] %s (%s)\n", i+1, len(watches), w.Name, watchDescription(*w))
+		if !checkScope(watchTargets(*w), false) {
+			continue
+		}
+
+		result, err := runner.Run(w)
+		report.Results = append(report.Results, result)
+		if err != nil {
+			if debugGlobal {
+				debug.PrintInfo("failed to run watch")
+				debug.PrintError(err)
+			}
+			zap.L().Error("run_watch",
+				zap.String("message", "failed to run watch"),
+				zap.String("name", w.Name),
+				zap.Error(err),
+			)
+			fmt.Printf("   [!] Error running watch %s: %v\n", w.Name, err)
+			if errors.Is(err, httpclient.ErrBudgetExceeded) || rootCmd.Context().Err() != nil {
+				break
+			}
+		}
+		printWatchResult(result)
+	}
+
+	if len(report.Results) > 0 {
+		// Each run has its own report so looping runs do not overwrite the last one
+		filename := watchOutputFile + "_" + now.Format("20060102_150405")
+		fmt.Printf("\n[*] Writing new records to file: %s%s\n", export.Path(filename), fType.Extension())
+		if err = export.WriteIStringToFile(report, filename, fType); err != nil {
+			zap.L().Error("write_watch_report",
+				zap.String("message", "failed to write watch report to file"),
+				zap.Error(err),
+			)
+			fmt.Printf("[!] Error writing watch report: %v\n", err)
+		} else {
+			fmt.Println("   [*] Success")
+		}
+	}
+	return rootCmd.Context().Err() == nil
+}
+
+// printWatchResult prints what a watch found that was not stored before
+func printWatchResult(result *watch.Result) {
+	if result.New() == 0 {
+		fmt.Printf("   [-] Nothing new (%d retrieved)\n", result.Total)
+		return
+	}
+	switch result.Kind {
+	case sqlite.WatchSubdomains:
+		fmt.Printf("   [+] %d new subdomains (%d retrieved)\n", len(result.Subdomains), result.Total)
+	case sqlite.WatchWhois:
+		if result.Whois != nil {
+			fmt.Printf("   [+] First WHOIS record stored for %s, later runs report its changes\n", result.Query)
+		} else {
+			fmt.Printf("   [+] %d WHOIS fields changed\n", len(result.Changes))
+		}
+	default:
+		fmt.Printf("   [+] %d new records, %d new credentials (%d retrieved)\n", len(result.Records), len(result.Creds), result.Total)
+	}
+
+	if len(result.Creds) > 0 {
+		var rows [][]string
+		for i, cred := range result.Creds {
+			if i == 50 {
+				fmt.Println("   [-] Large number of new credentials, displaying first 50...")
+				break
+			}
+			secret := cred.Password
+			if secret == "" {
+				secret = cred.HashedPassword
+			}
+			rows = append(rows, []string{cred.Email, cred.Username, secret, cred.DatabaseName})
+		}
+		pretty.Table([]string{"Email", "Username", "Password", "Breach"}, rows)
+	}
+	if len(result.Subdomains) > 0 {
+		var rows [][]string
+		for i, sub := range result.Subdomains {
+			if i == 50 {
+				fmt.Println("   [-] Large number of new subdomains, displaying first 50...")
+				break
+			}
+			rows = append(rows, []string{sub.Subdomain, sub.Domain})
+		}
+		pretty.Table([]string{"Subdomain", "Domain"}, rows)
+	}
+	if len(result.Changes) > 0 {
+		var rows [][]string
+		for _, change := range result.Changes {
+			rows = append(rows, []string{change.Field, change.Old, change.New})
+		}
+		pretty.Table([]string{"Field", "Was", "Now"}, rows)
+	}
+}
+
+// watchDescription describes what a watch queries
+func watchDescription(w sqlite.Watch) string {
+	switch w.Kind {
+	case sqlite.WatchSubdomains:
+		return "subdomains of " + w.Query
+	case sqlite.WatchWhois:
+		return "whois of " + w.Query
+	}
+	return fmt.Sprintf("dehashed %s:%s", w.Field, w.Query)
+}
+
+// watchTargets returns the scope targets a watch queries
+func watchTargets(w sqlite.Watch) []scope.Target {
+	switch {
+	case w.Kind == sqlite.WatchSubdomains || w.Kind == sqlite.WatchWhois || w.Field == "domain":
+		return []scope.Target{{Kind: scope.TargetDomain, Value: w.Query}}
+	case w.Field == "email":
+		return []scope.Target{{Kind: scope.TargetEmail, Value: w.Query}}
+	case w.Field == "ip":
+		return []scope.Target{{Kind: scope.TargetIP, Value: w.Query}}
+	}
+	return nil
+}
@@ -1,17 +1,24 @@
 package cmd
 
 import (
+	"context"
 	"crowsnest/internal/debug"
 	"crowsnest/internal/export"
 	"crowsnest/internal/files"
+	"crowsnest/internal/httpclient"
 	"crowsnest/internal/pretty"
+	"crowsnest/internal/scope"
 	"crowsnest/internal/sqlite"
 	"crowsnest/internal/whois"
+	"crowsnest/internal/workers"
+	"errors"
 	"fmt"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 	"os"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -32,6 +39,11 @@ func init() {
 	whoisCmd.Flags().BoolVarP(&whoisShowCredits, "credits", "c", false, "Show remaining WHOIS credits")
 	whoisCmd.Flags().BoolVarP(&whoisHistory, "history", "H", false, "Perform WHOIS history search [25 Credits]")
 	whoisCmd.Flags().BoolVarP(&whoisSubdomainScan, "subdomains", "s", false, "Perform WHOIS subdomain scan")
+	whoisCmd.Flags().BoolVar(&whoisDryRun, "dry-run", false, "Print the request and estimated cost without contacting the API")
+	whoisCmd.Flags().StringVarP(&whoisInput, "input", "l", "", "File of domains to look up, one per line (uses --workers)")
+
+	// A batch looks up every domain in the input file instead
+	whoisCmd.MarkFlagsMutuallyExclusive("input", "domain")
 }
 
 var (
@@ -48,6 +60,8 @@ var (
 	whoisShowCredits   bool
 	whoisHistory       bool
 	whoisSubdomainScan bool
+	whoisDryRun        bool
+	whoisInput         string
 
 	// WHOIS command
 	whoisCmd = &cobra.Command{
@@ -58,7 +72,7 @@ var (
 			key := getDehashedApiKey()
 
 			// Validate credentials
-			if key == "" {
+			if key == "" && !whoisDryRun {
 				fmt.Println("API key is required. Set the key with the \"set-key\" command. [crowsnest set-key <api_key>]")
 				return
 			}
@@ -93,7 +107,30 @@ var (
 				debug.PrintInfo("using output format: " + whoisOutputFormat)
 			}
 
-			w := whois.NewWhoIs(key, debugGlobal)
+			// Refuse lookups of targets outside the project scope
+			if !checkScope(whoisScopeTargets(), whoisDryRun) {
+				return
+			}
+
+			client := newHTTPClient(httpclient.Whois)
+			client.SetDryRun(whoisDryRun)
+			w := whois.NewWhoIs(key, client, debugGlobal)
+
+			// Balance lookups are skipped in a dry run
+			if whoisDryRun {
+				whoisShowCredits = false
+			}
+
+			// Load the remaining balance so the budget can stop before it runs out
+			if !whoisDryRun && getCreditBudget().Active(httpclient.Whois) {
+				_, err := w.Balance()
+				if err != nil {
+					zap.L().Error("whois_balance",
+						zap.String("message", "failed to get balance for budget"),
+						zap.Error(err),
+					)
+				}
+			}
 
 			// Show credits if requested
 			if whoisShowCredits {
@@ -103,6 +140,15 @@ var (
 				}
 			}
 
+			if whoisInput != "" {
+				if whoisHistory || whoisSubdomainScan {
+					fmt.Println("[!] Input files only support WHOIS lookups, run history and subdomain scans per domain.")
+					return
+				}
+				runWhoisBatch(key, client, fType)
+				return
+			}
+
 			// Check if domain is provided for history and subdomain scan
 			if whoisHistory || whoisSubdomainScan {
 				if whoisDomain == "" {
@@ -122,6 +168,9 @@ var (
 					// Domain lookup
 					result, err := w.WhoisSearch(whoisDomain)
 					if err != nil {
+						if isDryRun(err) {
+							return
+						}
 						if debugGlobal {
 							debug.PrintInfo("failed to perform whois search")
 							debug.PrintError(err)
@@ -179,15 +228,17 @@ var (
 					// Perform history search
 					historyRecords, err := w.WhoisHistory(whoisDomain)
 					if err != nil {
-						if debugGlobal {
-							debug.PrintInfo("failed to perform whois history lookup")
-							debug.PrintError(err)
+						if !isDryRun(err) {
+							if debugGlobal {
+								debug.PrintInfo("failed to perform whois history lookup")
+								debug.PrintError(err)
+							}
+							zap.L().Error("whois_history",
+								zap.String("message", "failed to perform whois history lookup"),
+								zap.Error(err),
+							)
+							fmt.Printf("[!] Error performing WHOIS history lookup: %v\n", err)
 						}
-						zap.L().Error("whois_history",
-							zap.String("message", "failed to perform whois history lookup"),
-							zap.Error(err),
-						)
-						fmt.Printf("[!] Error performing WHOIS history lookup: %v\n", err)
 					} else {
 						if whoisShowCredits {
 							checkBalance(w)
@@ -195,8 +246,8 @@ var (
 
 						// Write history records to file if any
 						if len(historyRecords) > 0 {
-							fmt.Println("[*] Records Found: %d\n", len(historyRecords))
-							fmt.Println("[*] WHOIS History being written to file: %s%s\n", whoisOutputFile, fType.Extension())
+							fmt.Printf("[*] Records Found: %d\n", len(historyRecords))
+							fmt.Printf("[*] WHOIS History being written to file: %s%s\n", filename, fType.Extension())
 							writeErr := export.WriteWhoIsHistoryToFile(historyRecords, filename, fType)
 							if writeErr != nil {
 								if debugGlobal {
@@ -246,15 +297,17 @@ var (
 					}
 
 					if err != nil {
-						if debugGlobal {
-							debug.PrintInfo("failed to perform subdomain scan")
-							debug.PrintError(err)
+						if !isDryRun(err) {
+							if debugGlobal {
+								debug.PrintInfo("failed to perform subdomain scan")
+								debug.PrintError(err)
+							}
+							zap.L().Error("whois_subdomain_scan",
+								zap.String("message", "failed to perform subdomain scan"),
+								zap.Error(err),
+							)
+							fmt.Printf("Error performing subdomain scan: %v\n", err)
 						}
-						zap.L().Error("whois_subdomain_scan",
-							zap.String("message", "failed to perform subdomain scan"),
-							zap.Error(err),
-						)
-						fmt.Printf("Error performing subdomain scan: %v\n", err)
 					} else {
 						// Store subdomains in subdomains table
 						var subs []sqlite.Subdomain
@@ -319,6 +372,9 @@ var (
 				// IP lookup
 				result, err := w.WhoisIP(whoisIPAddress)
 				if err != nil {
+					if isDryRun(err) {
+						return
+					}
 					if debugGlobal {
 						debug.PrintInfo("failed to perform ip lookup")
 						debug.PrintError(err)
@@ -381,6 +437,9 @@ var (
 				// MX lookup
 				result, err := w.WhoisMX(whoisMXAddress)
 				if err != nil {
+					if isDryRun(err) {
+						return
+					}
 					if debugGlobal {
 						debug.PrintInfo("failed to perform mx lookup")
 						debug.PrintError(err)
@@ -443,6 +502,9 @@ var (
 				// NS lookup
 				result, err := w.WhoisNS(whoisNSAddress)
 				if err != nil {
+					if isDryRun(err) {
+						return
+					}
 					if debugGlobal {
 						debug.PrintInfo("failed to perform ns lookup")
 						debug.PrintError(err)
@@ -535,6 +597,9 @@ var (
 				fmt.Println("[*] Performing reverse WHOIS lookup...")
 				result, err := w.ReverseWHOIS(includeTerms, excludeTerms, whoisReverseType)
 				if err != nil {
+					if isDryRun(err) {
+						return
+					}
 					if debugGlobal {
 						debug.PrintInfo("failed to perform reverse whois")
 						debug.PrintError(err)
@@ -595,6 +660,149 @@ var (
 	}
 )
 
+// whoisScopeTargets returns the domain, IP, MX or NS hostname and reverse WHOIS terms the lookup queries
+func whoisScopeTargets() []scope.Target {
+	var targets []scope.Target
+	for _, host := range []string{whoisDomain, whoisMXAddress, whoisNSAddress} {
+		if host != "" {
+			targets = append(targets, scope.Target{Kind: scope.TargetDomain, Value: host})
+		}
+	}
+	if whoisIPAddress != "" {
+		targets = append(targets, scope.Target{Kind: scope.TargetIP, Value: whoisIPAddress})
+	}
+	// Reverse WHOIS terms name the registrant, so they are checked against the scope companies
+	if whoisInclude != "" {
+		for _, term := range strings.Split(whoisInclude, ",") {
+			if term = strings.TrimSpace(term); term != "" {
+				targets = append(targets, scope.Target{Kind: scope.TargetCompany, Value: term})
+			}
+		}
+	}
+	return targets
+}
+
+// runWhoisBatch looks up every domain in the input file concurrently and writes one consolidated file
+func runWhoisBatch(key string, client *httpclient.Client, fType files.FileType) {
+	domains, err := files.ReadLines(whoisInput)
+	if err != nil {
+		zap.L().Error("read_whois_input",
+			zap.String("message", "failed to read whois input"),
+			zap.String("file", whoisInput),
+			zap.Error(err),
+		)
+		fmt.Printf("[!] Error reading input file: %v\n", err)
+		return
+	}
+	fmt.Printf("[*] Loaded %d domains from %s\n", len(domains), whoisInput)
+
+	// Drop the domains outside the project scope
+	domains = slices.DeleteFunc(domains, func(domain string) bool {
+		return !checkScope([]scope.Target{{Kind: scope.TargetDomain, Value: domain}}, whoisDryRun)
+	})
+	if len(domains) == 0 {
+		fmt.Println("[-] No domains in scope")
+		return
+	}
+
+	// Dry run output is only readable one request at a time
+	poolSize := workerCount
+	if whoisDryRun {
+		poolSize = 1
+	}
+
+	var (
+		mu      sync.Mutex
+		found   = make(sqlite.WhoisRecords, len(domains))
+		headers = []string{"Domain", "Registrar", "Created", "Expires", "Status"}
+		rows    = make([][]string, len(domains))
+	)
+	// Each lookup prints to its own buffer, written in order once the earlier lookups finish
+	output := workers.NewOutput(os.Stdout, len(domains), poolSize)
+	err = workers.Run(rootCmd.Context(), poolSize, len(domains), func(_ context.Context, i int) error {
+		defer output.Done(i)
+		out := output.Job(i)
+		domain := domains[i]
+		fmt.Fprintf(out, "[*] [%d/%d] Performing WHOIS lookup for %s...\n", i+1, len(domains), domain)
+
+		// Each worker has its own lookup so balances are not shared between goroutines
+		w := whois.NewWhoIs(key, client, debugGlobal)
+		result, err := w.WhoisSearch(domain)
+		if err != nil {
+			if isDryRun(err) {
+				return nil
+			}
+			if debugGlobal {
+				debug.PrintInfo("failed to perform whois search")
+				debug.PrintError(err)
+			}
+			zap.L().Error("whois_search",
+				zap.String("message", "failed to perform whois search"),
+				zap.String("domain", domain),
+				zap.Error(err),
+			)
+			fmt.Fprintf(out, "   [!] Error performing WHOIS lookup for %s: %v\n", domain, err)
+
+			mu.Lock()
+			rows[i] = []string{domain, "error", "", "", err.Error()}
+			mu.Unlock()
+			if errors.Is(err, httpclient.ErrBudgetExceeded) {
+				return err
+			}
+			return nil
+		}
+
+		err = sqlite.StoreWhoisRecord(result)
+		if err != nil {
+			if debugGlobal {
+				debug.PrintInfo("failed to store whois record")
+				debug.PrintError(err)
+			}
+			zap.L().Error("store_whois_record",
+				zap.String("message", "failed to store whois record"),
+				zap.Error(err),
+			)
+			fmt.Fprintf(out, "   [!] Error storing WHOIS record for %s: %v\n", domain, err)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		rows[i] = []string{domain, result.RegistrarName, result.CreatedDateNormalized, result.ExpiresDateNormalized, result.Status}
+		found[i] = result
+		return nil
+	})
+	output.Flush()
+	if errors.Is(err, httpclient.ErrBudgetExceeded) {
+		fmt.Println("[!] Credit budget reached, stopping batch")
+	} else if errors.Is(err, context.Canceled) {
+		fmt.Println("[!] Interrupted, stopping batch")
+	}
+
+	if whoisDryRun {
+		return
+	}
+
+	// Drop the domains that never started or returned no record
+	rows = slices.DeleteFunc(rows, func(row []string) bool { return row == nil })
+	records := slices.DeleteFunc(found, func(record sqlite.WhoisRecord) bool { return len(record.DomainName) == 0 })
+	fmt.Println("\n[*] Completing Process")
+	pretty.Table(headers, rows)
+
+	if len(records) == 0 {
+		fmt.Println("[-] No WHOIS records found")
+		return
+	}
+	fmt.Printf("[*] Writing %d WHOIS records to file: %s%s\n", len(records), whoisOutputFile, fType.Extension())
+	err = export.WriteIStringToFile(records, whoisOutputFile, fType)
+	if err != nil {
+		zap.L().Error("write_whois_records",
+			zap.String("message", "failed to write whois records to file"),
+			zap.Error(err),
+		)
+		fmt.Printf("[!] Error writing WHOIS records to file: %v\n", err)
+	}
+}
+
 func checkBalance(w *whois.DehashedWhoIs) {
 	balance, err := w.Balance()
 	if err != nil {
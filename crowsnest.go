@@ -3,6 +3,7 @@ package main
 import (
 	"crowsnest/cmd"
 	"crowsnest/internal/badger"
+	"crowsnest/internal/project"
 	"crowsnest/internal/sqlite"
 	"fmt"
 	"github.com/winking324/rzap"
@@ -78,9 +79,17 @@ func main() {
 	db := badger.Start(storePath)
 	defer db.Close()
 
-	// Set database path based on useLocalDatabase flag
+	// The active project has its own database, otherwise set the path based on useLocalDatabase flag
+	project.SetRoot(filepath.Join(basePath, "projects"))
+	activeProject, hasProject, err := project.Active()
+	if err != nil {
+		zap.L().Warn("active_project", zap.String("message", "active project cannot be used"), zap.Error(err))
+	}
 	useLocalDB := badger.GetUseLocalDB()
-	if useLocalDB {
+	if hasProject {
+		dbPath = activeProject.DBPath()
+		zap.L().Info("Using project database", zap.String("project", activeProject.Name), zap.String("path", dbPath))
+	} else if useLocalDB {
 		// Use local database in current directory
 		dbPath = "./"
 		zap.L().Info("Using local database", zap.String("path", dbPath))
@@ -91,7 +100,7 @@ func main() {
 	}
 
 	zap.L().Info("initializing_database")
-	_, err := sqlite.InitDB(dbPath)
+	_, err = sqlite.InitDB(dbPath)
 	if err != nil {
 		zap.L().Error("init_db",
 			zap.String("message", "failed to initialize database"),
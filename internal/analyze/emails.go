@@ -0,0 +1,224 @@
+package analyze
+
+import (
+	"crowsnest/internal/spray"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// EmailFormats are the local part conventions checked, written the way Hunter.io writes patterns
+var EmailFormats = []string{
+	"{first}.{last}", "{f}{last}", "{first}{l}", "{first}", "{last}", "{first}{last}", "{first}_{last}",
+	"{first}-{last}", "{f}.{last}", "{first}.{l}", "{last}.{first}", "{last}{f}", "{last}{first}", "{f}{l}",
+}
+
+// FormatCount is the evidence found for an email format
+type FormatCount struct {
+	Pattern    string  `json:"pattern" yaml:"pattern" xml:"pattern"`
+	Confirmed  int     `json:"confirmed" yaml:"confirmed" xml:"confirmed"`
+	Structural int     `json:"structural" yaml:"structural" xml:"structural"`
+	Confidence float64 `json:"confidence" yaml:"confidence" xml:"confidence"`
+	weight     float64
+}
+
+// SynthesizedEmail is an address built for a name with the inferred format
+type SynthesizedEmail struct {
+	Name  string `json:"name" yaml:"name" xml:"name"`
+	Email string `json:"email" yaml:"email" xml:"email"`
+	Known bool   `json:"known" yaml:"known" xml:"known"`
+}
+
+// EmailFormatReport holds the email formats inferred for a domain
+type EmailFormatReport struct {
+	XMLName       xml.Name           `json:"-" yaml:"-" xml:"email_format_report"`
+	Domain        string             `json:"domain" yaml:"domain" xml:"domain"`
+	Emails        int                `json:"emails" yaml:"emails" xml:"emails"`
+	Named         int                `json:"named" yaml:"named" xml:"named"`
+	Classified    int                `json:"classified" yaml:"classified" xml:"classified"`
+	Pattern       string             `json:"pattern" yaml:"pattern" xml:"pattern"`
+	Confidence    float64            `json:"confidence" yaml:"confidence" xml:"confidence"`
+	HunterPattern string             `json:"hunter_pattern,omitempty" yaml:"hunter_pattern,omitempty" xml:"hunter_pattern,omitempty"`
+	MatchesHunter bool               `json:"matches_hunter" yaml:"matches_hunter" xml:"matches_hunter"`
+	Formats       []FormatCount      `json:"formats" yaml:"formats" xml:"formats>format"`
+	Synthesized   []SynthesizedEmail `json:"synthesized,omitempty" yaml:"synthesized,omitempty" xml:"synthesized>email,omitempty"`
+}
+
+// InferEmailFormat scores the email formats of a domain. Addresses whose owner's name is known confirm the formats
+// that build them, and the remaining addresses count half for the formats their structure fits, using the known
+// first and last names to tell "jsmith" from "johns".
+func InferEmailFormat(domain string, emails []string, named map[string]spray.Person) *EmailFormatReport {
+	report := &EmailFormatReport{Domain: domain, Emails: len(emails)}
+
+	firsts := make(map[string]bool)
+	lasts := make(map[string]bool)
+	for _, p := range named {
+		firsts[p.First] = true
+		lasts[p.Last] = true
+	}
+
+	counts := make(map[string]*FormatCount)
+	for _, f := range EmailFormats {
+		counts[f] = &FormatCount{Pattern: f}
+	}
+
+	var total float64
+	for _, email := range emails {
+		at := strings.LastIndex(email, "@")
+		if at <= 0 {
+			continue
+		}
+		local := strings.ToLower(email[:at])
+
+		if p, ok := named[email]; ok {
+			report.Named++
+			var matches []string
+			for _, f := range EmailFormats {
+				if built, ok := spray.ApplyPattern(f, p); ok && built == local {
+					matches = append(matches, f)
+				}
+			}
+			for _, f := range matches {
+				counts[f].Confirmed++
+				counts[f].weight += 1 / float64(len(matches))
+			}
+			if len(matches) > 0 {
+				report.Classified++
+				total++
+			}
+			continue
+		}
+
+		if matches := structuralFormats(local, firsts, lasts); len(matches) > 0 {
+			for _, f := range matches {
+				counts[f].Structural++
+				counts[f].weight += 0.5 / float64(len(matches))
+			}
+			report.Classified++
+			total += 0.5
+		}
+	}
+
+	for _, f := range EmailFormats {
+		c := counts[f]
+		if c.Confirmed == 0 && c.Structural == 0 {
+			continue
+		}
+		c.Confidence = math.Round(c.weight*1000/total) / 10
+		report.Formats = append(report.Formats, *c)
+	}
+	sort.SliceStable(report.Formats, func(i, j int) bool {
+		return report.Formats[i].weight > report.Formats[j].weight
+	})
+	if len(report.Formats) > 0 {
+		report.Pattern = report.Formats[0].Pattern
+		report.Confidence = report.Formats[0].Confidence
+	}
+	return report
+}
+
+// CompareHunter records the Hunter.io pattern of the domain and whether the inferred format agrees with it
+func (r *EmailFormatReport) CompareHunter(pattern string) {
+	r.HunterPattern = pattern
+	r.MatchesHunter = pattern != "" && pattern == r.Pattern
+}
+
+// Synthesize builds an address for each person with the pattern, marking the addresses already stored
+func (r *EmailFormatReport) Synthesize(pattern string, people []spray.Person, known []string) {
+	stored := make(map[string]bool)
+	for _, e := range known {
+		stored[strings.ToLower(e)] = true
+	}
+	for _, p := range people {
+		local, ok := spray.ApplyPattern(pattern, p)
+		if !ok {
+			continue
+		}
+		email := local + "@" + r.Domain
+		r.Synthesized = append(r.Synthesized, SynthesizedEmail{
+			Name:  spray.Capitalize(p.First) + " " + spray.Capitalize(p.Last),
+			Email: email,
+			Known: stored[email],
+		})
+	}
+}
+
+// structuralFormats returns the formats a local part fits without knowing its owner
+func structuralFormats(local string, firsts, lasts map[string]bool) []string {
+	if strings.IndexFunc(local, unicode.IsDigit) >= 0 {
+		return nil
+	}
+	for _, sep := range []string{".", "_", "-"} {
+		parts := strings.Split(local, sep)
+		if len(parts) != 2 || !letters(parts[0]) || !letters(parts[1]) {
+			continue
+		}
+		switch {
+		case len(parts[0]) == 1 && len(parts[1]) > 1:
+			if sep == "." {
+				return []string{"{f}.{last}"}
+			}
+		case len(parts[0]) > 1 && len(parts[1]) == 1:
+			if sep == "." {
+				return []string{"{first}.{l}"}
+			}
+		case len(parts[0]) > 1 && len(parts[1]) > 1:
+			if lasts[parts[0]] && firsts[parts[1]] && sep == "." {
+				return []string{"{last}.{first}"}
+			}
+			return []string{"{first}" + sep + "{last}"}
+		}
+		return nil
+	}
+
+	if !letters(local) || len(local) < 2 {
+		return nil
+	}
+	var matches []string
+	if lasts[local[1:]] {
+		matches = append(matches, "{f}{last}")
+	}
+	if firsts[local[:len(local)-1]] {
+		matches = append(matches, "{first}{l}")
+	}
+	if firsts[local] {
+		matches = append(matches, "{first}")
+	}
+	if lasts[local] {
+		matches = append(matches, "{last}")
+	}
+	for first := range firsts {
+		if strings.HasPrefix(local, first) && lasts[local[len(first):]] {
+			matches = append(matches, "{first}{last}")
+			break
+		}
+	}
+	return matches
+}
+
+func letters(s string) bool {
+	return s != "" && strings.IndexFunc(s, func(r rune) bool { return !unicode.IsLetter(r) }) < 0
+}
+
+func (r *EmailFormatReport) String() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Domain: %s\nEmails: %d\nNamed: %d\nClassified: %d\n", r.Domain, r.Emails, r.Named, r.Classified))
+	sb.WriteString(fmt.Sprintf("Pattern: %s\nConfidence: %.1f%%\n", r.Pattern, r.Confidence))
+	if r.HunterPattern != "" {
+		sb.WriteString(fmt.Sprintf("Hunter Pattern: %s\nMatches Hunter: %t\n", r.HunterPattern, r.MatchesHunter))
+	}
+	sb.WriteString("\nFormats:\n")
+	for _, f := range r.Formats {
+		sb.WriteString(fmt.Sprintf("  %s: %d confirmed, %d structural (%.1f%%)\n", f.Pattern, f.Confirmed, f.Structural, f.Confidence))
+	}
+	if len(r.Synthesized) > 0 {
+		sb.WriteString("\nSynthesized:\n")
+		for _, s := range r.Synthesized {
+			sb.WriteString(fmt.Sprintf("  %s: %s\n", s.Name, s.Email))
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,111 @@
+package analyze
+
+import (
+	"crowsnest/internal/spray"
+	"testing"
+)
+
+func TestInferEmailFormat(t *testing.T) {
+	tests := []struct {
+		name       string
+		emails     []string
+		named      map[string]spray.Person
+		pattern    string
+		confidence float64
+		classified int
+		formats    map[string]float64 // Confidence of each format found
+	}{
+		{
+			name:    "no emails",
+			formats: map[string]float64{},
+		},
+		{
+			name:   "named confirm, unnamed count half",
+			emails: []string{"jane.doe@acme.com", "bob.jones@acme.com", "j.smith@acme.com"},
+			named: map[string]spray.Person{
+				"jane.doe@acme.com": {First: "jane", Last: "doe"},
+			},
+			pattern:    "{first}.{last}",
+			confidence: 75,
+			classified: 3,
+			formats:    map[string]float64{"{first}.{last}": 75, "{f}.{last}": 25},
+		},
+		{
+			name:   "known last names tell jsmith from johns",
+			emails: []string{"jsmith@acme.com", "adoe@acme.com", "jdoe2@acme.com", "nobody@acme.com"},
+			named: map[string]spray.Person{
+				"jsmith@acme.com":   {First: "john", Last: "smith"},
+				"ann.doe@gmail.com": {First: "ann", Last: "doe"},
+			},
+			pattern:    "{f}{last}",
+			confidence: 100,
+			classified: 2,
+			formats:    map[string]float64{"{f}{last}": 100},
+		},
+		{
+			name:   "last name first",
+			emails: []string{"doe.jane@acme.com", "smith.john@acme.com"},
+			named: map[string]spray.Person{
+				"doe.jane@acme.com": {First: "jane", Last: "doe"},
+				"x@acme.com":        {First: "john", Last: "smith"},
+			},
+			pattern:    "{last}.{first}",
+			confidence: 100,
+			classified: 2,
+			formats:    map[string]float64{"{last}.{first}": 100},
+		},
+		{
+			name:       "ambiguous unnamed address split between formats",
+			emails:     []string{"janed@acme.com"},
+			named:      map[string]spray.Person{"a@b.com": {First: "jane", Last: "janed"}},
+			classified: 1,
+			formats:    map[string]float64{"{first}{l}": 50, "{last}": 50},
+			pattern:    "{first}{l}",
+			confidence: 50,
+		},
+		{
+			name:    "unusable addresses",
+			emails:  []string{"info", "@acme.com", "j2.smith@acme.com", "a.b.c@acme.com"},
+			formats: map[string]float64{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := InferEmailFormat("acme.com", tt.emails, tt.named)
+			if report.Pattern != tt.pattern || report.Confidence != tt.confidence {
+				t.Errorf("pattern = %q (%.1f%%), want %q (%.1f%%)", report.Pattern, report.Confidence, tt.pattern, tt.confidence)
+			}
+			if report.Classified != tt.classified {
+				t.Errorf("classified = %d, want %d", report.Classified, tt.classified)
+			}
+			if len(report.Formats) != len(tt.formats) {
+				t.Errorf("formats = %+v, want %v", report.Formats, tt.formats)
+			}
+			for _, f := range report.Formats {
+				if want, ok := tt.formats[f.Pattern]; !ok || f.Confidence != want {
+					t.Errorf("format %s confidence = %.1f, want %.1f (found %v)", f.Pattern, f.Confidence, want, ok)
+				}
+			}
+		})
+	}
+}
+
+func TestSynthesize(t *testing.T) {
+	report := &EmailFormatReport{Domain: "acme.com"}
+	people := []spray.Person{{First: "jane", Last: "doe"}, {First: "", Last: "smith"}, {First: "bob", Last: "jones"}}
+	report.Synthesize("{f}{last}", people, []string{"JDoe@acme.com"})
+
+	want := []SynthesizedEmail{
+		{Name: "Jane Doe", Email: "jdoe@acme.com", Known: true},
+		{Name: "Bob Jones", Email: "bjones@acme.com"},
+	}
+	if len(report.Synthesized) != len(want) {
+		t.Fatalf("Synthesize() = %+v, want %+v", report.Synthesized, want)
+	}
+	for i := range want {
+		if report.Synthesized[i] != want[i] {
+			t.Errorf("Synthesize()[%d] = %+v, want %+v", i, report.Synthesized[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,423 @@
+package analyze
+
+import (
+	"crowsnest/internal/sqlite"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var (
+	yearSuffix     = regexp.MustCompile(`(19[5-9]\d|20\d{2})[^A-Za-z0-9]*$`)
+	digitSuffix    = regexp.MustCompile(`\d+[^A-Za-z0-9]*$`)
+	nonLetterEdges = regexp.MustCompile(`^[^A-Za-z]+|[^A-Za-z]+$`)
+	trailingSuffix = regexp.MustCompile(`[^A-Za-z]+$`)
+
+	seasons = []string{"spring", "summer", "autumn", "fall", "winter"}
+	months  = []string{"january", "february", "march", "april", "may", "june", "july", "august", "september",
+		"october", "november", "december"}
+
+	leet = strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s")
+)
+
+// Credential is a plaintext password of an account and the breaches it was found in
+type Credential struct {
+	Account  string
+	Password string
+	Sources  []string
+}
+
+// Count is the number of credentials sharing a value
+type Count struct {
+	Value   string  `json:"value" yaml:"value" xml:"value"`
+	Count   int     `json:"count" yaml:"count" xml:"count"`
+	Percent float64 `json:"percent" yaml:"percent" xml:"percent"`
+}
+
+// SharedPassword is a password used by more than one account
+type SharedPassword struct {
+	Password string `json:"password" yaml:"password" xml:"password"`
+	Accounts int    `json:"accounts" yaml:"accounts" xml:"accounts"`
+}
+
+// BreachReuse is an account whose password appears in more than one breach
+type BreachReuse struct {
+	Account  string   `json:"account" yaml:"account" xml:"account"`
+	Password string   `json:"password" yaml:"password" xml:"password"`
+	Breaches []string `json:"breaches" yaml:"breaches" xml:"breaches>breach"`
+}
+
+// PasswordReport holds the statistics over a set of plaintext credentials
+type PasswordReport struct {
+	XMLName          xml.Name         `json:"-" yaml:"-" xml:"password_report"`
+	Domain           string           `json:"domain,omitempty" yaml:"domain,omitempty" xml:"domain,omitempty"`
+	Company          string           `json:"company,omitempty" yaml:"company,omitempty" xml:"company,omitempty"`
+	Credentials      int              `json:"credentials" yaml:"credentials" xml:"credentials"`
+	Accounts         int              `json:"accounts" yaml:"accounts" xml:"accounts"`
+	UniquePasswords  int              `json:"unique_passwords" yaml:"unique_passwords" xml:"unique_passwords"`
+	Lengths          []Count          `json:"lengths" yaml:"lengths" xml:"lengths>length"`
+	CharacterClasses []Count          `json:"character_classes" yaml:"character_classes" xml:"character_classes>class"`
+	Patterns         []Count          `json:"patterns" yaml:"patterns" xml:"patterns>pattern"`
+	BaseWords        []Count          `json:"base_words" yaml:"base_words" xml:"base_words>word"`
+	Suffixes         []Count          `json:"suffixes" yaml:"suffixes" xml:"suffixes>suffix"`
+	SharedPasswords  []SharedPassword `json:"shared_passwords" yaml:"shared_passwords" xml:"shared_passwords>password"`
+	BreachReuse      []BreachReuse    `json:"breach_reuse" yaml:"breach_reuse" xml:"breach_reuse>account"`
+	SprayCandidates  []string         `json:"spray_candidates" yaml:"spray_candidates" xml:"spray_candidates>password"`
+}
+
+// LoadCredentials returns the plaintext credentials stored in the creds and dehashed tables, one per account and password.
+// A domain limits them to email accounts of the domain.
+func LoadCredentials(domain string) ([]Credential, error) {
+	domain = strings.ToLower(domain)
+	var (
+		byKey = make(map[string]*Credential)
+		creds []Credential
+		order []string
+	)
+	add := func(account, password, source string) {
+		account = strings.ToLower(strings.TrimSpace(account))
+		if account == "" || password == "" {
+			return
+		}
+		if domain != "" && !strings.HasSuffix(account, "@"+domain) {
+			return
+		}
+		key := account + "\x00" + password
+		c, ok := byKey[key]
+		if !ok {
+			c = &Credential{Account: account, Password: password}
+			byKey[key] = c
+			order = append(order, key)
+		}
+		if source != "" && !contains(c.Sources, source) {
+			c.Sources = append(c.Sources, source)
+		}
+	}
+
+	db := sqlite.GetDB()
+	var users []sqlite.User
+	query := db.Where("password IS NOT NULL AND password != ''")
+	if domain != "" {
+		query = query.Where("email LIKE ?", "%@"+domain)
+	}
+	if err := query.Find(&users).Error; err != nil {
+		return nil, err
+	}
+	for _, u := range users {
+		account := u.Email
+		if account == "" {
+			account = u.Username
+		}
+		add(account, u.Password, u.DatabaseName)
+	}
+
+	var results []sqlite.Result
+	query = db.Where("password IS NOT NULL AND password NOT IN ('', '[]', 'null')")
+	if domain != "" {
+		query = query.Where("email LIKE ?", "%@"+domain+"%")
+	}
+	if err := query.Find(&results).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range results {
+		accounts := r.Email
+		if len(accounts) == 0 {
+			accounts = r.Username
+		}
+		for _, account := range accounts {
+			for _, password := range r.Password {
+				add(account, password, r.DatabaseName)
+			}
+		}
+	}
+
+	for _, key := range order {
+		creds = append(creds, *byKey[key])
+	}
+	return creds, nil
+}
+
+// Passwords builds the password statistics, listing the top entries of each table.
+// The company name is matched inside passwords, leetspeak included.
+func Passwords(creds []Credential, company string, top int) *PasswordReport {
+	report := &PasswordReport{Company: company, Credentials: len(creds)}
+	company = strings.ToLower(strings.Join(strings.Fields(company), ""))
+
+	var (
+		accounts  = make(map[string]bool)
+		passwords = make(map[string]map[string]bool)
+		lengths   = make(map[int]int)
+		classes   = make(map[string]int)
+		patterns  = make(map[string]int)
+		bases     = make(map[string]int)
+		suffixes  = make(map[string]int)
+	)
+	for _, c := range creds {
+		accounts[c.Account] = true
+		if passwords[c.Password] == nil {
+			passwords[c.Password] = make(map[string]bool)
+		}
+		passwords[c.Password][c.Account] = true
+
+		lengths[len([]rune(c.Password))]++
+		classes[CharacterClasses(c.Password)]++
+		for _, p := range Patterns(c.Password, company) {
+			patterns[p]++
+		}
+		if base := BaseWord(c.Password); base != "" {
+			bases[base]++
+		}
+		if suffix := Suffix(c.Password); suffix != "" {
+			suffixes[suffix]++
+		}
+
+		if len(c.Sources) > 1 {
+			sources := append([]string(nil), c.Sources...)
+			sort.Strings(sources)
+			report.BreachReuse = append(report.BreachReuse, BreachReuse{Account: c.Account, Password: c.Password, Breaches: sources})
+		}
+	}
+	report.Accounts = len(accounts)
+	report.UniquePasswords = len(passwords)
+
+	// Lengths are listed in order, long passwords share a bucket
+	lengthCounts := make(map[string]int)
+	var lengthOrder []string
+	for _, l := range sortedKeys(lengths) {
+		label := strconv.Itoa(l)
+		if l >= 16 {
+			label = "16+"
+		}
+		if _, ok := lengthCounts[label]; !ok {
+			lengthOrder = append(lengthOrder, label)
+		}
+		lengthCounts[label] += lengths[l]
+	}
+	for _, label := range lengthOrder {
+		report.Lengths = append(report.Lengths, newCount(label, lengthCounts[label], len(creds)))
+	}
+
+	report.CharacterClasses = topCounts(classes, len(creds), 0)
+	report.Patterns = topCounts(patterns, len(creds), 0)
+	report.BaseWords = topCounts(bases, len(creds), top)
+	report.Suffixes = topCounts(suffixes, len(creds), top)
+
+	for password, users := range passwords {
+		if len(users) > 1 {
+			report.SharedPasswords = append(report.SharedPasswords, SharedPassword{Password: password, Accounts: len(users)})
+		}
+	}
+	sort.Slice(report.SharedPasswords, func(i, j int) bool {
+		a, b := report.SharedPasswords[i], report.SharedPasswords[j]
+		return a.Accounts > b.Accounts || a.Accounts == b.Accounts && a.Password < b.Password
+	})
+	if top > 0 && len(report.SharedPasswords) > top {
+		report.SharedPasswords = report.SharedPasswords[:top]
+	}
+	sort.Slice(report.BreachReuse, func(i, j int) bool {
+		a, b := report.BreachReuse[i], report.BreachReuse[j]
+		return len(a.Breaches) > len(b.Breaches) || len(a.Breaches) == len(b.Breaches) && a.Account < b.Account
+	})
+	if top > 0 && len(report.BreachReuse) > top {
+		report.BreachReuse = report.BreachReuse[:top]
+	}
+
+	report.SprayCandidates = sprayCandidates(report, top)
+	return report
+}
+
+// sprayCandidates lists the passwords shared between accounts, then the most common base words with the most
+// common suffixes when the result is long enough to pass a typical eight character policy
+func sprayCandidates(report *PasswordReport, top int) []string {
+	var candidates []string
+	add := func(password string) {
+		if password != "" && !contains(candidates, password) {
+			candidates = append(candidates, password)
+		}
+	}
+	for _, shared := range report.SharedPasswords {
+		add(shared.Password)
+	}
+	for i, base := range report.BaseWords {
+		if i == 5 {
+			break
+		}
+		word := []rune(base.Value)
+		word[0] = unicode.ToUpper(word[0])
+		for j, suffix := range report.Suffixes {
+			if j == 5 {
+				break
+			}
+			if candidate := string(word) + suffix.Value; len(candidate) >= 8 {
+				add(candidate)
+			}
+		}
+	}
+	if top > 0 && len(candidates) > top*2 {
+		candidates = candidates[:top*2]
+	}
+	return candidates
+}
+
+// CharacterClasses returns the classes of characters the password is made of, e.g. "lower+digit"
+func CharacterClasses(password string) string {
+	var lower, upper, digit, special bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			special = true
+		}
+	}
+	var classes []string
+	for _, c := range []struct {
+		present bool
+		name    string
+	}{{lower, "lower"}, {upper, "upper"}, {digit, "digit"}, {special, "special"}} {
+		if c.present {
+			classes = append(classes, c.name)
+		}
+	}
+	return strings.Join(classes, "+")
+}
+
+// Patterns returns the common structures found in the password: years, seasons, months, the company name and trailing characters.
+// Company names shorter than three letters match too many passwords and are ignored.
+func Patterns(password, company string) []string {
+	var found []string
+	plain := leet.Replace(strings.ToLower(password))
+
+	if yearSuffix.MatchString(password) {
+		found = append(found, "year suffix")
+	} else if digitSuffix.MatchString(password) {
+		found = append(found, "digit suffix")
+	}
+	if last := []rune(password); len(last) > 0 && !unicode.IsLetter(last[len(last)-1]) && !unicode.IsDigit(last[len(last)-1]) {
+		found = append(found, "special suffix")
+	}
+	if first := []rune(password); len(first) > 0 && unicode.IsUpper(first[0]) {
+		found = append(found, "capitalized")
+	}
+	for _, s := range seasons {
+		if strings.Contains(plain, s) {
+			found = append(found, "season")
+			break
+		}
+	}
+	for _, m := range months {
+		if strings.Contains(plain, m) {
+			found = append(found, "month")
+			break
+		}
+	}
+	if len(company) >= 3 && strings.Contains(plain, company) {
+		found = append(found, "company name")
+	}
+	return found
+}
+
+// BaseWord returns the password without leading and trailing digits and symbols, lowercased and with leetspeak undone
+func BaseWord(password string) string {
+	base := nonLetterEdges.ReplaceAllString(password, "")
+	base = leet.Replace(strings.ToLower(base))
+	if len([]rune(base)) < 3 || strings.IndexFunc(base, func(r rune) bool { return !unicode.IsLetter(r) }) >= 0 {
+		return ""
+	}
+	return base
+}
+
+// Suffix returns the digits and symbols the password ends with
+func Suffix(password string) string {
+	if nonLetterEdges.ReplaceAllString(password, "") == "" {
+		return ""
+	}
+	return trailingSuffix.FindString(password)
+}
+
+func (r *PasswordReport) String() string {
+	var sb strings.Builder
+	if r.Domain != "" {
+		sb.WriteString(fmt.Sprintf("Domain: %s\n", r.Domain))
+	}
+	if r.Company != "" {
+		sb.WriteString(fmt.Sprintf("Company: %s\n", r.Company))
+	}
+	sb.WriteString(fmt.Sprintf("Credentials: %d\nAccounts: %d\nUnique Passwords: %d\n", r.Credentials, r.Accounts, r.UniquePasswords))
+
+	writeCounts := func(title string, counts []Count) {
+		sb.WriteString(fmt.Sprintf("\n%s:\n", title))
+		for _, c := range counts {
+			sb.WriteString(fmt.Sprintf("  %s: %d (%.1f%%)\n", c.Value, c.Count, c.Percent))
+		}
+	}
+	writeCounts("Lengths", r.Lengths)
+	writeCounts("Character Classes", r.CharacterClasses)
+	writeCounts("Patterns", r.Patterns)
+	writeCounts("Base Words", r.BaseWords)
+	writeCounts("Suffixes", r.Suffixes)
+
+	sb.WriteString("\nShared Passwords:\n")
+	for _, s := range r.SharedPasswords {
+		sb.WriteString(fmt.Sprintf("  %s: %d accounts\n", s.Password, s.Accounts))
+	}
+	sb.WriteString("\nReused Across Breaches:\n")
+	for _, b := range r.BreachReuse {
+		sb.WriteString(fmt.Sprintf("  %s: %s (%s)\n", b.Account, b.Password, strings.Join(b.Breaches, ", ")))
+	}
+	sb.WriteString("\nSpray Candidates:\n")
+	for _, c := range r.SprayCandidates {
+		sb.WriteString(fmt.Sprintf("  %s\n", c))
+	}
+	return sb.String()
+}
+
+func newCount(value string, count, total int) Count {
+	percent := 0.0
+	if total > 0 {
+		percent = math.Round(float64(count)*1000/float64(total)) / 10
+	}
+	return Count{Value: value, Count: count, Percent: percent}
+}
+
+// topCounts returns the counts from most to least common, limited to top when it is positive
+func topCounts(counts map[string]int, total, top int) []Count {
+	var out []Count
+	for value, count := range counts {
+		out = append(out, newCount(value, count, total))
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Count > out[j].Count || out[i].Count == out[j].Count && out[i].Value < out[j].Value
+	})
+	if top > 0 && len(out) > top {
+		out = out[:top]
+	}
+	return out
+}
+
+func sortedKeys(m map[int]int) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -230,3 +230,170 @@ func StoreUseLocalDB(useLocal bool) error {
 	}
 	return err
 }
+
+func GetHTTPConfig(provider string) []byte {
+	var cfg []byte
+
+	err := db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte("cfg:http:" + provider))
+		if err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return nil
+			}
+			return err
+		}
+		cfg, err = item.ValueCopy(nil)
+		return err
+	})
+
+	if err != nil {
+		zap.L().Error("get_http_config",
+			zap.String("message", "failed to get http config"),
+			zap.String("provider", provider),
+			zap.Error(err),
+		)
+	}
+
+	return cfg
+}
+
+func StoreHTTPConfig(provider string, cfg []byte) error {
+	err := db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte("cfg:http:"+provider), cfg)
+	})
+	if err != nil {
+		zap.L().Error("set_http_config",
+			zap.String("message", "failed to set http config"),
+			zap.String("provider", provider),
+			zap.Error(err),
+		)
+	}
+	return err
+}
+
+func GetProject(name string) []byte {
+	var project []byte
+
+	err := db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte("project:" + name))
+		if err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return nil
+			}
+			return err
+		}
+		project, err = item.ValueCopy(nil)
+		return err
+	})
+
+	if err != nil {
+		zap.L().Error("get_project",
+			zap.String("message", "failed to get project"),
+			zap.String("project", name),
+			zap.Error(err),
+		)
+	}
+
+	return project
+}
+
+// GetProjects returns every stored project keyed by name
+func GetProjects() map[string][]byte {
+	projects := make(map[string][]byte)
+
+	err := db.View(func(txn *badger.Txn) error {
+		prefix := []byte("project:")
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			value, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			projects[strings.TrimPrefix(string(item.Key()), string(prefix))] = value
+		}
+		return nil
+	})
+
+	if err != nil {
+		zap.L().Error("get_projects",
+			zap.String("message", "failed to get projects"),
+			zap.Error(err),
+		)
+	}
+
+	return projects
+}
+
+func StoreProject(name string, project []byte) error {
+	err := db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte("project:"+name), project)
+	})
+	if err != nil {
+		zap.L().Error("set_project",
+			zap.String("message", "failed to set project"),
+			zap.String("project", name),
+			zap.Error(err),
+		)
+	}
+	return err
+}
+
+func DeleteProject(name string) error {
+	err := db.Update(func(txn *badger.Txn) error {
+		return txn.Delete([]byte("project:" + name))
+	})
+	if err != nil {
+		zap.L().Error("delete_project",
+			zap.String("message", "failed to delete project"),
+			zap.String("project", name),
+			zap.Error(err),
+		)
+	}
+	return err
+}
+
+func GetActiveProject() string {
+	var name string
+
+	err := db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte("cfg:active_project"))
+		if err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return nil
+			}
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			name = string(val)
+			return nil
+		})
+	})
+
+	if err != nil {
+		zap.L().Error("get_active_project",
+			zap.String("message", "failed to get active_project"),
+			zap.Error(err),
+		)
+	}
+
+	return name
+}
+
+// StoreActiveProject sets the project every command uses, an empty name clears it
+func StoreActiveProject(name string) error {
+	err := db.Update(func(txn *badger.Txn) error {
+		if name == "" {
+			return txn.Delete([]byte("cfg:active_project"))
+		}
+		return txn.Set([]byte("cfg:active_project"), []byte(name))
+	})
+	if err != nil {
+		zap.L().Error("set_active_project",
+			zap.String("message", "failed to set active_project"),
+			zap.Error(err),
+		)
+	}
+	return err
+}
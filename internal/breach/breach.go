@@ -0,0 +1,325 @@
+package breach
+
+import (
+	"bufio"
+	"crowsnest/internal/sqlite"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Formats lists the supported dump formats
+var Formats = []string{"combo", "csv", "json"}
+
+// BatchSize is the number of records parsed before they are stored
+const BatchSize = 1000
+
+// Stats counts what an import read and stored
+type Stats struct {
+	Records int // Records parsed from the file
+	Creds   int // Credentials extracted from the records
+	Skipped int // Lines or objects that could not be parsed
+	Failed  int // Records of batches that could not be stored
+}
+
+// fields maps the column and key names used by dumps to the dehashed fields they fill
+var fields = map[string]string{}
+
+func init() {
+	for field, names := range map[string][]string{
+		"email":           {"email", "mail", "email_address"},
+		"username":        {"username", "user", "login"},
+		"password":        {"password", "pass", "plaintext"},
+		"hashed_password": {"hashed_password", "hash", "password_hash"},
+		"hash_type":       {"hash_type"},
+		"name":            {"name", "full_name"},
+		"ip_address":      {"ip_address", "ip"},
+		"phone":           {"phone"},
+		"address":         {"address"},
+		"company":         {"company"},
+		"url":             {"url"},
+		"vin":             {"vin"},
+		"social":          {"social"},
+		"license_plate":   {"license_plate"},
+	} {
+		for _, name := range names {
+			fields[name] = field
+		}
+	}
+}
+
+// Import streams a breach dump into the dehashed and creds tables in batches, with the source as database name.
+// Record IDs are derived from the source and the record so importing the same file again stores nothing new.
+// Batches that fail to store are logged and counted in Stats.Failed, the import carries on with the next one.
+func Import(path, format, source string) (Stats, error) {
+	var stats Stats
+	f, err := os.Open(path)
+	if err != nil {
+		return stats, err
+	}
+	defer f.Close()
+
+	var batch []sqlite.Result
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		results := sqlite.DehashedResults{Results: batch}
+		err := sqlite.StoreDehashedResults(results)
+		creds := results.ExtractUsers()
+		if credsErr := sqlite.StoreUsers(creds); credsErr != nil {
+			err = credsErr
+		}
+		if err != nil {
+			// A failed batch is counted and the import carries on with the rest of the file
+			zap.L().Warn("import_breach",
+				zap.String("message", "failed to store batch"),
+				zap.Int("records", len(batch)),
+				zap.Error(err),
+			)
+			stats.Failed += len(batch)
+		} else {
+			stats.Records += len(batch)
+			stats.Creds += len(creds)
+		}
+		batch = nil
+	}
+	add := func(r sqlite.Result) {
+		if !hasData(r) {
+			stats.Skipped++
+			return
+		}
+		r.DatabaseName = source
+		r.DehashedId = recordID(source, r)
+		batch = append(batch, r)
+		if len(batch) >= BatchSize {
+			flush()
+		}
+	}
+
+	switch format {
+	case "combo":
+		err = readCombo(f, add, &stats)
+	case "csv":
+		err = readCSV(f, add, &stats)
+	case "json":
+		err = readJSON(f, add, &stats)
+	default:
+		return stats, fmt.Errorf("unknown format %q (%s)", format, strings.Join(Formats, ", "))
+	}
+	if err != nil {
+		return stats, err
+	}
+	flush()
+	return stats, nil
+}
+
+// readCombo reads user:password lines, also accepting ; as separator. Users with an @ are emails.
+func readCombo(r io.Reader, add func(sqlite.Result), stats *Stats) error {
+	scanner := bufio.NewScanner(skipBOM(r))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		sep := strings.IndexAny(line, ":;")
+		if sep <= 0 {
+			stats.Skipped++
+			continue
+		}
+		user, password := strings.TrimSpace(line[:sep]), line[sep+1:]
+		var record sqlite.Result
+		if strings.Contains(user, "@") {
+			record.Email = []string{strings.ToLower(user)}
+		} else {
+			record.Username = []string{user}
+		}
+		record.Password = []string{password}
+		add(record)
+	}
+	return scanner.Err()
+}
+
+// readCSV reads a CSV file whose header names the columns, e.g. email,password,hash
+func readCSV(r io.Reader, add func(sqlite.Result), stats *Stats) error {
+	reader := csv.NewReader(skipBOM(r))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	columns := make([]string, len(header))
+	known := false
+	for i, h := range header {
+		columns[i] = fields[strings.ToLower(strings.TrimSpace(h))]
+		known = known || columns[i] != ""
+	}
+	if !known {
+		return fmt.Errorf("CSV header has no known columns (%s)", strings.Join(header, ", "))
+	}
+
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			zap.L().Warn("import_breach", zap.String("message", "skipping unreadable CSV row"), zap.Error(err))
+			stats.Skipped++
+			continue
+		}
+		var record sqlite.Result
+		for i, value := range row {
+			if i < len(columns) && columns[i] != "" && value != "" {
+				setField(&record, columns[i], []string{value})
+			}
+		}
+		add(record)
+	}
+}
+
+// readJSON reads a JSON array of objects or one object per line. Values may be strings or arrays of strings.
+func readJSON(r io.Reader, add func(sqlite.Result), stats *Stats) error {
+	reader := bufio.NewReader(skipBOM(r))
+	decoder := json.NewDecoder(reader)
+	array := false
+	if b, err := peekNonSpace(reader); err == nil && b == '[' {
+		if _, err = decoder.Token(); err != nil {
+			return err
+		}
+		array = true
+	}
+
+	decoded := 0
+	for array && decoder.More() || !array {
+		var object map[string]interface{}
+		err := decoder.Decode(&object)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			// A malformed object cannot be skipped reliably in a stream
+			return fmt.Errorf("failed to decode JSON after %d records: %w", decoded, err)
+		}
+		// Keys are applied in order so the record, and its ID, are the same on every import
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var record sqlite.Result
+		for _, key := range keys {
+			if field := fields[strings.ToLower(key)]; field != "" {
+				setField(&record, field, toStrings(object[key]))
+			}
+		}
+		add(record)
+		decoded++
+	}
+	return nil
+}
+
+func setField(r *sqlite.Result, field string, values []string) {
+	switch field {
+	case "email":
+		for _, v := range values {
+			r.Email = append(r.Email, strings.ToLower(strings.TrimSpace(v)))
+		}
+	case "username":
+		r.Username = append(r.Username, values...)
+	case "password":
+		r.Password = append(r.Password, values...)
+	case "hashed_password":
+		r.HashedPassword = append(r.HashedPassword, values...)
+	case "hash_type":
+		if len(values) > 0 {
+			r.HashType = values[0]
+		}
+	case "name":
+		r.Name = append(r.Name, values...)
+	case "ip_address":
+		r.IpAddress = append(r.IpAddress, values...)
+	case "phone":
+		r.Phone = append(r.Phone, values...)
+	case "address":
+		r.Address = append(r.Address, values...)
+	case "company":
+		r.Company = append(r.Company, values...)
+	case "url":
+		r.Url = append(r.Url, values...)
+	case "vin":
+		r.Vin = append(r.Vin, values...)
+	case "social":
+		r.Social = append(r.Social, values...)
+	case "license_plate":
+		r.LicensePlate = append(r.LicensePlate, values...)
+	}
+}
+
+func toStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return nil
+		}
+		return []string{v}
+	case []interface{}:
+		var out []string
+		for _, item := range v {
+			out = append(out, toStrings(item)...)
+		}
+		return out
+	case nil:
+		return nil
+	default:
+		return []string{fmt.Sprint(v)}
+	}
+}
+
+// hasData reports whether the record identifies an account
+func hasData(r sqlite.Result) bool {
+	return len(r.Email) > 0 || len(r.Username) > 0 || len(r.Name) > 0 || len(r.IpAddress) > 0 || len(r.Phone) > 0
+}
+
+// recordID derives a stable ID for a record of a source
+func recordID(source string, r sqlite.Result) string {
+	data, _ := json.Marshal(r)
+	sum := sha256.Sum256(append([]byte(source+"\x00"), data...))
+	return "import-" + hex.EncodeToString(sum[:12])
+}
+
+// skipBOM drops the UTF-8 byte order mark spreadsheet tools write at the start of exported files
+func skipBOM(r io.Reader) io.Reader {
+	reader := bufio.NewReader(r)
+	if b, err := reader.Peek(3); err == nil && string(b) == "\xef\xbb\xbf" {
+		_, _ = reader.Discard(3)
+	}
+	return reader
+}
+
+func peekNonSpace(r *bufio.Reader) (byte, error) {
+	for {
+		b, err := r.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		if b[0] != ' ' && b[0] != '\t' && b[0] != '\r' && b[0] != '\n' {
+			return b[0], nil
+		}
+		if _, err = r.ReadByte(); err != nil {
+			return 0, err
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package breach
+
+import (
+	"crowsnest/internal/sqlite"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// read runs a reader over the input and returns the records it added, summarized by describe
+func read(t *testing.T, reader func(io.Reader, func(sqlite.Result), *Stats) error, input string) ([]string, Stats, error) {
+	t.Helper()
+	var (
+		stats   Stats
+		records []string
+	)
+	err := reader(strings.NewReader(input), func(r sqlite.Result) {
+		records = append(records, describe(r))
+	}, &stats)
+	return records, stats, err
+}
+
+func describe(r sqlite.Result) string {
+	var parts []string
+	for _, f := range []struct {
+		name   string
+		values []string
+	}{
+		{"email", r.Email}, {"user", r.Username}, {"pass", r.Password}, {"hash", r.HashedPassword}, {"name", r.Name}, {"ip", r.IpAddress},
+	} {
+		if len(f.values) > 0 {
+			parts = append(parts, f.name+"="+strings.Join(f.values, ","))
+		}
+	}
+	if r.HashType != "" {
+		parts = append(parts, "type="+r.HashType)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestReadCombo(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		skipped int
+	}{
+		{
+			name:  "emails and usernames",
+			input: "Jane@Acme.com:Summer2024!\nadmin:hunter2\n",
+			want:  []string{"email=jane@acme.com pass=Summer2024!", "user=admin pass=hunter2"},
+		},
+		{
+			name:  "semicolon and colons in password",
+			input: "bob@acme.com;pa:ss\r\njoe:a;b:c",
+			want:  []string{"email=bob@acme.com pass=pa:ss", "user=joe pass=a;b:c"},
+		},
+		{
+			name:    "blank and malformed lines",
+			input:   "\n  \nnoseparator\n:nouser\nok:pw",
+			want:    []string{"user=ok pass=pw"},
+			skipped: 2,
+		},
+		{
+			name:  "byte order mark",
+			input: "\xef\xbb\xbfjane@acme.com:pw",
+			want:  []string{"email=jane@acme.com pass=pw"},
+		},
+		{
+			name:  "empty password",
+			input: "jane@acme.com:",
+			want:  []string{"email=jane@acme.com pass="},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, stats, err := read(t, readCombo, tt.input)
+			if err != nil {
+				t.Fatalf("readCombo() error = %v", err)
+			}
+			if !slices.Equal(got, tt.want) || stats.Skipped != tt.skipped {
+				t.Errorf("readCombo() = %q, %d skipped, want %q, %d skipped", got, stats.Skipped, tt.want, tt.skipped)
+			}
+		})
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		skipped int
+		err     string
+	}{
+		{
+			name:  "known columns",
+			input: "Email,Password,Hash,Hash_Type,Notes\nJane@Acme.com,pw,5f4dcc3b5aa765d61d8327deb882cf99,md5,vip\n",
+			want:  []string{"email=jane@acme.com pass=pw hash=5f4dcc3b5aa765d61d8327deb882cf99 type=md5"},
+		},
+		{
+			name:  "column aliases and short rows",
+			input: "login,full_name,ip\nadmin,Jane Doe,10.0.0.1\nroot\n",
+			want:  []string{"user=admin name=Jane Doe ip=10.0.0.1", "user=root"},
+		},
+		{
+			name:  "quoted values",
+			input: "email,password\n\"jane@acme.com\",\"a,b\"\"c\"\n",
+			want:  []string{`email=jane@acme.com pass=a,b"c`},
+		},
+		{
+			name:  "byte order mark",
+			input: "\xef\xbb\xbfemail,password\njane@acme.com,pw\n",
+			want:  []string{"email=jane@acme.com pass=pw"},
+		},
+		{
+			name:  "empty values left out",
+			input: "email,password\njane@acme.com,\n",
+			want:  []string{"email=jane@acme.com"},
+		},
+		{
+			name:  "unknown header",
+			input: "foo,bar\n1,2\n",
+			err:   "no known columns",
+		},
+		{
+			name:  "empty file",
+			input: "",
+			err:   "failed to read CSV header",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, stats, err := read(t, readCSV, tt.input)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("readCSV() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readCSV() error = %v", err)
+			}
+			if !slices.Equal(got, tt.want) || stats.Skipped != tt.skipped {
+				t.Errorf("readCSV() = %q, %d skipped, want %q, %d skipped", got, stats.Skipped, tt.want, tt.skipped)
+			}
+		})
+	}
+}
+
+func TestReadJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+		err   string
+	}{
+		{
+			name:  "array",
+			input: ` [{"email":"Jane@Acme.com","password":"pw"},{"user":"admin","ip":["10.0.0.1","10.0.0.2"]}]`,
+			want:  []string{"email=jane@acme.com pass=pw", "user=admin ip=10.0.0.1,10.0.0.2"},
+		},
+		{
+			name:  "lines with byte order mark",
+			input: "\xef\xbb\xbf{\"email\":\"jane@acme.com\"}\n{\"username\":\"bob\",\"phone\":5551234}\n",
+			want:  []string{"email=jane@acme.com", "user=bob"},
+		},
+		{
+			name:  "malformed",
+			input: `{"email":"jane@acme.com"} {"email":`,
+			err:   "failed to decode JSON after 1 records",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := read(t, readJSON, tt.input)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("readJSON() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readJSON() error = %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("readJSON() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordID(t *testing.T) {
+	a := sqlite.Result{Email: []string{"jane@acme.com"}, Password: []string{"pw"}}
+	b := sqlite.Result{Email: []string{"jane@acme.com"}, Password: []string{"pw2"}}
+	if recordID("dump", a) != recordID("dump", a) {
+		t.Error("recordID() differs for the same record")
+	}
+	if recordID("dump", a) == recordID("dump", b) || recordID("dump", a) == recordID("other", a) {
+		t.Error("recordID() is the same for different records or sources")
+	}
+	if id := recordID("dump", a); !strings.HasPrefix(id, "import-") || len(id) != len("import-")+24 {
+		t.Errorf("recordID() = %q, want import- and 24 hex characters", id)
+	}
+}
@@ -0,0 +1,133 @@
+package budget
+
+import (
+	"crowsnest/internal/debug"
+	"crowsnest/internal/httpclient"
+	"crowsnest/internal/sqlite"
+	"fmt"
+	"go.uber.org/zap"
+	"sync"
+)
+
+// Budget enforces the per-run credit cap, the stored project budget and the provider balance
+type Budget struct {
+	maxCredits int
+	debug      bool
+
+	mu       sync.Mutex
+	spent    map[httpclient.Provider]int
+	reserved map[httpclient.Provider]int // Credits held by requests in flight
+	balance  map[httpclient.Provider]int
+}
+
+// NewBudget creates a new Budget, maxCredits caps the credits spent per provider in this run (0 disables)
+func NewBudget(maxCredits int, debug bool) *Budget {
+	return &Budget{
+		maxCredits: maxCredits,
+		debug:      debug,
+		spent:      make(map[httpclient.Provider]int),
+		reserved:   make(map[httpclient.Provider]int),
+		balance:    make(map[httpclient.Provider]int),
+	}
+}
+
+// Active reports whether any limit applies to the provider, so callers know to fetch its balance
+func (b *Budget) Active(provider httpclient.Provider) bool {
+	if b.maxCredits > 0 {
+		return true
+	}
+	stored, err := sqlite.GetBudget(string(provider))
+	return err == nil && stored.CreditLimit > 0
+}
+
+// Allow returns httpclient.ErrBudgetExceeded when spending credits would exceed any limit,
+// otherwise the credits are reserved until the request is charged or released
+func (b *Budget) Allow(provider httpclient.Provider, operation string, credits int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.debug {
+		debug.PrintInfo(fmt.Sprintf("checking budget for %s (%d credits)", operation, credits))
+	}
+
+	spent := b.spent[provider] + b.reserved[provider]
+	if b.maxCredits > 0 && spent+credits > b.maxCredits {
+		return fmt.Errorf("%w: %s needs %d credits, %d of %d already spent this run",
+			httpclient.ErrBudgetExceeded, operation, credits, spent, b.maxCredits)
+	}
+
+	stored, err := sqlite.GetBudget(string(provider))
+	if err != nil {
+		zap.L().Error("get_budget",
+			zap.String("message", "failed to load stored budget"),
+			zap.String("provider", string(provider)),
+			zap.Error(err),
+		)
+	} else if remaining := stored.Remaining(); remaining >= 0 && credits > remaining-b.reserved[provider] {
+		return fmt.Errorf("%w: %s needs %d credits, %d left in the %s project budget",
+			httpclient.ErrBudgetExceeded, operation, credits, remaining-b.reserved[provider], provider)
+	}
+
+	if balance, ok := b.balance[provider]; ok && credits > balance-b.reserved[provider] {
+		return fmt.Errorf("%w: %s needs %d credits, %d left on the %s account",
+			httpclient.ErrBudgetExceeded, operation, credits, balance-b.reserved[provider], provider)
+	}
+
+	b.reserved[provider] += credits
+	return nil
+}
+
+// Spend records the credits spent by a successful request
+func (b *Budget) Spend(provider httpclient.Provider, operation string, credits int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.release(provider, credits)
+	b.spent[provider] += credits
+	if balance, ok := b.balance[provider]; ok {
+		b.balance[provider] = balance - credits
+	}
+
+	err := sqlite.AddBudgetSpend(string(provider), credits)
+	if err != nil {
+		zap.L().Error("store_budget_spend",
+			zap.String("message", "failed to store credits spent"),
+			zap.String("provider", string(provider)),
+			zap.Error(err),
+		)
+	}
+	zap.L().Info("credits_spent",
+		zap.String("provider", string(provider)),
+		zap.String("operation", operation),
+		zap.Int("credits", credits),
+		zap.Int("run_total", b.spent[provider]),
+	)
+}
+
+// Release returns the credits reserved by a request that failed
+func (b *Budget) Release(provider httpclient.Provider, operation string, credits int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.release(provider, credits)
+}
+
+func (b *Budget) release(provider httpclient.Provider, credits int) {
+	b.reserved[provider] -= credits
+	if b.reserved[provider] < 0 {
+		b.reserved[provider] = 0
+	}
+}
+
+// SetBalance records the balance last reported by the provider
+func (b *Budget) SetBalance(provider httpclient.Provider, balance int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.balance[provider] = balance
+}
+
+// Spent returns the credits spent against the provider in this run
+func (b *Budget) Spent(provider httpclient.Provider) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.spent[provider]
+}
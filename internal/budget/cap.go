@@ -0,0 +1,105 @@
+package budget
+
+import (
+	"crowsnest/internal/httpclient"
+	"fmt"
+	"sync"
+)
+
+// Cap limits the credits spent across every provider between resets, on top of another guard.
+// Playbooks reset it before each step to enforce the step's credit cap.
+type Cap struct {
+	guard httpclient.Guard
+
+	mu       sync.Mutex
+	limit    int
+	spent    int
+	reserved int
+	reached  bool
+}
+
+// NewCap creates a new Cap in front of guard, without a limit until Reset sets one
+func NewCap(guard httpclient.Guard) *Cap {
+	return &Cap{guard: guard}
+}
+
+// Reset starts counting again from zero with a new limit (0 disables)
+func (c *Cap) Reset(limit int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.limit = limit
+	c.spent = 0
+	c.reserved = 0
+	c.reached = false
+}
+
+// Spent returns the credits spent since the last reset
+func (c *Cap) Spent() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.spent
+}
+
+// Reached reports whether a request was refused by the cap since the last reset
+func (c *Cap) Reached() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.reached
+}
+
+// Allow returns httpclient.ErrBudgetExceeded when spending credits would exceed the cap, otherwise it defers to
+// the guard behind it
+func (c *Cap) Allow(provider httpclient.Provider, operation string, credits int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.limit > 0 && c.spent+c.reserved+credits > c.limit {
+		c.reached = true
+		return fmt.Errorf("%w: %s needs %d credits, %d of the %d credit step cap already spent",
+			httpclient.ErrBudgetExceeded, operation, credits, c.spent+c.reserved, c.limit)
+	}
+	if c.guard != nil {
+		if err := c.guard.Allow(provider, operation, credits); err != nil {
+			return err
+		}
+	}
+	c.reserved += credits
+	return nil
+}
+
+// Spend records the credits spent by a successful request
+func (c *Cap) Spend(provider httpclient.Provider, operation string, credits int) {
+	c.mu.Lock()
+	c.release(credits)
+	c.spent += credits
+	c.mu.Unlock()
+
+	if c.guard != nil {
+		c.guard.Spend(provider, operation, credits)
+	}
+}
+
+// Release returns the credits reserved by a request that failed
+func (c *Cap) Release(provider httpclient.Provider, operation string, credits int) {
+	c.mu.Lock()
+	c.release(credits)
+	c.mu.Unlock()
+
+	if c.guard != nil {
+		c.guard.Release(provider, operation, credits)
+	}
+}
+
+func (c *Cap) release(credits int) {
+	c.reserved -= credits
+	if c.reserved < 0 {
+		c.reserved = 0
+	}
+}
+
+// SetBalance passes the balance reported by the provider to the guard
+func (c *Cap) SetBalance(provider httpclient.Provider, balance int) {
+	if c.guard != nil {
+		c.guard.SetBalance(provider, balance)
+	}
+}
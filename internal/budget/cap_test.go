@@ -0,0 +1,143 @@
+package budget
+
+import (
+	"crowsnest/internal/httpclient"
+	"errors"
+	"testing"
+)
+
+// fakeGuard records the credits passed through the cap and refuses requests over its balance
+type fakeGuard struct {
+	balance  int
+	spent    int
+	released int
+}
+
+func (g *fakeGuard) Allow(_ httpclient.Provider, _ string, credits int) error {
+	if g.balance >= 0 && credits > g.balance-g.spent {
+		return errors.New("guard refused")
+	}
+	return nil
+}
+
+func (g *fakeGuard) Spend(_ httpclient.Provider, _ string, credits int)   { g.spent += credits }
+func (g *fakeGuard) Release(_ httpclient.Provider, _ string, credits int) { g.released += credits }
+func (g *fakeGuard) SetBalance(_ httpclient.Provider, balance int)        { g.balance = balance }
+
+func TestCap(t *testing.T) {
+	type call struct {
+		op      string // allow, spend or release
+		credits int
+		err     string // "budget" for ErrBudgetExceeded, "guard" for the guard refusing
+	}
+	tests := []struct {
+		name    string
+		limit   int
+		balance int // -1 for a guard without a balance
+		calls   []call
+		spent   int
+		reached bool
+		guard   int // Credits the guard recorded as spent
+	}{
+		{
+			name:    "no limit",
+			balance: -1,
+			calls:   []call{{"allow", 500, ""}, {"spend", 500, ""}, {"allow", 500, ""}, {"spend", 500, ""}},
+			spent:   1000,
+			guard:   1000,
+		},
+		{
+			name:    "within limit",
+			limit:   10,
+			balance: -1,
+			calls:   []call{{"allow", 4, ""}, {"spend", 4, ""}, {"allow", 6, ""}, {"spend", 6, ""}},
+			spent:   10,
+			guard:   10,
+		},
+		{
+			name:    "over limit",
+			limit:   10,
+			balance: -1,
+			calls:   []call{{"allow", 6, ""}, {"spend", 6, ""}, {"allow", 5, "budget"}},
+			spent:   6,
+			reached: true,
+			guard:   6,
+		},
+		{
+			name:    "reservations count against the limit",
+			limit:   10,
+			balance: -1,
+			calls:   []call{{"allow", 6, ""}, {"allow", 6, "budget"}, {"release", 6, ""}, {"allow", 6, ""}, {"spend", 6, ""}},
+			spent:   6,
+			reached: true,
+			guard:   6,
+		},
+		{
+			name:    "released credits are not spent",
+			limit:   10,
+			balance: -1,
+			calls:   []call{{"allow", 8, ""}, {"release", 8, ""}, {"release", 8, ""}, {"allow", 10, ""}},
+			spent:   0,
+		},
+		{
+			name:    "guard refuses",
+			limit:   10,
+			balance: 3,
+			calls:   []call{{"allow", 4, "guard"}, {"allow", 3, ""}, {"spend", 3, ""}},
+			spent:   3,
+			guard:   3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			guard := &fakeGuard{balance: tt.balance}
+			c := NewCap(guard)
+			c.Reset(tt.limit)
+
+			for i, call := range tt.calls {
+				switch call.op {
+				case "allow":
+					err := c.Allow(httpclient.Dehashed, "search", call.credits)
+					switch call.err {
+					case "":
+						if err != nil {
+							t.Fatalf("call %d: Allow(%d) error = %v", i, call.credits, err)
+						}
+					case "budget":
+						if !errors.Is(err, httpclient.ErrBudgetExceeded) {
+							t.Fatalf("call %d: Allow(%d) error = %v, want ErrBudgetExceeded", i, call.credits, err)
+						}
+					default:
+						if err == nil || errors.Is(err, httpclient.ErrBudgetExceeded) {
+							t.Fatalf("call %d: Allow(%d) error = %v, want the guard's error", i, call.credits, err)
+						}
+					}
+				case "spend":
+					c.Spend(httpclient.Dehashed, "search", call.credits)
+				case "release":
+					c.Release(httpclient.Dehashed, "search", call.credits)
+				}
+			}
+
+			if c.Spent() != tt.spent || c.Reached() != tt.reached || guard.spent != tt.guard {
+				t.Errorf("spent %d, reached %v, guard spent %d, want %d, %v, %d",
+					c.Spent(), c.Reached(), guard.spent, tt.spent, tt.reached, tt.guard)
+			}
+
+			c.Reset(tt.limit)
+			if c.Spent() != 0 || c.Reached() {
+				t.Errorf("after Reset spent %d, reached %v, want 0, false", c.Spent(), c.Reached())
+			}
+		})
+	}
+}
+
+func TestCapSetBalance(t *testing.T) {
+	guard := &fakeGuard{balance: -1}
+	NewCap(guard).SetBalance(httpclient.Hunter, 42)
+	if guard.balance != 42 {
+		t.Errorf("guard balance = %d, want 42", guard.balance)
+	}
+	NewCap(nil).SetBalance(httpclient.Hunter, 42)
+}
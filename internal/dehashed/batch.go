@@ -0,0 +1,196 @@
+package dehashed
+
+import (
+	"bufio"
+	"crowsnest/internal/sqlite"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// BatchQuery is a single query read from a batch input file
+type BatchQuery struct {
+	Line   int
+	Fields map[DehashedParameter]string
+}
+
+// String returns the query fields in a stable order for progress output
+func (bq BatchQuery) String() string {
+	var parts []string
+	for param, value := range bq.Fields {
+		parts = append(parts, param.GetArgumentString(value))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, " & ")
+}
+
+// Apply sets the query fields on the query options
+func (bq BatchQuery) Apply(options *sqlite.QueryOptions) {
+	for param, value := range bq.Fields {
+		SetQueryField(options, param, value)
+	}
+}
+
+// GetDehashedParameter returns the search field matching the user input, accepting the command flag names
+func GetDehashedParameter(userInput string) (DehashedParameter, error) {
+	switch strings.ToLower(strings.TrimSpace(userInput)) {
+	case "username", "user":
+		return Username, nil
+	case "email", "email_query":
+		return Email, nil
+	case "password", "pass":
+		return Password, nil
+	case "hashed_password", "hash":
+		return HashedPassword, nil
+	case "name":
+		return Name, nil
+	case "ip_address", "ip":
+		return IpAddress, nil
+	case "domain":
+		return Domain, nil
+	case "vin":
+		return Vin, nil
+	case "license_plate", "license":
+		return LicensePlate, nil
+	case "address":
+		return Address, nil
+	case "phone":
+		return Phone, nil
+	case "social":
+		return Social, nil
+	case "cryptocurrency_address", "crypto":
+		return CryptoAddress, nil
+	default:
+		return "", fmt.Errorf("unknown field '%s'", userInput)
+	}
+}
+
+// SetQueryField sets the query options field for the search parameter
+func SetQueryField(options *sqlite.QueryOptions, param DehashedParameter, value string) {
+	switch param {
+	case Username:
+		options.UsernameQuery = value
+	case Email:
+		options.EmailQuery = value
+	case Password:
+		options.PassQuery = value
+	case HashedPassword:
+		options.HashQuery = value
+	case Name:
+		options.NameQuery = value
+	case IpAddress:
+		options.IpQuery = value
+	case Domain:
+		options.DomainQuery = value
+	case Vin:
+		options.VinQuery = value
+	case LicensePlate:
+		options.LicensePlateQuery = value
+	case Address:
+		options.AddressQuery = value
+	case Phone:
+		options.PhoneQuery = value
+	case Social:
+		options.SocialQuery = value
+	case CryptoAddress:
+		options.CryptoAddressQuery = value
+	}
+}
+
+// ReadBatchInput reads one query per line of a text file, searching each value in the given field
+func ReadBatchInput(path, field string) ([]BatchQuery, error) {
+	param, err := GetDehashedParameter(field)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var (
+		queries []BatchQuery
+		line    int
+	)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line++
+		value := strings.TrimSpace(scanner.Text())
+		if value == "" || strings.HasPrefix(value, "#") {
+			continue
+		}
+		queries = append(queries, BatchQuery{Line: line, Fields: map[DehashedParameter]string{param: value}})
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(queries) == 0 {
+		return nil, errors.New("no queries found in input file")
+	}
+	return queries, nil
+}
+
+// ReadBatchCSV reads one query per row of a CSV file whose header row names the field of each column.
+// Values in the same row are combined into a single query.
+func ReadBatchCSV(path string) ([]BatchQuery, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+
+	columns := make([]DehashedParameter, len(header))
+	for i, name := range header {
+		columns[i], err = GetDehashedParameter(name)
+		if err != nil {
+			return nil, fmt.Errorf("column %d: %w", i+1, err)
+		}
+	}
+
+	var queries []BatchQuery
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		fields := make(map[DehashedParameter]string)
+		for i, value := range record {
+			value = strings.TrimSpace(value)
+			if i >= len(columns) || value == "" {
+				continue
+			}
+			fields[columns[i]] = value
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		queries = append(queries, BatchQuery{Line: line, Fields: fields})
+	}
+
+	if len(queries) == 0 {
+		return nil, errors.New("no queries found in input file")
+	}
+	return queries, nil
+}
@@ -3,6 +3,7 @@ package dehashed
 import (
 	"bytes"
 	"crowsnest/internal/debug"
+	"crowsnest/internal/httpclient"
 	"crowsnest/internal/sqlite"
 	"crypto/sha256"
 	"encoding/hex"
@@ -15,6 +16,12 @@ import (
 	"strings"
 )
 
+// Dehashed search operation and its credit cost per request
+const (
+	OpSearch   = "dehashed_search"
+	SearchCost = 1
+)
+
 type DehashedParameter string
 
 const (
@@ -151,12 +158,18 @@ func (dsr *DehashedSearchRequest) AddNameQuery(query string) {
 
 type DehashedClientV2 struct {
 	apiKey  string
+	runID   uint
 	results []sqlite.Result
 	debug   bool
+	client  *httpclient.Client
 }
 
-func NewDehashedClientV2(apiKey string, debug bool) *DehashedClientV2 {
-	return &DehashedClientV2{apiKey: apiKey, debug: debug}
+// NewDehashedClientV2 creates a search client, falling back to the public Dehashed API when client is nil
+func NewDehashedClientV2(apiKey string, client *httpclient.Client, debug bool) *DehashedClientV2 {
+	if client == nil {
+		client = httpclient.Default(httpclient.Dehashed, debug)
+	}
+	return &DehashedClientV2{apiKey: apiKey, debug: debug, client: client}
 }
 
 func (dcv2 *DehashedClientV2) Search(searchRequest DehashedSearchRequest) (int, int, error) {
@@ -182,7 +195,7 @@ func (dcv2 *DehashedClientV2) Search(searchRequest DehashedSearchRequest) (int,
 		)
 	}
 
-	req, err := http.NewRequest("POST", "https://api.dehashed.com/v2/search", bytes.NewReader(reqBody))
+	req, err := http.NewRequest("POST", dcv2.client.URL("/v2/search"), bytes.NewReader(reqBody))
 	if err != nil {
 		return -1, -1, err
 	}
@@ -212,7 +225,7 @@ func (dcv2 *DehashedClientV2) Search(searchRequest DehashedSearchRequest) (int,
 		)
 	}
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := dcv2.client.Do(httpclient.WithRunID(httpclient.WithCost(req, OpSearch, SearchCost), dcv2.runID))
 	if res != nil {
 		defer res.Body.Close()
 	}
@@ -285,6 +298,7 @@ func (dcv2 *DehashedClientV2) Search(searchRequest DehashedSearchRequest) (int,
 		debug.PrintJson(fmt.Sprintf("Entries: %d\n", len(responseResults.Entries)))
 	}
 
+	dcv2.client.SetBalance(responseResults.Balance)
 	dcv2.results = append(dcv2.results, responseResults.Entries...)
 	return responseResults.TotalResults, responseResults.Balance, nil
 }
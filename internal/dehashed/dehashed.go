@@ -3,41 +3,83 @@ package dehashed
 import (
 	"crowsnest/internal/debug"
 	"crowsnest/internal/export"
+	"crowsnest/internal/httpclient"
 	"crowsnest/internal/pretty"
 	"crowsnest/internal/sqlite"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"go.uber.org/zap"
+	"io"
 	"os"
 	"strings"
 )
 
+// Maximum number of records the Dehashed API returns per page
+const maxPageSize = 10000
+
+// ErrZeroRequests is returned for a run that is not allowed a single request
+var ErrZeroRequests = errors.New("max requests cannot be zero")
+
 // Dehasher is a struct for querying the Dehashed API
 type Dehasher struct {
-	options  sqlite.QueryOptions
-	nextPage int
-	debug    bool
-	balance  int
-	request  *DehashedSearchRequest
-	client   *DehashedClientV2
+	options   sqlite.QueryOptions
+	run       *sqlite.QueryOptions
+	firstPage int // Page this invocation starts at, the request cap counts from it
+	nextPage  int
+	debug     bool
+	balance   int
+	request   *DehashedSearchRequest
+	client    *DehashedClientV2
+	batch     bool
+	out       io.Writer
 }
 
-// NewDehasher creates a new Dehasher
-func NewDehasher(options *sqlite.QueryOptions) *Dehasher {
+// NewDehasher creates a new Dehasher, continuing after the last checkpointed page of a stored run
+func NewDehasher(options *sqlite.QueryOptions) (*Dehasher, error) {
 	dh := &Dehasher{
-		options:  *options,
-		nextPage: options.StartingPage + 1,
-		debug:    options.Debug,
-		balance:  0,
-	}
-	dh.setQueries()
-	dh.request = NewDehashedSearchRequest(dh.options.StartingPage, dh.options.MaxRecords, dh.options.WildcardMatch, dh.options.RegexMatch, false, options.Debug)
-	dh.buildRequest()
-	return dh
+		options: *options,
+		run:     options,
+		debug:   options.Debug,
+		balance: 0,
+		out:     os.Stdout,
+	}
+	if err := dh.setQueries(); err != nil {
+		return nil, err
+	}
+
+	page := dh.options.StartingPage
+	if options.LastPage >= page {
+		page = options.LastPage + 1
+	}
+	dh.firstPage = page
+	dh.nextPage = page + 1
+
+	dh.request = NewDehashedSearchRequest(page, dh.options.MaxRecords, dh.options.WildcardMatch, dh.options.RegexMatch, false, options.Debug)
+	if err := dh.buildRequest(); err != nil {
+		return nil, err
+	}
+	return dh, nil
+}
+
+// SetClientCredentials sets the client credentials and http client for the dehasher
+func (dh *Dehasher) SetClientCredentials(key string, client *httpclient.Client) {
+	dh.client = NewDehashedClientV2(key, client, dh.debug)
+}
+
+// SetBatchMode stores results without writing per-query output, so a batch can write one consolidated file
+func (dh *Dehasher) SetBatchMode(batch bool) {
+	dh.batch = batch
+}
+
+// SetOutput sets where progress is printed, batches buffer it per query so concurrent queries do not interleave
+func (dh *Dehasher) SetOutput(out io.Writer) {
+	dh.out = out
 }
 
-// SetClientCredentials sets the client credentials for the dehasher
-func (dh *Dehasher) SetClientCredentials(key string) {
-	dh.client = NewDehashedClientV2(key, dh.debug)
+// GetResults returns the results retrieved so far
+func (dh *Dehasher) GetResults() sqlite.DehashedResults {
+	return dh.client.GetResults()
 }
 
 func (dh *Dehasher) getNextPage() int {
@@ -50,18 +92,23 @@ func (dh *Dehasher) getNextPage() int {
 }
 
 // setQueries sets the number of queries to make based on the number of records and requests
-func (dh *Dehasher) setQueries() {
+func (dh *Dehasher) setQueries() error {
 	var numQueries int
 
 	if dh.debug {
 		debug.PrintInfo("setting queries")
 	}
 
-	switch {
-	case dh.options.MaxRequests == 0:
+	if dh.options.MaxRequests == 0 {
 		zap.L().Error("max requests cannot be zero")
-		fmt.Println("[!] Max Requests cannot be zero")
-		os.Exit(1)
+		return ErrZeroRequests
+	}
+	if dh.options.FetchAll {
+		dh.setFetchAll()
+		return nil
+	}
+
+	switch {
 	case dh.options.MaxRecords <= 10000 || dh.options.MaxRequests == 1:
 		numQueries = 1
 		if dh.options.MaxRecords > 10000 {
@@ -98,16 +145,102 @@ func (dh *Dehasher) setQueries() {
 		debug.PrintInfo(fmt.Sprintf("setting max requests: %d", numQueries))
 		debug.PrintInfo(fmt.Sprintf("setting max records: %d", dh.options.MaxRecords))
 	}
+	return nil
+}
+
+// setFetchAll pages through every result the API reports, optionally capped by max requests. Pages hold
+// max records, up to the most the API returns per page.
+func (dh *Dehasher) setFetchAll() {
+	if dh.options.MaxRecords <= 0 || dh.options.MaxRecords > maxPageSize {
+		dh.options.MaxRecords = maxPageSize
+	}
+	zap.L().Info("fetching all records",
+		zap.Int("max_records", dh.options.MaxRecords),
+		zap.Int("max_requests", dh.options.MaxRequests),
+	)
+
+	if dh.debug {
+		debug.PrintInfo(fmt.Sprintf("setting max requests: %d", dh.options.MaxRequests))
+		debug.PrintInfo(fmt.Sprintf("setting max records: %d", dh.options.MaxRecords))
+	}
+}
+
+// printPlan prints the pages the run will request. It is called by Start and DryRun rather than
+// NewDehasher so the banner goes to the writer set with SetOutput.
+func (dh *Dehasher) printPlan() {
+	if dh.firstPage > dh.options.StartingPage {
+		fmt.Fprintf(dh.out, "[*] Resuming run %d from page %d\n", dh.run.ID, dh.firstPage)
+	}
+	switch {
+	case dh.options.FetchAll && dh.options.MaxRequests > 0:
+		fmt.Fprintf(dh.out, "Fetching All Records in Pages of %d (Up To %d Requests)\n", dh.options.MaxRecords, dh.options.MaxRequests)
+	case dh.options.FetchAll:
+		fmt.Fprintf(dh.out, "Fetching All Records in Pages of %d\n", dh.options.MaxRecords)
+	default:
+		fmt.Fprintf(dh.out, "Making %d Requests for %d Records (%d Total)\n", dh.options.MaxRequests, dh.options.MaxRecords, dh.options.MaxRequests*dh.options.MaxRecords)
+	}
+}
 
-	fmt.Printf("Making %d Requests for %d Records (%d Total)\n", dh.options.MaxRequests, dh.options.MaxRecords, dh.options.MaxRequests*dh.options.MaxRecords)
+// hasNextPage reports whether the current request page is still within the run limits
+func (dh *Dehasher) hasNextPage() bool {
+	return !dh.capped() && !dh.exhausted()
 }
 
-// Start starts the querying process
-func (dh *Dehasher) Start() {
-	fmt.Printf("[*] Querying Dehashed API...\n")
-	for i := 0; i < dh.options.MaxRequests; i++ {
-		fmt.Printf("   [*] Performing Request...\n")
-		count, balance, err := dh.client.Search(*dh.request)
+// capped reports whether this invocation has made every request it is allowed
+func (dh *Dehasher) capped() bool {
+	return dh.options.MaxRequests > 0 && dh.request.Page-dh.firstPage >= dh.options.MaxRequests
+}
+
+// exhausted reports whether the pages before the current request hold every result the API reported
+func (dh *Dehasher) exhausted() bool {
+	return dh.run.TotalResults > 0 && (dh.request.Page-1)*dh.options.MaxRecords >= dh.run.TotalResults
+}
+
+// checkpoint stores a completed page and records it on the run so it can be resumed
+func (dh *Dehasher) checkpoint(page, total int, entries []sqlite.Result) {
+	err := sqlite.StoreDehashedResults(sqlite.DehashedResults{Results: entries})
+	if err != nil {
+		zap.L().Error("store_results",
+			zap.String("message", "failed to store results"),
+			zap.Error(err),
+		)
+		fmt.Fprintf(dh.out, "   [!] Error storing results: %v\n", err)
+	}
+
+	dh.run.LastPage = page
+	dh.run.TotalResults = total
+	dh.saveRun()
+}
+
+// saveRun persists the run checkpoint if the run has been stored
+func (dh *Dehasher) saveRun() {
+	if dh.run.ID == 0 {
+		return
+	}
+	err := sqlite.UpdateDehashedQueryOptions(dh.run)
+	if err != nil {
+		if dh.debug {
+			debug.PrintInfo("failed to update run checkpoint")
+			debug.PrintError(err)
+		}
+		zap.L().Error("update_query_options",
+			zap.String("message", "failed to update run checkpoint"),
+			zap.Uint("run_id", dh.run.ID),
+			zap.Error(err),
+		)
+	}
+}
+
+// Start starts the querying process, returning the error that interrupted the run if any
+func (dh *Dehasher) Start() error {
+	dh.printPlan()
+	fmt.Fprintf(dh.out, "[*] Querying Dehashed API...\n")
+	dh.client.runID = dh.run.ID
+	complete := false
+	for dh.hasNextPage() {
+		fmt.Fprintf(dh.out, "   [*] Performing Request (Page %d)...\n", dh.request.Page)
+		fetched := dh.client.GetTotalResults()
+		total, balance, err := dh.client.Search(*dh.request)
 		if err != nil {
 			if dh.debug {
 				debug.PrintInfo("error performing request")
@@ -115,57 +248,115 @@ func (dh *Dehasher) Start() {
 			}
 
 			// Check if it's a DehashError
-			if dhErr, ok := err.(*DehashError); ok {
-				fmt.Printf("      [!] Dehashed API Error: %s (Code: %d)\n", dhErr.Message, dhErr.Code)
+			if errors.Is(err, httpclient.ErrBudgetExceeded) {
+				fmt.Fprintf(dh.out, "      [!] Stopping run: %v\n", err)
+				zap.L().Info("dehashed_budget_reached",
+					zap.String("message", "stopping run at credit budget"),
+					zap.Error(err),
+				)
+			} else if dhErr, ok := err.(*DehashError); ok {
+				fmt.Fprintf(dh.out, "      [!] Dehashed API Error: %s (Code: %d)\n", dhErr.Message, dhErr.Code)
 				zap.L().Error("dehashed_api_error",
 					zap.String("message", dhErr.Message),
 					zap.Int("code", dhErr.Code),
 				)
 			} else {
-				fmt.Printf("   [!] Error performing request: %v\n", err)
+				fmt.Fprintf(dh.out, "   [!] Error performing request: %v\n", err)
 				zap.L().Error("request_error",
 					zap.String("message", "failed to perform request"),
 					zap.Error(err),
 				)
 			}
 
-			if len(dh.client.results) > 0 {
-				fmt.Printf("   [!] Partial results retrieved. Storing Results...\n")
-				err := sqlite.StoreDehashedResults(dh.client.GetResults())
-				if err != nil {
-					zap.L().Error("store_results",
-						zap.String("message", "failed to store results"),
-						zap.Error(err),
-					)
-					fmt.Printf("   [!] Error storing results: %v\n", err)
-				}
+			if dh.client.GetTotalResults() > 0 {
+				fmt.Fprintf(dh.out, "   [!] Partial results retrieved.\n")
 			}
 			dh.parseResults()
-			os.Exit(-1)
+			return err
 		}
 
 		dh.balance = balance
+		entries := dh.client.results[fetched:]
+		dh.checkpoint(dh.request.Page, total, entries)
+		fmt.Fprintf(dh.out, "      [+] Retrieved %d records\n", len(entries))
 
-		if count < dh.options.MaxRecords {
-			fmt.Printf("      [+] Retrieved %d records\n", count)
-			fmt.Printf("      [-] Not enough entries, ending queries\n")
-			break
-		} else {
-			fmt.Printf("      [+] Retrieved %d records\n", dh.options.MaxRecords)
+		if dh.options.PrintBalance {
+			fmt.Fprintf(dh.out, "      [*] Balance: %d\n", balance)
 		}
 
-		if dh.options.PrintBalance {
-			fmt.Printf("      [*] Balance: %d\n", balance)
+		if len(entries) < dh.options.MaxRecords {
+			fmt.Fprintf(dh.out, "      [-] Not enough entries, ending queries\n")
+			complete = true
+			break
 		}
 
 		dh.request.Page = dh.getNextPage()
 	}
 
+	// A run stopped by its request cap with pages left stays open so it can be resumed
+	if complete || dh.exhausted() {
+		dh.run.Completed = true
+		dh.saveRun()
+	} else if dh.run.ID > 0 && !dh.batch {
+		fmt.Fprintf(dh.out, "   [*] More results remain after page %d. Continue with: crowsnest dehashed --resume %d\n", dh.run.LastPage, dh.run.ID)
+	}
 	dh.parseResults()
+	return nil
+}
+
+// DryRun prints the search request, the pages that would be requested and the estimated credit cost
+func (dh *Dehasher) DryRun() {
+	body, err := json.MarshalIndent(dh.request, "", "  ")
+	if err != nil {
+		fmt.Fprintf(dh.out, "[!] Error encoding request: %v\n", err)
+		return
+	}
+
+	dh.printPlan()
+	fmt.Fprintln(dh.out, "[*] Dry Run: Dehashed Search")
+	fmt.Fprintf(dh.out, "   [*] Query: %s\n", dh.request.Query)
+	fmt.Fprintf(dh.out, "   [*] POST %s\n", dh.client.client.URL("/v2/search"))
+	fmt.Fprintf(dh.out, "   [*] Request Body (Page %d):\n%s\n", dh.request.Page, string(body))
+
+	first := dh.request.Page
+	if dh.options.FetchAll && dh.options.MaxRequests <= 0 {
+		fmt.Fprintf(dh.out, "   [*] Pages: %d onwards, until every result reported by the API is retrieved (%d records per page)\n", first, dh.options.MaxRecords)
+		fmt.Fprintf(dh.out, "   [*] Estimated Cost: %d credit per page, total depends on the number of results\n", SearchCost)
+		return
+	}
+
+	last := first + dh.options.MaxRequests - 1
+	pages := last - first + 1
+	if pages < 0 {
+		pages = 0
+	}
+	if dh.options.FetchAll {
+		fmt.Fprintf(dh.out, "   [*] Pages: %d-%d at most, fewer if the API reports fewer results (%d records per page)\n", first, last, dh.options.MaxRecords)
+	} else {
+		fmt.Fprintf(dh.out, "   [*] Pages: %d-%d (%d records per page)\n", first, last, dh.options.MaxRecords)
+	}
+	fmt.Fprintf(dh.out, "   [*] Estimated Cost: up to %d credits\n", pages*SearchCost)
 }
 
 // buildRequest constructs the query map
-func (dh *Dehasher) buildRequest() {
+func (dh *Dehasher) buildRequest() error {
+	if len(dh.options.Expression) > 0 {
+		query, err := CompileQuery(dh.options.Expression, dh.options.WildcardMatch, dh.options.RegexMatch)
+		if err != nil {
+			zap.L().Error("compile_query",
+				zap.String("message", "failed to compile query expression"),
+				zap.String("expression", dh.options.Expression),
+				zap.Error(err),
+			)
+			return fmt.Errorf("invalid query: %w", err)
+		}
+		dh.request.Query = query
+		if dh.debug {
+			debug.PrintInfo(fmt.Sprintf("query built: %s", query))
+		}
+		return nil
+	}
+
 	if len(dh.options.UsernameQuery) > 0 {
 		dh.request.AddUsernameQuery(dh.options.UsernameQuery)
 	}
@@ -205,6 +396,7 @@ func (dh *Dehasher) buildRequest() {
 	if len(dh.options.CryptoAddressQuery) > 0 {
 		dh.request.AddCryptoAddressQuery(dh.options.CryptoAddressQuery)
 	}
+	return nil
 }
 
 // parseResults parses the results and writes them to a file
@@ -212,7 +404,7 @@ func (dh *Dehasher) parseResults() {
 	zap.L().Info("extracting_credentials")
 	results := dh.client.GetResults()
 	creds := results.ExtractUsers()
-	fmt.Printf("   [+] Discovered %d Credentials\n", len(creds))
+	fmt.Fprintf(dh.out, "   [+] Discovered %d Credentials\n", len(creds))
 	err := sqlite.StoreUsers(creds)
 	if err != nil {
 		zap.L().Error("store_creds",
@@ -222,15 +414,9 @@ func (dh *Dehasher) parseResults() {
 	}
 	zap.L().Info("creds_stored", zap.Int("count", len(creds)))
 
-	zap.L().Info("storing_results")
-	err = sqlite.StoreDehashedResults(results)
-	if err != nil {
-		zap.L().Error("store_results",
-			zap.String("message", "failed to store results"),
-			zap.Error(err),
-		)
+	if dh.batch {
+		return
 	}
-	zap.L().Info("results_stored", zap.Int("count", len(results.Results)))
 
 	if len(results.Results) > 0 {
 		var (
@@ -238,17 +424,17 @@ func (dh *Dehasher) parseResults() {
 			rows    [][]string
 		)
 
-		fmt.Printf("   [*] Writing entries to file: %s.%s\n", dh.options.OutputFile, dh.options.OutputFormat.String())
+		fmt.Fprintf(dh.out, "   [*] Writing entries to file: %s.%s\n", dh.options.OutputFile, dh.options.OutputFormat.String())
 		if !dh.options.CredsOnly {
 			err := export.WriteToFile(results, dh.options.OutputFile, dh.options.OutputFormat)
 			if err != nil {
-				fmt.Printf("[!] Error Writing to file: %v      Outputting to terminal.\n", err)
+				fmt.Fprintf(dh.out, "[!] Error Writing to file: %v      Outputting to terminal.\n", err)
 				zap.L().Error("write_results",
 					zap.String("message", "failed to write results to file"),
 					zap.Error(err),
 				)
 			} else {
-				fmt.Println("      [*] Success")
+				fmt.Fprintln(dh.out, "      [*] Success")
 			}
 
 			if dh.debug {
@@ -257,7 +443,7 @@ func (dh *Dehasher) parseResults() {
 
 			headers = []string{"Email", "Username", "Password", "Phone", "Company"}
 			if len(results.Results) > 50 {
-				fmt.Println("   [-] Large number of results recovered, displaying first 50...")
+				fmt.Fprintln(dh.out, "   [-] Large number of results recovered, displaying first 50...")
 				for i := 0; i < 50; i++ {
 					r := results.Results[i]
 					rows = append(rows, []string{
@@ -281,38 +467,34 @@ func (dh *Dehasher) parseResults() {
 			// Print Table
 			pretty.Table(headers, rows)
 		} else {
-			if dh.debug {
-				debug.PrintInfo("extracting credentials")
-			}
-			creds := results.ExtractUsers()
 			if dh.debug {
 				debug.PrintInfo("writing credentials to file")
 			}
 			err := export.WriteCredsToFile(creds, dh.options.OutputFile, dh.options.OutputFormat)
 			if err != nil {
-				fmt.Printf("[!] Error Writing to file: %v\n   Outputting to terminal.", err)
+				fmt.Fprintf(dh.out, "[!] Error Writing to file: %v\n   Outputting to terminal.", err)
 				zap.L().Error("write_creds",
 					zap.String("message", "failed to write creds to file"),
 					zap.Error(err),
 				)
 			} else {
-				fmt.Println("      [*] Success")
+				fmt.Fprintln(dh.out, "      [*] Success")
 			}
 
 			if dh.debug {
 				debug.PrintInfo("printing credentials table")
 			}
 
-			headers = []string{"Email", "Username", "Password"}
+			headers = []string{"Email", "Username", "Password", "Hashed Password"}
 			if len(creds) > 50 {
-				fmt.Println("   [-] Large number of results recovered, displaying first 50...")
+				fmt.Fprintln(dh.out, "   [-] Large number of results recovered, displaying first 50...")
 				for i := 0; i < 50; i++ {
 					c := creds[i]
-					rows = append(rows, []string{c.Email, c.Username, c.Password})
+					rows = append(rows, []string{c.Email, c.Username, c.Password, c.HashedPassword})
 				}
 			} else {
 				for _, c := range creds {
-					rows = append(rows, []string{c.Email, c.Username, c.Password})
+					rows = append(rows, []string{c.Email, c.Username, c.Password, c.HashedPassword})
 				}
 			}
 
@@ -320,6 +502,6 @@ func (dh *Dehasher) parseResults() {
 			pretty.Table(headers, rows)
 		}
 	} else {
-		fmt.Println("   [-] No results found")
+		fmt.Fprintln(dh.out, "   [-] No results found")
 	}
 }
@@ -0,0 +1,84 @@
+package dehashed
+
+import (
+	"crowsnest/internal/httpclient"
+	"crowsnest/internal/sqlite"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNewDehasherErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		options sqlite.QueryOptions
+		err     error
+	}{
+		{name: "zero requests", options: sqlite.QueryOptions{MaxRecords: 100, DomainQuery: "acme.com"}, err: ErrZeroRequests},
+		{name: "zero requests fetching all", options: sqlite.QueryOptions{FetchAll: true, DomainQuery: "acme.com"}, err: ErrZeroRequests},
+		{name: "invalid expression", options: sqlite.QueryOptions{MaxRequests: 1, Expression: "colour:red"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dh, err := NewDehasher(&tt.options)
+			if err == nil || dh != nil {
+				t.Fatalf("NewDehasher() = %v, %v, want an error", dh, err)
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("NewDehasher() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestDryRunPages(t *testing.T) {
+	tests := []struct {
+		name    string
+		options sqlite.QueryOptions
+		want    []string
+	}{
+		{
+			name:    "new run",
+			options: sqlite.QueryOptions{MaxRecords: 30000, MaxRequests: 3, StartingPage: 1},
+			want:    []string{"Pages: 1-3 (10000 records per page)", "up to 3 credits"},
+		},
+		{
+			name:    "resumed run",
+			options: sqlite.QueryOptions{MaxRecords: 30000, MaxRequests: 3, StartingPage: 1, LastPage: 3},
+			want:    []string{"Resuming run 0 from page 4", "Making 3 Requests", "Pages: 4-6 (10000 records per page)", "up to 3 credits"},
+		},
+		{
+			name:    "fetching all in smaller pages",
+			options: sqlite.QueryOptions{MaxRecords: 500, MaxRequests: 2, StartingPage: 1, FetchAll: true},
+			want:    []string{"Fetching All Records in Pages of 500 (Up To 2 Requests)", "Pages: 1-2 at most, fewer if the API reports fewer results (500 records per page)"},
+		},
+		{
+			name:    "resumed run fetching all",
+			options: sqlite.QueryOptions{MaxRequests: 2, StartingPage: 1, LastPage: 5, FetchAll: true},
+			want:    []string{"Resuming run 0 from page 6", "Up To 2 Requests", "Pages: 6-7 at most", "up to 2 credits"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.options.DomainQuery = "acme.com"
+			dh, err := NewDehasher(&tt.options)
+			if err != nil {
+				t.Fatalf("NewDehasher() error = %v", err)
+			}
+			client, err := httpclient.New(httpclient.Dehashed, httpclient.Config{}, false)
+			if err != nil {
+				t.Fatalf("httpclient.New() error = %v", err)
+			}
+			dh.SetClientCredentials("test-key", client)
+			var out strings.Builder
+			dh.SetOutput(&out)
+
+			dh.DryRun()
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("DryRun() output missing %q:\n%s", want, out.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,430 @@
+package dehashed
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// QueryError is a --query expression error at a byte offset of the expression
+type QueryError struct {
+	Pos int
+	Msg string
+}
+
+func (qe *QueryError) Error() string {
+	return fmt.Sprintf("%s (at position %d)", qe.Msg, qe.Pos+1)
+}
+
+// Caret returns the expression with a marker under the position of the error
+func (qe *QueryError) Caret(expression string) string {
+	return expression + "\n" + strings.Repeat(" ", qe.Pos) + "^"
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenLParen
+	tokenRParen
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenTerm
+)
+
+func (tk tokenKind) String() string {
+	switch tk {
+	case tokenEOF:
+		return "end of query"
+	case tokenLParen:
+		return "'('"
+	case tokenRParen:
+		return "')'"
+	case tokenAnd:
+		return "AND"
+	case tokenOr:
+		return "OR"
+	case tokenNot:
+		return "NOT"
+	default:
+		return "term"
+	}
+}
+
+type token struct {
+	kind  tokenKind
+	pos   int
+	field string
+	value string
+}
+
+// lexQuery splits the expression into parentheses, operators and field:value terms
+func lexQuery(expression string) ([]token, error) {
+	var (
+		tokens []token
+		runes  = []rune(expression)
+		offset = func(i int) int { return len(string(runes[:i])) }
+	)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenLParen, pos: offset(i)})
+			i++
+			continue
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenRParen, pos: offset(i)})
+			i++
+			continue
+		case r == '"':
+			return nil, &QueryError{Pos: offset(i), Msg: "quoted value without a field, use field:\"value\""}
+		}
+
+		// Read a bare word up to the next space, parenthesis or field separator
+		start := i
+		for i < len(runes) && !unicode.IsSpace(runes[i]) && runes[i] != '(' && runes[i] != ')' && runes[i] != ':' && runes[i] != '"' {
+			i++
+		}
+		word := string(runes[start:i])
+
+		if i >= len(runes) || runes[i] != ':' {
+			switch strings.ToUpper(word) {
+			case "AND", "&&":
+				tokens = append(tokens, token{kind: tokenAnd, pos: offset(start)})
+			case "OR", "||":
+				tokens = append(tokens, token{kind: tokenOr, pos: offset(start)})
+			case "NOT":
+				tokens = append(tokens, token{kind: tokenNot, pos: offset(start)})
+			case "":
+				return nil, &QueryError{Pos: offset(i), Msg: fmt.Sprintf("unexpected '%c'", runes[i])}
+			default:
+				return nil, &QueryError{Pos: offset(start), Msg: fmt.Sprintf("expected field:value, found '%s'", word)}
+			}
+			continue
+		}
+		if word == "" {
+			return nil, &QueryError{Pos: offset(i), Msg: "missing field name before ':'"}
+		}
+
+		// Skip the separator and read the value, quoted values may contain spaces and escaped quotes.
+		// Other backslashes are kept, so regex escapes such as \. pass through.
+		i++
+		tok := token{kind: tokenTerm, pos: offset(start), field: word}
+		if i < len(runes) && runes[i] == '"' {
+			quote := i
+			var sb strings.Builder
+			for i++; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) && (runes[i+1] == '"' || runes[i+1] == '\\') {
+					i++
+				}
+				sb.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, &QueryError{Pos: offset(quote), Msg: "unterminated quoted value"}
+			}
+			i++
+			tok.value = sb.String()
+		} else {
+			valueStart := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && runes[i] != '(' && runes[i] != ')' {
+				i++
+			}
+			tok.value = string(runes[valueStart:i])
+		}
+		if strings.TrimSpace(tok.value) == "" {
+			return nil, &QueryError{Pos: tok.pos, Msg: fmt.Sprintf("missing value for field '%s'", word)}
+		}
+		tokens = append(tokens, tok)
+	}
+
+	tokens = append(tokens, token{kind: tokenEOF, pos: len(expression)})
+	return tokens, nil
+}
+
+// QueryExpr is a node of a parsed --query expression
+type QueryExpr interface {
+	compile(sb *strings.Builder)
+	precedence() int
+}
+
+// TermExpr matches a single field value
+type TermExpr struct {
+	Field DehashedParameter
+	Value string
+	Regex bool // Regex values are only quoted when they contain spaces, never escaped
+	pos   int
+}
+
+// NotExpr excludes the records matched by its operand
+type NotExpr struct {
+	X QueryExpr
+}
+
+// BinaryExpr joins two expressions with AND or OR
+type BinaryExpr struct {
+	Op          string
+	Left, Right QueryExpr
+}
+
+func (t *TermExpr) precedence() int { return 4 }
+func (n *NotExpr) precedence() int  { return 3 }
+func (b *BinaryExpr) precedence() int {
+	if b.Op == "AND" {
+		return 2
+	}
+	return 1
+}
+
+func (t *TermExpr) compile(sb *strings.Builder) {
+	value := t.Value
+	if t.Regex {
+		value = enquoteSpaced(value)
+	} else if strings.ContainsAny(value, " \t\"():\\") {
+		value = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+	}
+	sb.WriteString(t.Field.GetArgumentString(value))
+}
+
+func (n *NotExpr) compile(sb *strings.Builder) {
+	sb.WriteString("NOT ")
+	compileOperand(sb, n.X, n.precedence())
+}
+
+func (b *BinaryExpr) compile(sb *strings.Builder) {
+	compileOperand(sb, b.Left, b.precedence())
+	sb.WriteString(" " + b.Op + " ")
+	compileOperand(sb, b.Right, b.precedence())
+}
+
+// compileOperand wraps operands that bind looser than their parent in parentheses
+func compileOperand(sb *strings.Builder, x QueryExpr, parent int) {
+	if x.precedence() < parent {
+		sb.WriteString("(")
+		x.compile(sb)
+		sb.WriteString(")")
+		return
+	}
+	x.compile(sb)
+}
+
+type queryParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *queryParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *queryParser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// parseOr parses: and { OR and }
+func (p *queryParser) parseOr() (QueryExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{Op: "OR", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+// parseAnd parses: unary { AND unary }
+func (p *queryParser) parseAnd() (QueryExpr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch p.peek().kind {
+		case tokenAnd:
+			p.next()
+		case tokenTerm, tokenNot, tokenLParen:
+			tok := p.peek()
+			return nil, &QueryError{Pos: tok.pos, Msg: fmt.Sprintf("expected AND or OR before %s", tok.kind)}
+		default:
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{Op: "AND", Left: left, Right: right}
+	}
+}
+
+// parseUnary parses: NOT unary | '(' or ')' | field:value
+func (p *queryParser) parseUnary() (QueryExpr, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenNot:
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &NotExpr{X: x}, nil
+	case tokenLParen:
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokenRParen {
+			if closing.kind == tokenEOF {
+				return nil, &QueryError{Pos: tok.pos, Msg: "unclosed '('"}
+			}
+			return nil, &QueryError{Pos: closing.pos, Msg: fmt.Sprintf("expected ')', found %s", closing.kind)}
+		}
+		return x, nil
+	case tokenTerm:
+		field, err := GetDehashedParameter(tok.field)
+		if err != nil {
+			return nil, &QueryError{Pos: tok.pos, Msg: fmt.Sprintf("unknown field '%s' (%s)", tok.field, strings.Join(queryFields(), ", "))}
+		}
+		return &TermExpr{Field: field, Value: tok.value, pos: tok.pos}, nil
+	default:
+		return nil, &QueryError{Pos: tok.pos, Msg: fmt.Sprintf("expected field:value, found %s", tok.kind)}
+	}
+}
+
+// ParseQuery parses a boolean --query expression such as
+// (email:"*@acme.com" OR domain:acme.com) AND NOT username:test.
+// AND binds tighter than OR, NOT binds tighter than both, and parentheses group terms.
+func ParseQuery(expression string) (QueryExpr, error) {
+	if strings.TrimSpace(expression) == "" {
+		return nil, &QueryError{Pos: 0, Msg: "empty query"}
+	}
+
+	tokens, err := lexQuery(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &queryParser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		if tok.kind == tokenRParen {
+			return nil, &QueryError{Pos: tok.pos, Msg: "unmatched ')'"}
+		}
+		return nil, &QueryError{Pos: tok.pos, Msg: fmt.Sprintf("unexpected %s", tok.kind)}
+	}
+	return expr, nil
+}
+
+// CompileQuery parses and validates the expression and returns the Dehashed query string.
+// Wildcards require wildcard matching and may not lead a value, regex values are passed through as written.
+// Passwords are searched by their SHA-256 hash, the same as the --password flag.
+func CompileQuery(expression string, wildcard, regex bool) (string, error) {
+	expr, err := ParseQuery(expression)
+	if err != nil {
+		return "", err
+	}
+
+	if !matchesTerm(expr, false) {
+		return "", &QueryError{Pos: 0, Msg: "query cannot only exclude records, add a term to match to every OR branch"}
+	}
+
+	var validateErr error
+	walkTerms(expr, func(t *TermExpr) {
+		if validateErr == nil {
+			validateErr = validateTerm(t, wildcard, regex)
+		}
+	})
+	if validateErr != nil {
+		return "", validateErr
+	}
+
+	var sb strings.Builder
+	expr.compile(&sb)
+	return sb.String(), nil
+}
+
+// validateTerm rejects values the Dehashed API cannot search and hashes passwords
+func validateTerm(t *TermExpr, wildcard, regex bool) error {
+	term := t.Field.GetArgumentString(t.Value)
+	hasWildcard := strings.ContainsAny(t.Value, "*?")
+
+	if !regex && hasWildcard {
+		switch {
+		case strings.HasPrefix(t.Value, "*") || strings.HasPrefix(t.Value, "?"):
+			return &QueryError{Pos: t.pos, Msg: fmt.Sprintf("leading wildcard in '%s' is not supported, remove it to match a substring", term)}
+		case !wildcard:
+			return &QueryError{Pos: t.pos, Msg: fmt.Sprintf("wildcard in '%s' requires --wildcard-match", term)}
+		}
+	}
+
+	t.Regex = regex
+	if t.Field == Password {
+		if hasWildcard || regex {
+			return &QueryError{Pos: t.pos, Msg: fmt.Sprintf("'%s' cannot use wildcard or regex matching, passwords are searched by hash", term)}
+		}
+		hash := sha256.Sum256([]byte(t.Value))
+		t.Field = HashedPassword
+		t.Value = hex.EncodeToString(hash[:])
+	}
+	return nil
+}
+
+// matchesTerm reports whether every record the expression matches must match one of its terms, rather than
+// only not matching some. Negated expressions are resolved with De Morgan's laws.
+func matchesTerm(expr QueryExpr, negated bool) bool {
+	switch x := expr.(type) {
+	case *TermExpr:
+		return !negated
+	case *NotExpr:
+		return matchesTerm(x.X, !negated)
+	case *BinaryExpr:
+		left, right := matchesTerm(x.Left, negated), matchesTerm(x.Right, negated)
+		if (x.Op == "AND") != negated {
+			return left || right
+		}
+		return left && right
+	}
+	return false
+}
+
+// walkTerms calls fn for every term of the expression
+func walkTerms(expr QueryExpr, fn func(t *TermExpr)) {
+	switch x := expr.(type) {
+	case *TermExpr:
+		fn(x)
+	case *NotExpr:
+		walkTerms(x.X, fn)
+	case *BinaryExpr:
+		walkTerms(x.Left, fn)
+		walkTerms(x.Right, fn)
+	}
+}
+
+// queryFields returns the field names accepted in a query expression
+func queryFields() []string {
+	fields := []string{
+		string(Username), string(Email), string(Password), string(HashedPassword), string(Name),
+		string(IpAddress), string(Domain), string(Vin), string(LicensePlate), string(Address),
+		string(Phone), string(Social), string(CryptoAddress),
+	}
+	sort.Strings(fields)
+	return fields
+}
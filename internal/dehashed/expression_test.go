@@ -0,0 +1,146 @@
+package dehashed
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		want       string // Compiled expression, empty when parsing fails
+		err        string
+		errPos     int
+	}{
+		{name: "term", expression: "domain:acme.com", want: "domain:acme.com"},
+		{name: "field alias", expression: "user:admin", want: "username:admin"},
+		{name: "and binds tighter than or", expression: "email:a OR email:b AND username:c", want: "email:a OR email:b AND username:c"},
+		{name: "parentheses kept when needed", expression: "(email:a OR email:b) AND username:c", want: "(email:a OR email:b) AND username:c"},
+		{name: "redundant parentheses dropped", expression: "(email:a) AND ((username:c))", want: "email:a AND username:c"},
+		{name: "symbolic operators", expression: "email:a && username:b || name:c", want: "email:a AND username:b OR name:c"},
+		{name: "lowercase operators", expression: "email:a and not username:b", want: "email:a AND NOT username:b"},
+		{name: "not of group", expression: "domain:acme.com AND NOT (username:a OR username:b)", want: "domain:acme.com AND NOT (username:a OR username:b)"},
+		{name: "quoted value with spaces", expression: `name:"John Smith"`, want: `name:"John Smith"`},
+		{name: "escaped quote", expression: `name:"a \"b\""`, want: `name:"a \"b\""`},
+		{name: "empty", expression: "  ", err: "empty query", errPos: 0},
+		{name: "unknown field", expression: "colour:red", err: "unknown field 'colour'", errPos: 0},
+		{name: "missing operator", expression: "email:a username:b", err: "expected AND or OR before term", errPos: 8},
+		{name: "unclosed parenthesis", expression: "(email:a OR email:b", err: "unclosed '('", errPos: 0},
+		{name: "unmatched parenthesis", expression: "email:a)", err: "unmatched ')'", errPos: 7},
+		{name: "dangling operator", expression: "email:a AND", err: "expected field:value, found end of query", errPos: 11},
+		{name: "bare word", expression: "acme.com", err: "expected field:value, found 'acme.com'", errPos: 0},
+		{name: "missing value", expression: "email: AND domain:b", err: "missing value for field 'email'", errPos: 0},
+		{name: "missing field", expression: ":acme.com", err: "missing field name before ':'", errPos: 0},
+		{name: "unterminated quote", expression: `name:"John`, err: "unterminated quoted value", errPos: 5},
+		{name: "quote without field", expression: `"acme"`, err: "quoted value without a field", errPos: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := ParseQuery(tt.expression)
+			if tt.err != "" {
+				var qe *QueryError
+				if !errors.As(err, &qe) {
+					t.Fatalf("ParseQuery(%q) error = %v, want a QueryError", tt.expression, err)
+				}
+				if !strings.Contains(qe.Msg, tt.err) || qe.Pos != tt.errPos {
+					t.Errorf("ParseQuery(%q) error = %q at %d, want %q at %d", tt.expression, qe.Msg, qe.Pos, tt.err, tt.errPos)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseQuery(%q) error = %v", tt.expression, err)
+			}
+			var sb strings.Builder
+			expr.compile(&sb)
+			if got := sb.String(); got != tt.want {
+				t.Errorf("ParseQuery(%q) = %q, want %q", tt.expression, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileQuery(t *testing.T) {
+	hash := sha256.Sum256([]byte("hunter2"))
+	hashed := hex.EncodeToString(hash[:])
+
+	tests := []struct {
+		name       string
+		expression string
+		wildcard   bool
+		regex      bool
+		want       string
+		err        string
+	}{
+		{name: "plain", expression: "domain:acme.com AND NOT username:test", want: "domain:acme.com AND NOT username:test"},
+		{name: "password is hashed", expression: "password:hunter2", want: "hashed_password:" + hashed},
+		{name: "wildcard allowed", expression: "email:admin*", wildcard: true, want: "email:admin*"},
+		{name: "wildcard needs flag", expression: "email:admin*", err: "requires --wildcard-match"},
+		{name: "leading wildcard", expression: `email:"*@acme.com"`, wildcard: true, err: "leading wildcard"},
+		{name: "regex with spaces quoted", expression: `name:"john .*"`, regex: true, want: `name:"john .*"`},
+		{name: "password with wildcard", expression: "password:hunt*", wildcard: true, err: "passwords are searched by hash"},
+		{name: "password with regex", expression: "password:hunter2", regex: true, err: "passwords are searched by hash"},
+		{name: "only exclusion", expression: "NOT username:test", err: "cannot only exclude records"},
+		{name: "exclusions joined by and", expression: "NOT username:a AND NOT username:b", err: "cannot only exclude records"},
+		{name: "exclusion in one or branch", expression: "domain:acme.com OR NOT username:test", err: "cannot only exclude records"},
+		{name: "negated exclusion", expression: "NOT (NOT domain:acme.com)", want: "NOT NOT domain:acme.com"},
+		{name: "de morgan", expression: "NOT (NOT domain:a OR NOT domain:b)", want: "NOT (NOT domain:a OR NOT domain:b)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CompileQuery(tt.expression, tt.wildcard, tt.regex)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("CompileQuery(%q) error = %v, want %q", tt.expression, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CompileQuery(%q) error = %v", tt.expression, err)
+			}
+			if got != tt.want {
+				t.Errorf("CompileQuery(%q) = %q, want %q", tt.expression, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLexQueryEscapes(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       string
+	}{
+		{`email:"a \"b\""`, `a "b"`},
+		{`email:"a\\b"`, `a\b`},
+		{`email:"^a.*@acme\.com$"`, `^a.*@acme\.com$`},
+		{`email:"\d+ \w"`, `\d+ \w`},
+		{`email:"trailing\"`, ""}, // The escaped quote leaves the value unterminated
+	}
+	for _, tt := range tests {
+		tokens, err := lexQuery(tt.expression)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("lexQuery(%q) = %+v, want an error", tt.expression, tokens)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("lexQuery(%q) error = %v", tt.expression, err)
+			continue
+		}
+		if got := tokens[0].value; got != tt.want {
+			t.Errorf("lexQuery(%q) value = %q, want %q", tt.expression, got, tt.want)
+		}
+	}
+
+	// Regex values reach Dehashed as written
+	got, err := CompileQuery(`email:"^a.*@acme\.com$"`, false, true)
+	if want := `email:^a.*@acme\.com$`; err != nil || got != want {
+		t.Errorf("CompileQuery() = %q, %v, want %q", got, err, want)
+	}
+}
@@ -0,0 +1,47 @@
+package dehashed
+
+import (
+	"crowsnest/internal/scope"
+	"crowsnest/internal/sqlite"
+)
+
+// ScopeTargets returns the email, domain and IP values a query searches for so they can be checked against the scope.
+// Terms under NOT in a query expression only exclude records and are not targets.
+func ScopeTargets(options *sqlite.QueryOptions) []scope.Target {
+	var targets []scope.Target
+	add := func(param DehashedParameter, value string) {
+		switch {
+		case value == "":
+		case param == Email:
+			targets = append(targets, scope.Target{Kind: scope.TargetEmail, Value: value})
+		case param == Domain:
+			targets = append(targets, scope.Target{Kind: scope.TargetDomain, Value: value})
+		case param == IpAddress:
+			targets = append(targets, scope.Target{Kind: scope.TargetIP, Value: value})
+		}
+	}
+
+	add(Email, options.EmailQuery)
+	add(Domain, options.DomainQuery)
+	add(IpAddress, options.IpQuery)
+
+	if options.Expression != "" {
+		if expr, err := ParseQuery(options.Expression); err == nil {
+			walkTargets(expr, func(t *TermExpr) {
+				add(t.Field, t.Value)
+			})
+		}
+	}
+	return targets
+}
+
+// walkTargets calls fn for every term of the expression that is not negated
+func walkTargets(expr QueryExpr, fn func(t *TermExpr)) {
+	switch x := expr.(type) {
+	case *TermExpr:
+		fn(x)
+	case *BinaryExpr:
+		walkTargets(x.Left, fn)
+		walkTargets(x.Right, fn)
+	}
+}
@@ -39,7 +39,7 @@ func WriteCredsToFile(creds []sqlite.User, outputFile string, fileType files.Fil
 		return err
 	}
 
-	filePath := fmt.Sprintf("%s.%s", outputFile, fileType.String())
+	filePath := Path(fmt.Sprintf("%s.%s", outputFile, fileType.String()))
 	return os.WriteFile(filePath, data, 0644)
 }
 
@@ -73,7 +73,7 @@ func WriteToFile(results sqlite.DehashedResults, outputFile string, fileType fil
 		return err
 	}
 
-	filePath := fmt.Sprintf("%s.%s", outputFile, fileType)
+	filePath := Path(fmt.Sprintf("%s.%s", outputFile, fileType.String()))
 	return ioutil.WriteFile(filePath, data, 0644)
 }
 
@@ -129,7 +129,7 @@ func WriteQueryResultsToFile(results []map[string]interface{}, outputFile string
 		return err
 	}
 
-	filePath := fmt.Sprintf("%s.%s", outputFile, fileType.String())
+	filePath := Path(fmt.Sprintf("%s.%s", outputFile, fileType.String()))
 	return os.WriteFile(filePath, data, 0644)
 }
 
@@ -158,7 +158,7 @@ func WriteWhoIsHistoryToFile(results []sqlite.HistoryRecord, outputFile string,
 		return err
 	}
 
-	filePath := fmt.Sprintf("%s.%s", outputFile, fileType.String())
+	filePath := Path(fmt.Sprintf("%s.%s", outputFile, fileType.String()))
 	return os.WriteFile(filePath, data, 0644)
 }
 
@@ -183,7 +183,7 @@ func WriteWhoIsRecordToFile(record sqlite.WhoisRecord, outputFile string, fileTy
 		return err
 	}
 
-	filePath := fmt.Sprintf("%s.%s", outputFile, fileType.String())
+	filePath := Path(fmt.Sprintf("%s.%s", outputFile, fileType.String()))
 	return os.WriteFile(filePath, data, 0644)
 }
 
@@ -215,7 +215,7 @@ func WriteSubdomainsToFile(records []sqlite.SubdomainRecord, outputFile string,
 		return err
 	}
 
-	filePath := fmt.Sprintf("%s.%s", outputFile, fileType.String())
+	filePath := Path(fmt.Sprintf("%s.%s", outputFile, fileType.String()))
 	return os.WriteFile(filePath, data, 0644)
 }
 
@@ -247,6 +247,6 @@ func WriteIPLookupToFile(records []sqlite.LookupResult, outputFile string, fileT
 		return err
 	}
 
-	filePath := fmt.Sprintf("%s.%s", outputFile, fileType.String())
+	filePath := Path(fmt.Sprintf("%s.%s", outputFile, fileType.String()))
 	return os.WriteFile(filePath, data, 0644)
 }
@@ -31,6 +31,6 @@ func WriteIStringToFile(iString sqlite.IString, outputFile string, fileType file
 		return err
 	}
 
-	filePath := fmt.Sprintf("%s.%s", outputFile, fileType.String())
+	filePath := Path(fmt.Sprintf("%s.%s", outputFile, fileType.String()))
 	return os.WriteFile(filePath, data, 0644)
 }
@@ -0,0 +1,21 @@
+package export
+
+import (
+	"path/filepath"
+)
+
+// outputDir is where relative output files are written, the working directory when empty
+var outputDir string
+
+// SetOutputDir sets the directory relative output files are written to
+func SetOutputDir(dir string) {
+	outputDir = dir
+}
+
+// Path returns where an output file is written, placing relative paths in the output directory
+func Path(name string) string {
+	if outputDir == "" || filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(outputDir, name)
+}
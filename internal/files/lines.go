@@ -0,0 +1,35 @@
+package files
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"strings"
+)
+
+// ReadLines returns the trimmed lines of a target list file, skipping blank lines and # comments
+func ReadLines(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(lines) == 0 {
+		return nil, errors.New("no entries found in input file")
+	}
+	return lines, nil
+}
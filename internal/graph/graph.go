@@ -0,0 +1,245 @@
+package graph
+
+import (
+	"crowsnest/internal/sqlite"
+	"strconv"
+	"strings"
+)
+
+// Node types
+const (
+	Domain     = "domain"
+	Subdomain  = "subdomain"
+	IP         = "ip"
+	MX         = "mx"
+	NS         = "ns"
+	Registrant = "registrant"
+	Email      = "email"
+	Username   = "username"
+	Password   = "password"
+	Breach     = "breach"
+)
+
+// Edge types
+const (
+	HasSubdomain = "HAS_SUBDOMAIN"
+	ResolvesTo   = "RESOLVES_TO"
+	UsesMX       = "USES_MX"
+	UsesNS       = "USES_NS"
+	RegisteredBy = "REGISTERED_BY"
+	HasContact   = "HAS_CONTACT"
+	HasEmail     = "HAS_EMAIL"
+	HasUsername  = "HAS_USERNAME"
+	UsesPassword = "USES_PASSWORD"
+	ExposedIn    = "EXPOSED_IN"
+	SeenFrom     = "SEEN_FROM"
+)
+
+// hostTypes share their IDs, a host found as a subdomain and as a mail server is one node
+var hostTypes = map[string]bool{Domain: true, Subdomain: true, MX: true, NS: true}
+
+// Node is an entity of the graph
+type Node struct {
+	ID    string
+	Type  string
+	Label string
+}
+
+// Edge is a typed link between two nodes. Weight counts the records the link was found in.
+type Edge struct {
+	Source string
+	Target string
+	Type   string
+	Weight int
+}
+
+// Graph is a directed graph of the stored intelligence, nodes and edges kept in the order they were found
+type Graph struct {
+	Nodes []Node
+	Edges []Edge
+	nodes map[string]int
+	edges map[string]int
+	// linked holds the links already counted for a record, so a record seen through several tables counts once
+	linked map[string]bool
+}
+
+// New returns an empty graph
+func New() *Graph {
+	return &Graph{nodes: make(map[string]int), edges: make(map[string]int), linked: make(map[string]bool)}
+}
+
+// AddNode adds a node unless it exists and returns its ID, empty for an empty value
+func (g *Graph) AddNode(nodeType, value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+	// Passwords and breach names are case sensitive, the rest is not
+	label := value
+	if nodeType != Password && nodeType != Breach && nodeType != Registrant {
+		label = strings.ToLower(strings.TrimSuffix(value, "."))
+	}
+	namespace := nodeType
+	if hostTypes[nodeType] {
+		namespace = "host"
+	}
+	id := namespace + ":" + label
+	if _, ok := g.nodes[id]; !ok {
+		g.nodes[id] = len(g.Nodes)
+		g.Nodes = append(g.Nodes, Node{ID: id, Type: nodeType, Label: label})
+	}
+	return id
+}
+
+// AddEdge links two nodes, counting the links found again. Links to a missing node are ignored.
+func (g *Graph) AddEdge(source, target, edgeType string) {
+	if source == "" || target == "" || source == target {
+		return
+	}
+	key := source + "\x00" + target + "\x00" + edgeType
+	if i, ok := g.edges[key]; ok {
+		g.Edges[i].Weight++
+		return
+	}
+	g.edges[key] = len(g.Edges)
+	g.Edges = append(g.Edges, Edge{Source: source, Target: target, Type: edgeType, Weight: 1})
+}
+
+// link adds an edge found in a record, counting it once per record. The credentials extracted from a Dehashed
+// record share its ID, so the links they repeat do not add to the weight.
+func (g *Graph) link(record, source, target, edgeType string) {
+	if source == "" || target == "" || source == target {
+		return
+	}
+	key := record + "\x00" + source + "\x00" + target + "\x00" + edgeType
+	if g.linked[key] {
+		return
+	}
+	g.linked[key] = true
+	g.AddEdge(source, target, edgeType)
+}
+
+// Build turns the database into a graph of domains, subdomains, IPs, MX and NS hosts, registrants, emails,
+// usernames, passwords and breaches
+func Build() (*Graph, error) {
+	g := New()
+	db := sqlite.GetDB()
+
+	var whois []sqlite.WhoisRecord
+	if err := db.Find(&whois).Error; err != nil {
+		return nil, err
+	}
+	for _, w := range whois {
+		domain := g.AddNode(Domain, w.DomainName)
+		registrant := w.Registrant.Organization
+		if registrant == "" {
+			registrant = w.Registrant.Name
+		}
+		g.AddEdge(domain, g.AddNode(Registrant, registrant), RegisteredBy)
+		g.AddEdge(domain, g.AddNode(Email, w.ContactEmail), HasContact)
+		for _, host := range w.NameServers.HostNames {
+			g.AddEdge(domain, g.AddNode(NS, host), UsesNS)
+		}
+	}
+
+	var subdomains []sqlite.Subdomain
+	if err := db.Find(&subdomains).Error; err != nil {
+		return nil, err
+	}
+	for _, s := range subdomains {
+		g.AddEdge(g.AddNode(Domain, s.Domain), g.AddNode(Subdomain, s.Subdomain), HasSubdomain)
+	}
+
+	var lookups []sqlite.LookupResult
+	if err := db.Find(&lookups).Error; err != nil {
+		return nil, err
+	}
+	for _, l := range lookups {
+		switch l.Type {
+		case "Reverse IP":
+			g.AddEdge(g.AddNode(Domain, l.Name), g.AddNode(IP, l.SearchTerm), ResolvesTo)
+		case "MX":
+			g.AddEdge(g.AddNode(Domain, l.Name), g.AddNode(MX, l.SearchTerm), UsesMX)
+		case "NS":
+			g.AddEdge(g.AddNode(Domain, l.Name), g.AddNode(NS, l.SearchTerm), UsesNS)
+		}
+	}
+
+	var hunterEmails []sqlite.HunterEmail
+	if err := db.Find(&hunterEmails).Error; err != nil {
+		return nil, err
+	}
+	for _, e := range hunterEmails {
+		g.addEmail("hunter_email:"+e.Value, e.Value)
+	}
+
+	var results []sqlite.Result
+	if err := db.Find(&results).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range results {
+		record := resultRecord(r)
+		var accounts []string
+		for _, email := range r.Email {
+			accounts = append(accounts, g.addEmail(record, email))
+		}
+		for _, username := range r.Username {
+			user := g.AddNode(Username, username)
+			for _, email := range accounts {
+				g.link(record, email, user, HasUsername)
+			}
+			accounts = append(accounts, user)
+		}
+		breach := g.AddNode(Breach, r.DatabaseName)
+		for _, account := range accounts {
+			for _, password := range r.Password {
+				g.link(record, account, g.AddNode(Password, password), UsesPassword)
+			}
+			for _, ip := range r.IpAddress {
+				g.link(record, account, g.AddNode(IP, ip), SeenFrom)
+			}
+			g.link(record, account, breach, ExposedIn)
+		}
+	}
+
+	var creds []sqlite.User
+	if err := db.Find(&creds).Error; err != nil {
+		return nil, err
+	}
+	for _, c := range creds {
+		// Credentials extracted from a Dehashed record only add the links the record lacks, e.g. cracked passwords
+		record := "creds:" + strconv.FormatUint(uint64(c.ID), 10)
+		if c.DehashedId != "" {
+			record = "dehashed:" + c.DehashedId
+		}
+		email := g.addEmail(record, c.Email)
+		user := g.AddNode(Username, c.Username)
+		g.link(record, email, user, HasUsername)
+		breach := g.AddNode(Breach, c.DatabaseName)
+		for _, account := range []string{email, user} {
+			if c.Password != "" {
+				g.link(record, account, g.AddNode(Password, c.Password), UsesPassword)
+			}
+			g.link(record, account, breach, ExposedIn)
+		}
+	}
+
+	return g, nil
+}
+
+// addEmail adds an email node linked from the node of its domain, the link counted once for the record
+func (g *Graph) addEmail(record, email string) string {
+	id := g.AddNode(Email, email)
+	if at := strings.LastIndex(email, "@"); at >= 0 {
+		g.link(record, g.AddNode(Domain, email[at+1:]), id, HasEmail)
+	}
+	return id
+}
+
+// resultRecord returns the record key of a Dehashed result, shared with the credentials extracted from it
+func resultRecord(r sqlite.Result) string {
+	if r.DehashedId == "" {
+		return "results:" + strconv.FormatUint(uint64(r.ID), 10)
+	}
+	return "dehashed:" + r.DehashedId
+}
@@ -0,0 +1,260 @@
+package graph
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Formats lists the supported graph formats
+var Formats = []string{"graphml", "gexf", "neo4j", "dot"}
+
+// Write writes the graph in a format next to the base path and returns the files written.
+// Neo4j graphs are written as a nodes and a relationships CSV file for neo4j-admin import.
+func Write(g *Graph, format, base string) ([]string, error) {
+	switch format {
+	case "graphml":
+		return writeFile(base+".graphml", func(w io.Writer) error { return WriteGraphML(w, g) })
+	case "gexf":
+		return writeFile(base+".gexf", func(w io.Writer) error { return WriteGEXF(w, g) })
+	case "dot":
+		return writeFile(base+".dot", func(w io.Writer) error { return WriteDOT(w, g) })
+	case "neo4j":
+		nodes, err := writeFile(base+"_nodes.csv", func(w io.Writer) error { return WriteNeo4jNodes(w, g) })
+		if err != nil {
+			return nodes, err
+		}
+		relationships, err := writeFile(base+"_relationships.csv", func(w io.Writer) error { return WriteNeo4jRelationships(w, g) })
+		return append(nodes, relationships...), err
+	default:
+		return nil, fmt.Errorf("unknown format %q (%s)", format, strings.Join(Formats, ", "))
+	}
+}
+
+func writeFile(path string, write func(io.Writer) error) ([]string, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriter(f)
+	if err = write(w); err == nil {
+		err = w.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return []string{path}, err
+}
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   struct {
+		ID          string        `xml:"id,attr"`
+		EdgeDefault string        `xml:"edgedefault,attr"`
+		Nodes       []graphMLItem `xml:"node"`
+		Edges       []graphMLItem `xml:"edge"`
+	} `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLItem struct {
+	ID     string        `xml:"id,attr"`
+	Source string        `xml:"source,attr,omitempty"`
+	Target string        `xml:"target,attr,omitempty"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// WriteGraphML writes the graph as GraphML, with the type and label of nodes and the type and weight of edges as data
+func WriteGraphML(w io.Writer, g *Graph) error {
+	doc := graphML{
+		Xmlns: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "type", For: "node", Name: "type", Type: "string"},
+			{ID: "label", For: "node", Name: "label", Type: "string"},
+			{ID: "relation", For: "edge", Name: "type", Type: "string"},
+			{ID: "weight", For: "edge", Name: "weight", Type: "int"},
+		},
+	}
+	doc.Graph.ID = "crowsnest"
+	doc.Graph.EdgeDefault = "directed"
+	for _, n := range g.Nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLItem{
+			ID:   n.ID,
+			Data: []graphMLData{{Key: "type", Value: n.Type}, {Key: "label", Value: n.Label}},
+		})
+	}
+	for i, e := range g.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLItem{
+			ID:     "e" + strconv.Itoa(i),
+			Source: e.Source,
+			Target: e.Target,
+			Data:   []graphMLData{{Key: "relation", Value: e.Type}, {Key: "weight", Value: strconv.Itoa(e.Weight)}},
+		})
+	}
+	return writeXML(w, doc)
+}
+
+type gexf struct {
+	XMLName xml.Name `xml:"gexf"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Version string   `xml:"version,attr"`
+	Creator string   `xml:"meta>creator"`
+	Graph   struct {
+		DefaultEdgeType string `xml:"defaultedgetype,attr"`
+		Mode            string `xml:"mode,attr"`
+		Attributes      struct {
+			Class      string          `xml:"class,attr"`
+			Attributes []gexfAttribute `xml:"attribute"`
+		} `xml:"attributes"`
+		Nodes []gexfNode `xml:"nodes>node"`
+		Edges []gexfEdge `xml:"edges>edge"`
+	} `xml:"graph"`
+}
+
+type gexfAttribute struct {
+	ID    string `xml:"id,attr"`
+	Title string `xml:"title,attr"`
+	Type  string `xml:"type,attr"`
+}
+
+type gexfNode struct {
+	ID        string         `xml:"id,attr"`
+	Label     string         `xml:"label,attr"`
+	AttValues []gexfAttValue `xml:"attvalues>attvalue"`
+}
+
+type gexfAttValue struct {
+	For   string `xml:"for,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type gexfEdge struct {
+	ID     string `xml:"id,attr"`
+	Source string `xml:"source,attr"`
+	Target string `xml:"target,attr"`
+	Label  string `xml:"label,attr"`
+	Weight int    `xml:"weight,attr"`
+}
+
+// WriteGEXF writes the graph as GEXF 1.3 for Gephi, with the node type as attribute and the edge type as label
+func WriteGEXF(w io.Writer, g *Graph) error {
+	doc := gexf{Xmlns: "http://gexf.net/1.3", Version: "1.3", Creator: "crowsnest"}
+	doc.Graph.DefaultEdgeType = "directed"
+	doc.Graph.Mode = "static"
+	doc.Graph.Attributes.Class = "node"
+	doc.Graph.Attributes.Attributes = []gexfAttribute{{ID: "type", Title: "type", Type: "string"}}
+
+	for _, n := range g.Nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, gexfNode{
+			ID:        n.ID,
+			Label:     n.Label,
+			AttValues: []gexfAttValue{{For: "type", Value: n.Type}},
+		})
+	}
+	for i, e := range g.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, gexfEdge{
+			ID:     "e" + strconv.Itoa(i),
+			Source: e.Source,
+			Target: e.Target,
+			Label:  e.Type,
+			Weight: e.Weight,
+		})
+	}
+	return writeXML(w, doc)
+}
+
+func writeXML(w io.Writer, doc interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// WriteNeo4jNodes writes the nodes as a neo4j-admin import CSV file, labelled with their type
+func WriteNeo4jNodes(w io.Writer, g *Graph) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"id:ID", "name", ":LABEL"}); err != nil {
+		return err
+	}
+	for _, n := range g.Nodes {
+		if err := writer.Write([]string{n.ID, n.Label, neo4jLabel(n.Type)}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteNeo4jRelationships writes the edges as a neo4j-admin import CSV file
+func WriteNeo4jRelationships(w io.Writer, g *Graph) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{":START_ID", ":END_ID", ":TYPE", "weight:int"}); err != nil {
+		return err
+	}
+	for _, e := range g.Edges {
+		if err := writer.Write([]string{e.Source, e.Target, e.Type, strconv.Itoa(e.Weight)}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// neo4jLabel returns the node label of a type, e.g. Email or IP
+func neo4jLabel(nodeType string) string {
+	switch nodeType {
+	case IP, MX, NS:
+		return strings.ToUpper(nodeType)
+	default:
+		return strings.ToUpper(nodeType[:1]) + nodeType[1:]
+	}
+}
+
+// WriteDOT writes the graph as a Graphviz digraph, shaping nodes by type
+func WriteDOT(w io.Writer, g *Graph) error {
+	shapes := map[string]string{
+		Domain: "box", Subdomain: "box", IP: "diamond", MX: "box", NS: "box", Registrant: "house",
+		Email: "ellipse", Username: "ellipse", Password: "note", Breach: "cylinder",
+	}
+	var sb strings.Builder
+	sb.WriteString("digraph crowsnest {\n")
+	sb.WriteString("  rankdir=LR;\n")
+	for _, n := range g.Nodes {
+		sb.WriteString(fmt.Sprintf("  %s [label=%s, type=%s, shape=%s];\n", dotQuote(n.ID), dotQuote(n.Label), dotQuote(n.Type), shapes[n.Type]))
+	}
+	for _, e := range g.Edges {
+		sb.WriteString(fmt.Sprintf("  %s -> %s [label=%s, weight=%d];\n", dotQuote(e.Source), dotQuote(e.Target), dotQuote(e.Type), e.Weight))
+	}
+	sb.WriteString("}\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func dotQuote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", "").Replace(s)
+	return `"` + s + `"`
+}
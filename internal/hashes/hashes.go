@@ -0,0 +1,108 @@
+package hashes
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Type is a password hash format and its hashcat mode and john format
+type Type struct {
+	Name    string
+	Hashcat int
+	John    string
+	pattern *regexp.Regexp
+}
+
+// Unknown is returned for hashes that match no known format
+var Unknown = Type{Name: "unknown", Hashcat: -1}
+
+// Types lists the known formats. Formats sharing a pattern are ordered by how common they are in breach data.
+var Types = []Type{
+	{Name: "bcrypt", Hashcat: 3200, John: "bcrypt", pattern: regexp.MustCompile(`^\$2[abxy]?\$\d{2}\$[./A-Za-z0-9]{53}$`)},
+	{Name: "md5crypt", Hashcat: 500, John: "md5crypt", pattern: regexp.MustCompile(`^\$1\$[^$]{0,8}\$[./A-Za-z0-9]{22}$`)},
+	{Name: "sha256crypt", Hashcat: 7400, John: "sha256crypt", pattern: regexp.MustCompile(`^\$5\$(rounds=\d+\$)?[^$]{0,16}\$[./A-Za-z0-9]{43}$`)},
+	{Name: "sha512crypt", Hashcat: 1800, John: "sha512crypt", pattern: regexp.MustCompile(`^\$6\$(rounds=\d+\$)?[^$]{0,16}\$[./A-Za-z0-9]{86}$`)},
+	{Name: "phpass", Hashcat: 400, John: "phpass", pattern: regexp.MustCompile(`^\$[PH]\$[./A-Za-z0-9]{31}$`)},
+	{Name: "argon2", Hashcat: 34000, John: "argon2", pattern: regexp.MustCompile(`^\$argon2(i|d|id)\$`)},
+	{Name: "mysql5", Hashcat: 300, John: "mysql-sha1", pattern: regexp.MustCompile(`^\*[A-Fa-f0-9]{40}$`)},
+	{Name: "md5", Hashcat: 0, John: "raw-md5", pattern: regexp.MustCompile(`^[A-Fa-f0-9]{32}$`)},
+	{Name: "ntlm", Hashcat: 1000, John: "nt", pattern: regexp.MustCompile(`^[A-Fa-f0-9]{32}$`)},
+	{Name: "sha1", Hashcat: 100, John: "raw-sha1", pattern: regexp.MustCompile(`^[A-Fa-f0-9]{40}$`)},
+	{Name: "sha256", Hashcat: 1400, John: "raw-sha256", pattern: regexp.MustCompile(`^[A-Fa-f0-9]{64}$`)},
+	{Name: "sha512", Hashcat: 1700, John: "raw-sha512", pattern: regexp.MustCompile(`^[A-Fa-f0-9]{128}$`)},
+	{Name: "descrypt", Hashcat: 1500, John: "descrypt", pattern: regexp.MustCompile(`^[./A-Za-z0-9]{13}$`)},
+}
+
+// aliases maps the spellings breach sources use for a hash type to the known format names
+var aliases = map[string]string{
+	"nt":          "ntlm",
+	"ntlmv1":      "ntlm",
+	"sha":         "sha1",
+	"bcrypt2y":    "bcrypt",
+	"blowfish":    "bcrypt",
+	"md5unix":     "md5crypt",
+	"sha256unix":  "sha256crypt",
+	"sha512unix":  "sha512crypt",
+	"wordpress":   "phpass",
+	"phpbb3":      "phpass",
+	"mysql41":     "mysql5",
+	"mysqlsha1":   "mysql5",
+	"argon2id":    "argon2",
+	"argon2i":     "argon2",
+	"argon2d":     "argon2",
+	"traditional": "descrypt",
+	"des":         "descrypt",
+}
+
+// Lookup returns the format for a hash type name such as "SHA-1" or "sha512_crypt"
+func Lookup(name string) (Type, bool) {
+	name = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, strings.ToLower(name))
+	if alias, ok := aliases[name]; ok {
+		name = alias
+	}
+	for _, t := range Types {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return Unknown, false
+}
+
+// Identify returns the formats the hash could be, most likely first.
+// Uppercase 32 character hex is taken as NTLM, the way Windows tooling prints it, and lowercase as MD5.
+func Identify(hash string) []Type {
+	hash = strings.TrimSpace(hash)
+	var matches []Type
+	for _, t := range Types {
+		if t.pattern.MatchString(hash) {
+			matches = append(matches, t)
+		}
+	}
+	if len(hash) == 32 && hash == strings.ToUpper(hash) && strings.ContainsAny(hash, "ABCDEF") {
+		for i, t := range matches {
+			if t.Name == "ntlm" {
+				matches = append([]Type{t}, append(matches[:i:i], matches[i+1:]...)...)
+				break
+			}
+		}
+	}
+	return matches
+}
+
+// Classify returns the format of a hash, trusting the type supplied by the source when it is known
+func Classify(hash, supplied string) Type {
+	if supplied != "" {
+		if t, ok := Lookup(supplied); ok {
+			return t
+		}
+	}
+	if matches := Identify(hash); len(matches) > 0 {
+		return matches[0]
+	}
+	return Unknown
+}
@@ -0,0 +1,71 @@
+package hashes
+
+import (
+	"strings"
+	"testing"
+)
+
+func names(types []Type) string {
+	var out []string
+	for _, t := range types {
+		out = append(out, t.Name)
+	}
+	return strings.Join(out, ",")
+}
+
+func TestIdentify(t *testing.T) {
+	tests := []struct {
+		name string
+		hash string
+		want string
+	}{
+		{"md5 lowercase", "5f4dcc3b5aa765d61d8327deb882cf99", "md5,ntlm"},
+		{"ntlm uppercase", "8846F7EAEE8FB117AD06BDD830B7586C", "ntlm,md5"},
+		{"digits only", "12345678901234567890123456789012", "md5,ntlm"},
+		{"sha1", "5baa61e4c9b93f3f0682250b6cf8331b7ee68fd8", "sha1"},
+		{"sha256", "5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8", "sha256"},
+		{"sha512", strings.Repeat("ab", 64), "sha512"},
+		{"bcrypt", "$2y$10$" + strings.Repeat("a", 53), "bcrypt"},
+		{"md5crypt", "$1$saltsalt$" + strings.Repeat("a", 22), "md5crypt"},
+		{"sha512crypt", "$6$rounds=5000$salt$" + strings.Repeat("a", 86), "sha512crypt"},
+		{"phpass", "$P$" + strings.Repeat("a", 31), "phpass"},
+		{"argon2", "$argon2id$v=19$m=65536,t=3,p=4$c2FsdA$aGFzaA", "argon2"},
+		{"mysql5", "*2470C0C06DEE42FD1618BB99005ADCA2EC9D1E19", "mysql5"},
+		{"descrypt", "abJnggxhB/yWI", "descrypt"},
+		{"surrounding space", " 5baa61e4c9b93f3f0682250b6cf8331b7ee68fd8 ", "sha1"},
+		{"plaintext", "password123", ""},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := names(Identify(tt.hash)); got != tt.want {
+				t.Errorf("Identify(%q) = %s, want %s", tt.hash, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name     string
+		hash     string
+		supplied string
+		want     string
+		hashcat  int
+	}{
+		{"supplied type", "5f4dcc3b5aa765d61d8327deb882cf99", "NTLM", "ntlm", 1000},
+		{"supplied alias", "anything", "SHA-512 Crypt", "sha512crypt", 1800},
+		{"supplied alias with separators", "anything", "sha512_unix", "sha512crypt", 1800},
+		{"unknown supplied type", "5baa61e4c9b93f3f0682250b6cf8331b7ee68fd8", "custom", "sha1", 100},
+		{"identified", "8846F7EAEE8FB117AD06BDD830B7586C", "", "ntlm", 1000},
+		{"unknown", "hunter2", "", "unknown", -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Classify(tt.hash, tt.supplied)
+			if got.Name != tt.want || got.Hashcat != tt.hashcat {
+				t.Errorf("Classify(%q, %q) = %s (%d), want %s (%d)", tt.hash, tt.supplied, got.Name, got.Hashcat, tt.want, tt.hashcat)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package hashes
+
+import (
+	"bufio"
+	"encoding/hex"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var (
+	// johnPrefix matches the tags john adds to raw hashes in its potfile, e.g. $NT$ or $dynamic_0$
+	johnPrefix = regexp.MustCompile(`^\$(NT|LM|dynamic_\d+)\$`)
+	hexHash    = regexp.MustCompile(`^\*?[A-Fa-f0-9]+$`)
+)
+
+// Potfile maps cracked hashes to their plaintext, read from a hashcat or john potfile
+type Potfile map[string]string
+
+// ReadPotfile reads the hash:plain lines of a hashcat or john potfile.
+// Salted hashes and plaintexts may contain colons, so every split of a line is kept and the stored hash picks the right one.
+func ReadPotfile(path string) (Potfile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	pot := make(Potfile)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		for i := 0; i < len(line); i++ {
+			if line[i] != ':' || i == 0 {
+				continue
+			}
+			key := Key(line[:i])
+			if _, ok := pot[key]; !ok {
+				pot[key] = decodePlain(line[i+1:])
+			}
+		}
+	}
+	return pot, scanner.Err()
+}
+
+// Plain returns the cracked plaintext of a stored hash
+func (p Potfile) Plain(hash string) (string, bool) {
+	plain, ok := p[Key(hash)]
+	return plain, ok
+}
+
+// Key normalizes a hash so stored and potfile values compare equal.
+// Hashcat writes hex hashes in lowercase and john tags raw hashes with their format.
+func Key(hash string) string {
+	hash = johnPrefix.ReplaceAllString(strings.TrimSpace(hash), "")
+	if hexHash.MatchString(hash) {
+		return strings.ToLower(hash)
+	}
+	return hash
+}
+
+// decodePlain decodes the $HEX[...] form hashcat writes plaintexts containing colons or non printable bytes in
+func decodePlain(plain string) string {
+	if strings.HasPrefix(plain, "$HEX[") && strings.HasSuffix(plain, "]") {
+		if decoded, err := hex.DecodeString(plain[5 : len(plain)-1]); err == nil {
+			return string(decoded)
+		}
+	}
+	return plain
+}
@@ -0,0 +1,44 @@
+package hashes
+
+import "testing"
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		hash string
+		want string
+	}{
+		{"8846F7EAEE8FB117AD06BDD830B7586C", "8846f7eaee8fb117ad06bdd830b7586c"},
+		{"$NT$8846F7EAEE8FB117AD06BDD830B7586C", "8846f7eaee8fb117ad06bdd830b7586c"},
+		{"$dynamic_0$5F4DCC3B5AA765D61D8327DEB882CF99", "5f4dcc3b5aa765d61d8327deb882cf99"},
+		{"*2470C0C06DEE42FD1618BB99005ADCA2EC9D1E19", "*2470c0c06dee42fd1618bb99005adca2ec9d1e19"},
+		{" 5baa61e4c9b93f3f0682250b6cf8331b7ee68fd8\t", "5baa61e4c9b93f3f0682250b6cf8331b7ee68fd8"},
+		// Salted and encoded hashes are case sensitive
+		{"$2y$10$AbCdEf", "$2y$10$AbCdEf"},
+		{"abJnggxhB/yWI", "abJnggxhB/yWI"},
+	}
+	for _, tt := range tests {
+		if got := Key(tt.hash); got != tt.want {
+			t.Errorf("Key(%q) = %q, want %q", tt.hash, got, tt.want)
+		}
+	}
+}
+
+func TestDecodePlain(t *testing.T) {
+	tests := []struct {
+		plain string
+		want  string
+	}{
+		{"password", "password"},
+		{"$HEX[706173733a776f7264]", "pass:word"},
+		{"$HEX[c3a96c6c65]", "élle"},
+		{"$HEX[]", ""},
+		{"$HEX[zz]", "$HEX[zz]"},
+		{"$HEX[7061", "$HEX[7061"},
+		{"pre$HEX[70]", "pre$HEX[70]"},
+	}
+	for _, tt := range tests {
+		if got := decodePlain(tt.plain); got != tt.want {
+			t.Errorf("decodePlain(%q) = %q, want %q", tt.plain, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// Capture is a response as received from the provider, kept so it can be parsed again later
+type Capture struct {
+	Provider   Provider
+	Operation  string
+	RunID      uint
+	Method     string
+	Endpoint   string // Request URI with API keys redacted
+	StatusCode int
+	Request    []byte
+	Response   []byte
+}
+
+// Recorder stores a captured response
+type Recorder func(capture Capture)
+
+type runKey struct{}
+
+// WithRunID tags the request with the run it belongs to so captured responses can be traced back to it
+func WithRunID(req *http.Request, runID uint) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), runKey{}, runID))
+}
+
+// RunIDOf returns the run the request belongs to, or 0 when it was not tagged
+func RunIDOf(req *http.Request) uint {
+	runID, _ := req.Context().Value(runKey{}).(uint)
+	return runID
+}
+
+// SetRecorder sets the recorder every response is captured to
+func (c *Client) SetRecorder(recorder Recorder) {
+	c.recorder = recorder
+}
+
+// capture hands a copy of the response to the recorder, leaving the body readable for the caller
+func (c *Client) capture(req *http.Request, resp *http.Response) error {
+	response, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(response))
+	if err != nil {
+		return err
+	}
+
+	var request []byte
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			request, _ = io.ReadAll(body)
+			body.Close()
+		}
+	}
+
+	endpoint := req.URL.RequestURI()
+	if redacted, err := url.Parse(redactURL(req.URL)); err == nil {
+		endpoint = redacted.RequestURI()
+	}
+
+	cost, _ := CostOf(req)
+	c.recorder(Capture{
+		Provider:   c.provider,
+		Operation:  cost.Operation,
+		RunID:      RunIDOf(req),
+		Method:     req.Method,
+		Endpoint:   endpoint,
+		StatusCode: resp.StatusCode,
+		Request:    request,
+		Response:   response,
+	})
+	return nil
+}
+
+// boundContext takes cancellation from the client context and values from the request context
+type boundContext struct {
+	context.Context
+	values context.Context
+}
+
+func (b boundContext) Value(key any) any {
+	if v := b.values.Value(key); v != nil {
+		return v
+	}
+	return b.Context.Value(key)
+}
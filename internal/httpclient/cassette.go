@@ -0,0 +1,221 @@
+package httpclient
+
+import (
+	"bytes"
+	"crowsnest/internal/debug"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Query parameters that carry credentials and are never written to a cassette
+var redactedParams = []string{"api_key", "apikey", "key"}
+
+// errNoFixture is returned when replaying a request that was never recorded
+var errNoFixture = errors.New("no recorded fixture")
+
+// Sequence numbers are shared by every client in the process so repeated requests map to distinct fixtures
+var (
+	sequenceMu sync.Mutex
+	sequences  = make(map[string]int)
+)
+
+// Fixture is a single recorded request/response pair
+type Fixture struct {
+	Provider    Provider            `json:"provider"`
+	Method      string              `json:"method"`
+	URL         string              `json:"url"`
+	RequestBody string              `json:"request_body,omitempty"`
+	StatusCode  int                 `json:"status_code"`
+	Headers     map[string][]string `json:"headers,omitempty"`
+	Body        string              `json:"body"`
+	RecordedAt  time.Time           `json:"recorded_at"`
+}
+
+// cassette records or replays fixtures for a single provider
+type cassette struct {
+	provider Provider
+	dir      string
+	replay   bool
+	next     http.RoundTripper
+	debug    bool
+}
+
+func newCassette(provider Provider, dir string, replay bool, next http.RoundTripper, debugEnabled bool) (*cassette, error) {
+	if replay {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open replay directory: %w", err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("replay path '%s' is not a directory", dir)
+		}
+	} else {
+		err := os.MkdirAll(dir, 0755)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create record directory: %w", err)
+		}
+	}
+
+	return &cassette{
+		provider: provider,
+		dir:      dir,
+		replay:   replay,
+		next:     next,
+		debug:    debugEnabled,
+	}, nil
+}
+
+// RoundTrip serves the request from the cassette when replaying, otherwise performs and records it
+func (c *cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	redactedURL := redactURL(req.URL)
+	path := c.fixturePath(req.Method, req.URL, reqBody)
+
+	if c.replay {
+		return c.load(req, path)
+	}
+
+	resp, err := c.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response for recording: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	headers := resp.Header.Clone()
+	headers.Del("Set-Cookie")
+
+	fixture := Fixture{
+		Provider:    c.provider,
+		Method:      req.Method,
+		URL:         redactedURL,
+		RequestBody: string(reqBody),
+		StatusCode:  resp.StatusCode,
+		Headers:     headers,
+		Body:        string(body),
+		RecordedAt:  time.Now(),
+	}
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode fixture: %w", err)
+	}
+	err = os.WriteFile(path, data, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write fixture: %w", err)
+	}
+
+	if c.debug {
+		debug.PrintInfo(fmt.Sprintf("recorded %s %s to %s", req.Method, redactedURL, path))
+	}
+	zap.L().Info("cassette_record",
+		zap.String("provider", string(c.provider)),
+		zap.String("url", redactedURL),
+		zap.String("fixture", path),
+	)
+
+	return resp, nil
+}
+
+func (c *cassette) load(req *http.Request, path string) (*http.Response, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		zap.L().Error("cassette_replay",
+			zap.String("message", "no fixture recorded for request"),
+			zap.String("provider", string(c.provider)),
+			zap.String("url", redactURL(req.URL)),
+			zap.String("fixture", path),
+		)
+		return nil, fmt.Errorf("%w for %s %s", errNoFixture, req.Method, redactURL(req.URL))
+	}
+
+	var fixture Fixture
+	err = json.Unmarshal(data, &fixture)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode fixture %s: %w", path, err)
+	}
+
+	if c.debug {
+		debug.PrintInfo(fmt.Sprintf("replayed %s %s from %s", req.Method, fixture.URL, path))
+	}
+	zap.L().Info("cassette_replay",
+		zap.String("provider", string(c.provider)),
+		zap.String("url", fixture.URL),
+		zap.String("fixture", path),
+	)
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", fixture.StatusCode, http.StatusText(fixture.StatusCode)),
+		StatusCode:    fixture.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header(fixture.Headers),
+		Body:          io.NopCloser(bytes.NewReader([]byte(fixture.Body))),
+		ContentLength: int64(len(fixture.Body)),
+		Request:       req,
+	}, nil
+}
+
+// fixturePath returns the file for the request, numbering repeated identical requests in order.
+// The host is left out of the key so a cassette can be replayed against any base url.
+func (c *cassette) fixturePath(method string, u *url.URL, body []byte) string {
+	redacted, _ := url.Parse(redactURL(u))
+	sum := sha256.Sum256([]byte(method + " " + redacted.RequestURI() + "\n" + string(body)))
+	name := fmt.Sprintf("%s-%s", c.provider, hex.EncodeToString(sum[:])[:16])
+
+	counter := fmt.Sprintf("%t:%s", c.replay, filepath.Join(c.dir, name))
+	sequenceMu.Lock()
+	sequences[counter]++
+	seq := sequences[counter]
+	sequenceMu.Unlock()
+
+	return filepath.Join(c.dir, fmt.Sprintf("%s-%03d.json", name, seq))
+}
+
+// readRequestBody reads the request body and restores it so the request can still be sent
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// redactURL strips credentials from the url so it can be stored and matched safely
+func redactURL(u *url.URL) string {
+	redacted := *u
+	query := redacted.Query()
+	for _, param := range redactedParams {
+		if query.Has(param) {
+			query.Set(param, "REDACTED")
+		}
+	}
+	redacted.RawQuery = query.Encode()
+	redacted.User = nil
+	return redacted.String()
+}
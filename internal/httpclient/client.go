@@ -0,0 +1,366 @@
+package httpclient
+
+import (
+	"context"
+	"crowsnest/internal/debug"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+type Provider string
+
+const (
+	Dehashed Provider = "dehashed"
+	Whois    Provider = "whois"
+	Hunter   Provider = "hunter"
+)
+
+// DefaultBaseURL returns the public API endpoint for the provider
+func (p Provider) DefaultBaseURL() string {
+	switch p {
+	case Dehashed, Whois:
+		return "https://api.dehashed.com"
+	case Hunter:
+		return "https://api.hunter.io"
+	default:
+		return ""
+	}
+}
+
+// ConfigProvider returns the provider whose stored transport settings apply, WHOIS shares the Dehashed API
+func (p Provider) ConfigProvider() Provider {
+	if p == Whois {
+		return Dehashed
+	}
+	return p
+}
+
+// GetProvider returns the provider matching the user input
+func GetProvider(userInput string) (Provider, error) {
+	switch strings.ToLower(strings.TrimSpace(userInput)) {
+	case "dehashed":
+		return Dehashed, nil
+	case "whois":
+		return Whois, nil
+	case "hunter", "hunter.io":
+		return Hunter, nil
+	default:
+		return "", fmt.Errorf("unknown provider '%s' (dehashed, whois, hunter)", userInput)
+	}
+}
+
+// Config holds the transport settings for a single provider
+type Config struct {
+	BaseURL            string            `json:"base_url,omitempty"`
+	Proxy              string            `json:"proxy,omitempty"`
+	Timeout            int               `json:"timeout,omitempty"` // Seconds
+	InsecureSkipVerify *bool             `json:"insecure_skip_verify,omitempty"`
+	CACertFile         string            `json:"ca_cert_file,omitempty"`
+	MaxRetries         *int              `json:"max_retries,omitempty"`
+	RetryWait          *int              `json:"retry_wait,omitempty"` // Seconds
+	RateLimit          float64           `json:"rate_limit,omitempty"` // Requests per second
+	Burst              int               `json:"burst,omitempty"`
+	Transport          http.RoundTripper `json:"-"`
+
+	// Fixture cassette directories, set per run and never stored
+	RecordDir string `json:"-"`
+	ReplayDir string `json:"-"`
+}
+
+// Merge overlays the non-empty values of other onto the config
+func (c Config) Merge(other Config) Config {
+	if other.BaseURL != "" {
+		c.BaseURL = other.BaseURL
+	}
+	if other.Proxy != "" {
+		c.Proxy = other.Proxy
+	}
+	if other.Timeout > 0 {
+		c.Timeout = other.Timeout
+	}
+	if other.InsecureSkipVerify != nil {
+		c.InsecureSkipVerify = other.InsecureSkipVerify
+	}
+	if other.CACertFile != "" {
+		c.CACertFile = other.CACertFile
+	}
+	if other.MaxRetries != nil {
+		c.MaxRetries = other.MaxRetries
+	}
+	if other.RetryWait != nil {
+		c.RetryWait = other.RetryWait
+	}
+	if other.RateLimit > 0 {
+		c.RateLimit = other.RateLimit
+	}
+	if other.Burst > 0 {
+		c.Burst = other.Burst
+	}
+	if other.Transport != nil {
+		c.Transport = other.Transport
+	}
+	if other.RecordDir != "" {
+		c.RecordDir = other.RecordDir
+	}
+	if other.ReplayDir != "" {
+		c.ReplayDir = other.ReplayDir
+	}
+	return c
+}
+
+// Client is the shared HTTP layer used by every provider
+type Client struct {
+	provider   Provider
+	baseURL    string
+	http       *http.Client
+	maxRetries int
+	retryWait  time.Duration
+	limiter    *RateLimiter
+	replay     bool
+	recorder   Recorder
+	ctx        context.Context
+	guard      Guard
+	dryRun     bool
+	debug      bool
+}
+
+// New creates a client for the provider from the given config
+func New(provider Provider, cfg Config, debugEnabled bool) (*Client, error) {
+	baseURL := strings.TrimRight(strings.TrimSpace(cfg.BaseURL), "/")
+	if baseURL == "" {
+		baseURL = provider.DefaultBaseURL()
+	}
+	if _, err := url.ParseRequestURI(baseURL); err != nil {
+		return nil, fmt.Errorf("invalid base url '%s': %w", baseURL, err)
+	}
+
+	transport := cfg.Transport
+	if transport == nil {
+		t, err := newTransport(cfg)
+		if err != nil {
+			return nil, err
+		}
+		transport = t
+	}
+
+	if cfg.RecordDir != "" && cfg.ReplayDir != "" {
+		return nil, errors.New("record and replay cannot be used together")
+	}
+	if cfg.RecordDir != "" {
+		c, err := newCassette(provider, cfg.RecordDir, false, transport, debugEnabled)
+		if err != nil {
+			return nil, err
+		}
+		transport = c
+	}
+	if cfg.ReplayDir != "" {
+		c, err := newCassette(provider, cfg.ReplayDir, true, transport, debugEnabled)
+		if err != nil {
+			return nil, err
+		}
+		transport = c
+	}
+
+	httpClient := &http.Client{Transport: transport}
+	if cfg.Timeout > 0 {
+		httpClient.Timeout = time.Duration(cfg.Timeout) * time.Second
+	}
+
+	if debugEnabled {
+		debug.PrintInfo(fmt.Sprintf("%s client using base url: %s", provider, baseURL))
+		if cfg.Proxy != "" {
+			debug.PrintInfo(fmt.Sprintf("%s client using proxy: %s", provider, cfg.Proxy))
+		}
+		if cfg.RecordDir != "" {
+			debug.PrintInfo(fmt.Sprintf("%s client recording fixtures to: %s", provider, cfg.RecordDir))
+		}
+		if cfg.ReplayDir != "" {
+			debug.PrintInfo(fmt.Sprintf("%s client replaying fixtures from: %s", provider, cfg.ReplayDir))
+		}
+	}
+	zap.L().Info("http_client",
+		zap.String("provider", string(provider)),
+		zap.String("base_url", baseURL),
+		zap.Bool("proxy", cfg.Proxy != ""),
+		zap.Int("timeout", cfg.Timeout),
+		zap.Any("insecure", cfg.InsecureSkipVerify),
+		zap.Any("max_retries", cfg.MaxRetries),
+		zap.Any("retry_wait", cfg.RetryWait),
+		zap.Float64("rate_limit", cfg.RateLimit),
+		zap.Int("burst", cfg.Burst),
+		zap.String("record_dir", cfg.RecordDir),
+		zap.String("replay_dir", cfg.ReplayDir),
+	)
+
+	maxRetries := DefaultMaxRetries
+	if cfg.MaxRetries != nil {
+		maxRetries = *cfg.MaxRetries
+	}
+	if maxRetries < 0 {
+		return nil, fmt.Errorf("invalid max retries '%d'", maxRetries)
+	}
+	retryWait := DefaultRetryWait
+	if cfg.RetryWait != nil {
+		retryWait = time.Duration(*cfg.RetryWait) * time.Second
+	}
+	if retryWait < 0 {
+		return nil, fmt.Errorf("invalid retry wait '%s'", retryWait)
+	}
+	if cfg.RateLimit < 0 || cfg.Burst < 0 {
+		return nil, fmt.Errorf("invalid rate limit '%g' with burst '%d'", cfg.RateLimit, cfg.Burst)
+	}
+
+	// Replayed fixtures never reach the provider so they are not rate limited
+	var limiter *RateLimiter
+	if cfg.ReplayDir == "" {
+		rate := cfg.RateLimit
+		if rate == 0 {
+			rate = provider.DefaultRateLimit()
+		}
+		burst := cfg.Burst
+		if burst == 0 {
+			burst = int(rate)
+		}
+		limiter = sharedLimiter(provider, rate, burst)
+	}
+
+	return &Client{
+		provider:   provider,
+		baseURL:    baseURL,
+		http:       httpClient,
+		maxRetries: maxRetries,
+		retryWait:  retryWait,
+		limiter:    limiter,
+		replay:     cfg.ReplayDir != "",
+		debug:      debugEnabled,
+	}, nil
+}
+
+// Default returns a client for the provider using the public API and default transport
+func Default(provider Provider, debugEnabled bool) *Client {
+	return &Client{
+		provider:   provider,
+		baseURL:    provider.DefaultBaseURL(),
+		http:       &http.Client{},
+		maxRetries: DefaultMaxRetries,
+		retryWait:  DefaultRetryWait,
+		limiter:    defaultLimiter(provider),
+		debug:      debugEnabled,
+	}
+}
+
+func newTransport(cfg Config) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if cfg.Proxy != "" {
+		proxyURL, err := url.Parse(cfg.Proxy)
+		if err != nil || proxyURL.Scheme == "" || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy url '%s'", cfg.Proxy)
+		}
+		switch proxyURL.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme '%s' (http, https, socks5, socks5h)", proxyURL.Scheme)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	insecure := cfg.InsecureSkipVerify != nil && *cfg.InsecureSkipVerify
+	if insecure || cfg.CACertFile != "" {
+		tlsConfig := &tls.Config{InsecureSkipVerify: insecure}
+		if cfg.CACertFile != "" {
+			pem, err := os.ReadFile(cfg.CACertFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read ca certificate: %w", err)
+			}
+			pool, err := x509.SystemCertPool()
+			if err != nil || pool == nil {
+				pool = x509.NewCertPool()
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, errors.New("no certificates found in ca certificate file")
+			}
+			tlsConfig.RootCAs = pool
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return transport, nil
+}
+
+// Provider returns the provider this client was created for
+func (c *Client) Provider() Provider {
+	return c.provider
+}
+
+// BaseURL returns the base url requests are sent to
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// URL joins the base url with the given api path
+func (c *Client) URL(path string) string {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return c.baseURL + path
+}
+
+// SetContext sets the context every request is bound to, cancelling it aborts requests in flight
+func (c *Client) SetContext(ctx context.Context) {
+	c.ctx = ctx
+}
+
+// Do performs the request with the configured transport, retrying rate limits and transient failures.
+// Requests wait for the provider rate limit and are bound to the client context when one is set.
+// Requests tagged with WithCost reserve credits from the guard first and are charged once they succeed.
+// Responses are handed to the recorder when one is set. In dry-run mode the request is printed and ErrDryRun is returned.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	if c.dryRun {
+		return nil, c.printDryRun(req)
+	}
+
+	if c.ctx != nil {
+		req = req.WithContext(boundContext{Context: c.ctx, values: req.Context()})
+	}
+
+	cost, priced := CostOf(req)
+	if priced && c.guard != nil {
+		err := c.guard.Allow(c.provider, cost.Operation, cost.Credits)
+		if err != nil {
+			if c.debug {
+				debug.PrintInfo(fmt.Sprintf("%s request blocked by budget", c.provider))
+				debug.PrintError(err)
+			}
+			zap.L().Error("http_budget",
+				zap.String("message", "request blocked by budget"),
+				zap.String("provider", string(c.provider)),
+				zap.String("operation", cost.Operation),
+				zap.Int("credits", cost.Credits),
+				zap.Error(err),
+			)
+			return nil, err
+		}
+	}
+
+	resp, err := c.doWithRetry(req)
+	if priced && c.guard != nil {
+		if err == nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			c.guard.Spend(c.provider, cost.Operation, cost.Credits)
+		} else {
+			c.guard.Release(c.provider, cost.Operation, cost.Credits)
+		}
+	}
+	if err == nil && c.recorder != nil {
+		err = c.capture(req, resp)
+	}
+	return resp, err
+}
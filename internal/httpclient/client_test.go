@@ -0,0 +1,25 @@
+package httpclient
+
+import "testing"
+
+func TestConfigMergeInsecure(t *testing.T) {
+	on, off := true, false
+	tests := []struct {
+		name   string
+		stored *bool
+		flag   *bool
+		want   bool
+	}{
+		{"unset", nil, nil, false},
+		{"stored on", &on, nil, true},
+		{"flag on", nil, &on, true},
+		{"flag turns stored off", &on, &off, false},
+		{"flag turns stored on", &off, &on, true},
+	}
+	for _, tt := range tests {
+		got := Config{InsecureSkipVerify: tt.stored}.Merge(Config{InsecureSkipVerify: tt.flag})
+		if insecure := got.InsecureSkipVerify != nil && *got.InsecureSkipVerify; insecure != tt.want {
+			t.Errorf("%s: Merge() insecure = %v, want %v", tt.name, insecure, tt.want)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package httpclient
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrDryRun is returned instead of a response when the client is in dry-run mode
+var ErrDryRun = errors.New("dry run, request not sent")
+
+// SetDryRun makes the client print requests instead of sending them
+func (c *Client) SetDryRun(dryRun bool) {
+	c.dryRun = dryRun
+}
+
+// printDryRun prints the request as it would be sent, without credentials
+func (c *Client) printDryRun(req *http.Request) error {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return err
+	}
+
+	if cost, ok := CostOf(req); ok {
+		fmt.Printf("[*] Dry Run: %s (Estimated Cost: %d credits)\n", cost.Operation, cost.Credits)
+	} else {
+		fmt.Printf("[*] Dry Run: %s request\n", c.provider)
+	}
+	fmt.Printf("   [*] %s %s\n", req.Method, redactURL(req.URL))
+	if len(body) > 0 {
+		fmt.Printf("   [*] Body: %s\n", string(body))
+	}
+	return ErrDryRun
+}
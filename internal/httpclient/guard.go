@@ -0,0 +1,51 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// ErrBudgetExceeded is returned when the next request would spend more credits than allowed
+var ErrBudgetExceeded = errors.New("credit budget exceeded")
+
+// Guard decides whether a priced request may be sent and records the credits it spent.
+// Allow reserves the credits so concurrent requests cannot overspend, Spend charges the
+// reservation once the request succeeds and Release returns it when the request fails.
+type Guard interface {
+	Allow(provider Provider, operation string, credits int) error
+	Spend(provider Provider, operation string, credits int)
+	Release(provider Provider, operation string, credits int)
+	SetBalance(provider Provider, balance int)
+}
+
+// Cost is the operation name and credit price attached to a request
+type Cost struct {
+	Operation string
+	Credits   int
+}
+
+type costKey struct{}
+
+// WithCost tags the request with the operation it performs and the credits it spends
+func WithCost(req *http.Request, operation string, credits int) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), costKey{}, Cost{Operation: operation, Credits: credits}))
+}
+
+// CostOf returns the cost attached to the request, if any
+func CostOf(req *http.Request) (Cost, bool) {
+	cost, ok := req.Context().Value(costKey{}).(Cost)
+	return cost, ok
+}
+
+// SetGuard sets the budget guard consulted before every priced request
+func (c *Client) SetGuard(guard Guard) {
+	c.guard = guard
+}
+
+// SetBalance reports the remaining provider balance to the guard
+func (c *Client) SetBalance(balance int) {
+	if c.guard != nil {
+		c.guard.SetBalance(c.provider, balance)
+	}
+}
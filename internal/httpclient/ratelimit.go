@@ -0,0 +1,128 @@
+package httpclient
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultRateLimit returns the requests per second allowed against the provider unless configured otherwise
+func (p Provider) DefaultRateLimit() float64 {
+	switch p.ConfigProvider() {
+	case Dehashed:
+		return 5
+	case Hunter:
+		return 10
+	default:
+		return 5
+	}
+}
+
+// RateLimiter is a token bucket shared by every request sent to a provider
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a new RateLimiter allowing rate requests per second with bursts of up to burst requests
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// set changes the rate and burst, keeping the tokens already available up to the new burst
+func (r *RateLimiter) set(rate float64, burst int) {
+	if burst < 1 {
+		burst = 1
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rate = rate
+	r.burst = float64(burst)
+	if r.tokens > r.burst {
+		r.tokens = r.burst
+	}
+}
+
+// Wait blocks until a request may be sent or the context is cancelled
+func (r *RateLimiter) Wait(ctx context.Context) error {
+	for {
+		wait := r.reserve()
+		if wait <= 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// reserve takes a token if one is available, otherwise it returns how long until the next one
+func (r *RateLimiter) reserve() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	r.tokens += now.Sub(r.last).Seconds() * r.rate
+	if r.tokens > r.burst {
+		r.tokens = r.burst
+	}
+	r.last = now
+
+	if r.tokens >= 1 {
+		r.tokens--
+		return 0
+	}
+	return time.Duration((1 - r.tokens) / r.rate * float64(time.Second))
+}
+
+var (
+	limitersMu sync.Mutex
+	limiters   = make(map[Provider]*RateLimiter)
+)
+
+// sharedLimiter returns the process-wide limiter for the provider so concurrent clients share one bucket.
+// WHOIS shares the Dehashed bucket since both are served by the same API. A client configured with another
+// rate or burst updates the shared bucket, so the latest settings apply to every client of the provider.
+func sharedLimiter(provider Provider, rate float64, burst int) *RateLimiter {
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+
+	key := provider.ConfigProvider()
+	if limiter, ok := limiters[key]; ok {
+		limiter.set(rate, burst)
+		return limiter
+	}
+	limiter := NewRateLimiter(rate, burst)
+	limiters[key] = limiter
+	return limiter
+}
+
+// defaultLimiter returns the shared limiter of the provider, created with its default rate if no client made one
+func defaultLimiter(provider Provider) *RateLimiter {
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+
+	key := provider.ConfigProvider()
+	if limiter, ok := limiters[key]; ok {
+		return limiter
+	}
+	limiter := NewRateLimiter(provider.DefaultRateLimit(), int(provider.DefaultRateLimit()))
+	limiters[key] = limiter
+	return limiter
+}
@@ -0,0 +1,148 @@
+package httpclient
+
+import (
+	"crowsnest/internal/debug"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	DefaultMaxRetries = 3
+	DefaultRetryWait  = 2 * time.Second
+
+	// Upper bounds on a single wait so a bad header cannot stall a run
+	maxBackoff    = 60 * time.Second
+	maxRetryAfter = 5 * time.Minute
+)
+
+// doWithRetry sends the request, retrying 429, transient 5xx and network errors with exponential backoff.
+// Replayed fixtures are retried without waiting as they never reach the provider.
+func (c *Client) doWithRetry(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			if err := rewindBody(req); err != nil {
+				return nil, err
+			}
+		}
+		if c.limiter != nil {
+			if err := c.limiter.Wait(req.Context()); err != nil {
+				return nil, err
+			}
+		}
+
+		resp, err := c.http.Do(req)
+		if attempt >= c.maxRetries || !shouldRetry(req, resp, err) {
+			return resp, err
+		}
+
+		wait := c.backoff(attempt)
+		reason := ""
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = resp.Status
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+				wait = retryAfter
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if c.replay {
+			wait = 0
+		}
+
+		if c.debug {
+			debug.PrintInfo(fmt.Sprintf("%s request failed (%s), retry %d/%d in %s", c.provider, reason, attempt+1, c.maxRetries, wait))
+		}
+		zap.L().Info("http_retry",
+			zap.String("provider", string(c.provider)),
+			zap.String("url", redactURL(req.URL)),
+			zap.String("reason", reason),
+			zap.Int("attempt", attempt+1),
+			zap.Int("max_retries", c.maxRetries),
+			zap.Duration("wait", wait),
+		)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns the exponential wait for the given attempt, no wait when the retry wait is 0
+func (c *Client) backoff(attempt int) time.Duration {
+	if c.retryWait <= 0 {
+		return 0
+	}
+	wait := c.retryWait << attempt
+	if wait <= 0 || wait > maxBackoff {
+		return maxBackoff
+	}
+	return wait
+}
+
+// shouldRetry reports whether the response or error is worth another attempt
+func shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	if err != nil {
+		return req.Context().Err() == nil && !errors.Is(err, errNoFixture)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// rewindBody restores the request body before it is sent again
+func rewindBody(req *http.Request) error {
+	if req.GetBody == nil {
+		return nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return fmt.Errorf("failed to rewind request body: %w", err)
+	}
+	req.Body = body
+	return nil
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	var wait time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		wait = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(value); err == nil {
+		wait = time.Until(date)
+	} else {
+		return 0, false
+	}
+
+	if wait < 0 {
+		wait = 0
+	}
+	if wait > maxRetryAfter {
+		wait = maxRetryAfter
+	}
+	return wait, true
+}
@@ -2,6 +2,7 @@ package hunter_io
 
 import (
 	"crowsnest/internal/debug"
+	"crowsnest/internal/httpclient"
 	"crowsnest/internal/sqlite"
 	"encoding/json"
 	"fmt"
@@ -9,24 +10,80 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"sync"
 )
 
 const (
-	DOMAIN_SEARCH       = "https://api.hunter.io/v2/domain-search?domain={{domain}}&api_key={{apikey}}"
-	EMAIL_FINDER        = "https://api.hunter.io/v2/email-finder?domain={{domain}}&first_name={{first_name}}&last_name={{last_name}}&api_key={{apikey}}"
-	EMAIL_VERIFICATION  = "https://api.hunter.io/v2/email-verifier?email={{email}}&api_key={{apikey}}"
-	COMPANY_ENRICHMENT  = "https://api.hunter.io/v2/companies/find?domain={{domain}}&api_key={{apikey}}"
-	PERSON_ENRICHMENT   = "https://api.hunter.io/v2/people/find?email={{email}}&api_key={{apikey}}"
-	COMBINED_ENRICHMENT = "https://api.hunter.io/v2/combined/find?email={{email}}&api_key={{apikey}}"
+	DOMAIN_SEARCH       = "/v2/domain-search?domain={{domain}}&api_key={{apikey}}"
+	EMAIL_FINDER        = "/v2/email-finder?domain={{domain}}&first_name={{first_name}}&last_name={{last_name}}&api_key={{apikey}}"
+	EMAIL_VERIFICATION  = "/v2/email-verifier?email={{email}}&api_key={{apikey}}"
+	COMPANY_ENRICHMENT  = "/v2/companies/find?domain={{domain}}&api_key={{apikey}}"
+	PERSON_ENRICHMENT   = "/v2/people/find?email={{email}}&api_key={{apikey}}"
+	COMBINED_ENRICHMENT = "/v2/combined/find?email={{email}}&api_key={{apikey}}"
+	ACCOUNT             = "/v2/account?api_key={{apikey}}"
 )
 
+// Hunter.io operations
+const (
+	OpDomainSearch       = "domain_search"
+	OpEmailFinder        = "email_finder"
+	OpEmailVerification  = "email_verification"
+	OpCompanyEnrichment  = "company_enrichment"
+	OpPersonEnrichment   = "person_enrichment"
+	OpCombinedEnrichment = "combined_enrichment"
+)
+
+// Costs are the credits charged per request. A domain search returns up to 10 emails for 1 credit and a
+// combined enrichment is a person and a company enrichment. Verifications cost half a credit, see cost.
+var Costs = map[string]int{
+	OpDomainSearch:       1,
+	OpEmailFinder:        1,
+	OpEmailVerification:  1,
+	OpCompanyEnrichment:  1,
+	OpPersonEnrichment:   1,
+	OpCombinedEnrichment: 2,
+}
+
 type HunterIO struct {
 	apiKey string
 	debug  bool
+	client *httpclient.Client
+
+	mu      sync.Mutex
+	covered bool // A verification charged a whole credit and succeeded, so half a credit covers the next one
+}
+
+// NewHunterIO creates a Hunter.io client, falling back to the public API when client is nil
+func NewHunterIO(apiKey string, client *httpclient.Client, debugEnabled bool) *HunterIO {
+	if client == nil {
+		client = httpclient.Default(httpclient.Hunter, debugEnabled)
+	}
+	return &HunterIO{apiKey: apiKey, debug: debugEnabled, client: client}
+}
+
+// cost returns the credits to reserve for the next request of the operation. The budget counts whole
+// credits, so a verification is charged a credit unless the half credit left by an earlier one covers it.
+func (h *HunterIO) cost(operation string) int {
+	if operation != OpEmailVerification {
+		return Costs[operation]
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.covered {
+		h.covered = false
+		return 0
+	}
+	return Costs[operation]
 }
 
-func NewHunterIO(apiKey string, debugEnabled bool) *HunterIO {
-	return &HunterIO{apiKey: apiKey, debug: debugEnabled}
+// settle records the outcome of a verification. Only a charged verification that succeeded leaves half a
+// credit over, a covered one that was refused or failed hands it back for the next.
+func (h *HunterIO) settle(credits int, succeeded bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if succeeded == (credits > 0) {
+		h.covered = true
+	}
 }
 
 func (h *HunterIO) DomainSearch(domain string) (sqlite.HunterDomainData, error) {
@@ -52,7 +109,12 @@ func (h *HunterIO) DomainSearch(domain string) (sqlite.HunterDomainData, error)
 		)
 	}
 
-	resp, err := http.Get(url)
+	req, err := http.NewRequest("GET", h.client.URL(url), nil)
+	if err != nil {
+		return hunterDomainData, err
+	}
+
+	resp, err := h.client.Do(httpclient.WithCost(req, OpDomainSearch, h.cost(OpDomainSearch)))
 	if err != nil {
 		if h.debug {
 			debug.PrintInfo("failed to perform request")
@@ -125,26 +187,7 @@ func (h *HunterIO) DomainSearch(domain string) (sqlite.HunterDomainData, error)
 	hunterDomainData = hunterDomainSearchResult.Data
 
 	// Create a list of email object associated with the domain
-	var emails []sqlite.HunterEmail
-	for _, email := range hunterDomainData.Emails {
-		emails = append(emails, sqlite.HunterEmail{
-			Domain:       domain,
-			Value:        email.Value,
-			Type:         email.Type,
-			Confidence:   email.Confidence,
-			Sources:      email.Sources,
-			FirstName:    email.FirstName,
-			LastName:     email.LastName,
-			Position:     email.Position,
-			PositionRaw:  email.PositionRaw,
-			Seniority:    email.Seniority,
-			Department:   email.Department,
-			Linkedin:     email.Linkedin,
-			Twitter:      email.Twitter,
-			PhoneNumber:  email.PhoneNumber,
-			Verification: email.Verification,
-		})
-	}
+	emails := DomainEmails(hunterDomainData, domain)
 
 	err = sqlite.StoreHunterEmails(emails)
 	if err != nil {
@@ -175,6 +218,31 @@ func (h *HunterIO) DomainSearch(domain string) (sqlite.HunterDomainData, error)
 	return hunterDomainData, nil
 }
 
+// DomainEmails returns the email records of a domain search for storage
+func DomainEmails(data sqlite.HunterDomainData, domain string) []sqlite.HunterEmail {
+	var emails []sqlite.HunterEmail
+	for _, email := range data.Emails {
+		emails = append(emails, sqlite.HunterEmail{
+			Domain:       domain,
+			Value:        email.Value,
+			Type:         email.Type,
+			Confidence:   email.Confidence,
+			Sources:      email.Sources,
+			FirstName:    email.FirstName,
+			LastName:     email.LastName,
+			Position:     email.Position,
+			PositionRaw:  email.PositionRaw,
+			Seniority:    email.Seniority,
+			Department:   email.Department,
+			Linkedin:     email.Linkedin,
+			Twitter:      email.Twitter,
+			PhoneNumber:  email.PhoneNumber,
+			Verification: email.Verification,
+		})
+	}
+	return emails
+}
+
 func (h *HunterIO) EmailFinder(domain, firstName, lastName string) (sqlite.HunterEmailFinderData, error) {
 	var hunterEmailFinderData sqlite.HunterEmailFinderData
 
@@ -200,7 +268,12 @@ func (h *HunterIO) EmailFinder(domain, firstName, lastName string) (sqlite.Hunte
 		)
 	}
 
-	resp, err := http.Get(url)
+	req, err := http.NewRequest("GET", h.client.URL(url), nil)
+	if err != nil {
+		return hunterEmailFinderData, err
+	}
+
+	resp, err := h.client.Do(httpclient.WithCost(req, OpEmailFinder, h.cost(OpEmailFinder)))
 	if err != nil {
 		if h.debug {
 			debug.PrintInfo("failed to perform request")
@@ -330,7 +403,14 @@ func (h *HunterIO) EmailVerification(email string) (sqlite.HunterEmailVerifyData
 		)
 	}
 
-	resp, err := http.Get(url)
+	req, err := http.NewRequest("GET", h.client.URL(url), nil)
+	if err != nil {
+		return hunterEmailVerifyData, err
+	}
+
+	credits := h.cost(OpEmailVerification)
+	resp, err := h.client.Do(httpclient.WithCost(req, OpEmailVerification, credits))
+	h.settle(credits, err == nil && resp.StatusCode >= 200 && resp.StatusCode < 300)
 	if err != nil {
 		if h.debug {
 			debug.PrintInfo("failed to perform request")
@@ -427,7 +507,12 @@ func (h *HunterIO) CompanyEnrichment(domain string) (sqlite.CompanyData, error)
 		)
 	}
 
-	resp, err := http.Get(url)
+	req, err := http.NewRequest("GET", h.client.URL(url), nil)
+	if err != nil {
+		return companyData, err
+	}
+
+	resp, err := h.client.Do(httpclient.WithCost(req, OpCompanyEnrichment, h.cost(OpCompanyEnrichment)))
 	if err != nil {
 		if h.debug {
 			debug.PrintInfo("failed to perform request")
@@ -524,7 +609,12 @@ func (h *HunterIO) PersonEnrichment(email string) (sqlite.PersonData, error) {
 		)
 	}
 
-	resp, err := http.Get(url)
+	req, err := http.NewRequest("GET", h.client.URL(url), nil)
+	if err != nil {
+		return personData, err
+	}
+
+	resp, err := h.client.Do(httpclient.WithCost(req, OpPersonEnrichment, h.cost(OpPersonEnrichment)))
 	if err != nil {
 		if h.debug {
 			debug.PrintInfo("failed to perform request")
@@ -621,7 +711,12 @@ func (h *HunterIO) CombinedEnrichment(email string) (sqlite.CombinedData, error)
 		)
 	}
 
-	resp, err := http.Get(url)
+	req, err := http.NewRequest("GET", h.client.URL(url), nil)
+	if err != nil {
+		return combinedData, err
+	}
+
+	resp, err := h.client.Do(httpclient.WithCost(req, OpCombinedEnrichment, h.cost(OpCombinedEnrichment)))
 	if err != nil {
 		if h.debug {
 			debug.PrintInfo("failed to perform request")
@@ -694,3 +789,77 @@ func (h *HunterIO) CombinedEnrichment(email string) (sqlite.CombinedData, error)
 
 	return combinedData, nil
 }
+
+// Account returns the account quota and reports the remaining credits to the budget guard
+func (h *HunterIO) Account() (sqlite.HunterAccountData, error) {
+	var accountData sqlite.HunterAccountData
+
+	if h.debug {
+		debug.PrintInfo("getting account information")
+		zap.L().Info("hunter_account_debug",
+			zap.String("message", "getting account information"),
+		)
+	}
+
+	url := strings.Replace(ACCOUNT, "{{apikey}}", h.apiKey, -1)
+
+	req, err := http.NewRequest("GET", h.client.URL(url), nil)
+	if err != nil {
+		return accountData, err
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		if h.debug {
+			debug.PrintInfo("failed to perform request")
+			debug.PrintError(err)
+		}
+		zap.L().Error("hunter_account",
+			zap.String("message", "failed to perform request"),
+			zap.Error(err),
+		)
+		return accountData, err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		zap.L().Error("hunter_account",
+			zap.String("message", "failed to read response body"),
+			zap.Error(err),
+		)
+		return accountData, err
+	}
+
+	if resp.StatusCode != 200 {
+		if h.debug {
+			debug.PrintInfo("received error status code")
+			debug.PrintJson(fmt.Sprintf("Status Code: %d\n", resp.StatusCode))
+			debug.PrintJson(fmt.Sprintf("Body: %s\n", string(b)))
+		}
+		zap.L().Error("hunter_account",
+			zap.String("message", "received error status code"),
+			zap.Int("status_code", resp.StatusCode),
+			zap.String("body_error", string(b)),
+		)
+		return accountData, fmt.Errorf("received error status code: %d", resp.StatusCode)
+	}
+
+	var hunterAccountResult sqlite.HunterAccountResponse
+	err = json.Unmarshal(b, &hunterAccountResult)
+	if err != nil {
+		zap.L().Error("hunter_account",
+			zap.String("message", "failed to unmarshal response body"),
+			zap.Error(err),
+		)
+		return accountData, err
+	}
+
+	accountData = hunterAccountResult.Data
+	if h.debug {
+		debug.PrintJson(fmt.Sprintf("Remaining Credits: %d\n", accountData.Remaining()))
+	}
+	h.client.SetBalance(accountData.Remaining())
+
+	return accountData, nil
+}
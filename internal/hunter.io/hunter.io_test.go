@@ -0,0 +1,135 @@
+package hunter_io
+
+import (
+	"crowsnest/internal/httpclient"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestEmailVerification replays the verifications recorded in testdata/cassettes
+func TestEmailVerification(t *testing.T) {
+	client, err := httpclient.New(httpclient.Hunter, httpclient.Config{ReplayDir: "testdata/cassettes"}, false)
+	if err != nil {
+		t.Fatalf("httpclient.New() error = %v", err)
+	}
+	h := NewHunterIO("test-key", client, false)
+
+	tests := []struct {
+		email   string
+		result  string
+		status  string
+		score   int
+		sources int
+		err     string
+	}{
+		{email: "jane.doe@acme.com", result: "deliverable", status: "valid", score: 97, sources: 1},
+		{email: "info@acme.com", result: "risky", status: "accept_all", score: 68},
+		{email: "nobody@acme.com", err: "received error status code: 401"},
+		{email: "unrecorded@acme.com", err: "no recorded fixture"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.email, func(t *testing.T) {
+			data, err := h.EmailVerification(tt.email)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("EmailVerification(%q) error = %v, want %q", tt.email, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("EmailVerification(%q) error = %v", tt.email, err)
+			}
+			if data.Email != tt.email || data.Result != tt.result || data.Status != tt.status ||
+				data.Score != tt.score || len(data.Sources) != tt.sources {
+				t.Errorf("EmailVerification(%q) = %s %s %s %d, %d sources, want %s %s %d, %d sources", tt.email,
+					data.Email, data.Result, data.Status, data.Score, len(data.Sources), tt.result, tt.status, tt.score, tt.sources)
+			}
+		})
+	}
+}
+
+// creditGuard records the credits of every request and refuses those over its balance
+type creditGuard struct {
+	balance int
+	spent   int
+	asked   []int
+}
+
+func (g *creditGuard) Allow(_ httpclient.Provider, _ string, credits int) error {
+	g.asked = append(g.asked, credits)
+	if g.spent+credits > g.balance {
+		return httpclient.ErrBudgetExceeded
+	}
+	return nil
+}
+
+func (g *creditGuard) Spend(_ httpclient.Provider, _ string, credits int) { g.spent += credits }
+func (g *creditGuard) Release(httpclient.Provider, string, int)           {}
+func (g *creditGuard) SetBalance(httpclient.Provider, int)                {}
+
+// verifier answers verifications of emails starting with "bad" with a server error
+type verifier struct{}
+
+func (verifier) RoundTrip(req *http.Request) (*http.Response, error) {
+	email := req.URL.Query().Get("email")
+	status, body := http.StatusOK, fmt.Sprintf(`{"data":{"email":%q,"result":"deliverable"}}`, email)
+	if strings.HasPrefix(email, "bad") {
+		status, body = http.StatusBadRequest, `{"errors":[{"id":"wrong_params"}]}`
+	}
+	return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(body)), Request: req}, nil
+}
+
+// TestVerificationCost checks that only successful verifications are counted towards the half credit
+func TestVerificationCost(t *testing.T) {
+	tests := []struct {
+		name    string
+		balance int
+		emails  []string
+		asked   []int
+		spent   int
+	}{
+		{
+			name:    "pairs of successes",
+			balance: 10,
+			emails:  []string{"a@acme.com", "b@acme.com", "c@acme.com", "d@acme.com", "e@acme.com"},
+			asked:   []int{1, 0, 1, 0, 1},
+			spent:   3,
+		},
+		{
+			name:    "failed verification is not counted",
+			balance: 10,
+			emails:  []string{"bad1@acme.com", "a@acme.com", "bad2@acme.com", "b@acme.com"},
+			asked:   []int{1, 1, 0, 0},
+			spent:   1,
+		},
+		{
+			name:    "refused verification is not counted",
+			balance: 1,
+			emails:  []string{"a@acme.com", "b@acme.com", "c@acme.com", "d@acme.com"},
+			asked:   []int{1, 0, 1, 1},
+			spent:   1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := httpclient.New(httpclient.Hunter, httpclient.Config{Transport: verifier{}, MaxRetries: new(int)}, false)
+			if err != nil {
+				t.Fatalf("httpclient.New() error = %v", err)
+			}
+			guard := &creditGuard{balance: tt.balance}
+			client.SetGuard(guard)
+			h := NewHunterIO("test-key", client, false)
+
+			for _, email := range tt.emails {
+				_, _ = h.EmailVerification(email)
+			}
+			if !slices.Equal(guard.asked, tt.asked) || guard.spent != tt.spent {
+				t.Errorf("credits asked %v, spent %d, want %v, spent %d", guard.asked, guard.spent, tt.asked, tt.spent)
+			}
+		})
+	}
+}
@@ -0,0 +1,368 @@
+package identity
+
+import (
+	"crowsnest/internal/spray"
+	"crowsnest/internal/sqlite"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Weights of the attributes two records can share. An identity is only as certain as the weakest match that joined it.
+const (
+	EmailWeight    = 1.0
+	PhoneWeight    = 0.9
+	SocialWeight   = 0.9
+	UsernameWeight = 0.7
+	NameWeight     = 0.6 // Same name with an email at the same domain
+)
+
+// genericUsernames are shared by unrelated accounts and never join records
+var genericUsernames = map[string]bool{
+	"admin": true, "administrator": true, "root": true, "guest": true, "test": true, "user": true,
+	"info": true, "support": true, "contact": true, "sales": true, "webmaster": true, "null": true,
+}
+
+// Record is a stored record with the attributes that identify its owner, normalized
+type Record struct {
+	Table     string
+	Key       string
+	Emails    []string
+	Usernames []string
+	Phones    []string
+	Names     []string
+	Socials   []string // network:handle
+}
+
+// Load returns the records of the creds, dehashed, hunter_email and person tables
+func Load() ([]Record, error) {
+	db := sqlite.GetDB()
+	var records []Record
+
+	var creds []sqlite.User
+	if err := db.Find(&creds).Error; err != nil {
+		return nil, err
+	}
+	for _, c := range creds {
+		records = append(records, Record{
+			Table:     "creds",
+			Key:       strconv.FormatUint(uint64(c.ID), 10),
+			Emails:    []string{c.Email},
+			Usernames: []string{c.Username},
+			Phones:    []string{c.Phone, c.PhoneNumber},
+			Names:     []string{c.FullName},
+			Socials: []string{"linkedin:" + c.Linkedin, "twitter:" + c.Twitter, "facebook:" + c.Facebook,
+				"instagram:" + c.Instagram, "youtube:" + c.Youtube, "gravatar:" + c.Gravatar},
+		})
+	}
+
+	var results []sqlite.Result
+	if err := db.Find(&results).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range results {
+		record := Record{
+			Table:     "dehashed",
+			Key:       r.DehashedId,
+			Emails:    r.Email,
+			Usernames: r.Username,
+			Phones:    r.Phone,
+			Names:     r.Name,
+		}
+		for _, s := range r.Social {
+			record.Socials = append(record.Socials, "social:"+s)
+		}
+		records = append(records, record)
+	}
+
+	var emails []sqlite.HunterEmail
+	if err := db.Find(&emails).Error; err != nil {
+		return nil, err
+	}
+	for _, e := range emails {
+		records = append(records, Record{
+			Table:   "hunter_email",
+			Key:     e.Value,
+			Emails:  []string{e.Value},
+			Phones:  []string{e.PhoneNumber},
+			Names:   []string{strings.TrimSpace(e.FirstName + " " + e.LastName)},
+			Socials: []string{"linkedin:" + e.Linkedin, "twitter:" + e.Twitter},
+		})
+	}
+
+	var people []sqlite.PersonData
+	if err := db.Find(&people).Error; err != nil {
+		return nil, err
+	}
+	for _, p := range people {
+		name := p.Name.FullName
+		if name == "" {
+			name = strings.TrimSpace(p.Name.GivenName + " " + p.Name.FamilyName)
+		}
+		records = append(records, Record{
+			Table:  "person",
+			Key:    p.Email,
+			Emails: []string{p.Email},
+			Phones: []string{p.Phone},
+			Names:  []string{name},
+			Socials: []string{"facebook:" + p.Facebook.Handle, "github:" + p.GitHub.Handle, "twitter:" + p.Twitter.Handle,
+				"linkedin:" + p.LinkedIn.Handle, "googleplus:" + p.GooglePlus.Handle, "gravatar:" + p.Gravatar.Handle},
+		})
+	}
+
+	for i := range records {
+		records[i].normalize()
+	}
+	return records, nil
+}
+
+// normalize cleans up the attributes and drops the empty ones. Usernames that are email addresses move to the emails.
+func (r *Record) normalize() {
+	var emails, usernames []string
+	for _, e := range r.Emails {
+		emails = append(emails, strings.ToLower(strings.TrimSpace(e)))
+	}
+	for _, u := range r.Usernames {
+		u = strings.ToLower(strings.TrimSpace(u))
+		if strings.Contains(u, "@") {
+			emails = append(emails, u)
+		} else {
+			usernames = append(usernames, u)
+		}
+	}
+	r.Emails = unique(emails, func(e string) bool { return strings.Count(e, "@") == 1 && !strings.HasPrefix(e, "@") })
+	r.Usernames = unique(usernames, nil)
+
+	var phones []string
+	for _, p := range r.Phones {
+		phones = append(phones, normalizePhone(p))
+	}
+	r.Phones = unique(phones, nil)
+
+	var names []string
+	for _, n := range r.Names {
+		names = append(names, strings.Join(strings.Fields(n), " "))
+	}
+	r.Names = unique(names, nil)
+
+	var socials []string
+	for _, s := range r.Socials {
+		network, handle, _ := strings.Cut(s, ":")
+		if handle = normalizeHandle(handle); handle != "" {
+			socials = append(socials, network+":"+handle)
+		}
+	}
+	r.Socials = unique(socials, nil)
+}
+
+// matchKeys returns the attributes of the record that join it to other records, with the weight of each
+func (r Record) matchKeys() map[string]float64 {
+	keys := make(map[string]float64)
+	for _, e := range r.Emails {
+		keys["email:"+e] = EmailWeight
+	}
+	for _, p := range r.Phones {
+		if len(p) >= 7 {
+			keys["phone:"+p] = PhoneWeight
+		}
+	}
+	for _, s := range r.Socials {
+		// The same handle on two networks is most likely the same person
+		_, handle, _ := strings.Cut(s, ":")
+		if len(handle) >= 4 {
+			keys["social:"+handle] = SocialWeight
+		}
+	}
+	for _, u := range r.Usernames {
+		if len(u) >= 5 && !genericUsernames[u] {
+			keys["username:"+u] = UsernameWeight
+		}
+	}
+	for _, n := range r.Names {
+		first, last := spray.SplitName(n)
+		first, last = letters(first), letters(last)
+		if first == "" || last == "" {
+			continue
+		}
+		for _, e := range r.Emails {
+			keys["name:"+first+" "+last+"@"+e[strings.LastIndex(e, "@")+1:]] = NameWeight
+		}
+	}
+	return keys
+}
+
+// Resolved is an identity with the records resolved to it
+type Resolved struct {
+	Identity sqlite.Identity
+	Records  []sqlite.IdentityRecord
+}
+
+// Resolve clusters the records sharing an email, phone, social handle, username, or a name with an email at the same
+// domain. Each identity's confidence is the weight of the weakest match that joined its records.
+func Resolve(records []Record) []Resolved {
+	parent := make([]int, len(records))
+	confidence := make([]float64, len(records))
+	matchedOn := make([]string, len(records))
+	for i := range records {
+		parent[i] = i
+		confidence[i] = 1
+	}
+	var find func(int) int
+	find = func(i int) int {
+		for parent[i] != i {
+			parent[i] = parent[parent[i]]
+			i = parent[i]
+		}
+		return i
+	}
+
+	owners := make(map[string]int)
+	for i, r := range records {
+		keys := r.matchKeys()
+		// Strongest matches first, so a record is reported as joined by its most certain attribute
+		ordered := make([]string, 0, len(keys))
+		for k := range keys {
+			ordered = append(ordered, k)
+		}
+		sort.Slice(ordered, func(a, b int) bool {
+			if keys[ordered[a]] != keys[ordered[b]] {
+				return keys[ordered[a]] > keys[ordered[b]]
+			}
+			return ordered[a] < ordered[b]
+		})
+
+		for _, key := range ordered {
+			owner, ok := owners[key]
+			if !ok {
+				owners[key] = i
+				continue
+			}
+			a, b := find(owner), find(i)
+			if a == b {
+				continue
+			}
+			if matchedOn[i] == "" {
+				matchedOn[i] = key
+			}
+			parent[b] = a
+			confidence[a] = math.Min(keys[key], math.Min(confidence[a], confidence[b]))
+		}
+	}
+
+	clusters := make(map[int][]int)
+	var roots []int
+	for i := range records {
+		root := find(i)
+		if _, ok := clusters[root]; !ok {
+			roots = append(roots, root)
+		}
+		clusters[root] = append(clusters[root], i)
+	}
+
+	var resolved []Resolved
+	for _, root := range roots {
+		var (
+			identity = sqlite.Identity{Confidence: confidence[root]}
+			links    []sqlite.IdentityRecord
+			emails   = make(map[string]int)
+			names    = make(map[string]int)
+		)
+		for _, i := range clusters[root] {
+			r := records[i]
+			links = append(links, sqlite.IdentityRecord{RecordTable: r.Table, RecordKey: r.Key, MatchedOn: matchedOn[i]})
+			identity.Emails = append(identity.Emails, r.Emails...)
+			identity.Usernames = append(identity.Usernames, r.Usernames...)
+			identity.Phones = append(identity.Phones, r.Phones...)
+			identity.Names = append(identity.Names, r.Names...)
+			identity.Socials = append(identity.Socials, r.Socials...)
+			for _, e := range r.Emails {
+				emails[e]++
+			}
+			for _, n := range r.Names {
+				names[n]++
+			}
+		}
+		identity.Emails = unique(identity.Emails, nil)
+		identity.Usernames = unique(identity.Usernames, nil)
+		identity.Phones = unique(identity.Phones, nil)
+		identity.Names = unique(identity.Names, nil)
+		identity.Socials = unique(identity.Socials, nil)
+		identity.PrimaryEmail = mostCommon(emails)
+		identity.Name = mostCommon(names)
+		identity.Records = len(links)
+
+		// Records without anything that identifies a person are left out
+		if len(identity.Emails) == 0 && len(identity.Usernames) == 0 && len(identity.Phones) == 0 &&
+			len(identity.Names) == 0 && len(identity.Socials) == 0 {
+			continue
+		}
+		resolved = append(resolved, Resolved{Identity: identity, Records: links})
+	}
+
+	sort.SliceStable(resolved, func(i, j int) bool {
+		return resolved[i].Identity.Records > resolved[j].Identity.Records
+	})
+	return resolved
+}
+
+// mostCommon returns the value counted most often, the smallest one on a tie
+func mostCommon(counts map[string]int) string {
+	var best string
+	for v, n := range counts {
+		if n > counts[best] || n == counts[best] && (best == "" || v < best) {
+			best = v
+		}
+	}
+	return best
+}
+
+// normalizePhone keeps the digits of a phone number, dropping the country code of numbers longer than 10 digits
+func normalizePhone(phone string) string {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, phone)
+	if len(digits) > 10 {
+		digits = digits[len(digits)-10:]
+	}
+	return digits
+}
+
+// normalizeHandle lowercases a social handle and strips profile URLs and a leading @
+func normalizeHandle(handle string) string {
+	handle = strings.ToLower(strings.TrimSpace(handle))
+	handle = strings.TrimSuffix(handle, "/")
+	if i := strings.LastIndex(handle, "/"); i >= 0 {
+		handle = handle[i+1:]
+	}
+	return strings.TrimPrefix(handle, "@")
+}
+
+// letters lowercases a name and keeps only its letters
+func letters(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+}
+
+// unique sorts the values and removes empty and repeated ones, along with those keep rejects
+func unique(values []string, keep func(string) bool) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, v := range values {
+		if v == "" || seen[v] || keep != nil && !keep(v) {
+			continue
+		}
+		seen[v] = true
+		out = append(out, v)
+	}
+	sort.Strings(out)
+	return out
+}
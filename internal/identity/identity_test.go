@@ -0,0 +1,130 @@
+package identity
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	records := []Record{
+		{Table: "creds", Key: "1", Emails: []string{"jane@acme.com"}, Usernames: []string{"jdoe99"}},
+		{Table: "dehashed", Key: "d1", Emails: []string{" JANE@acme.com"}, Phones: []string{"+1 (555) 123-4567"}},
+		{Table: "hunter_email", Key: "jane.d@gmail.com", Emails: []string{"jane.d@gmail.com"}, Phones: []string{"555.123.4567"}},
+		{Table: "dehashed", Key: "d2", Usernames: []string{"JDoe99"}},
+		{Table: "creds", Key: "2", Usernames: []string{"admin"}, Emails: []string{"@acme.com"}},
+		{Table: "creds", Key: "3", Usernames: []string{"admin"}},
+		{Table: "dehashed", Key: "d3"},
+		{Table: "person", Key: "bob@acme.com", Emails: []string{"bob@acme.com"}, Names: []string{"Bob  Smith"}},
+		{Table: "hunter_email", Key: "bob.smith@acme.com", Emails: []string{"bob.smith@acme.com"}, Names: []string{"bob smith"}},
+		{Table: "person", Key: "bob@other.com", Emails: []string{"bob@other.com"}, Names: []string{"Bob Smith"}},
+		{Table: "creds", Key: "4", Socials: []string{"linkedin:https://www.linkedin.com/in/Alice-W/", "twitter:"}},
+		{Table: "person", Key: "alice@acme.org", Emails: []string{"alice@acme.org"}, Socials: []string{"github:@alice-w"}},
+		{Table: "creds", Key: "5", Usernames: []string{"bob"}},
+		{Table: "dehashed", Key: "d4", Usernames: []string{"bob"}},
+	}
+	for i := range records {
+		records[i].normalize()
+	}
+
+	want := map[string]struct {
+		confidence float64
+		primary    string
+	}{
+		// Joined by email, phone and username, as certain as the username match
+		"creds:1 dehashed:d1 dehashed:d2 hunter_email:jane.d@gmail.com": {UsernameWeight, "jane@acme.com"},
+		"hunter_email:bob.smith@acme.com person:bob@acme.com":           {NameWeight, "bob.smith@acme.com"},
+		"person:bob@other.com":          {1, "bob@other.com"},
+		"creds:4 person:alice@acme.org": {SocialWeight, "alice@acme.org"},
+		// Generic and short usernames do not join records
+		"creds:2":     {1, ""},
+		"creds:3":     {1, ""},
+		"creds:5":     {1, ""},
+		"dehashed:d4": {1, ""},
+	}
+
+	resolved := Resolve(records)
+	got := make(map[string]bool)
+	for i, r := range resolved {
+		var keys []string
+		for _, link := range r.Records {
+			keys = append(keys, link.RecordTable+":"+link.RecordKey)
+		}
+		sort.Strings(keys)
+		cluster := strings.Join(keys, " ")
+		got[cluster] = true
+
+		w, ok := want[cluster]
+		if !ok {
+			t.Errorf("unexpected identity %q", cluster)
+			continue
+		}
+		if r.Identity.Confidence != w.confidence || r.Identity.PrimaryEmail != w.primary || r.Identity.Records != len(keys) {
+			t.Errorf("identity %q = confidence %.1f, primary %q, %d records, want %.1f, %q, %d",
+				cluster, r.Identity.Confidence, r.Identity.PrimaryEmail, r.Identity.Records, w.confidence, w.primary, len(keys))
+		}
+		if i > 0 && r.Identity.Records > resolved[i-1].Identity.Records {
+			t.Errorf("identity %q is ordered after a smaller one", cluster)
+		}
+	}
+	for cluster := range want {
+		if !got[cluster] {
+			t.Errorf("missing identity %q", cluster)
+		}
+	}
+}
+
+func TestResolveMatchedOn(t *testing.T) {
+	records := []Record{
+		{Table: "creds", Key: "1", Emails: []string{"jane@acme.com"}, Phones: []string{"5551234567"}},
+		{Table: "dehashed", Key: "d1", Emails: []string{"jane@acme.com"}, Phones: []string{"5551234567"}, Usernames: []string{"janedoe"}},
+		{Table: "dehashed", Key: "d2", Usernames: []string{"janedoe"}},
+	}
+	for i := range records {
+		records[i].normalize()
+	}
+	resolved := Resolve(records)
+	if len(resolved) != 1 {
+		t.Fatalf("Resolve() = %d identities, want 1", len(resolved))
+	}
+
+	var matched []string
+	for _, link := range resolved[0].Records {
+		matched = append(matched, link.MatchedOn)
+	}
+	want := []string{"", "email:jane@acme.com", "username:janedoe"}
+	if !slices.Equal(matched, want) {
+		t.Errorf("MatchedOn = %q, want %q", matched, want)
+	}
+	if got := resolved[0].Identity.Usernames; !slices.Equal(got, []string{"janedoe"}) {
+		t.Errorf("Usernames = %q, want [janedoe]", got)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	r := Record{
+		Emails:    []string{" Jane@Acme.com ", "jane@acme.com", "not-an-email", "a@b@c"},
+		Usernames: []string{"JDoe", "Other@Acme.com", ""},
+		Phones:    []string{"+44 20 7946 0018", "(020) 7946-0018", ""},
+		Names:     []string{"  Jane   Doe "},
+		Socials:   []string{"twitter:@JaneDoe", "linkedin:https://linkedin.com/in/janedoe/", "facebook:"},
+	}
+	r.normalize()
+
+	checks := []struct {
+		field     string
+		got, want []string
+	}{
+		{"emails", r.Emails, []string{"jane@acme.com", "other@acme.com"}},
+		{"usernames", r.Usernames, []string{"jdoe"}},
+		{"phones", r.Phones, []string{"2079460018"}}, // National and international forms compare equal
+		{"names", r.Names, []string{"Jane Doe"}},
+		{"socials", r.Socials, []string{"linkedin:janedoe", "twitter:janedoe"}},
+	}
+	for _, c := range checks {
+		if !slices.Equal(c.got, c.want) {
+			t.Errorf("%s = %q, want %q", c.field, c.got, c.want)
+		}
+	}
+}
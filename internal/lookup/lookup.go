@@ -0,0 +1,93 @@
+package lookup
+
+import (
+	"crowsnest/internal/dehashed"
+	"crowsnest/internal/httpclient"
+	hunter "crowsnest/internal/hunter.io"
+	"crowsnest/internal/sqlite"
+	"crowsnest/internal/whois"
+	"fmt"
+	"go.uber.org/zap"
+)
+
+// The lookups below query a provider and store what it returned, for the commands that chain lookups: recon,
+// playbooks and watches. Storing links the records to the current command run and flags those outside the
+// scope. When the provider answered but storing failed, the results are returned with the error.
+
+// Dehashed stores the options as a run and retrieves its records in batch mode. The records retrieved before
+// an error are returned with it.
+func Dehashed(options *sqlite.QueryOptions, key string, client *httpclient.Client) ([]sqlite.Result, error) {
+	dh, err := dehashed.NewDehasher(options)
+	if err != nil {
+		return nil, err
+	}
+	if err = sqlite.StoreDehashedQueryOptions(options); err != nil {
+		zap.L().Error("store_query_options",
+			zap.String("message", "failed to store query options"),
+			zap.Error(err),
+		)
+	}
+
+	dh.SetClientCredentials(key, client)
+	dh.SetBatchMode(true)
+	err = dh.Start()
+	return dh.GetResults().Results, err
+}
+
+// Whois looks up the WHOIS record of the domain and stores it
+func Whois(w *whois.DehashedWhoIs, domain string) (sqlite.WhoisRecord, error) {
+	record, err := w.WhoisSearch(domain)
+	if err != nil {
+		return record, err
+	}
+	if err = sqlite.StoreWhoisRecord(record); err != nil {
+		return record, fmt.Errorf("failed to store WHOIS record: %w", err)
+	}
+	return record, nil
+}
+
+// Subdomains scans the subdomains of the domain and stores them
+func Subdomains(w *whois.DehashedWhoIs, domain string) ([]sqlite.Subdomain, error) {
+	records, err := w.WhoisSubdomainScan(domain)
+	if err != nil {
+		return nil, err
+	}
+	subs := make([]sqlite.Subdomain, 0, len(records))
+	for _, r := range records {
+		subs = append(subs, sqlite.Subdomain{Domain: domain, Subdomain: r.Domain})
+	}
+	if err = sqlite.StoreSubdomains(subs); err != nil {
+		return subs, fmt.Errorf("failed to store subdomains: %w", err)
+	}
+	return subs, nil
+}
+
+// HunterDomain searches the emails of the domain and stores them as credentials
+func HunterDomain(h *hunter.HunterIO, domain string) (sqlite.HunterDomainData, error) {
+	data, err := h.DomainSearch(domain)
+	if err != nil {
+		return data, err
+	}
+	creds := make([]sqlite.User, 0, len(data.Emails))
+	for _, e := range data.Emails {
+		creds = append(creds, sqlite.User{Email: e.Value})
+	}
+	if err = sqlite.StoreUsers(creds); err != nil {
+		return data, fmt.Errorf("failed to store users: %w", err)
+	}
+	return data, nil
+}
+
+// HunterVerify verifies the email and stores it as a credential when it is deliverable
+func HunterVerify(h *hunter.HunterIO, email string) (sqlite.HunterEmailVerifyData, error) {
+	data, err := h.EmailVerification(email)
+	if err != nil {
+		return data, err
+	}
+	if data.Result == "deliverable" {
+		if err = sqlite.StoreUsers([]sqlite.User{{Email: data.Email}}); err != nil {
+			return data, fmt.Errorf("failed to store verified email: %w", err)
+		}
+	}
+	return data, nil
+}
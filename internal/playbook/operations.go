@@ -0,0 +1,253 @@
+package playbook
+
+import (
+	"crowsnest/internal/files"
+	"crowsnest/internal/httpclient"
+	"crowsnest/internal/lookup"
+	"crowsnest/internal/scope"
+	"crowsnest/internal/sqlite"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Operation is an API lookup a step can run
+type Operation struct {
+	Name     string
+	Provider httpclient.Provider
+	Inputs   []string // Values the operation looks up
+	Options  []string // Inputs that tune the lookup
+	Required []string // Inputs that must be set
+	AnyInput bool     // At least one of the inputs must be set
+	Outputs  []string // Values later steps can refer to
+	run      func(r *Runner, in map[string]string) (Outputs, error)
+}
+
+// Outputs are the values a step produced, by output name
+type Outputs map[string]Values
+
+// add appends the values not already held, ignoring empty ones
+func (o Outputs) add(name string, values ...string) {
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v != "" && !slices.Contains(o[name], v) {
+			o[name] = append(o[name], v)
+		}
+	}
+}
+
+// merge adds every output of other
+func (o Outputs) merge(other Outputs) {
+	for name, values := range other {
+		o.add(name, values...)
+	}
+}
+
+// inputTargets maps the inputs checked against the scope to their target kind
+var inputTargets = map[string]string{
+	"domain": scope.TargetDomain,
+	"email":  scope.TargetEmail,
+	"ip":     scope.TargetIP,
+}
+
+// Operations are the operations steps can run, by name
+var Operations = map[string]*Operation{
+	"whois.lookup": {
+		Provider: httpclient.Whois,
+		Inputs:   []string{"domain"},
+		Required: []string{"domain"},
+		Outputs:  []string{"domain", "registrar", "registrant", "created_date", "name_servers", "contact_email"},
+		run:      (*Runner).whoisLookup,
+	},
+	"whois.subdomains": {
+		Provider: httpclient.Whois,
+		Inputs:   []string{"domain"},
+		Required: []string{"domain"},
+		Outputs:  []string{"subdomains"},
+		run:      (*Runner).whoisSubdomains,
+	},
+	"hunter.domain-search": {
+		Provider: httpclient.Hunter,
+		Inputs:   []string{"domain"},
+		Required: []string{"domain"},
+		Outputs:  []string{"emails", "pattern", "organization"},
+		run:      (*Runner).hunterDomainSearch,
+	},
+	"hunter.email-finder": {
+		Provider: httpclient.Hunter,
+		Inputs:   []string{"domain", "first_name", "last_name"},
+		Required: []string{"domain", "first_name", "last_name"},
+		Outputs:  []string{"email", "position"},
+		run:      (*Runner).hunterEmailFinder,
+	},
+	"hunter.company": {
+		Provider: httpclient.Hunter,
+		Inputs:   []string{"domain"},
+		Required: []string{"domain"},
+		Outputs:  []string{"name", "industry", "employees"},
+		run:      (*Runner).hunterCompany,
+	},
+	"hunter.verify": {
+		Provider: httpclient.Hunter,
+		Inputs:   []string{"email"},
+		Required: []string{"email"},
+		Outputs:  []string{"result", "deliverable"},
+		run:      (*Runner).hunterVerify,
+	},
+	"dehashed.search": {
+		Provider: httpclient.Dehashed,
+		Inputs:   sqlite.QueryFields,
+		Options:  []string{"max_records", "max_requests", "wildcard", "regex"},
+		AnyInput: true,
+		Outputs:  []string{"records", "emails", "usernames", "passwords", "hashes", "ips", "names", "phones", "breaches"},
+		run:      (*Runner).dehashedSearch,
+	},
+}
+
+func init() {
+	for name, op := range Operations {
+		op.Name = name
+	}
+}
+
+// OperationNames returns the names of every operation, sorted
+func OperationNames() string {
+	var names []string
+	for name := range Operations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func (op *Operation) inputNames() string {
+	return strings.Join(append(slices.Clone(op.Inputs), op.Options...), ", ")
+}
+
+func (r *Runner) whoisLookup(in map[string]string) (Outputs, error) {
+	record, err := lookup.Whois(r.Whois, in["domain"])
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(Outputs)
+	out.add("domain", record.DomainName)
+	out.add("registrar", record.RegistrarName)
+	out.add("registrant", record.Registrant.Organization)
+	if len(out["registrant"]) == 0 {
+		out.add("registrant", record.Registrant.Name)
+	}
+	out.add("created_date", record.CreatedDate)
+	out.add("name_servers", record.NameServers.HostNames...)
+	out.add("contact_email", record.ContactEmail)
+	return out, nil
+}
+
+func (r *Runner) whoisSubdomains(in map[string]string) (Outputs, error) {
+	subs, err := lookup.Subdomains(r.Whois, in["domain"])
+	if err != nil {
+		return nil, err
+	}
+	out := make(Outputs)
+	for _, s := range subs {
+		out.add("subdomains", s.Subdomain)
+	}
+	return out, nil
+}
+
+func (r *Runner) hunterDomainSearch(in map[string]string) (Outputs, error) {
+	data, err := lookup.HunterDomain(r.Hunter, in["domain"])
+	if err != nil {
+		return nil, err
+	}
+	out := make(Outputs)
+	for _, e := range data.Emails {
+		out.add("emails", e.Value)
+	}
+	out.add("pattern", data.Pattern)
+	out.add("organization", data.Organization)
+	return out, nil
+}
+
+func (r *Runner) hunterEmailFinder(in map[string]string) (Outputs, error) {
+	data, err := r.Hunter.EmailFinder(in["domain"], in["first_name"], in["last_name"])
+	if err != nil {
+		return nil, err
+	}
+	out := make(Outputs)
+	out.add("email", data.Email)
+	out.add("position", data.Position)
+	return out, nil
+}
+
+func (r *Runner) hunterCompany(in map[string]string) (Outputs, error) {
+	data, err := r.Hunter.CompanyEnrichment(in["domain"])
+	if err != nil {
+		return nil, err
+	}
+	out := make(Outputs)
+	out.add("name", data.Name)
+	out.add("industry", data.Category.Industry)
+	out.add("employees", data.Metrics.Employees)
+	return out, nil
+}
+
+func (r *Runner) hunterVerify(in map[string]string) (Outputs, error) {
+	data, err := lookup.HunterVerify(r.Hunter, in["email"])
+	if err != nil {
+		return nil, err
+	}
+	out := make(Outputs)
+	out.add("result", data.Result)
+	if data.Result == "deliverable" {
+		out.add("deliverable", data.Email)
+	}
+	return out, nil
+}
+
+func (r *Runner) dehashedSearch(in map[string]string) (Outputs, error) {
+	options := &sqlite.QueryOptions{
+		MaxRecords:   10000,
+		MaxRequests:  1,
+		StartingPage: 1,
+		OutputFormat: files.JSON,
+		OutputFile:   "playbook",
+		FetchAll:     true,
+		Debug:        r.Debug,
+	}
+	for input, value := range in {
+		var err error
+		switch input {
+		case "max_records":
+			options.MaxRecords, err = strconv.Atoi(value)
+		case "max_requests":
+			options.MaxRequests, err = strconv.Atoi(value)
+		case "wildcard":
+			options.WildcardMatch, err = strconv.ParseBool(value)
+		case "regex":
+			options.RegexMatch, err = strconv.ParseBool(value)
+		default:
+			err = options.SetQuery(input, value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", input, value, err)
+		}
+	}
+
+	results, err := lookup.Dehashed(options, r.DehashedKey, r.DehashedClient)
+	out := make(Outputs)
+	for _, result := range results {
+		out.add("records", result.DehashedId)
+		out.add("emails", result.Email...)
+		out.add("usernames", result.Username...)
+		out.add("passwords", result.Password...)
+		out.add("hashes", result.HashedPassword...)
+		out.add("ips", result.IpAddress...)
+		out.add("names", result.Name...)
+		out.add("phones", result.Phone...)
+		out.add("breaches", result.DatabaseName)
+	}
+	return out, err
+}
@@ -0,0 +1,201 @@
+package playbook
+
+import (
+	"bytes"
+	"crowsnest/internal/httpclient"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Playbook is a recon workflow read from YAML: named variables and the steps to run in order
+type Playbook struct {
+	Name        string            `yaml:"name"`
+	Description string            `yaml:"description"`
+	Vars        map[string]Values `yaml:"vars"`
+	Steps       []Step            `yaml:"steps"`
+}
+
+// Step runs an operation once, or once per value of ForEach. Inputs, ForEach and When are templated
+// from the variables and the outputs of earlier steps.
+type Step struct {
+	ID              string            `yaml:"id"`
+	Op              string            `yaml:"op"`
+	With            map[string]string `yaml:"with"`
+	ForEach         string            `yaml:"for_each"`
+	When            string            `yaml:"when"`
+	MaxCredits      int               `yaml:"max_credits"`
+	ContinueOnError bool              `yaml:"continue_on_error"`
+}
+
+// Values is a list of strings, written in YAML as a single scalar or a sequence
+type Values []string
+
+func (v *Values) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		*v = Values{node.Value}
+		return nil
+	case yaml.SequenceNode:
+		var values []string
+		if err := node.Decode(&values); err != nil {
+			return err
+		}
+		*v = values
+		return nil
+	default:
+		return fmt.Errorf("line %d: expected a value or a list of values", node.Line)
+	}
+}
+
+var stepID = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Load reads and validates a playbook file, vars override the variables it declares
+func Load(path string, vars map[string]Values) (*Playbook, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var pb Playbook
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err = decoder.Decode(&pb); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	if pb.Vars == nil {
+		pb.Vars = make(map[string]Values)
+	}
+	for name, values := range vars {
+		pb.Vars[name] = values
+	}
+
+	if err = pb.Validate(); err != nil {
+		return nil, err
+	}
+	return &pb, nil
+}
+
+// Validate checks the steps, their operations and inputs, and that every template refers to a variable or
+// to an output of an earlier step, so mistakes are caught before any credits are spent
+func (pb *Playbook) Validate() error {
+	if len(pb.Steps) == 0 {
+		return errors.New("playbook has no steps")
+	}
+
+	outputs := make(map[string][]string)
+	for i, step := range pb.Steps {
+		name := fmt.Sprintf("step %d", i+1)
+		if step.ID != "" {
+			name = fmt.Sprintf("step %q", step.ID)
+		}
+
+		if !stepID.MatchString(step.ID) {
+			return fmt.Errorf("%s: id is required and may only contain letters, digits, '-' and '_'", name)
+		}
+		if _, ok := outputs[step.ID]; ok {
+			return fmt.Errorf("%s: id is used by an earlier step", name)
+		}
+		op, ok := Operations[step.Op]
+		if !ok {
+			return fmt.Errorf("%s: unknown op %q (%s)", name, step.Op, OperationNames())
+		}
+		if step.MaxCredits < 0 {
+			return fmt.Errorf("%s: max_credits cannot be negative", name)
+		}
+
+		for input := range step.With {
+			if !slices.Contains(op.Inputs, input) && !slices.Contains(op.Options, input) {
+				return fmt.Errorf("%s: %s does not take input %q (%s)", name, op.Name, input, op.inputNames())
+			}
+		}
+		for _, input := range op.Required {
+			if step.With[input] == "" {
+				return fmt.Errorf("%s: %s requires input %q", name, op.Name, input)
+			}
+		}
+		if op.AnyInput && !slices.ContainsFunc(op.Inputs, func(input string) bool { return step.With[input] != "" }) {
+			return fmt.Errorf("%s: %s requires at least one of %v", name, op.Name, op.Inputs)
+		}
+
+		check := func(field, text string) error {
+			refs, err := references(text)
+			if err != nil {
+				return fmt.Errorf("%s: %s: %w", name, field, err)
+			}
+			for _, ref := range refs {
+				if err = pb.checkReference(ref, outputs, step.ForEach != "" && field != "for_each"); err != nil {
+					return fmt.Errorf("%s: %s: %w", name, field, err)
+				}
+			}
+			return nil
+		}
+		for input, text := range step.With {
+			if err := check(input, text); err != nil {
+				return err
+			}
+		}
+		if err := check("for_each", step.ForEach); err != nil {
+			return err
+		}
+		// A literal zero would stop the search before its first request
+		if text, ok := step.With["max_requests"]; ok && !expression.MatchString(text) {
+			if n, err := strconv.Atoi(strings.TrimSpace(text)); err != nil || n == 0 {
+				return fmt.Errorf("%s: max_requests must be -1 for every page or a positive number, got %q", name, text)
+			}
+		}
+		when, err := parseCondition(step.When)
+		if err != nil {
+			return fmt.Errorf("%s: when: %w", name, err)
+		}
+		for _, ref := range when.references() {
+			if err = pb.checkReference(ref, outputs, false); err != nil {
+				return fmt.Errorf("%s: when: %w", name, err)
+			}
+		}
+
+		outputs[step.ID] = op.Outputs
+	}
+	return nil
+}
+
+// checkReference reports a template reference to an undeclared variable, a later step or an unknown output
+func (pb *Playbook) checkReference(ref reference, outputs map[string][]string, item bool) error {
+	switch ref.kind {
+	case refItem:
+		if !item {
+			return errors.New("item is only set in the inputs of a step with for_each")
+		}
+	case refVar:
+		if _, ok := pb.Vars[ref.name]; !ok {
+			return fmt.Errorf("variable %q is not declared, add it to vars or pass it with --var", ref.name)
+		}
+	case refStep:
+		stepOutputs, ok := outputs[ref.name]
+		if !ok {
+			return fmt.Errorf("no earlier step has id %q", ref.name)
+		}
+		if !slices.Contains(stepOutputs, ref.output) {
+			return fmt.Errorf("step %q has no output %q (%v)", ref.name, ref.output, stepOutputs)
+		}
+	}
+	return nil
+}
+
+// Providers returns the providers the playbook's steps query, so their clients can be created up front
+func (pb *Playbook) Providers() []httpclient.Provider {
+	var providers []httpclient.Provider
+	for _, step := range pb.Steps {
+		provider := Operations[step.Op].Provider
+		if !slices.Contains(providers, provider) {
+			providers = append(providers, provider)
+		}
+	}
+	return providers
+}
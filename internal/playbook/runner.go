@@ -0,0 +1,300 @@
+package playbook
+
+import (
+	"context"
+	"crowsnest/internal/budget"
+	"crowsnest/internal/httpclient"
+	hunter "crowsnest/internal/hunter.io"
+	"crowsnest/internal/scope"
+	"crowsnest/internal/sqlite"
+	"crowsnest/internal/whois"
+	"crowsnest/internal/workers"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Step statuses
+const (
+	StatusOK      = "ok"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+	StatusCapped  = "capped"
+)
+
+// errOutOfScope skips an item whose inputs are outside the project scope
+var errOutOfScope = errors.New("out of scope")
+
+// Runner runs playbooks with the clients of the providers their steps use
+type Runner struct {
+	Whois          *whois.DehashedWhoIs
+	Hunter         *hunter.HunterIO
+	DehashedKey    string
+	DehashedClient *httpclient.Client
+	Cap            *budget.Cap               // Guard of every client, reset to each step's max_credits
+	InScope        func([]scope.Target) bool // Reports whether the inputs of a lookup may be queried
+	Workers        int                       // Items of a for_each step run at once
+	Debug          bool
+}
+
+// Run runs the steps in order. A step that fails stops the playbook unless it continues on error, reaching
+// the run's credit budget or being interrupted always does, and the remaining steps are reported as skipped.
+// A step reaching its own max_credits keeps what it retrieved and the playbook carries on.
+func (r *Runner) Run(ctx context.Context, pb *Playbook) *Report {
+	report := &Report{Playbook: pb.Name}
+	if r.Cap == nil {
+		r.Cap = budget.NewCap(nil)
+	}
+	if run, err := sqlite.CurrentCommandRun(); err == nil {
+		report.CommandRun = run.ID
+	}
+
+	e := &env{vars: pb.Vars, steps: make(map[string]Outputs)}
+	var stopped string
+	for i, step := range pb.Steps {
+		result := StepReport{ID: step.ID, Op: step.Op}
+		e.steps[step.ID] = make(Outputs)
+
+		if stopped != "" {
+			result.Status = StatusSkipped
+			result.Detail = stopped
+			report.Steps = append(report.Steps, result)
+			continue
+		}
+
+		fmt.Printf("\n[*] [%d/%d] %s (%s)\n", i+1, len(pb.Steps), step.ID, step.Op)
+		err := r.runStep(ctx, step, e, &result)
+		if err != nil {
+			result.Error = err.Error()
+			zap.L().Error("playbook_step",
+				zap.String("message", "playbook step failed"),
+				zap.String("step", step.ID),
+				zap.String("op", step.Op),
+				zap.Error(err),
+			)
+			fmt.Printf("   [!] %s failed: %v\n", step.ID, err)
+		}
+
+		switch {
+		case result.Status == StatusCapped:
+			fmt.Printf("   [-] %s reached its cap of %d credits\n", step.ID, step.MaxCredits)
+		case errors.Is(err, httpclient.ErrBudgetExceeded):
+			stopped = "credit budget reached"
+		case ctx.Err() != nil:
+			stopped = "interrupted"
+		case result.Status == StatusFailed && !step.ContinueOnError:
+			stopped = fmt.Sprintf("step %s failed", step.ID)
+		}
+		if result.Status != StatusSkipped && result.Detail != "" {
+			fmt.Printf("   [+] %s\n", result.Detail)
+		} else if result.Status == StatusSkipped {
+			fmt.Printf("   [-] Skipped: %s\n", result.Detail)
+		}
+		report.Steps = append(report.Steps, result)
+	}
+	return report
+}
+
+// runStep runs the step once or once per for_each item, recording its status, outputs and credits on result
+func (r *Runner) runStep(ctx context.Context, step Step, e *env, result *StepReport) error {
+	when, err := parseCondition(step.When)
+	if err == nil {
+		var ok bool
+		if ok, err = when.eval(e); err == nil && !ok {
+			result.Status = StatusSkipped
+			result.Detail = "condition not met: " + step.When
+			return nil
+		}
+	}
+	if err != nil {
+		result.Status = StatusFailed
+		return fmt.Errorf("when: %w", err)
+	}
+
+	items := Values{""}
+	if step.ForEach != "" {
+		if items, err = e.render(step.ForEach); err != nil {
+			result.Status = StatusFailed
+			return fmt.Errorf("for_each: %w", err)
+		}
+		if len(items) == 0 {
+			result.Status = StatusSkipped
+			result.Detail = "nothing to run for each of"
+			return nil
+		}
+	}
+
+	op := Operations[step.Op]
+	r.Cap.Reset(step.MaxCredits)
+	var (
+		mu       sync.Mutex
+		outputs  = make(Outputs)
+		failures []error
+		skipped  int
+	)
+	err = workers.Run(ctx, r.Workers, len(items), func(_ context.Context, i int) error {
+		itemEnv := &env{vars: e.vars, steps: e.steps, item: items[i]}
+		if step.ForEach != "" {
+			fmt.Printf("   [*] [%d/%d] %s\n", i+1, len(items), items[i])
+		}
+
+		out, err := r.runItem(op, step, itemEnv)
+		mu.Lock()
+		defer mu.Unlock()
+		outputs.merge(out)
+		if errors.Is(err, errOutOfScope) {
+			skipped++
+			return nil
+		}
+		if err != nil {
+			if errors.Is(err, httpclient.ErrBudgetExceeded) {
+				return err
+			}
+			if step.ForEach != "" {
+				fmt.Printf("      [!] %v\n", err)
+			}
+			failures = append(failures, err)
+		}
+		return nil
+	})
+
+	e.steps[step.ID] = outputs
+	result.Items = len(items)
+	result.Credits = r.Cap.Spent()
+	result.Outputs = outputs.list(op.Outputs)
+	result.Detail = outputs.summary(op.Outputs)
+	if skipped > 0 {
+		result.Detail += fmt.Sprintf(" (%d out of scope)", skipped)
+	}
+
+	switch {
+	case r.Cap.Reached():
+		result.Status = StatusCapped
+		return nil
+	case err != nil:
+		result.Status = StatusFailed
+		return err
+	case len(failures) > 0:
+		result.Status = StatusFailed
+		if len(failures) == 1 {
+			return failures[0]
+		}
+		return fmt.Errorf("%d of %d items failed, first error: %w", len(failures), len(items), failures[0])
+	}
+	result.Status = StatusOK
+	return nil
+}
+
+// runItem renders the step's inputs for one item, checks them against the scope and runs the operation
+func (r *Runner) runItem(op *Operation, step Step, e *env) (Outputs, error) {
+	in := make(map[string]string)
+	var targets []scope.Target
+	for input, text := range step.With {
+		values, err := e.render(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", input, err)
+		}
+		if len(values) > 1 {
+			return nil, fmt.Errorf("%s has %d values, use for_each to run the step once per value", input, len(values))
+		}
+		if len(values) == 0 || values[0] == "" {
+			continue
+		}
+		in[input] = values[0]
+		if kind, ok := inputTargets[input]; ok {
+			targets = append(targets, scope.Target{Kind: kind, Value: values[0]})
+		}
+	}
+
+	for _, input := range op.Required {
+		if in[input] == "" {
+			return nil, fmt.Errorf("input %s is empty", input)
+		}
+	}
+	if r.InScope != nil && len(targets) > 0 && !r.InScope(targets) {
+		return nil, errOutOfScope
+	}
+	return op.run(r, in)
+}
+
+// list returns the outputs in the operation's order
+func (o Outputs) list(names []string) []Output {
+	var list []Output
+	for _, name := range names {
+		if len(o[name]) > 0 {
+			list = append(list, Output{Name: name, Values: o[name]})
+		}
+	}
+	return list
+}
+
+// summary describes the outputs in one line, single values in full and lists by their length
+func (o Outputs) summary(names []string) string {
+	var parts []string
+	for _, name := range names {
+		switch values := o[name]; len(values) {
+		case 0:
+		case 1:
+			parts = append(parts, fmt.Sprintf("%s %s", name, values[0]))
+		default:
+			parts = append(parts, fmt.Sprintf("%d %s", len(values), name))
+		}
+	}
+	if len(parts) == 0 {
+		return "no results"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Output is a named output of a step as written to the report
+type Output struct {
+	Name   string   `json:"name" yaml:"name" xml:"name,attr"`
+	Values []string `json:"values" yaml:"values" xml:"value"`
+}
+
+// StepReport is the outcome of one step of a playbook
+type StepReport struct {
+	ID      string   `json:"id" yaml:"id" xml:"id,attr"`
+	Op      string   `json:"op" yaml:"op" xml:"op,attr"`
+	Status  string   `json:"status" yaml:"status" xml:"status"`
+	Items   int      `json:"items" yaml:"items" xml:"items"`
+	Credits int      `json:"credits" yaml:"credits" xml:"credits"`
+	Detail  string   `json:"detail,omitempty" yaml:"detail,omitempty" xml:"detail,omitempty"`
+	Error   string   `json:"error,omitempty" yaml:"error,omitempty" xml:"error,omitempty"`
+	Outputs []Output `json:"outputs,omitempty" yaml:"outputs,omitempty" xml:"outputs>output,omitempty"`
+}
+
+// Report is the outcome of a playbook run
+type Report struct {
+	XMLName    xml.Name     `json:"-" yaml:"-" xml:"playbook_report"`
+	Playbook   string       `json:"playbook" yaml:"playbook" xml:"playbook"`
+	CommandRun uint         `json:"command_run" yaml:"command_run" xml:"command_run"`
+	Steps      []StepReport `json:"steps" yaml:"steps" xml:"steps>step"`
+}
+
+func (r *Report) String() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Playbook: %s\nCommand Run: %d\n", r.Playbook, r.CommandRun))
+	for _, step := range r.Steps {
+		sb.WriteString(fmt.Sprintf("\n%s (%s): %s\n", step.ID, step.Op, step.Status))
+		if step.Detail != "" {
+			sb.WriteString("  " + step.Detail + "\n")
+		}
+		if step.Error != "" {
+			sb.WriteString("  Error: " + step.Error + "\n")
+		}
+		if step.Credits > 0 {
+			sb.WriteString(fmt.Sprintf("  Credits: %d\n", step.Credits))
+		}
+		for _, output := range step.Outputs {
+			values := append([]string(nil), output.Values...)
+			sort.Strings(values)
+			sb.WriteString(fmt.Sprintf("  %s: %s\n", output.Name, strings.Join(values, ", ")))
+		}
+	}
+	return sb.String()
+}
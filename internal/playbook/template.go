@@ -0,0 +1,304 @@
+package playbook
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Kinds of template references
+const (
+	refVar  = "vars"
+	refStep = "steps"
+	refItem = "item"
+)
+
+// expression matches a {{ reference }} in a template
+var expression = regexp.MustCompile(`\{\{\s*(.*?)\s*\}\}`)
+
+// reference is a value a template refers to: vars.<name>, steps.<id>.<output> or item,
+// with an optional .count suffix for the number of values
+type reference struct {
+	kind   string
+	name   string
+	output string
+	count  bool
+}
+
+func parseReference(text string) (reference, error) {
+	parts := strings.Split(strings.TrimSpace(text), ".")
+	var ref reference
+	if n := len(parts); n > 1 && parts[n-1] == "count" {
+		ref.count = true
+		parts = parts[:n-1]
+	}
+
+	switch {
+	case len(parts) == 1 && parts[0] == refItem:
+		ref.kind = refItem
+	case len(parts) == 2 && parts[0] == refVar && parts[1] != "":
+		ref.kind, ref.name = refVar, parts[1]
+	case len(parts) == 3 && parts[0] == refStep && parts[1] != "" && parts[2] != "":
+		ref.kind, ref.name, ref.output = refStep, parts[1], parts[2]
+	default:
+		return ref, fmt.Errorf("invalid reference %q, expected vars.<name>, steps.<id>.<output> or item", text)
+	}
+	return ref, nil
+}
+
+// references returns the references of every {{ }} expression in text
+func references(text string) ([]reference, error) {
+	var refs []reference
+	for _, match := range expression.FindAllStringSubmatch(text, -1) {
+		ref, err := parseReference(match[1])
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+// env holds the values templates are rendered from while a playbook runs
+type env struct {
+	vars  map[string]Values
+	steps map[string]Outputs
+	item  string
+}
+
+func (e *env) lookup(ref reference) Values {
+	var values Values
+	switch ref.kind {
+	case refItem:
+		values = Values{e.item}
+	case refVar:
+		values = e.vars[ref.name]
+	case refStep:
+		values = e.steps[ref.name][ref.output]
+	}
+	if ref.count {
+		return Values{strconv.Itoa(len(values))}
+	}
+	return values
+}
+
+// render expands the templates in text. A text that is a single expression renders to all of its values,
+// an expression embedded in other text is replaced by its values joined with commas.
+func (e *env) render(text string) (Values, error) {
+	if match := expression.FindStringSubmatch(text); match != nil && match[0] == strings.TrimSpace(text) {
+		ref, err := parseReference(match[1])
+		if err != nil {
+			return nil, err
+		}
+		return e.lookup(ref), nil
+	}
+
+	var err error
+	rendered := expression.ReplaceAllStringFunc(text, func(match string) string {
+		ref, refErr := parseReference(expression.FindStringSubmatch(match)[1])
+		if refErr != nil {
+			err = refErr
+			return ""
+		}
+		return strings.Join(e.lookup(ref), ",")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return Values{rendered}, nil
+}
+
+// Condition operators
+var operators = []string{"==", "!=", ">=", "<=", ">", "<", "contains"}
+
+// operand is a literal or a reference of a condition
+type operand struct {
+	literal string
+	ref     *reference
+}
+
+// condition is a parsed when: [not] <operand> [<operator> <operand>]
+type condition struct {
+	negate      bool
+	left, right operand
+	operator    string
+}
+
+// parseCondition parses a step condition. A reference alone is true when it has a value that is not
+// empty, "false" or "0", and a comparison of numbers is numeric. An empty condition is always true.
+func parseCondition(text string) (*condition, error) {
+	text = strings.TrimSpace(text)
+	if match := expression.FindStringSubmatch(text); match != nil && match[0] == text {
+		text = match[1]
+	}
+	tokens, err := tokenize(text)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	var c condition
+	if tokens[0] == "not" {
+		c.negate = true
+		tokens = tokens[1:]
+	}
+	switch len(tokens) {
+	case 1:
+	case 3:
+		if !slices.Contains(operators, tokens[1]) {
+			return nil, fmt.Errorf("unknown operator %q (%s)", tokens[1], strings.Join(operators, ", "))
+		}
+		c.operator = tokens[1]
+		if c.right, err = parseOperand(tokens[2]); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("invalid condition %q, expected [not] <value> [<operator> <value>]", text)
+	}
+	if c.left, err = parseOperand(tokens[0]); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// references returns the references of the condition's operands
+func (c *condition) references() []reference {
+	var refs []reference
+	if c == nil {
+		return refs
+	}
+	for _, o := range []operand{c.left, c.right} {
+		if o.ref != nil {
+			refs = append(refs, *o.ref)
+		}
+	}
+	return refs
+}
+
+func (c *condition) eval(e *env) (bool, error) {
+	if c == nil {
+		return true, nil
+	}
+	left := c.left.values(e)
+	if c.operator == "" {
+		return truthy(left) != c.negate, nil
+	}
+
+	right := strings.Join(c.right.values(e), ",")
+	var result bool
+	switch c.operator {
+	case "==":
+		result = strings.Join(left, ",") == right
+	case "!=":
+		result = strings.Join(left, ",") != right
+	case "contains":
+		result = slices.Contains(left, right)
+	default:
+		l, lErr := strconv.ParseFloat(strings.Join(left, ","), 64)
+		r, rErr := strconv.ParseFloat(right, 64)
+		if lErr != nil || rErr != nil {
+			return false, fmt.Errorf("%s needs numbers, got %q and %q", c.operator, strings.Join(left, ","), right)
+		}
+		switch c.operator {
+		case ">":
+			result = l > r
+		case ">=":
+			result = l >= r
+		case "<":
+			result = l < r
+		case "<=":
+			result = l <= r
+		}
+	}
+	return result != c.negate, nil
+}
+
+func parseOperand(token string) (operand, error) {
+	if len(token) >= 2 && (token[0] == '"' || token[0] == '\'') && token[len(token)-1] == token[0] {
+		return operand{literal: token[1 : len(token)-1]}, nil
+	}
+	if _, err := strconv.ParseFloat(token, 64); err == nil || token == "true" || token == "false" {
+		return operand{literal: token}, nil
+	}
+	if match := expression.FindStringSubmatch(token); match != nil && match[0] == token {
+		token = match[1]
+	}
+	ref, err := parseReference(token)
+	if err != nil {
+		return operand{}, err
+	}
+	return operand{ref: &ref}, nil
+}
+
+func (o operand) values(e *env) Values {
+	if o.ref != nil {
+		return e.lookup(*o.ref)
+	}
+	return Values{o.literal}
+}
+
+// tokenize splits a condition on spaces, keeping quoted strings and {{ }} expressions whole
+func tokenize(text string) ([]string, error) {
+	var (
+		tokens []string
+		token  strings.Builder
+		quote  rune
+		braces bool
+	)
+	flush := func() {
+		if token.Len() > 0 {
+			tokens = append(tokens, token.String())
+			token.Reset()
+		}
+	}
+	for i, r := range text {
+		switch {
+		case quote != 0:
+			token.WriteRune(r)
+			if r == quote {
+				quote = 0
+			}
+		case braces:
+			if r != ' ' {
+				token.WriteRune(r)
+			}
+			if strings.HasSuffix(token.String(), "}}") {
+				braces = false
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			token.WriteRune(r)
+		case strings.HasPrefix(text[i:], "{{"):
+			braces = true
+			token.WriteRune(r)
+		case r == ' ' || r == '\t':
+			flush()
+		default:
+			token.WriteRune(r)
+		}
+	}
+	if quote != 0 || braces {
+		return nil, errors.New("unterminated quote or {{ expression")
+	}
+	flush()
+	return tokens, nil
+}
+
+// truthy reports whether values hold anything other than nothing, an empty string, "false" or "0"
+func truthy(values Values) bool {
+	if len(values) == 0 {
+		return false
+	}
+	if len(values) == 1 {
+		switch strings.TrimSpace(values[0]) {
+		case "", "false", "0":
+			return false
+		}
+	}
+	return true
+}
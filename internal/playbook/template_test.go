@@ -0,0 +1,165 @@
+package playbook
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func testEnv() *env {
+	return &env{
+		vars: map[string]Values{
+			"domain":  {"acme.com"},
+			"domains": {"acme.com", "acme.org"},
+			"enabled": {"true"},
+		},
+		steps: map[string]Outputs{
+			"hunter": {"emails": {"a@acme.com", "b@acme.com"}, "pattern": {"{first}.{last}"}},
+			"whois":  {"registrar": {"Example Registrar"}},
+			"empty":  {"emails": nil, "result": {"0"}},
+		},
+		item: "a@acme.com",
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		text string
+		want Values
+		err  string
+	}{
+		{text: "acme.com", want: Values{"acme.com"}},
+		{text: "{{ vars.domain }}", want: Values{"acme.com"}},
+		{text: " {{vars.domains}} ", want: Values{"acme.com", "acme.org"}},
+		{text: "{{ steps.hunter.emails }}", want: Values{"a@acme.com", "b@acme.com"}},
+		{text: "{{ steps.hunter.emails.count }}", want: Values{"2"}},
+		{text: "{{ steps.empty.emails.count }}", want: Values{"0"}},
+		{text: "{{ steps.empty.emails }}", want: nil},
+		{text: "{{ item }}", want: Values{"a@acme.com"}},
+		{text: "site:{{ vars.domains }} -{{item}}", want: Values{"site:acme.com,acme.org -a@acme.com"}},
+		{text: "{{ vars.missing }}", want: nil},
+		{text: "{{ domain }}", err: "invalid reference"},
+		{text: "x {{ steps.hunter }}", err: "invalid reference"},
+		{text: "{{ vars. }}", err: "invalid reference"},
+	}
+	e := testEnv()
+	for _, tt := range tests {
+		got, err := e.render(tt.text)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("render(%q) error = %v, want %q", tt.text, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("render(%q) error = %v", tt.text, err)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("render(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestCondition(t *testing.T) {
+	tests := []struct {
+		when string
+		want bool
+		err  string
+	}{
+		{when: "", want: true},
+		{when: "vars.enabled", want: true},
+		{when: "{{ vars.enabled }}", want: true},
+		{when: "not vars.enabled", want: false},
+		{when: "steps.hunter.emails", want: true},
+		{when: "steps.empty.emails", want: false},
+		{when: "steps.empty.result", want: false},
+		{when: "vars.missing", want: false},
+		{when: "steps.hunter.emails.count > 1", want: true},
+		{when: "{{ steps.hunter.emails.count }} >= 3", want: false},
+		{when: "steps.empty.emails.count == 0", want: true},
+		{when: "not steps.empty.emails.count < 1", want: false},
+		{when: "steps.whois.registrar == 'Example Registrar'", want: true},
+		{when: `steps.whois.registrar != "Example Registrar"`, want: false},
+		{when: "steps.hunter.emails contains 'b@acme.com'", want: true},
+		{when: "steps.hunter.emails contains 'acme.com'", want: false},
+		{when: "vars.domains == 'acme.com,acme.org'", want: true},
+		{when: "steps.hunter.pattern == '{first}.{last}'", want: true},
+		{when: "10 > 9", want: true}, // Numbers compare numerically, not as strings
+		{when: "vars.domain > 1", err: "> needs numbers"},
+		{when: "vars.domain ~= acme", err: "unknown operator"},
+		{when: "vars.domain ==", err: "invalid condition"},
+		{when: "vars.domain == 'acme.com", err: "unterminated"},
+		{when: "{{ vars.domain == 1", err: "unterminated"},
+		{when: "domain == 1", err: "invalid reference"},
+	}
+	e := testEnv()
+	for _, tt := range tests {
+		c, err := parseCondition(tt.when)
+		var got bool
+		if err == nil {
+			got, err = c.eval(e)
+		}
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("condition %q error = %v, want %q", tt.when, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("condition %q error = %v", tt.when, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("condition %q = %v, want %v", tt.when, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	hunter := Step{ID: "hunter", Op: "hunter.domain-search", With: map[string]string{"domain": "{{ vars.domain }}"}}
+	tests := []struct {
+		name  string
+		steps []Step
+		err   string
+	}{
+		{
+			name: "valid",
+			steps: []Step{hunter, {
+				ID: "verify", Op: "hunter.verify", ForEach: "{{ steps.hunter.emails }}",
+				With: map[string]string{"email": "{{ item }}"}, When: "steps.hunter.emails.count > 0",
+			}},
+		},
+		{name: "no steps", err: "playbook has no steps"},
+		{name: "missing id", steps: []Step{{Op: "whois.lookup", With: map[string]string{"domain": "acme.com"}}}, err: "id is required"},
+		{name: "duplicate id", steps: []Step{hunter, hunter}, err: "id is used by an earlier step"},
+		{name: "unknown op", steps: []Step{{ID: "a", Op: "shodan.host"}}, err: `unknown op "shodan.host"`},
+		{name: "unknown input", steps: []Step{{ID: "a", Op: "whois.lookup", With: map[string]string{"domain": "acme.com", "ip": "1.1.1.1"}}}, err: `does not take input "ip"`},
+		{name: "missing input", steps: []Step{{ID: "a", Op: "hunter.email-finder", With: map[string]string{"domain": "acme.com"}}}, err: `requires input "first_name"`},
+		{name: "no search input", steps: []Step{{ID: "a", Op: "dehashed.search", With: map[string]string{"max_records": "10"}}}, err: "requires at least one of"},
+		{name: "undeclared variable", steps: []Step{{ID: "a", Op: "whois.lookup", With: map[string]string{"domain": "{{ vars.target }}"}}}, err: `variable "target" is not declared`},
+		{name: "later step", steps: []Step{{ID: "a", Op: "hunter.verify", With: map[string]string{"email": "{{ steps.hunter.emails }}"}}, hunter}, err: `no earlier step has id "hunter"`},
+		{name: "unknown output", steps: []Step{hunter, {ID: "a", Op: "hunter.verify", With: map[string]string{"email": "{{ steps.hunter.email }}"}}}, err: `has no output "email"`},
+		{name: "item without for_each", steps: []Step{{ID: "a", Op: "hunter.verify", With: map[string]string{"email": "{{ item }}"}}}, err: "item is only set"},
+		{name: "item in for_each", steps: []Step{{ID: "a", Op: "hunter.verify", ForEach: "{{ item }}", With: map[string]string{"email": "{{ item }}"}}}, err: "item is only set"},
+		{name: "invalid condition", steps: []Step{{ID: "a", Op: "whois.lookup", With: map[string]string{"domain": "acme.com"}, When: "vars.domain is not set"}}, err: "when: invalid condition"},
+		{name: "zero requests", steps: []Step{{ID: "a", Op: "dehashed.search", With: map[string]string{"domain": "acme.com", "max_requests": "0"}}}, err: "max_requests must be -1 for every page or a positive number"},
+		{name: "templated requests", steps: []Step{{ID: "a", Op: "dehashed.search", With: map[string]string{"domain": "acme.com", "max_requests": "{{ vars.domain }}"}}}},
+		{name: "negative credits", steps: []Step{{ID: "a", Op: "whois.lookup", With: map[string]string{"domain": "acme.com"}, MaxCredits: -1}}, err: "max_credits cannot be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pb := &Playbook{Vars: map[string]Values{"domain": {"acme.com"}}, Steps: tt.steps}
+			err := pb.Validate()
+			if tt.err == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/lipgloss/tree"
+	"slices"
+	"sort"
+	"strings"
 )
 
 func WhoIsTree(root string, record sqlite.WhoisRecord) {
@@ -343,3 +346,130 @@ func personTree(record sqlite.PersonData) *tree.Tree {
 
 	return personTree
 }
+
+func IdentityTree(root string, details sqlite.IdentityDetails) {
+	enumeratorStyle := lipgloss.NewStyle().Foreground(purple).MarginRight(1)
+	rootStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("63"))
+	itemStyle := lipgloss.NewStyle().Foreground(gray)
+
+	identity := details.Identity
+	rootTree := tree.Root(root)
+
+	// Root Tree Children
+	rootTree.Child("Name: " + identity.Name)
+	rootTree.Child("Primary Email: " + identity.PrimaryEmail)
+	rootTree.Child(fmt.Sprintf("Confidence: %.0f%%", identity.Confidence*100))
+	rootTree.Child(fmt.Sprintf("Records: %d", identity.Records))
+	rootTree.Child(listTree("Emails", identity.Emails))
+	rootTree.Child(listTree("Usernames", identity.Usernames))
+	rootTree.Child(listTree("Names", identity.Names))
+	rootTree.Child(listTree("Phones", identity.Phones))
+
+	// Breaches Tree, the credentials of each breach database
+	var (
+		databases []string
+		passwords []string
+		breaches  = make(map[string][]string)
+	)
+	addCredential := func(database, account, password, hash, hashType string, cracked bool) {
+		if database == "" {
+			database = "Unknown"
+		}
+		var secret string
+		switch {
+		case password != "" && cracked:
+			secret = password + " (cracked)"
+		case password != "":
+			secret = password
+		case hash != "" && hashType != "":
+			secret = hash + " (" + hashType + ")"
+		default:
+			secret = hash
+		}
+		if secret == "" {
+			return
+		}
+		if _, ok := breaches[database]; !ok {
+			databases = append(databases, database)
+		}
+		line := account + ": " + secret
+		if !slices.Contains(breaches[database], line) {
+			breaches[database] = append(breaches[database], line)
+		}
+		if password != "" && !slices.Contains(passwords, password) {
+			passwords = append(passwords, password)
+		}
+	}
+	for _, c := range details.Creds {
+		account := c.Email
+		if account == "" {
+			account = c.Username
+		}
+		addCredential(c.DatabaseName, account, c.Password, c.HashedPassword, c.HashType, c.Cracked)
+	}
+	for _, r := range details.Dehashed {
+		account := strings.Join(append(slices.Clone(r.Email), r.Username...), ", ")
+		for _, p := range r.Password {
+			addCredential(r.DatabaseName, account, p, "", "", false)
+		}
+		for _, h := range r.HashedPassword {
+			addCredential(r.DatabaseName, account, "", h, r.HashType, false)
+		}
+		if len(r.Password) == 0 && len(r.HashedPassword) == 0 && r.DatabaseName != "" {
+			if _, ok := breaches[r.DatabaseName]; !ok {
+				databases = append(databases, r.DatabaseName)
+				breaches[r.DatabaseName] = nil
+			}
+		}
+	}
+	breachesTree := tree.Root("Breaches")
+	sort.Strings(databases)
+	for _, database := range databases {
+		breachesTree.Child(listTree(database, breaches[database]))
+	}
+	rootTree.Child(breachesTree)
+	rootTree.Child(listTree("Passwords", passwords))
+
+	// Roles Tree, the positions held according to each source
+	var roles []string
+	addRole := func(parts ...string) {
+		var fields []string
+		for _, p := range parts {
+			if p = strings.TrimSpace(p); p != "" && !slices.Contains(fields, p) {
+				fields = append(fields, p)
+			}
+		}
+		if role := strings.Join(fields, ", "); role != "" && !slices.Contains(roles, role) {
+			roles = append(roles, role)
+		}
+	}
+	for _, e := range details.HunterEmails {
+		addRole(e.Position, e.Department, e.Seniority, e.Domain)
+	}
+	for _, p := range details.People {
+		addRole(p.Employment.Title, p.Employment.Role, p.Employment.Seniority, p.Employment.Name)
+	}
+	for _, c := range details.Creds {
+		addRole(c.Position, c.Department, c.Company)
+	}
+	rootTree.Child(listTree("Roles", roles))
+	rootTree.Child(listTree("Social Profiles", identity.Socials))
+
+	// Styles
+	rootTree.Enumerator(tree.RoundedEnumerator)
+	rootTree.EnumeratorStyle(enumeratorStyle)
+	rootTree.RootStyle(rootStyle)
+	rootTree.ItemStyle(itemStyle)
+
+	// Print Tree
+	fmt.Println(rootTree)
+}
+
+// listTree returns a tree with a child for each value
+func listTree(root string, values []string) *tree.Tree {
+	listTree := tree.Root(root)
+	for _, v := range values {
+		listTree.Child(v)
+	}
+	return listTree
+}
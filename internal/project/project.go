@@ -0,0 +1,185 @@
+package project
+
+import (
+	"crowsnest/internal/badger"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when no project has the given name
+	ErrNotFound = errors.New("project not found")
+	// ErrArchived is returned when an archived project is made active
+	ErrArchived = errors.New("project is archived")
+
+	validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+	// rootDir holds one directory per project
+	rootDir string
+)
+
+// Project is an engagement workspace with its own database, output directory, budget and scope.
+// The budget and scope live in the project database so they never mix with another engagement.
+type Project struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Path        string    `json:"path"`       // Directory holding the project database
+	OutputDir   string    `json:"output_dir"` // Relative output files are written here
+	Archived    bool      `json:"archived"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// DBPath returns the directory of the project database
+func (p Project) DBPath() string {
+	return filepath.Join(p.Path, "db")
+}
+
+// OutputInside reports whether the output directory lives inside the project directory, so deleting the
+// project removes it too
+func (p Project) OutputInside() bool {
+	rel, err := filepath.Rel(p.Path, p.OutputDir)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+// SetRoot sets the directory new projects are created in
+func SetRoot(dir string) {
+	rootDir = dir
+}
+
+// Create stores a new project and creates its directories.
+// The output directory defaults to an output directory inside the project.
+func Create(name, description, outputDir string) (Project, error) {
+	if !validName.MatchString(name) {
+		return Project{}, fmt.Errorf("invalid project name %q (letters, numbers, '.', '_' and '-' only)", name)
+	}
+	if _, err := Get(name); err == nil {
+		return Project{}, fmt.Errorf("project %s already exists", name)
+	}
+
+	p := Project{
+		Name:        name,
+		Description: description,
+		Path:        filepath.Join(rootDir, name),
+		OutputDir:   outputDir,
+		CreatedAt:   time.Now(),
+	}
+	if p.OutputDir == "" {
+		p.OutputDir = filepath.Join(p.Path, "output")
+	}
+	if abs, err := filepath.Abs(p.OutputDir); err == nil {
+		p.OutputDir = abs
+	}
+
+	for _, dir := range []string{p.DBPath(), p.OutputDir} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return Project{}, fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+	}
+	return p, save(p)
+}
+
+// Get returns the project with the given name
+func Get(name string) (Project, error) {
+	var p Project
+	data := badger.GetProject(name)
+	if data == nil {
+		return p, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	err := json.Unmarshal(data, &p)
+	return p, err
+}
+
+// List returns every project sorted by name
+func List() ([]Project, error) {
+	var projects []Project
+	for _, data := range badger.GetProjects() {
+		var p Project
+		if err := json.Unmarshal(data, &p); err != nil {
+			return nil, err
+		}
+		projects = append(projects, p)
+	}
+	sort.Slice(projects, func(i, j int) bool {
+		return projects[i].Name < projects[j].Name
+	})
+	return projects, nil
+}
+
+// Active returns the active project, or false when the default database is in use.
+// An error is returned when the active project was archived or removed outside of crowsnest.
+func Active() (Project, bool, error) {
+	name := badger.GetActiveProject()
+	if name == "" {
+		return Project{}, false, nil
+	}
+	p, err := Get(name)
+	if err != nil {
+		return Project{}, false, fmt.Errorf("active project %s cannot be used: %w", name, err)
+	}
+	if p.Archived {
+		return Project{}, false, fmt.Errorf("active project %s cannot be used: %w", name, ErrArchived)
+	}
+	return p, true, nil
+}
+
+// Use makes the project active for every following command, an empty name returns to the default database
+func Use(name string) error {
+	if name == "" {
+		return badger.StoreActiveProject("")
+	}
+	p, err := Get(name)
+	if err != nil {
+		return err
+	}
+	if p.Archived {
+		return fmt.Errorf("%w: %s", ErrArchived, name)
+	}
+	return badger.StoreActiveProject(name)
+}
+
+// SetArchived archives or restores a project. An archived project is kept on disk but cannot be used.
+func SetArchived(name string, archived bool) error {
+	p, err := Get(name)
+	if err != nil {
+		return err
+	}
+	p.Archived = archived
+	if archived && badger.GetActiveProject() == name {
+		if err = badger.StoreActiveProject(""); err != nil {
+			return err
+		}
+	}
+	return save(p)
+}
+
+// Delete removes the project and its database. The output directory is removed too when it lives inside the project.
+func Delete(name string) error {
+	p, err := Get(name)
+	if err != nil {
+		return err
+	}
+	if badger.GetActiveProject() == name {
+		if err = badger.StoreActiveProject(""); err != nil {
+			return err
+		}
+	}
+	if err = os.RemoveAll(p.Path); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", p.Path, err)
+	}
+	return badger.DeleteProject(name)
+}
+
+func save(p Project) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return badger.StoreProject(p.Name, data)
+}
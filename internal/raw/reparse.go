@@ -0,0 +1,131 @@
+package raw
+
+import (
+	"crowsnest/internal/dehashed"
+	hunter "crowsnest/internal/hunter.io"
+	"crowsnest/internal/sqlite"
+	"crowsnest/internal/whois"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrNotReparseable is returned for responses whose operation has no typed table to parse into
+var ErrNotReparseable = errors.New("operation is not stored in a typed table")
+
+// Reparse parses a stored response into the typed tables again and returns the number of records stored.
+// Records already stored are overwritten so fields added to the models since are filled in.
+func Reparse(r sqlite.RawResponse) (int, error) {
+	if r.StatusCode < 200 || r.StatusCode >= 300 {
+		return 0, fmt.Errorf("response has status %d", r.StatusCode)
+	}
+
+	body, err := r.ResponseBody()
+	if err != nil {
+		return 0, fmt.Errorf("failed to decompress response: %w", err)
+	}
+
+	switch r.Operation {
+	case dehashed.OpSearch:
+		var response sqlite.DehashedResponse
+		if err = json.Unmarshal(body, &response); err != nil {
+			return 0, err
+		}
+		results := sqlite.DehashedResults{Results: response.Entries}
+		if err = sqlite.UpsertRecords(results.Results, "dehashed_id"); err != nil {
+			return 0, err
+		}
+		return len(response.Entries), sqlite.StoreUsers(results.ExtractUsers())
+
+	case whois.OpSearch:
+		var response sqlite.WhoIsLookupResult
+		if err = json.Unmarshal(body, &response); err != nil {
+			return 0, err
+		}
+		if len(response.Data.WhoisRecord.DomainName) == 0 {
+			return 0, nil
+		}
+		return 1, sqlite.UpsertRecords([]sqlite.WhoisRecord{response.Data.WhoisRecord}, "domain_name")
+
+	case whois.OpHistory:
+		var response sqlite.WhoIsHistory
+		if err = json.Unmarshal(body, &response); err != nil {
+			return 0, err
+		}
+		return len(response.Data.Records), sqlite.UpsertRecords(response.Data.Records, "domain_name")
+
+	case whois.OpIP, whois.OpMX, whois.OpNS:
+		var response sqlite.WhoIsIPLookup
+		if err = json.Unmarshal(body, &response); err != nil {
+			return 0, err
+		}
+		request, err := whoisRequest(r)
+		if err != nil {
+			return 0, err
+		}
+		var lookups []sqlite.LookupResult
+		switch r.Operation {
+		case whois.OpIP:
+			lookups = whois.LookupResults(response.Data, request.IPAddress, "Reverse IP")
+		case whois.OpMX:
+			lookups = whois.LookupResults(response.Data, request.MXAddress, "MX")
+		default:
+			lookups = whois.LookupResults(response.Data, request.NSAddress, "NS")
+		}
+		return len(lookups), sqlite.UpsertRecords(lookups, "name")
+
+	case whois.OpSubdomainScan:
+		var response sqlite.WhoIsSubdomainScan
+		if err = json.Unmarshal(body, &response); err != nil {
+			return 0, err
+		}
+		request, err := whoisRequest(r)
+		if err != nil {
+			return 0, err
+		}
+		var subs []sqlite.Subdomain
+		for _, s := range response.Data.Result.Records {
+			subs = append(subs, sqlite.Subdomain{Domain: request.Domain, Subdomain: s.Domain})
+		}
+		return len(subs), sqlite.UpsertRecords(subs, "subdomain")
+
+	case hunter.OpDomainSearch:
+		var response sqlite.HunterDomainSearchResult
+		if err = json.Unmarshal(body, &response); err != nil {
+			return 0, err
+		}
+		emails := hunter.DomainEmails(response.Data, response.Data.Domain)
+		if err = sqlite.UpsertRecords(emails, "value"); err != nil {
+			return 0, err
+		}
+		if err = sqlite.UpsertRecords([]sqlite.HunterDomainData{response.Data}, "domain"); err != nil {
+			return 0, err
+		}
+		var creds []sqlite.User
+		for _, email := range emails {
+			creds = append(creds, sqlite.User{Email: email.Value})
+		}
+		return len(emails) + 1, sqlite.StoreUsers(creds)
+
+	case hunter.OpPersonEnrichment:
+		var response sqlite.HunterPersonEnrichmentResponse
+		if err = json.Unmarshal(body, &response); err != nil {
+			return 0, err
+		}
+		return 1, sqlite.UpsertRecords([]sqlite.PersonData{response.Data}, "email")
+
+	default:
+		return 0, fmt.Errorf("%w: %s", ErrNotReparseable, r.Operation)
+	}
+}
+
+// whoisRequest decodes the stored request body, which carries the search term the response lacks
+func whoisRequest(r sqlite.RawResponse) (whois.DehashedWHOISSearchRequest, error) {
+	var request whois.DehashedWHOISSearchRequest
+	body, err := r.RequestBody()
+	if err != nil {
+		return request, fmt.Errorf("failed to decompress request: %w", err)
+	}
+	err = json.Unmarshal(body, &request)
+	return request, err
+}
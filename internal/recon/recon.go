@@ -0,0 +1,308 @@
+package recon
+
+import (
+	"cmp"
+	"context"
+	"crowsnest/internal/files"
+	"crowsnest/internal/httpclient"
+	hunter "crowsnest/internal/hunter.io"
+	"crowsnest/internal/lookup"
+	"crowsnest/internal/scope"
+	"crowsnest/internal/sqlite"
+	"crowsnest/internal/whois"
+	"crowsnest/internal/workers"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Steps of the pipeline, in the order they run
+const (
+	StepWhois          = "whois"
+	StepSubdomains     = "subdomains"
+	StepHunterDomain   = "hunter-domain"
+	StepCompany        = "company"
+	StepDehashedDomain = "dehashed-domain"
+	StepDehashedEmails = "dehashed-emails"
+	StepVerify         = "verify"
+)
+
+// Steps lists every step of the pipeline in order
+var Steps = []string{StepWhois, StepSubdomains, StepHunterDomain, StepCompany, StepDehashedDomain, StepDehashedEmails, StepVerify}
+
+// Step statuses
+const (
+	StatusOK      = "ok"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+)
+
+// Pipeline chains the WHOIS, Hunter.io and Dehashed lookups of a domain. Each step feeds the next: the emails
+// found by the Hunter.io and Dehashed domain searches are searched in Dehashed and verified with Hunter.io.
+type Pipeline struct {
+	Domain         string
+	Steps          []string                  // Steps to run, every step when empty
+	MaxEmails      int                       // Emails searched and verified, 0 for all
+	MaxRecords     int                       // Dehashed records per page
+	MaxRequests    int                       // Dehashed requests per search, -1 for no limit
+	Workers        int                       // Emails searched or verified at once
+	Whois          *whois.DehashedWhoIs      // Client of the WHOIS steps
+	Hunter         *hunter.HunterIO          // Client of the Hunter.io steps
+	DehashedKey    string                    // API key of the Dehashed steps
+	DehashedClient *httpclient.Client        // HTTP client of the Dehashed steps
+	InScope        func([]scope.Target) bool // Reports whether discovered targets may be queried
+	Debug          bool
+
+	mu      sync.Mutex
+	emails  []string
+	summary *Summary
+}
+
+// Run runs the selected steps in order. A step failing does not stop the ones after it, running out of
+// credits or being interrupted does, and the remaining steps are reported as skipped.
+func (p *Pipeline) Run(ctx context.Context) *Summary {
+	p.summary = &Summary{Domain: p.Domain}
+	if run, err := sqlite.CurrentCommandRun(); err == nil {
+		p.summary.CommandRun = run.ID
+	}
+
+	steps := map[string]func(context.Context) (string, error){
+		StepWhois:          p.whois,
+		StepSubdomains:     p.subdomains,
+		StepHunterDomain:   p.hunterDomain,
+		StepCompany:        p.company,
+		StepDehashedDomain: p.dehashedDomain,
+		StepDehashedEmails: p.dehashedEmails,
+		StepVerify:         p.verify,
+	}
+
+	selected := slices.DeleteFunc(slices.Clone(Steps), func(step string) bool {
+		return len(p.Steps) > 0 && !slices.Contains(p.Steps, step)
+	})
+
+	var stopped string
+	for i, step := range selected {
+		result := StepResult{Step: step}
+		if stopped != "" {
+			result.Status = StatusSkipped
+			result.Detail = stopped
+			p.summary.Steps = append(p.summary.Steps, result)
+			continue
+		}
+
+		fmt.Printf("\n[*] [%d/%d] %s\n", i+1, len(selected), step)
+		detail, err := steps[step](ctx)
+		result.Detail = detail
+		result.Status = StatusOK
+		if err != nil {
+			result.Status = StatusFailed
+			result.Error = err.Error()
+			zap.L().Error("recon_step",
+				zap.String("message", "recon step failed"),
+				zap.String("step", step),
+				zap.Error(err),
+			)
+			fmt.Printf("   [!] %s failed: %v\n", step, err)
+
+			switch {
+			case errors.Is(err, httpclient.ErrBudgetExceeded):
+				stopped = "credit budget reached"
+			case ctx.Err() != nil:
+				stopped = "interrupted"
+			}
+		} else if detail != "" {
+			fmt.Printf("   [+] %s\n", detail)
+		}
+		p.summary.Steps = append(p.summary.Steps, result)
+	}
+
+	p.summary.Emails = p.emails
+	sort.Strings(p.summary.Breaches)
+	return p.summary
+}
+
+func (p *Pipeline) whois(context.Context) (string, error) {
+	record, err := lookup.Whois(p.Whois, p.Domain)
+	if err != nil {
+		return "", err
+	}
+
+	p.summary.Registrar = record.RegistrarName
+	p.summary.Registrant = record.Registrant.Organization
+	if p.summary.Registrant == "" {
+		p.summary.Registrant = record.Registrant.Name
+	}
+	p.summary.CreatedDate = record.CreatedDate
+	p.summary.NameServers = record.NameServers.HostNames
+	return fmt.Sprintf("Registrar %s, %d name servers", cmp.Or(record.RegistrarName, "unknown"), len(record.NameServers.HostNames)), nil
+}
+
+func (p *Pipeline) subdomains(context.Context) (string, error) {
+	subs, err := lookup.Subdomains(p.Whois, p.Domain)
+	if err != nil {
+		return "", err
+	}
+	for _, s := range subs {
+		p.summary.Subdomains = append(p.summary.Subdomains, s.Subdomain)
+	}
+	return fmt.Sprintf("%d subdomains", len(subs)), nil
+}
+
+func (p *Pipeline) hunterDomain(context.Context) (string, error) {
+	data, err := lookup.HunterDomain(p.Hunter, p.Domain)
+	if err != nil {
+		return "", err
+	}
+	for _, e := range data.Emails {
+		p.addEmail(e.Value)
+	}
+
+	p.summary.EmailPattern = data.Pattern
+	if p.summary.Organization == "" {
+		p.summary.Organization = data.Organization
+	}
+	return fmt.Sprintf("%d emails, pattern %s", len(data.Emails), cmp.Or(data.Pattern, "unknown")), nil
+}
+
+func (p *Pipeline) company(context.Context) (string, error) {
+	data, err := p.Hunter.CompanyEnrichment(p.Domain)
+	if err != nil {
+		return "", err
+	}
+	if data.Name != "" {
+		p.summary.Organization = data.Name
+	}
+	p.summary.Industry = data.Category.Industry
+	p.summary.Employees = data.Metrics.Employees
+	return fmt.Sprintf("%s, %s", cmp.Or(data.Name, "unknown company"), cmp.Or(data.Category.Industry, "unknown industry")), nil
+}
+
+func (p *Pipeline) dehashedDomain(context.Context) (string, error) {
+	results, err := p.search(func(options *sqlite.QueryOptions) { options.DomainQuery = p.Domain })
+	p.addResults(results)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d records, %d emails known", len(results), len(p.emails)), nil
+}
+
+func (p *Pipeline) dehashedEmails(ctx context.Context) (string, error) {
+	emails := p.targetEmails()
+	if len(emails) == 0 {
+		return "no emails to search", nil
+	}
+
+	var records int
+	err := workers.Run(ctx, p.Workers, len(emails), func(_ context.Context, i int) error {
+		fmt.Printf("   [*] [%d/%d] %s\n", i+1, len(emails), emails[i])
+		results, err := p.search(func(options *sqlite.QueryOptions) { options.EmailQuery = emails[i] })
+		p.addResults(results)
+		p.mu.Lock()
+		records += len(results)
+		p.mu.Unlock()
+		if errors.Is(err, httpclient.ErrBudgetExceeded) {
+			return err
+		}
+		return nil
+	})
+	return fmt.Sprintf("%d records for %d emails", records, len(emails)), err
+}
+
+func (p *Pipeline) verify(ctx context.Context) (string, error) {
+	emails := p.targetEmails()
+	if len(emails) == 0 {
+		return "no emails to verify", nil
+	}
+
+	err := workers.Run(ctx, p.Workers, len(emails), func(_ context.Context, i int) error {
+		result, err := lookup.HunterVerify(p.Hunter, emails[i])
+		if err != nil {
+			fmt.Printf("   [!] Error verifying %s: %v\n", emails[i], err)
+			if errors.Is(err, httpclient.ErrBudgetExceeded) {
+				return err
+			}
+			return nil
+		}
+		fmt.Printf("   [*] [%d/%d] %s: %s\n", i+1, len(emails), emails[i], result.Result)
+		if result.Result != "deliverable" {
+			return nil
+		}
+		p.mu.Lock()
+		p.summary.Deliverable = append(p.summary.Deliverable, result.Email)
+		p.mu.Unlock()
+		return nil
+	})
+	sort.Strings(p.summary.Deliverable)
+	return fmt.Sprintf("%d of %d emails deliverable", len(p.summary.Deliverable), len(emails)), err
+}
+
+// search runs a Dehashed search of the domain or an email as its own resumable run
+func (p *Pipeline) search(query func(*sqlite.QueryOptions)) ([]sqlite.Result, error) {
+	options := &sqlite.QueryOptions{
+		MaxRecords:   p.MaxRecords,
+		MaxRequests:  p.MaxRequests,
+		StartingPage: 1,
+		OutputFormat: files.JSON,
+		OutputFile:   "recon",
+		FetchAll:     true,
+		Debug:        p.Debug,
+	}
+	query(options)
+	return lookup.Dehashed(options, p.DehashedKey, p.DehashedClient)
+}
+
+// addResults counts Dehashed records and their credentials and breaches, and keeps the emails of the domain
+func (p *Pipeline) addResults(results []sqlite.Result) {
+	found := sqlite.DehashedResults{Results: results}
+	creds := found.ExtractUsers()
+	p.mu.Lock()
+	p.summary.DehashedRecords += len(results)
+	p.summary.Credentials += len(creds)
+	for _, r := range results {
+		if r.DatabaseName != "" && !slices.Contains(p.summary.Breaches, r.DatabaseName) {
+			p.summary.Breaches = append(p.summary.Breaches, r.DatabaseName)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, r := range results {
+		for _, e := range r.Email {
+			p.addEmail(e)
+		}
+	}
+}
+
+// addEmail keeps an email address of the domain for the per email steps
+func (p *Pipeline) addEmail(email string) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if !strings.HasSuffix(email, "@"+strings.ToLower(p.Domain)) {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !slices.Contains(p.emails, email) {
+		p.emails = append(p.emails, email)
+	}
+}
+
+// targetEmails returns the emails found so far that are in scope, up to the maximum
+func (p *Pipeline) targetEmails() []string {
+	p.mu.Lock()
+	emails := slices.Clone(p.emails)
+	p.mu.Unlock()
+
+	if p.InScope != nil {
+		emails = slices.DeleteFunc(emails, func(email string) bool {
+			return !p.InScope([]scope.Target{{Kind: scope.TargetEmail, Value: email}})
+		})
+	}
+	if p.MaxEmails > 0 && len(emails) > p.MaxEmails {
+		fmt.Printf("   [-] %d emails found, using the first %d (--max-emails)\n", len(emails), p.MaxEmails)
+		emails = emails[:p.MaxEmails]
+	}
+	return emails
+}
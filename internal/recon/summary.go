@@ -0,0 +1,86 @@
+package recon
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// StepResult is the outcome of one step of the pipeline
+type StepResult struct {
+	Step   string `json:"step" yaml:"step" xml:"step"`
+	Status string `json:"status" yaml:"status" xml:"status"`
+	Detail string `json:"detail,omitempty" yaml:"detail,omitempty" xml:"detail,omitempty"`
+	Error  string `json:"error,omitempty" yaml:"error,omitempty" xml:"error,omitempty"`
+}
+
+// Summary is what a pipeline found for a domain
+type Summary struct {
+	XMLName         xml.Name     `json:"-" yaml:"-" xml:"recon_summary"`
+	Domain          string       `json:"domain" yaml:"domain" xml:"domain"`
+	CommandRun      uint         `json:"command_run" yaml:"command_run" xml:"command_run"`
+	Registrar       string       `json:"registrar,omitempty" yaml:"registrar,omitempty" xml:"registrar,omitempty"`
+	Registrant      string       `json:"registrant,omitempty" yaml:"registrant,omitempty" xml:"registrant,omitempty"`
+	CreatedDate     string       `json:"created_date,omitempty" yaml:"created_date,omitempty" xml:"created_date,omitempty"`
+	NameServers     []string     `json:"name_servers,omitempty" yaml:"name_servers,omitempty" xml:"name_servers>name_server,omitempty"`
+	Subdomains      []string     `json:"subdomains,omitempty" yaml:"subdomains,omitempty" xml:"subdomains>subdomain,omitempty"`
+	Organization    string       `json:"organization,omitempty" yaml:"organization,omitempty" xml:"organization,omitempty"`
+	Industry        string       `json:"industry,omitempty" yaml:"industry,omitempty" xml:"industry,omitempty"`
+	Employees       string       `json:"employees,omitempty" yaml:"employees,omitempty" xml:"employees,omitempty"`
+	EmailPattern    string       `json:"email_pattern,omitempty" yaml:"email_pattern,omitempty" xml:"email_pattern,omitempty"`
+	Emails          []string     `json:"emails,omitempty" yaml:"emails,omitempty" xml:"emails>email,omitempty"`
+	Deliverable     []string     `json:"deliverable,omitempty" yaml:"deliverable,omitempty" xml:"deliverable>email,omitempty"`
+	DehashedRecords int          `json:"dehashed_records" yaml:"dehashed_records" xml:"dehashed_records"`
+	Credentials     int          `json:"credentials" yaml:"credentials" xml:"credentials"`
+	Breaches        []string     `json:"breaches,omitempty" yaml:"breaches,omitempty" xml:"breaches>breach,omitempty"`
+	Steps           []StepResult `json:"steps" yaml:"steps" xml:"steps>step"`
+}
+
+func (s *Summary) String() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Domain: %s\nCommand Run: %d\n", s.Domain, s.CommandRun))
+	for _, field := range []struct{ name, value string }{
+		{"Registrar", s.Registrar},
+		{"Registrant", s.Registrant},
+		{"Created Date", s.CreatedDate},
+		{"Organization", s.Organization},
+		{"Industry", s.Industry},
+		{"Employees", s.Employees},
+		{"Email Pattern", s.EmailPattern},
+	} {
+		if field.value != "" {
+			sb.WriteString(fmt.Sprintf("%s: %s\n", field.name, field.value))
+		}
+	}
+	sb.WriteString(fmt.Sprintf("Dehashed Records: %d\nCredentials: %d\n", s.DehashedRecords, s.Credentials))
+	for _, list := range []struct {
+		name   string
+		values []string
+	}{
+		{"Name Servers", s.NameServers},
+		{"Subdomains", s.Subdomains},
+		{"Emails", s.Emails},
+		{"Deliverable", s.Deliverable},
+		{"Breaches", s.Breaches},
+	} {
+		if len(list.values) == 0 {
+			continue
+		}
+		sb.WriteString("\n" + list.name + ":\n")
+		for _, v := range list.values {
+			sb.WriteString("  " + v + "\n")
+		}
+	}
+	sb.WriteString("\nSteps:\n")
+	for _, step := range s.Steps {
+		sb.WriteString(fmt.Sprintf("  %s: %s", step.Step, step.Status))
+		if step.Detail != "" {
+			sb.WriteString(" (" + step.Detail + ")")
+		}
+		if step.Error != "" {
+			sb.WriteString(": " + step.Error)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,256 @@
+package scope
+
+import (
+	"crowsnest/internal/sqlite"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Scope entry kinds
+const (
+	KindDomain      = "domain"
+	KindEmailDomain = "email-domain"
+	KindCIDR        = "cidr"
+	KindCompany     = "company"
+)
+
+// Target kinds
+const (
+	TargetDomain  = "domain"
+	TargetEmail   = "email"
+	TargetIP      = "ip"
+	TargetCompany = "company"
+)
+
+// Kinds lists the scope entry kinds in the order they are shown
+var Kinds = []string{KindDomain, KindEmailDomain, KindCIDR, KindCompany}
+
+// Target is a value a lookup queries, checked against the scope before the request is sent
+type Target struct {
+	Kind  string // domain, email, ip or company
+	Value string
+}
+
+func (t Target) String() string {
+	return t.Kind + ":" + t.Value
+}
+
+// Scope is the set of targets an engagement allows. An empty scope allows everything.
+type Scope struct {
+	domains      []string
+	emailDomains []string
+	cidrs        []*net.IPNet
+	companies    []string
+}
+
+// Load builds the scope from the entries stored in the project database
+func Load() (*Scope, error) {
+	entries, err := sqlite.GetScopeEntries()
+	if err != nil {
+		return nil, err
+	}
+	return New(entries)
+}
+
+// New builds the scope from scope entries
+func New(entries []sqlite.ScopeEntry) (*Scope, error) {
+	s := &Scope{}
+	for _, e := range entries {
+		value, err := Normalize(e.Kind, e.Value)
+		if err != nil {
+			return nil, err
+		}
+		switch e.Kind {
+		case KindDomain:
+			s.domains = append(s.domains, value)
+		case KindEmailDomain:
+			s.emailDomains = append(s.emailDomains, value)
+		case KindCIDR:
+			_, cidr, _ := net.ParseCIDR(value)
+			s.cidrs = append(s.cidrs, cidr)
+		case KindCompany:
+			s.companies = append(s.companies, value)
+		}
+	}
+	return s, nil
+}
+
+// Normalize validates a scope entry and returns the value as stored. A single IP is stored as a /32 or /128.
+func Normalize(kind, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", fmt.Errorf("empty %s", kind)
+	}
+	switch kind {
+	case KindDomain, KindEmailDomain:
+		value = normalizeDomain(strings.TrimPrefix(value, "@"))
+		if strings.ContainsAny(value, " /@*?") || !strings.Contains(value, ".") {
+			return "", fmt.Errorf("invalid %s %q", kind, value)
+		}
+		return value, nil
+	case KindCIDR:
+		if ip := net.ParseIP(value); ip != nil {
+			if ip.To4() != nil {
+				return value + "/32", nil
+			}
+			return value + "/128", nil
+		}
+		_, cidr, err := net.ParseCIDR(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid CIDR %q", value)
+		}
+		return cidr.String(), nil
+	case KindCompany:
+		return strings.ToLower(strings.Join(strings.Fields(value), " ")), nil
+	default:
+		return "", fmt.Errorf("unknown scope kind %q (%s)", kind, strings.Join(Kinds, ", "))
+	}
+}
+
+// Empty reports whether no scope is defined, in which case every target is allowed
+func (s *Scope) Empty() bool {
+	return len(s.domains) == 0 && len(s.emailDomains) == 0 && len(s.cidrs) == 0 && len(s.companies) == 0
+}
+
+// Domain reports whether the domain or a parent domain is in scope. Domains are unrestricted while the scope
+// has no domain entries, and wildcard or regex patterns cannot be checked and are allowed (see Unchecked).
+func (s *Scope) Domain(domain string) bool {
+	if len(s.domains) == 0 {
+		return true
+	}
+	domain = normalizeDomain(domain)
+	if !checkable(domain) {
+		return true
+	}
+	return matchDomain(domain, s.domains)
+}
+
+// Email reports whether the domain of the email is an in scope email domain or domain. Emails are unrestricted
+// while the scope has neither, and partial values with no domain, such as a bare username, are allowed.
+func (s *Scope) Email(email string) bool {
+	if !s.restrictsEmails() {
+		return true
+	}
+	domain, ok := emailDomain(email)
+	if !ok {
+		return true
+	}
+	return matchDomain(domain, s.emailDomains) || matchDomain(domain, s.domains)
+}
+
+// IP reports whether the address is inside an in scope CIDR. Addresses are unrestricted while the scope has no
+// CIDR entries, and partial addresses cannot be checked and are allowed.
+func (s *Scope) IP(ip string) bool {
+	if len(s.cidrs) == 0 {
+		return true
+	}
+	addr := net.ParseIP(strings.TrimSpace(ip))
+	if addr == nil {
+		return true
+	}
+	for _, cidr := range s.cidrs {
+		if cidr.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Company reports whether the company name is in scope, ignoring case and spacing.
+// Companies are unrestricted while the scope has no company entries.
+func (s *Scope) Company(company string) bool {
+	if len(s.companies) == 0 {
+		return true
+	}
+	company = strings.ToLower(strings.Join(strings.Fields(company), " "))
+	for _, c := range s.companies {
+		if company == c {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns the targets that fall outside the scope
+func (s *Scope) Check(targets []Target) []Target {
+	var out []Target
+	for _, t := range targets {
+		var ok bool
+		switch t.Kind {
+		case TargetDomain:
+			ok = s.Domain(t.Value)
+		case TargetEmail:
+			ok = s.Email(t.Value)
+		case TargetIP:
+			ok = s.IP(t.Value)
+		case TargetCompany:
+			ok = s.Company(t.Value)
+		default:
+			ok = true
+		}
+		if !ok {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// Unchecked returns the targets of a restricted kind that cannot be matched against the scope: wildcard and
+// regex patterns, emails without a domain and partial addresses. Check allows them, so lookups refuse them
+// separately.
+func (s *Scope) Unchecked(targets []Target) []Target {
+	var out []Target
+	for _, t := range targets {
+		var ok bool
+		switch t.Kind {
+		case TargetDomain:
+			ok = len(s.domains) == 0 || checkable(normalizeDomain(t.Value))
+		case TargetEmail:
+			_, parsed := emailDomain(t.Value)
+			ok = !s.restrictsEmails() || parsed
+		case TargetIP:
+			ok = len(s.cidrs) == 0 || net.ParseIP(strings.TrimSpace(t.Value)) != nil
+		default:
+			ok = true
+		}
+		if !ok {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func (s *Scope) restrictsEmails() bool {
+	return len(s.emailDomains) > 0 || len(s.domains) > 0
+}
+
+// emailDomain returns the normalized domain of the email, false when it has none that can be checked
+func emailDomain(email string) (string, bool) {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return "", false
+	}
+	domain := normalizeDomain(email[at+1:])
+	return domain, checkable(domain)
+}
+
+func normalizeDomain(domain string) string {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	domain = strings.TrimPrefix(domain, "*.")
+	return strings.Trim(domain, ".")
+}
+
+// checkable reports whether a value can be matched, wildcard and regex patterns cannot
+func checkable(domain string) bool {
+	return domain != "" && !strings.ContainsAny(domain, "*?^$[](){}|+\\")
+}
+
+func matchDomain(domain string, allowed []string) bool {
+	for _, a := range allowed {
+		if domain == a || strings.HasSuffix(domain, "."+a) {
+			return true
+		}
+	}
+	return false
+}
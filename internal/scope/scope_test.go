@@ -0,0 +1,169 @@
+package scope
+
+import (
+	"crowsnest/internal/sqlite"
+	"slices"
+	"testing"
+)
+
+func newScope(t *testing.T, entries ...string) *Scope {
+	t.Helper()
+	var stored []sqlite.ScopeEntry
+	for i := 0; i+1 < len(entries); i += 2 {
+		stored = append(stored, sqlite.ScopeEntry{Kind: entries[i], Value: entries[i+1]})
+	}
+	s, err := New(stored)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return s
+}
+
+func TestDomain(t *testing.T) {
+	s := newScope(t, KindDomain, "Acme.com", KindEmailDomain, "acme-mail.com")
+	tests := []struct {
+		domain string
+		want   bool
+	}{
+		{"acme.com", true},
+		{"ACME.COM.", true},
+		{"vpn.acme.com", true},
+		{"*.acme.com", true},
+		{"notacme.com", false},
+		{"acme.com.evil.com", false},
+		{"acme-mail.com", false}, // Email domains only scope emails
+		{"*.evil.com", false},
+		{"*evil*", true}, // Patterns cannot be checked, Unchecked reports them
+	}
+	for _, tt := range tests {
+		if got := s.Domain(tt.domain); got != tt.want {
+			t.Errorf("Domain(%q) = %v, want %v", tt.domain, got, tt.want)
+		}
+	}
+
+	if unrestricted := newScope(t, KindCIDR, "10.0.0.0/8"); !unrestricted.Domain("evil.com") {
+		t.Error("Domain() without domain entries = false, want true")
+	}
+}
+
+func TestEmail(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []string
+		email   string
+		want    bool
+	}{
+		{"email domain", []string{KindEmailDomain, "@acme-mail.com"}, "jane@acme-mail.com", true},
+		{"domain scopes emails", []string{KindDomain, "acme.com"}, "jane@eu.acme.com", true},
+		{"out of scope", []string{KindDomain, "acme.com"}, "jane@evil.com", false},
+		{"case", []string{KindDomain, "acme.com"}, "Jane@ACME.com", true},
+		{"username only", []string{KindDomain, "acme.com"}, "jane", true},
+		{"wildcard domain", []string{KindDomain, "acme.com"}, "jane@*", true},
+		{"unrestricted", []string{KindCompany, "Acme Inc"}, "jane@evil.com", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newScope(t, tt.entries...).Email(tt.email); got != tt.want {
+				t.Errorf("Email(%q) = %v, want %v", tt.email, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIP(t *testing.T) {
+	s := newScope(t, KindCIDR, "10.1.0.0/16", KindCIDR, "192.0.2.7", KindCIDR, "2001:db8::/32")
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"10.1.2.3", true},
+		{" 10.1.255.255 ", true},
+		{"10.2.0.1", false},
+		{"192.0.2.7", true},
+		{"192.0.2.8", false},
+		{"2001:db8::1", true},
+		{"2001:db9::1", false},
+		{"10.1.*", true}, // Partial addresses cannot be checked, Unchecked reports them
+	}
+	for _, tt := range tests {
+		if got := s.IP(tt.ip); got != tt.want {
+			t.Errorf("IP(%q) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+
+	if unrestricted := newScope(t, KindDomain, "acme.com"); !unrestricted.IP("8.8.8.8") {
+		t.Error("IP() without CIDR entries = false, want true")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	s := newScope(t, KindDomain, "acme.com", KindCompany, "Acme  Inc")
+	targets := []Target{
+		{Kind: TargetDomain, Value: "acme.com"},
+		{Kind: TargetDomain, Value: "evil.com"},
+		{Kind: TargetEmail, Value: "jane@evil.com"},
+		{Kind: TargetIP, Value: "8.8.8.8"},
+		{Kind: TargetCompany, Value: "acme inc"},
+		{Kind: TargetCompany, Value: "Evil Corp"},
+	}
+	want := []Target{targets[1], targets[2], targets[5]}
+	if got := s.Check(targets); !slices.Equal(got, want) {
+		t.Errorf("Check() = %v, want %v", got, want)
+	}
+}
+
+func TestUnchecked(t *testing.T) {
+	targets := []Target{
+		{Kind: TargetDomain, Value: "acme.com"},
+		{Kind: TargetDomain, Value: "acme.*"},
+		{Kind: TargetDomain, Value: "^acme"},
+		{Kind: TargetEmail, Value: "jane@acme.com"},
+		{Kind: TargetEmail, Value: "jane"},
+		{Kind: TargetIP, Value: "10.1.2.3"},
+		{Kind: TargetIP, Value: "10.1.*"},
+		{Kind: TargetCompany, Value: "Acme*"},
+	}
+	tests := []struct {
+		name    string
+		entries []string
+		want    []Target
+	}{
+		{"empty scope", nil, nil},
+		{"domains", []string{KindDomain, "acme.com"}, []Target{targets[1], targets[2], targets[4]}},
+		{"email domains", []string{KindEmailDomain, "acme.com"}, []Target{targets[4]}},
+		{"cidrs", []string{KindCIDR, "10.0.0.0/8"}, []Target{targets[6]}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newScope(t, tt.entries...).Unchecked(targets); !slices.Equal(got, tt.want) {
+				t.Errorf("Unchecked() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		kind, value string
+		want        string
+		err         bool
+	}{
+		{KindDomain, " *.Acme.com. ", "acme.com", false},
+		{KindEmailDomain, "@acme.com", "acme.com", false},
+		{KindDomain, "acme", "", true},
+		{KindDomain, "acme.com/login", "", true},
+		{KindCIDR, "10.0.0.1", "10.0.0.1/32", false},
+		{KindCIDR, "2001:db8::1", "2001:db8::1/128", false},
+		{KindCIDR, "10.1.2.3/16", "10.1.0.0/16", false},
+		{KindCIDR, "10.0.0.0/33", "", true},
+		{KindCompany, " Acme   Inc ", "acme inc", false},
+		{"asn", "AS1234", "", true},
+		{KindDomain, " ", "", true},
+	}
+	for _, tt := range tests {
+		got, err := Normalize(tt.kind, tt.value)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("Normalize(%q, %q) = %q, %v, want %q, error %v", tt.kind, tt.value, got, err, tt.want, tt.err)
+		}
+	}
+}
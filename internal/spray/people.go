@@ -0,0 +1,190 @@
+package spray
+
+import (
+	"crowsnest/internal/files"
+	"crowsnest/internal/sqlite"
+	"strings"
+	"unicode"
+)
+
+// Person is a name found for an employee of the target
+type Person struct {
+	First string
+	Last  string
+}
+
+// LoadPeople returns the names stored for a domain in the hunter_email, person and dehashed tables, lowercased and de-duplicated
+func LoadPeople(domain string) ([]Person, error) {
+	domain = strings.ToLower(domain)
+	var (
+		people []Person
+		seen   = make(map[Person]bool)
+	)
+	add := func(first, last string) {
+		p := Person{First: normalizeName(first), Last: normalizeName(last)}
+		if p.First == "" || p.Last == "" || seen[p] {
+			return
+		}
+		seen[p] = true
+		people = append(people, p)
+	}
+
+	db := sqlite.GetDB()
+	var emails []sqlite.HunterEmail
+	if err := db.Where("domain = ? OR value LIKE ?", domain, "%@"+domain).Find(&emails).Error; err != nil {
+		return nil, err
+	}
+	for _, e := range emails {
+		add(e.FirstName, e.LastName)
+	}
+
+	var persons []sqlite.PersonData
+	if err := db.Where("email LIKE ?", "%@"+domain).Find(&persons).Error; err != nil {
+		return nil, err
+	}
+	for _, p := range persons {
+		if p.Name.GivenName != "" && p.Name.FamilyName != "" {
+			add(p.Name.GivenName, p.Name.FamilyName)
+			continue
+		}
+		add(SplitName(p.Name.FullName))
+	}
+
+	var results []sqlite.Result
+	if err := db.Where("email LIKE ? AND name IS NOT NULL AND name NOT IN ('', '[]', 'null')", "%@"+domain+"%").Find(&results).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range results {
+		for _, name := range r.Name {
+			add(SplitName(name))
+		}
+	}
+
+	return people, nil
+}
+
+// LoadEmails returns the email addresses already stored for a domain in the creds, dehashed and hunter_email tables
+func LoadEmails(domain string) ([]string, error) {
+	domain = strings.ToLower(domain)
+	db := sqlite.GetDB()
+
+	var creds, hunter []string
+	if err := db.Model(&sqlite.User{}).Where("email LIKE ?", "%@"+domain).Distinct().Pluck("email", &creds).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Model(&sqlite.HunterEmail{}).Where("value LIKE ?", "%@"+domain).Distinct().Pluck("value", &hunter).Error; err != nil {
+		return nil, err
+	}
+	var results []sqlite.Result
+	if err := db.Select("email").Where("email LIKE ?", "%@"+domain+"%").Find(&results).Error; err != nil {
+		return nil, err
+	}
+
+	var emails []string
+	for _, e := range append(creds, hunter...) {
+		emails = append(emails, strings.ToLower(strings.TrimSpace(e)))
+	}
+	for _, r := range results {
+		for _, e := range r.Email {
+			if e = strings.ToLower(strings.TrimSpace(e)); strings.HasSuffix(e, "@"+domain) {
+				emails = append(emails, e)
+			}
+		}
+	}
+	return dedupe(emails), nil
+}
+
+// LoadNamedEmails returns the stored email addresses of a domain whose owner's name is known, from the hunter_email
+// and person tables and from dehashed records holding a single email and a single name
+func LoadNamedEmails(domain string) (map[string]Person, error) {
+	domain = strings.ToLower(domain)
+	named := make(map[string]Person)
+	add := func(email, first, last string) {
+		email = strings.ToLower(strings.TrimSpace(email))
+		p := Person{First: normalizeName(first), Last: normalizeName(last)}
+		if p.First == "" || p.Last == "" || !strings.HasSuffix(email, "@"+domain) {
+			return
+		}
+		named[email] = p
+	}
+
+	db := sqlite.GetDB()
+	var results []sqlite.Result
+	if err := db.Where("email LIKE ? AND name IS NOT NULL AND name NOT IN ('', '[]', 'null')", "%@"+domain+"%").Find(&results).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range results {
+		if len(r.Email) == 1 && len(r.Name) == 1 {
+			first, last := SplitName(r.Name[0])
+			add(r.Email[0], first, last)
+		}
+	}
+
+	var persons []sqlite.PersonData
+	if err := db.Where("email LIKE ?", "%@"+domain).Find(&persons).Error; err != nil {
+		return nil, err
+	}
+	for _, p := range persons {
+		first, last := p.Name.GivenName, p.Name.FamilyName
+		if first == "" || last == "" {
+			first, last = SplitName(p.Name.FullName)
+		}
+		add(p.Email, first, last)
+	}
+
+	// Hunter.io names are the most reliable and override the others
+	var emails []sqlite.HunterEmail
+	if err := db.Where("value LIKE ?", "%@"+domain).Find(&emails).Error; err != nil {
+		return nil, err
+	}
+	for _, e := range emails {
+		add(e.Value, e.FirstName, e.LastName)
+	}
+
+	return named, nil
+}
+
+// ReadRoster reads a roster file of employee names, one "First Last" or "Last, First" per line
+func ReadRoster(path string) ([]Person, error) {
+	lines, err := files.ReadLines(path)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		people []Person
+		seen   = make(map[Person]bool)
+	)
+	for _, line := range lines {
+		first, last := SplitName(line)
+		p := Person{First: normalizeName(first), Last: normalizeName(last)}
+		if p.First == "" || p.Last == "" || seen[p] {
+			continue
+		}
+		seen[p] = true
+		people = append(people, p)
+	}
+	return people, nil
+}
+
+// SplitName splits a full name into first and last name, dropping middle names
+func SplitName(name string) (string, string) {
+	if comma := strings.Index(name, ","); comma >= 0 {
+		// "Last, First"
+		name = name[comma+1:] + " " + name[:comma]
+	}
+	fields := strings.Fields(name)
+	if len(fields) < 2 {
+		return "", ""
+	}
+	return fields[0], fields[len(fields)-1]
+}
+
+// normalizeName lowercases a name and keeps only its letters
+func normalizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+}
@@ -0,0 +1,168 @@
+package spray
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var (
+	placeholder = regexp.MustCompile(`\{(first|last|f|l)\}`)
+
+	seasons = []string{"Spring", "Summer", "Autumn", "Fall", "Winter"}
+
+	// defaultWords are the base words of default and help desk passwords
+	defaultWords = []string{"Welcome", "Password", "Changeme"}
+
+	// legalSuffixes are the company forms dropped from the end of a company name, compared without dots
+	legalSuffixes = map[string]bool{
+		"inc": true, "incorporated": true, "llc": true, "llp": true, "lp": true, "ltd": true, "limited": true,
+		"corp": true, "corporation": true, "co": true, "company": true, "plc": true, "gmbh": true, "ag": true,
+		"sa": true, "sas": true, "sarl": true, "srl": true, "spa": true, "bv": true, "nv": true, "pty": true,
+		"pte": true, "oy": true, "ab": true, "as": true, "kg": true,
+	}
+)
+
+// Options controls the candidate passwords generated for a target
+type Options struct {
+	Company   string   // Company name, used with digit and year suffixes
+	Years     []int    // Years appended to seasons, words and first names
+	BaseWords []string // Base words observed in breached passwords
+	Suffixes  []string // Suffixes observed in breached passwords
+	Observed  []string // Breached passwords worth spraying as they are
+	MinLength int      // Shortest candidate kept, e.g. the password policy minimum
+}
+
+// ApplyPattern builds the local part of an email address from a Hunter pattern such as {first}.{last} or {f}{last}.
+// It reports false when the pattern is unknown or needs a name the person does not have.
+func ApplyPattern(pattern string, p Person) (string, bool) {
+	if !placeholder.MatchString(pattern) {
+		return "", false
+	}
+	ok := true
+	local := placeholder.ReplaceAllStringFunc(pattern, func(m string) string {
+		var value string
+		switch m {
+		case "{first}":
+			value = p.First
+		case "{last}":
+			value = p.Last
+		case "{f}":
+			value = initial(p.First)
+		case "{l}":
+			value = initial(p.Last)
+		}
+		if value == "" {
+			ok = false
+		}
+		return value
+	})
+	if !ok || strings.ContainsAny(local, "{}@ ") {
+		return "", false
+	}
+	return strings.ToLower(local), true
+}
+
+// Passwords returns the candidate passwords for every account of the target: observed passwords, season and year,
+// company and digits, default words and the observed base words with the observed suffixes
+func Passwords(opts Options) []string {
+	var candidates []string
+	candidates = append(candidates, opts.Observed...)
+
+	for _, year := range opts.Years {
+		y := strconv.Itoa(year)
+		for _, season := range seasons {
+			candidates = append(candidates, season+y, season+y+"!", season+"@"+y)
+		}
+	}
+
+	if company := CompanyWord(opts.Company); company != "" {
+		candidates = append(candidates, withSuffixes(company, opts.Years)...)
+	}
+	for _, word := range defaultWords {
+		candidates = append(candidates, withSuffixes(word, opts.Years)...)
+	}
+
+	for _, base := range opts.BaseWords {
+		for _, suffix := range opts.Suffixes {
+			candidates = append(candidates, Capitalize(base)+suffix)
+		}
+	}
+
+	return filter(candidates, opts.MinLength)
+}
+
+// UserPasswords returns the candidate passwords specific to one person, their first name with years and digits
+func UserPasswords(p Person, opts Options) []string {
+	if p.First == "" {
+		return nil
+	}
+	return filter(withSuffixes(Capitalize(p.First), opts.Years), opts.MinLength)
+}
+
+// CompanyWord returns the company name as a password base word: legal forms such as Inc or GmbH are dropped from
+// the end and the remaining words are joined without spaces or punctuation, e.g. "Acme Widgets, Inc." gives AcmeWidgets
+func CompanyWord(company string) string {
+	words := strings.FieldsFunc(strings.ReplaceAll(company, ".", ""), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for len(words) > 1 && legalSuffixes[strings.ToLower(words[len(words)-1])] {
+		words = words[:len(words)-1]
+	}
+	return Capitalize(strings.Join(words, ""))
+}
+
+// Capitalize upper cases the first letter of a word
+func Capitalize(word string) string {
+	runes := []rune(word)
+	if len(runes) == 0 {
+		return ""
+	}
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
+
+// withSuffixes returns the word followed by common digit runs and the years
+func withSuffixes(word string, years []int) []string {
+	out := []string{word + "1", word + "1!", word + "123", word + "123!"}
+	for _, year := range years {
+		y := strconv.Itoa(year)
+		out = append(out, word+y, word+y+"!", word+"@"+y)
+	}
+	return out
+}
+
+// filter removes duplicates and candidates shorter than the minimum length, keeping the first occurrence
+func filter(candidates []string, minLength int) []string {
+	var out []string
+	for _, c := range dedupe(candidates) {
+		if len([]rune(c)) >= minLength {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// dedupe removes empty and repeated values, keeping the first occurrence
+func dedupe(values []string) []string {
+	var (
+		out  []string
+		seen = make(map[string]bool)
+	)
+	for _, v := range values {
+		if v == "" || seen[v] {
+			continue
+		}
+		seen[v] = true
+		out = append(out, v)
+	}
+	return out
+}
+
+func initial(name string) string {
+	for _, r := range name {
+		return string(r)
+	}
+	return ""
+}
@@ -0,0 +1,52 @@
+package spray
+
+import "testing"
+
+func TestCompanyWord(t *testing.T) {
+	tests := []struct {
+		company string
+		want    string
+	}{
+		{"Acme", "Acme"},
+		{"Acme Widgets, Inc.", "AcmeWidgets"},
+		{"acme corp", "Acme"},
+		{"Müller GmbH & Co. KG", "Müller"},
+		{"Example Pty Ltd", "Example"},
+		{"A.C.M.E. Ltd", "ACME"},
+		{"Johnson & Johnson", "JohnsonJohnson"},
+		{"3M Company", "3M"},
+		{"Company", "Company"}, // A suffix alone is the name
+		{"Inc", "Inc"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := CompanyWord(tt.company); got != tt.want {
+			t.Errorf("CompanyWord(%q) = %q, want %q", tt.company, got, tt.want)
+		}
+	}
+}
+
+func TestApplyPattern(t *testing.T) {
+	jane := Person{First: "Jane", Last: "Doe"}
+	tests := []struct {
+		pattern string
+		person  Person
+		want    string
+		ok      bool
+	}{
+		{"{first}.{last}", jane, "jane.doe", true},
+		{"{f}{last}", jane, "jdoe", true},
+		{"{first}{l}", jane, "janed", true},
+		{"{last}_{f}", jane, "doe_j", true},
+		{"{first}.{last}", Person{First: "jane"}, "", false},
+		{"{first} {last}", jane, "", false},
+		{"{middle}.{last}", jane, "", false},
+		{"admin", jane, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := ApplyPattern(tt.pattern, tt.person)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ApplyPattern(%q, %v) = %q, %v, want %q, %v", tt.pattern, tt.person, got, ok, tt.want, tt.ok)
+		}
+	}
+}
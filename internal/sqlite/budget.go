@@ -0,0 +1,74 @@
+package sqlite
+
+import (
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Budget is the persistent credit allowance for a single provider
+type Budget struct {
+	gorm.Model
+	Provider     string `json:"provider" gorm:"uniqueIndex"`
+	CreditLimit  int    `json:"credit_limit"`
+	CreditsSpent int    `json:"credits_spent"`
+}
+
+func (Budget) TableName() string {
+	return "budgets"
+}
+
+// Remaining returns the credits left in the budget, or -1 when no limit is set
+func (b Budget) Remaining() int {
+	if b.CreditLimit <= 0 {
+		return -1
+	}
+	return b.CreditLimit - b.CreditsSpent
+}
+
+// GetBudget returns the budget for the provider, or an empty budget if none has been stored
+func GetBudget(provider string) (Budget, error) {
+	db := GetDB()
+	budget := Budget{Provider: provider}
+	err := db.Where("provider = ?", provider).First(&budget).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return budget, nil
+	}
+	return budget, err
+}
+
+// GetBudgets returns every stored budget
+func GetBudgets() ([]Budget, error) {
+	db := GetDB()
+	var budgets []Budget
+	err := db.Order("provider").Find(&budgets).Error
+	return budgets, err
+}
+
+// SetBudgetLimit sets the credit limit for the provider, keeping the credits already spent
+func SetBudgetLimit(provider string, limit int) error {
+	db := GetDB()
+	budget := Budget{Provider: provider, CreditLimit: limit}
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "provider"}},
+		DoUpdates: clause.AssignmentColumns([]string{"credit_limit", "updated_at"}),
+	}).Create(&budget).Error
+}
+
+// AddBudgetSpend records credits spent against the provider budget
+func AddBudgetSpend(provider string, credits int) error {
+	db := GetDB()
+	budget := Budget{Provider: provider, CreditsSpent: credits}
+	return db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "provider"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"credits_spent": gorm.Expr("credits_spent + ?", credits),
+		}),
+	}).Create(&budget).Error
+}
+
+// ResetBudget clears the credits spent against the provider budget
+func ResetBudget(provider string) error {
+	db := GetDB()
+	return db.Model(&Budget{}).Where("provider = ?", provider).Update("credits_spent", 0).Error
+}
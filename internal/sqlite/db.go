@@ -3,6 +3,7 @@ package sqlite
 import (
 	"fmt"
 	"go.uber.org/zap"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -42,26 +43,102 @@ func InitDB(dbPath string) (*gorm.DB, error) {
 	}
 
 	zap.L().Info("Opening database", zap.String("finalPath", finalDbPath))
-	db, err := gorm.Open(sql.Open(finalDbPath), &gorm.Config{
+	_, statErr := os.Stat(finalDbPath)
+	existing := statErr == nil
+	dsn, err := databaseDSN(finalDbPath)
+	if err != nil {
+		zap.L().Error("Failed to connect to database", zap.Error(err))
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	db, err := gorm.Open(sql.Open(dsn), &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Silent),
 	})
 	if err != nil {
 		zap.L().Error("Failed to connect to database", zap.Error(err))
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
+	if err = enableWAL(db, finalDbPath, existing); err != nil {
+		zap.L().Error("Failed to enable write-ahead logging", zap.Error(err))
+		return nil, fmt.Errorf("failed to enable write-ahead logging: %w", err)
+	}
+
+	hadCracks := db.Migrator().HasTable(&Crack{})
 
 	// Auto migrate your models
 	err = db.AutoMigrate(&Result{}, &User{}, &QueryOptions{}, &User{}, &WhoisRecord{}, &HistoryRecord{},
-		&LookupResult{}, &HunterDomainData{}, &HunterEmail{}, &PersonData{}, &Subdomain{})
+		&LookupResult{}, &HunterDomainData{}, &HunterEmail{}, &PersonData{}, &Subdomain{}, &Budget{}, &RawResponse{},
+		&CommandRun{}, &RecordSource{}, &ScopeEntry{}, &Identity{}, &IdentityRecord{}, &Watch{}, &Crack{})
 	if err != nil {
 		zap.L().Error("Failed to migrate database", zap.Error(err))
 		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
 
+	// Credentials were unique on email, username and password before hashes were stored, which
+	// collapses hash only credentials of the same account into one row
+	if db.Migrator().HasIndex(&User{}, "idx_email_username_password") {
+		if err = db.Migrator().DropIndex(&User{}, "idx_email_username_password"); err != nil {
+			zap.L().Error("Failed to migrate database", zap.Error(err))
+			return nil, fmt.Errorf("failed to migrate database: %w", err)
+		}
+		err = db.Model(&User{}).Unscoped().Where("hashed_password IS NULL").Update("hashed_password", "").Error
+		if err != nil {
+			zap.L().Error("Failed to migrate database", zap.Error(err))
+			return nil, fmt.Errorf("failed to migrate database: %w", err)
+		}
+	}
+
+	// Cracks were stored on the credentials only before they had their own table
+	if !hadCracks {
+		if err = backfillCracks(db); err != nil {
+			zap.L().Error("Failed to migrate database", zap.Error(err))
+			return nil, fmt.Errorf("failed to migrate database: %w", err)
+		}
+	}
+
 	DB = db
 	return db, nil
 }
 
+// databaseDSN builds a file URI for the database so paths holding '?' or '#' are escaped. Concurrent lookups
+// store results from several goroutines, so connections wait on a locked database instead of failing.
+func databaseDSN(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	abs = filepath.ToSlash(abs)
+	if !strings.HasPrefix(abs, "/") {
+		abs = "/" + abs // Windows drive letters
+	}
+	query := url.Values{}
+	query.Add("_pragma", "busy_timeout(10000)")
+	query.Add("_txlock", "immediate")
+	return (&url.URL{Scheme: "file", Path: abs, RawQuery: query.Encode()}).String(), nil
+}
+
+// enableWAL switches the database to write-ahead logging so lookups can read while a worker writes. The journal
+// mode is stored in the database file, so switching an existing database is reported once.
+func enableWAL(db *gorm.DB, path string, existing bool) error {
+	var mode string
+	if err := db.Raw("PRAGMA journal_mode").Scan(&mode).Error; err != nil {
+		return err
+	}
+	if strings.EqualFold(mode, "wal") {
+		return nil
+	}
+	if err := db.Exec("PRAGMA journal_mode=WAL").Error; err != nil {
+		return err
+	}
+	zap.L().Info("Switched database to write-ahead logging",
+		zap.String("path", path),
+		zap.String("previous_mode", mode),
+	)
+	if existing {
+		fmt.Printf("[*] Switched database %s from %s to WAL journal mode for concurrent lookups\n", path, mode)
+	}
+	return nil
+}
+
 // GetDB returns the database connection
 func GetDB() *gorm.DB {
 	if DB == nil {
@@ -85,6 +162,15 @@ const (
 	HunterDomainTable
 	HunterEmailTable
 	PersonTable
+	BudgetsTable
+	RawResponsesTable
+	CommandRunsTable
+	RecordSourcesTable
+	ScopeTable
+	IdentitiesTable
+	IdentityRecordsTable
+	WatchesTable
+	CracksTable
 	UnknownTable
 )
 
@@ -110,6 +196,24 @@ func GetTable(userInput string) Table {
 		return HunterEmailTable
 	case "person":
 		return PersonTable
+	case "budgets":
+		return BudgetsTable
+	case "raw_responses":
+		return RawResponsesTable
+	case "command_runs":
+		return CommandRunsTable
+	case "record_sources":
+		return RecordSourcesTable
+	case "scope":
+		return ScopeTable
+	case "identities":
+		return IdentitiesTable
+	case "identity_records":
+		return IdentityRecordsTable
+	case "watches":
+		return WatchesTable
+	case "cracks":
+		return CracksTable
 	default:
 		return UnknownTable
 	}
@@ -137,6 +241,24 @@ func (t Table) Object() interface{} {
 		return HunterEmail{}
 	case PersonTable:
 		return PersonData{}
+	case BudgetsTable:
+		return Budget{}
+	case RawResponsesTable:
+		return RawResponse{}
+	case CommandRunsTable:
+		return CommandRun{}
+	case RecordSourcesTable:
+		return RecordSource{}
+	case ScopeTable:
+		return ScopeEntry{}
+	case IdentitiesTable:
+		return Identity{}
+	case IdentityRecordsTable:
+		return IdentityRecord{}
+	case WatchesTable:
+		return Watch{}
+	case CracksTable:
+		return Crack{}
 	default:
 		return nil
 	}
@@ -30,15 +30,60 @@ type QueryOptions struct {
 	PhoneQuery         string         `json:"phone_query"`
 	SocialQuery        string         `json:"social_query"`
 	CryptoAddressQuery string         `json:"crypto_address_query"`
+	Expression         string         `json:"expression"`
 	PrintBalance       bool           `json:"print_balance"`
 	CredsOnly          bool           `json:"creds_only"`
 	Debug              bool           `json:"debug"`
+	FetchAll           bool           `json:"fetch_all"`
+	LastPage           int            `json:"last_page"`
+	TotalResults       int            `json:"total_results"`
+	Completed          bool           `json:"completed"`
+	Project            string         `json:"project"`
 }
 
 func (QueryOptions) TableName() string {
 	return "query_options"
 }
 
+// QueryFields are the fields a search can query, as named by SetQuery
+var QueryFields = []string{"username", "email", "ip", "password", "hash", "name", "domain", "vin", "license_plate",
+	"address", "phone", "social", "crypto_address"}
+
+// SetQuery sets the query of a field named in QueryFields
+func (o *QueryOptions) SetQuery(field, value string) error {
+	switch field {
+	case "username":
+		o.UsernameQuery = value
+	case "email":
+		o.EmailQuery = value
+	case "ip":
+		o.IpQuery = value
+	case "password":
+		o.PassQuery = value
+	case "hash":
+		o.HashQuery = value
+	case "name":
+		o.NameQuery = value
+	case "domain":
+		o.DomainQuery = value
+	case "vin":
+		o.VinQuery = value
+	case "license_plate":
+		o.LicensePlateQuery = value
+	case "address":
+		o.AddressQuery = value
+	case "phone":
+		o.PhoneQuery = value
+	case "social":
+		o.SocialQuery = value
+	case "crypto_address":
+		o.CryptoAddressQuery = value
+	default:
+		return fmt.Errorf("unknown query field %q", field)
+	}
+	return nil
+}
+
 func NewQueryOptions(maxRecords, maxRequests, startingPage int, outputFormat, outputFile, usernameQuery, emailQuery, ipQuery, passQuery, hashQuery, nameQuery, domainQuery, vinQuery, licensePlateQuery, addressQuery, phoneQuery, socialQuery, cryptoAddressQuery string, regexMatch, wildcardMatch, printBalance, credsOnly, debug bool) *QueryOptions {
 	return &QueryOptions{
 		MaxRecords:         maxRecords,
@@ -103,6 +148,7 @@ type Result struct {
 	Phone                 []string `json:"phone,omitempty" xml:"phone,omitempty" yaml:"phone,omitempty" gorm:"serializer:json"`
 	Company               []string `json:"company,omitempty" xml:"company,omitempty" yaml:"company,omitempty" gorm:"serializer:json"`
 	DatabaseName          string   `json:"database_name,omitempty" xml:"database_name,omitempty" yaml:"database_name,omitempty"`
+	OutOfScope            bool     `json:"out_of_scope" yaml:"out_of_scope" xml:"out_of_scope" gorm:"index"`
 }
 
 func (Result) TableName() string {
@@ -113,45 +159,86 @@ type DehashedResults struct {
 	Results []Result `json:"results"`
 }
 
+// ExtractUsers returns a credential for every email, username and password combination on the results.
+// Records with only hashed passwords are kept with the hash in place of the plaintext. The hash type is only the one
+// the source supplied, unknown types are identified when the hashes are exported.
 func (dr *DehashedResults) ExtractUsers() []User {
 	var creds []User
+	seen := make(map[[4]string]bool)
 
-	results := dr.Results
+	for _, r := range dr.Results {
+		secrets := r.secrets()
+		if len(secrets) == 0 {
+			continue
+		}
 
-	for _, r := range results {
-		if len(r.Password) > 0 {
-			// Get first email if available
-			email := ""
-			if len(r.Email) > 0 {
-				email = r.Email[0]
-			}
+		for _, email := range orEmpty(r.Email) {
+			for _, username := range orEmpty(r.Username) {
+				for _, secret := range secrets {
+					key := [4]string{email, username, secret.password, secret.hash}
+					if seen[key] {
+						continue
+					}
+					seen[key] = true
 
-			// Get first username if available
-			username := ""
-			if len(r.Username) > 0 {
-				username = r.Username[0]
+					creds = append(creds, User{
+						Email:          email,
+						Username:       username,
+						Password:       secret.password,
+						HashedPassword: secret.hash,
+						HashType:       r.HashType,
+						DatabaseName:   r.DatabaseName,
+						DehashedId:     r.DehashedId,
+					})
+				}
 			}
+		}
+	}
 
-			// Get first password
-			password := r.Password[0]
+	return creds
+}
 
-			cred := User{Email: email, Password: password, Username: username}
-			creds = append(creds, cred)
-		}
+// secret is a plaintext password, a hashed password or both when a record holds exactly one of each
+type secret struct {
+	password string
+	hash     string
+}
+
+// secrets returns the passwords and hashes of the result. A record with a single password and a single
+// hash is assumed to describe one credential, otherwise they cannot be paired and are kept apart.
+func (r Result) secrets() []secret {
+	passwords := nonEmpty(r.Password)
+	hashes := nonEmpty(r.HashedPassword)
+	if len(passwords) == 1 && len(hashes) == 1 {
+		return []secret{{password: passwords[0], hash: hashes[0]}}
 	}
 
-	go func() {
-		err := StoreUsers(creds)
-		if err != nil {
-			zap.L().Error("store_creds",
-				zap.String("message", "failed to store creds"),
-				zap.Error(err),
-			)
-			fmt.Printf("Error Storing Results: %v", err)
+	var secrets []secret
+	for _, p := range passwords {
+		secrets = append(secrets, secret{password: p})
+	}
+	for _, h := range hashes {
+		secrets = append(secrets, secret{hash: h})
+	}
+	return secrets
+}
+
+func nonEmpty(values []string) []string {
+	var out []string
+	for _, v := range values {
+		if v != "" {
+			out = append(out, v)
 		}
-	}()
+	}
+	return out
+}
 
-	return creds
+// orEmpty returns the non empty values, or a single empty value so a missing field still yields a combination
+func orEmpty(values []string) []string {
+	if out := nonEmpty(values); len(out) > 0 {
+		return out
+	}
+	return []string{""}
 }
 
 func (User) TableName() string {
@@ -159,6 +246,9 @@ func (User) TableName() string {
 }
 
 func (c User) ToString() string {
+	if c.Password == "" && c.HashedPassword != "" {
+		return fmt.Sprintf("%s%s%s", c.Username, "%", c.HashedPassword)
+	}
 	return fmt.Sprintf("%s%s%s", c.Username, "%", c.Password)
 }
 
@@ -193,10 +283,35 @@ func StoreDehashedResults(results DehashedResults) error {
 		}
 	}
 
+	if err := trackRecords(resultSlice, "dehashed_id"); err != nil {
+		lastErr = err
+	}
+
 	return lastErr
 }
 
+// StoreDehashedQueryOptions stores a new run, recording the active project on it
 func StoreDehashedQueryOptions(queryOptions *QueryOptions) error {
+	if queryOptions.Project == "" {
+		queryOptions.Project = ActiveProject()
+	}
 	db := GetDB()
 	return db.Create(queryOptions).Error
 }
+
+// UpdateDehashedQueryOptions saves the checkpoint state of a stored run
+func UpdateDehashedQueryOptions(queryOptions *QueryOptions) error {
+	db := GetDB()
+	return db.Save(queryOptions).Error
+}
+
+// GetDehashedQueryOptions returns the stored run with the given id
+func GetDehashedQueryOptions(id uint) (*QueryOptions, error) {
+	db := GetDB()
+	var queryOptions QueryOptions
+	err := db.First(&queryOptions, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &queryOptions, nil
+}
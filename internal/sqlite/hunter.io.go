@@ -6,6 +6,7 @@ import (
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
+	"strings"
 )
 
 // HunterDomainSearchResult represents the response from Hunter.io domain search API
@@ -41,6 +42,7 @@ type HunterDomainData struct {
 	CompanyType   string        `json:"company_type"`
 	Emails        []HunterEmail `json:"emails" gorm:"serializer:json"`
 	LinkedDomains []string      `json:"linked_domains" gorm:"serializer:json"`
+	OutOfScope    bool          `json:"out_of_scope" gorm:"index"`
 }
 
 func (h HunterDomainData) String() string {
@@ -70,6 +72,7 @@ type HunterEmail struct {
 	Twitter      string             `json:"twitter"`
 	PhoneNumber  string             `json:"phone_number"`
 	Verification HunterVerification `json:"verification" gorm:"embedded;embeddedPrefix:verification_"`
+	OutOfScope   bool               `json:"out_of_scope" gorm:"index"`
 }
 
 func (he *HunterEmail) ToTree() *tree.Tree {
@@ -216,6 +219,18 @@ func (ev HunterEmailVerifyData) String() string {
 		ev.Status, ev.Result, ev.DeprecationNotice, ev.Score, ev.Email, ev.Regexp, ev.Gibberish, ev.Disposable, ev.Webmail, ev.MXRecords, ev.SMTPServer, ev.SMTPCheck, ev.AcceptAll, ev.Block, ev.Sources)
 }
 
+// HunterEmailVerifyResults is a list of email verifications written as one file by batch lookups
+type HunterEmailVerifyResults []HunterEmailVerifyData
+
+func (evr HunterEmailVerifyResults) String() string {
+	var sb strings.Builder
+	for _, ev := range evr {
+		sb.WriteString(ev.String())
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
 // EmailVerifyMeta contains metadata about the API response
 type EmailVerifyMeta struct {
 	Params EmailVerifyParams `json:"params" gorm:"embedded;embeddedPrefix:params_"`
@@ -539,6 +554,7 @@ type PersonData struct {
 	Phone         string     `json:"phone"`
 	ActiveAt      string     `json:"activeAt"`
 	InactiveAt    string     `json:"inactiveAt"`
+	OutOfScope    bool       `json:"out_of_scope" gorm:"index"`
 }
 
 func (pd PersonData) String() string {
@@ -770,7 +786,7 @@ func StoreHunterDomain(hunterDomain HunterDomainData) error {
 		return err
 	}
 
-	return nil
+	return trackRecords([]HunterDomainData{hunterDomain}, "domain")
 }
 
 func StoreHunterEmails(hunterEmails []HunterEmail) error {
@@ -801,6 +817,10 @@ func StoreHunterEmails(hunterEmails []HunterEmail) error {
 		}
 	}
 
+	if err := trackRecords(hunterEmails, "value"); err != nil {
+		lastErr = err
+	}
+
 	return lastErr
 }
 
@@ -816,5 +836,39 @@ func StoreHunterPersonData(personData PersonData) error {
 		return err
 	}
 
-	return nil
+	return trackRecords([]PersonData{personData}, "email")
+}
+
+// HunterAccountResponse represents the response from Hunter.io account API
+type HunterAccountResponse struct {
+	Data HunterAccountData `json:"data"`
+}
+
+// HunterAccountData contains the plan and quota of the Hunter.io account
+type HunterAccountData struct {
+	Email     string              `json:"email"`
+	PlanName  string              `json:"plan_name"`
+	PlanLevel int                 `json:"plan_level"`
+	ResetDate string              `json:"reset_date"`
+	Requests  HunterAccountQuotas `json:"requests"`
+}
+
+type HunterAccountQuotas struct {
+	Searches      HunterAccountQuota `json:"searches"`
+	Verifications HunterAccountQuota `json:"verifications"`
+	Credits       HunterAccountQuota `json:"credits"`
+}
+
+type HunterAccountQuota struct {
+	Used      float64 `json:"used"`
+	Available float64 `json:"available"`
+}
+
+// Remaining returns the credits left on the account, falling back to the search quota on older plans
+func (ad HunterAccountData) Remaining() int {
+	quota := ad.Requests.Credits
+	if quota.Available == 0 {
+		quota = ad.Requests.Searches
+	}
+	return int(quota.Available - quota.Used)
 }
@@ -0,0 +1,153 @@
+package sqlite
+
+import (
+	"fmt"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"strconv"
+	"strings"
+)
+
+// Identity is a person the records of the creds, dehashed, hunter_email and person tables were resolved to
+type Identity struct {
+	gorm.Model
+	Name         string   `json:"name" yaml:"name" xml:"name"`
+	PrimaryEmail string   `json:"primary_email" yaml:"primary_email" xml:"primary_email" gorm:"index"`
+	Emails       []string `json:"emails" yaml:"emails" xml:"emails" gorm:"serializer:json"`
+	Usernames    []string `json:"usernames" yaml:"usernames" xml:"usernames" gorm:"serializer:json"`
+	Phones       []string `json:"phones" yaml:"phones" xml:"phones" gorm:"serializer:json"`
+	Names        []string `json:"names" yaml:"names" xml:"names" gorm:"serializer:json"`
+	Socials      []string `json:"socials" yaml:"socials" xml:"socials" gorm:"serializer:json"`
+	Records      int      `json:"records" yaml:"records" xml:"records"`
+	Confidence   float64  `json:"confidence" yaml:"confidence" xml:"confidence"` // Weakest link joining the records, 1 for a single record
+}
+
+func (Identity) TableName() string {
+	return "identities"
+}
+
+// IdentityRecord links a record to the identity it was resolved to
+type IdentityRecord struct {
+	ID          uint   `json:"id" gorm:"primarykey"`
+	IdentityID  uint   `json:"identity_id" gorm:"index"`
+	RecordTable string `json:"record_table" gorm:"uniqueIndex:idx_identity_record"`
+	RecordKey   string `json:"record_key" gorm:"uniqueIndex:idx_identity_record"` // creds id, dehashed id, hunter_email value or person email
+	MatchedOn   string `json:"matched_on"`                                        // Attribute that joined the record to the identity, empty for the first record
+}
+
+func (IdentityRecord) TableName() string {
+	return "identity_records"
+}
+
+// IdentityDetails is an identity with the records resolved to it
+type IdentityDetails struct {
+	Identity     Identity
+	Creds        []User
+	Dehashed     []Result
+	HunterEmails []HunterEmail
+	People       []PersonData
+}
+
+// ReplaceIdentities replaces the stored identities with a new resolution.
+// records holds the records of each identity, in the same order as identities.
+func ReplaceIdentities(identities []Identity, records [][]IdentityRecord) error {
+	if len(identities) != len(records) {
+		return fmt.Errorf("got records for %d identities, expected %d", len(records), len(identities))
+	}
+
+	zap.L().Info("Storing identities", zap.Int("count", len(identities)))
+	return GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Unscoped().Delete(&IdentityRecord{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Unscoped().Delete(&Identity{}).Error; err != nil {
+			return err
+		}
+		if len(identities) == 0 {
+			return nil
+		}
+
+		const batchSize = 100
+		if err := tx.CreateInBatches(&identities, batchSize).Error; err != nil {
+			return err
+		}
+		var links []IdentityRecord
+		for i, identity := range identities {
+			for _, r := range records[i] {
+				r.IdentityID = identity.ID
+				links = append(links, r)
+			}
+		}
+		return tx.CreateInBatches(&links, batchSize).Error
+	})
+}
+
+// likeEscaper escapes the LIKE wildcards of a value matched with ESCAPE '\'
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// IdentitiesStale reports whether records were stored or changed since the identities were last resolved,
+// or none are stored
+func IdentitiesStale() (bool, error) {
+	db := GetDB()
+	var latest Identity
+	err := db.Order("created_at DESC").Limit(1).Find(&latest).Error
+	if err != nil || latest.ID == 0 {
+		return true, err
+	}
+
+	for _, model := range []interface{}{&User{}, &Result{}, &HunterEmail{}, &PersonData{}} {
+		var count int64
+		if err = db.Model(model).Where("updated_at > ?", latest.CreatedAt).Limit(1).Count(&count).Error; err != nil {
+			return false, err
+		}
+		if count > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// FindIdentity returns the identity holding an email address, with the records resolved to it
+func FindIdentity(email string) (*IdentityDetails, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	db := GetDB()
+
+	var identities []Identity
+	quoted := likeEscaper.Replace(strconv.Quote(email))
+	if err := db.Where(`primary_email = ? OR emails LIKE ? ESCAPE '\'`, email, "%"+quoted+"%").Find(&identities).Error; err != nil {
+		return nil, err
+	}
+	if len(identities) == 0 {
+		return nil, nil
+	}
+
+	details := &IdentityDetails{Identity: identities[0]}
+	var links []IdentityRecord
+	if err := db.Where("identity_id = ?", details.Identity.ID).Order("id").Find(&links).Error; err != nil {
+		return nil, err
+	}
+	keys := make(map[string][]string)
+	for _, l := range links {
+		keys[l.RecordTable] = append(keys[l.RecordTable], l.RecordKey)
+	}
+
+	lookups := []struct {
+		table  string
+		column string
+		dest   interface{}
+	}{
+		{"creds", "id", &details.Creds},
+		{"dehashed", "dehashed_id", &details.Dehashed},
+		{"hunter_email", "value", &details.HunterEmails},
+		{"person", "email", &details.People},
+	}
+	for _, l := range lookups {
+		if len(keys[l.table]) == 0 {
+			continue
+		}
+		if err := db.Where(l.column+" IN ?", keys[l.table]).Find(l.dest).Error; err != nil {
+			return nil, err
+		}
+	}
+	return details, nil
+}
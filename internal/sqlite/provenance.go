@@ -0,0 +1,244 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CommandRun is one invocation of crowsnest that stored records
+type CommandRun struct {
+	gorm.Model
+	Command     string `json:"command" yaml:"command" xml:"command"`
+	CommandLine string `json:"command_line" yaml:"command_line" xml:"command_line"`
+	Provider    string `json:"provider" yaml:"provider" xml:"provider"` // Comma separated when a command queries several providers
+	Project     string `json:"project" yaml:"project" xml:"project"`
+	OutOfScope  string `json:"out_of_scope" yaml:"out_of_scope" xml:"out_of_scope"` // Targets queried despite falling outside the scope
+}
+
+func (CommandRun) TableName() string {
+	return "command_runs"
+}
+
+// RecordSource links a stored record to a command run that returned it.
+// Records are de-duplicated when stored, so a record found again by a later run gains another link.
+type RecordSource struct {
+	ID           uint      `json:"id" gorm:"primarykey"`
+	CreatedAt    time.Time `json:"created_at"`
+	CommandRunID uint      `json:"command_run_id" gorm:"uniqueIndex:idx_record_source"`
+	RecordTable  string    `json:"record_table" gorm:"uniqueIndex:idx_record_source;index:idx_record"`
+	RecordID     uint      `json:"record_id" gorm:"uniqueIndex:idx_record_source;index:idx_record"`
+}
+
+func (RecordSource) TableName() string {
+	return "record_sources"
+}
+
+var (
+	runMu          sync.Mutex
+	currentRun     *CommandRun
+	runCommand     string
+	runCommandLine string
+	runProviders   []string
+	activeProject  string
+	noLinks        bool
+)
+
+// SetProject sets the active project recorded on every run
+func SetProject(name string) {
+	runMu.Lock()
+	defer runMu.Unlock()
+	activeProject = name
+}
+
+// ActiveProject returns the project recorded on every run, empty for the default database
+func ActiveProject() string {
+	runMu.Lock()
+	defer runMu.Unlock()
+	return activeProject
+}
+
+// SetCommand sets the command recorded on the run created when the command first stores something
+func SetCommand(command, commandLine string) {
+	runMu.Lock()
+	defer runMu.Unlock()
+	runCommand = command
+	runCommandLine = commandLine
+}
+
+// SetLinkRecords sets whether stored records are linked to the current run. Reparsing turns it off, as the
+// records come from responses stored by earlier runs that are already linked to them.
+func SetLinkRecords(link bool) {
+	runMu.Lock()
+	defer runMu.Unlock()
+	noLinks = !link
+}
+
+// AddRunProvider records a provider the current command queries
+func AddRunProvider(provider string) {
+	runMu.Lock()
+	defer runMu.Unlock()
+	if provider == "" || slices.Contains(runProviders, provider) {
+		return
+	}
+	runProviders = append(runProviders, provider)
+	if currentRun != nil {
+		currentRun.Provider = strings.Join(runProviders, ",")
+		if err := GetDB().Model(currentRun).Update("provider", currentRun.Provider).Error; err != nil {
+			zap.L().Warn("update_command_run", zap.String("message", "failed to update command run provider"), zap.Error(err))
+		}
+	}
+}
+
+// CurrentCommandRun returns the run of the current command, creating it on first use
+func CurrentCommandRun() (*CommandRun, error) {
+	runMu.Lock()
+	defer runMu.Unlock()
+	if currentRun != nil {
+		return currentRun, nil
+	}
+
+	run := &CommandRun{
+		Command:     runCommand,
+		CommandLine: runCommandLine,
+		Provider:    strings.Join(runProviders, ","),
+		Project:     activeProject,
+	}
+	if err := GetDB().Create(run).Error; err != nil {
+		return nil, err
+	}
+	currentRun = run
+	return currentRun, nil
+}
+
+// MarkOutOfScope records on the current run the targets it queried outside the scope
+func MarkOutOfScope(targets []string) error {
+	run, err := CurrentCommandRun()
+	if err != nil {
+		return err
+	}
+
+	runMu.Lock()
+	defer runMu.Unlock()
+	for _, target := range targets {
+		if !slices.Contains(strings.Split(run.OutOfScope, ","), target) {
+			run.OutOfScope = strings.TrimPrefix(run.OutOfScope+","+target, ",")
+		}
+	}
+	return GetDB().Model(run).Update("out_of_scope", run.OutOfScope).Error
+}
+
+// linkRecords links the stored rows matching the records on the key columns to the current run.
+// Rows are looked up again because records skipped as duplicates are never given an ID by the insert.
+func linkRecords(records interface{}, keyColumns ...string) error {
+	runMu.Lock()
+	skip := noLinks
+	runMu.Unlock()
+
+	value := reflect.Indirect(reflect.ValueOf(records))
+	if skip || value.Len() == 0 {
+		return nil
+	}
+
+	run, err := CurrentCommandRun()
+	if err != nil {
+		zap.L().Warn("link_records", zap.String("message", "failed to create command run"), zap.Error(err))
+		return err
+	}
+
+	db := GetDB()
+	table, keys, err := recordKeys(db, records, keyColumns)
+	if err != nil {
+		return err
+	}
+
+	const batchSize = 100
+	var lastErr error
+	where := fmt.Sprintf("(%s) IN ?", strings.Join(keyColumns, ", "))
+	for i := 0; i < len(keys); i += batchSize {
+		end := i + batchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		var ids []uint
+		if err = db.Table(table).Where(where, keys[i:end]).Pluck("id", &ids).Error; err != nil {
+			zap.L().Warn("link_records", zap.String("message", "failed to look up stored records"), zap.Error(err))
+			lastErr = err
+			continue
+		}
+		if len(ids) == 0 {
+			continue
+		}
+
+		sources := make([]RecordSource, 0, len(ids))
+		for _, id := range ids {
+			sources = append(sources, RecordSource{CommandRunID: run.ID, RecordTable: table, RecordID: id})
+		}
+		if err = db.Clauses(clause.OnConflict{DoNothing: true}).Create(&sources).Error; err != nil {
+			zap.L().Warn("link_records", zap.String("message", "failed to link records to command run"), zap.Error(err))
+			lastErr = err
+		}
+	}
+
+	return lastErr
+}
+
+// recordKeys returns the table of the records and the values of the key columns of each record
+func recordKeys(db *gorm.DB, records interface{}, keyColumns []string) (string, [][]interface{}, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(records); err != nil {
+		return "", nil, err
+	}
+	table := stmt.Schema.Table
+
+	value := reflect.Indirect(reflect.ValueOf(records))
+	keys := make([][]interface{}, 0, value.Len())
+	for i := 0; i < value.Len(); i++ {
+		elem := value.Index(i)
+		key := make([]interface{}, 0, len(keyColumns))
+		for _, column := range keyColumns {
+			field := stmt.Schema.LookUpField(column)
+			if field == nil {
+				return "", nil, fmt.Errorf("unknown column %s for table %s", column, table)
+			}
+			v, _ := field.ValueOf(context.Background(), elem)
+			key = append(key, v)
+		}
+		keys = append(keys, key)
+	}
+	return table, keys, nil
+}
+
+// JoinSources joins the runs that stored each record of the object's table onto the query.
+// Every link becomes a row with the run ID, command line, provider and the time the run found the record.
+func JoinSources(query *gorm.DB, object interface{}, columns []string) (*gorm.DB, error) {
+	stmt := &gorm.Statement{DB: query}
+	if err := stmt.Parse(object); err != nil {
+		return nil, err
+	}
+	table := stmt.Schema.Table
+
+	selected := make([]string, 0, len(columns)+4)
+	for _, column := range columns {
+		selected = append(selected, table+"."+column)
+	}
+	selected = append(selected,
+		"command_runs.id AS found_by_run",
+		"command_runs.command_line AS found_by_command",
+		"command_runs.provider AS found_by_provider",
+		"record_sources.created_at AS found_at",
+	)
+
+	return query.Select(selected).
+		Joins(fmt.Sprintf("JOIN record_sources ON record_sources.record_table = ? AND record_sources.record_id = %s.id", table), table).
+		Joins("JOIN command_runs ON command_runs.id = record_sources.command_run_id").
+		Order("record_sources.id"), nil
+}
@@ -0,0 +1,148 @@
+package sqlite
+
+import (
+	"bytes"
+	"compress/gzip"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"io"
+	"reflect"
+	"time"
+)
+
+// RawResponse is an API response stored as received so it can be parsed again when the models change
+type RawResponse struct {
+	gorm.Model
+	RunID        uint   `json:"run_id" gorm:"index"`
+	CommandRunID uint   `json:"command_run_id" gorm:"index"`
+	Provider     string `json:"provider" gorm:"index"`
+	Operation    string `json:"operation" gorm:"index"`
+	Method       string `json:"method"`
+	Endpoint     string `json:"endpoint"` // Request URI with API keys redacted
+	StatusCode   int    `json:"status_code"`
+	ResponseSize int    `json:"response_size"` // Uncompressed bytes
+	Request      []byte `json:"-"`             // Gzip compressed request body
+	Response     []byte `json:"-"`             // Gzip compressed response body
+}
+
+func (RawResponse) TableName() string {
+	return "raw_responses"
+}
+
+// RequestBody returns the decompressed request body
+func (r RawResponse) RequestBody() ([]byte, error) {
+	return gunzip(r.Request)
+}
+
+// ResponseBody returns the decompressed response body
+func (r RawResponse) ResponseBody() ([]byte, error) {
+	return gunzip(r.Response)
+}
+
+// RawResponseFilter selects stored raw responses, zero values match everything
+type RawResponseFilter struct {
+	RunID     uint
+	Provider  string
+	Operation string
+	Since     time.Time
+	Limit     int
+}
+
+// StoreRawResponse compresses and stores the request and response bodies
+func StoreRawResponse(raw RawResponse, request, response []byte) error {
+	var err error
+	if raw.Request, err = gzipBytes(request); err != nil {
+		return err
+	}
+	if raw.Response, err = gzipBytes(response); err != nil {
+		return err
+	}
+	raw.ResponseSize = len(response)
+	if run, err := CurrentCommandRun(); err == nil {
+		raw.CommandRunID = run.ID
+	}
+
+	db := GetDB()
+	return db.Create(&raw).Error
+}
+
+// GetRawResponse returns the stored raw response with the given ID
+func GetRawResponse(id uint) (RawResponse, error) {
+	db := GetDB()
+	var raw RawResponse
+	err := db.First(&raw, id).Error
+	return raw, err
+}
+
+// GetRawResponses returns the stored raw responses matching the filter, oldest first
+func GetRawResponses(filter RawResponseFilter) ([]RawResponse, error) {
+	db := GetDB()
+	query := db.Model(&RawResponse{}).Order("id")
+	if filter.RunID > 0 {
+		query = query.Where("run_id = ?", filter.RunID)
+	}
+	if filter.Provider != "" {
+		query = query.Where("provider = ?", filter.Provider)
+	}
+	if filter.Operation != "" {
+		query = query.Where("operation = ?", filter.Operation)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("created_at >= ?", filter.Since)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	var raws []RawResponse
+	err := query.Find(&raws).Error
+	return raws, err
+}
+
+// UpsertRecords stores a slice of records, overwriting the stored copy of any record matching on the conflict columns.
+// Reparsing uses it so columns added to a model are filled in for records already stored.
+// The records are flagged against the scope like any other stored record, and linked to the current run unless
+// linking is turned off with SetLinkRecords.
+func UpsertRecords(records interface{}, conflictColumns ...string) error {
+	if reflect.ValueOf(records).Len() == 0 {
+		return nil
+	}
+
+	var columns []clause.Column
+	for _, name := range conflictColumns {
+		columns = append(columns, clause.Column{Name: name})
+	}
+
+	db := GetDB()
+	if err := db.Clauses(clause.OnConflict{Columns: columns, UpdateAll: true}).CreateInBatches(records, 100).Error; err != nil {
+		return err
+	}
+	return trackRecords(records, conflictColumns...)
+}
+
+func gzipBytes(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func gunzip(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
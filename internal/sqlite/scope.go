@@ -0,0 +1,241 @@
+package sqlite
+
+import (
+	"fmt"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// ScopeEntry is a target the engagement allows, stored in the project database
+type ScopeEntry struct {
+	gorm.Model
+	Kind  string `json:"kind" yaml:"kind" xml:"kind" gorm:"uniqueIndex:idx_scope_entry"`
+	Value string `json:"value" yaml:"value" xml:"value" gorm:"uniqueIndex:idx_scope_entry"`
+}
+
+func (ScopeEntry) TableName() string {
+	return "scope"
+}
+
+// ScopeChecker reports whether targets are inside the engagement scope
+type ScopeChecker interface {
+	Domain(domain string) bool
+	Email(email string) bool
+	IP(ip string) bool
+	Company(company string) bool
+}
+
+// scoped is implemented by stored records that can be checked against the scope
+type scoped interface {
+	inScope(s ScopeChecker) bool
+}
+
+var (
+	scopeMu      sync.RWMutex
+	scopeChecker ScopeChecker
+)
+
+// SetScopeChecker sets the scope stored records are flagged against, nil stops flagging
+func SetScopeChecker(s ScopeChecker) {
+	scopeMu.Lock()
+	defer scopeMu.Unlock()
+	scopeChecker = s
+}
+
+// AddScopeEntries stores scope entries, skipping those already stored
+func AddScopeEntries(entries []ScopeEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	db := GetDB()
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&entries).Error
+}
+
+// RemoveScopeEntry removes a scope entry and returns whether it was stored
+func RemoveScopeEntry(kind, value string) (bool, error) {
+	db := GetDB()
+	result := db.Unscoped().Where("kind = ? AND value = ?", kind, value).Delete(&ScopeEntry{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// ClearScope removes every scope entry
+func ClearScope() error {
+	db := GetDB()
+	return db.Unscoped().Where("1 = 1").Delete(&ScopeEntry{}).Error
+}
+
+// GetScopeEntries returns the stored scope entries ordered by kind and value
+func GetScopeEntries() ([]ScopeEntry, error) {
+	db := GetDB()
+	var entries []ScopeEntry
+	err := db.Order("kind, value").Find(&entries).Error
+	return entries, err
+}
+
+// RecheckScope flags every stored record against the current scope, returning the number out of scope.
+// Run it after the scope changes so records stored earlier are flagged the same way as new ones.
+func RecheckScope() (int64, error) {
+	tables := []struct {
+		records interface{}
+		keys    []string
+	}{
+		{&[]Result{}, []string{"dehashed_id"}},
+		{&[]User{}, userKeys},
+		{&[]WhoisRecord{}, []string{"domain_name"}},
+		{&[]HistoryRecord{}, []string{"domain_name"}},
+		{&[]LookupResult{}, []string{"name"}},
+		{&[]Subdomain{}, []string{"subdomain"}},
+		{&[]HunterDomainData{}, []string{"domain"}},
+		{&[]HunterEmail{}, []string{"value"}},
+		{&[]PersonData{}, []string{"email"}},
+	}
+
+	db := GetDB()
+	scopeMu.RLock()
+	checker := scopeChecker
+	scopeMu.RUnlock()
+	for _, t := range tables {
+		// Without a scope nothing is out of scope
+		if checker == nil {
+			if err := db.Model(t.records).Where("out_of_scope = ?", true).Update("out_of_scope", false).Error; err != nil {
+				return 0, err
+			}
+			continue
+		}
+		err := db.FindInBatches(t.records, 500, func(tx *gorm.DB, batch int) error {
+			return flagOutOfScope(reflect.ValueOf(t.records).Elem().Interface(), t.keys...)
+		}).Error
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	var total int64
+	for _, t := range tables {
+		var count int64
+		if err := db.Model(t.records).Where("out_of_scope = ?", true).Count(&count).Error; err != nil {
+			return 0, err
+		}
+		total += count
+	}
+	return total, nil
+}
+
+// trackRecords links stored records to the current run and flags those outside the scope
+func trackRecords(records interface{}, keyColumns ...string) error {
+	err := linkRecords(records, keyColumns...)
+	if flagErr := flagOutOfScope(records, keyColumns...); flagErr != nil {
+		zap.L().Warn("flag_out_of_scope", zap.String("message", "failed to flag out of scope records"), zap.Error(flagErr))
+		err = flagErr
+	}
+	return err
+}
+
+// flagOutOfScope sets out_of_scope on the stored rows matching the records on the key columns
+func flagOutOfScope(records interface{}, keyColumns ...string) error {
+	scopeMu.RLock()
+	checker := scopeChecker
+	scopeMu.RUnlock()
+
+	value := reflect.Indirect(reflect.ValueOf(records))
+	if checker == nil || value.Len() == 0 {
+		return nil
+	}
+
+	db := GetDB()
+	table, keys, err := recordKeys(db, records, keyColumns)
+	if err != nil {
+		return err
+	}
+
+	var in, out [][]interface{}
+	for i := 0; i < value.Len(); i++ {
+		record, ok := value.Index(i).Interface().(scoped)
+		if !ok {
+			return fmt.Errorf("records of table %s cannot be checked against the scope", table)
+		}
+		if record.inScope(checker) {
+			in = append(in, keys[i])
+		} else {
+			out = append(out, keys[i])
+		}
+	}
+
+	const batchSize = 100
+	where := fmt.Sprintf("(%s) IN ?", strings.Join(keyColumns, ", "))
+	for flag, group := range map[bool][][]interface{}{false: in, true: out} {
+		for i := 0; i < len(group); i += batchSize {
+			end := i + batchSize
+			if end > len(group) {
+				end = len(group)
+			}
+			if err = db.Table(table).Where(where, group[i:end]).Update("out_of_scope", flag).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// scopeTarget is a set of record values and the check they are matched with
+type scopeTarget struct {
+	values []string
+	check  func(string) bool
+}
+
+// anyInScope reports whether any target value is in scope, records with nothing to check are in scope
+func anyInScope(targets ...scopeTarget) bool {
+	checked := false
+	for _, t := range targets {
+		for _, v := range t.values {
+			if v == "" {
+				continue
+			}
+			if t.check(v) {
+				return true
+			}
+			checked = true
+		}
+	}
+	return !checked
+}
+
+func (r Result) inScope(s ScopeChecker) bool {
+	return anyInScope(scopeTarget{r.Email, s.Email}, scopeTarget{r.IpAddress, s.IP}, scopeTarget{r.Company, s.Company})
+}
+
+func (u User) inScope(s ScopeChecker) bool {
+	return anyInScope(scopeTarget{[]string{u.Email}, s.Email})
+}
+
+func (w WhoisRecord) inScope(s ScopeChecker) bool {
+	return anyInScope(scopeTarget{[]string{w.DomainName}, s.Domain})
+}
+
+func (h HistoryRecord) inScope(s ScopeChecker) bool {
+	return anyInScope(scopeTarget{[]string{h.DomainName}, s.Domain})
+}
+
+func (l LookupResult) inScope(s ScopeChecker) bool {
+	return anyInScope(scopeTarget{[]string{l.Name}, s.Domain})
+}
+
+func (sub Subdomain) inScope(s ScopeChecker) bool {
+	return anyInScope(scopeTarget{[]string{sub.Subdomain}, s.Domain})
+}
+
+func (h HunterDomainData) inScope(s ScopeChecker) bool {
+	return anyInScope(scopeTarget{[]string{h.Domain}, s.Domain})
+}
+
+func (h HunterEmail) inScope(s ScopeChecker) bool {
+	return anyInScope(scopeTarget{[]string{h.Value}, s.Email})
+}
+
+func (p PersonData) inScope(s ScopeChecker) bool {
+	return anyInScope(scopeTarget{[]string{p.Email}, s.Email})
+}
@@ -8,8 +8,9 @@ import (
 
 type Subdomain struct {
 	gorm.Model
-	Domain    string `json:"domain" yaml:"domain" xml:"domain"`
-	Subdomain string `json:"subdomain" yaml:"subdomain" xml:"subdomain" gorm:"uniqueIndex:idx_subdomain"`
+	Domain     string `json:"domain" yaml:"domain" xml:"domain"`
+	Subdomain  string `json:"subdomain" yaml:"subdomain" xml:"subdomain" gorm:"uniqueIndex:idx_subdomain"`
+	OutOfScope bool   `json:"out_of_scope" yaml:"out_of_scope" xml:"out_of_scope" gorm:"index"`
 }
 
 func StoreSubdomains(subs []Subdomain) error {
@@ -41,5 +42,9 @@ func StoreSubdomains(subs []Subdomain) error {
 		}
 	}
 
+	if err := trackRecords(subs, "subdomain"); err != nil {
+		lastErr = err
+	}
+
 	return lastErr
 }
@@ -1,6 +1,7 @@
 package sqlite
 
 import (
+	"crowsnest/internal/hashes"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
@@ -8,23 +9,42 @@ import (
 
 type User struct {
 	gorm.Model
-	Company     string `json:"company" yaml:"company" xml:"company"`
-	Position    string `json:"position" yaml:"position" xml:"position"`
-	Department  string `json:"department" yaml:"department" xml:"department"`
-	PhoneNumber string `json:"phone_number" yaml:"phone_number" xml:"phone_number"`
-	FullName    string `json:"full_name" yaml:"full_name" xml:"full_name"`
-	Phone       string `json:"phone" yaml:"phone" xml:"phone"`
-	Linkedin    string `json:"linkedin" yaml:"linkedin" xml:"linkedin"`
-	Twitter     string `json:"twitter" yaml:"twitter" xml:"twitter"`
-	Facebook    string `json:"facebook" yaml:"facebook" xml:"facebook"`
-	Instagram   string `json:"instagram" yaml:"instagram" xml:"instagram"`
-	Youtube     string `json:"youtube" yaml:"youtube" xml:"youtube"`
-	Gravatar    string `json:"gravatar" yaml:"gravatar" xml:"gravatar"`
-	Email       string `json:"email" yaml:"email" xml:"email" gorm:"uniqueIndex:idx_email_username_password"`
-	Username    string `json:"username" yaml:"username" xml:"username" gorm:"uniqueIndex:idx_email_username_password"`
-	Password    string `json:"password" yaml:"password" xml:"password" gorm:"uniqueIndex:idx_email_username_password"`
+	Company        string `json:"company" yaml:"company" xml:"company"`
+	Position       string `json:"position" yaml:"position" xml:"position"`
+	Department     string `json:"department" yaml:"department" xml:"department"`
+	PhoneNumber    string `json:"phone_number" yaml:"phone_number" xml:"phone_number"`
+	FullName       string `json:"full_name" yaml:"full_name" xml:"full_name"`
+	Phone          string `json:"phone" yaml:"phone" xml:"phone"`
+	Linkedin       string `json:"linkedin" yaml:"linkedin" xml:"linkedin"`
+	Twitter        string `json:"twitter" yaml:"twitter" xml:"twitter"`
+	Facebook       string `json:"facebook" yaml:"facebook" xml:"facebook"`
+	Instagram      string `json:"instagram" yaml:"instagram" xml:"instagram"`
+	Youtube        string `json:"youtube" yaml:"youtube" xml:"youtube"`
+	Gravatar       string `json:"gravatar" yaml:"gravatar" xml:"gravatar"`
+	Email          string `json:"email" yaml:"email" xml:"email" gorm:"uniqueIndex:idx_creds"`
+	Username       string `json:"username" yaml:"username" xml:"username" gorm:"uniqueIndex:idx_creds"`
+	Password       string `json:"password" yaml:"password" xml:"password" gorm:"uniqueIndex:idx_creds"`
+	HashedPassword string `json:"hashed_password" yaml:"hashed_password" xml:"hashed_password" gorm:"uniqueIndex:idx_creds"`
+	HashType       string `json:"hash_type" yaml:"hash_type" xml:"hash_type"`
+	DatabaseName   string `json:"database_name" yaml:"database_name" xml:"database_name"`
+	DehashedId     string `json:"dehashed_id" yaml:"dehashed_id" xml:"dehashed_id" gorm:"index"`
+	Cracked        bool   `json:"cracked" yaml:"cracked" xml:"cracked"`
+	CrackSource    string `json:"crack_source" yaml:"crack_source" xml:"crack_source"`
+	OutOfScope     bool   `json:"out_of_scope" yaml:"out_of_scope" xml:"out_of_scope" gorm:"index"`
 }
 
+// Crack is the plaintext of a cracked hash. Cracks are keyed by hash rather than stored on credentials, so
+// credentials stored again with the same hash, e.g. when a record is fetched again, pick the plaintext up.
+type Crack struct {
+	gorm.Model
+	Hash     string `json:"hash" yaml:"hash" xml:"hash" gorm:"uniqueIndex"` // Normalized with hashes.Key
+	Password string `json:"password" yaml:"password" xml:"password"`
+	Source   string `json:"source" yaml:"source" xml:"source"`
+}
+
+// userKeys are the columns identifying a stored credential
+var userKeys = []string{"email", "username", "password", "hashed_password"}
+
 func StoreUsers(users []User) error {
 	if len(users) == 0 {
 		return nil
@@ -33,6 +53,11 @@ func StoreUsers(users []User) error {
 	zap.L().Info("Storing credentials", zap.Int("count", len(users)))
 	db := GetDB()
 
+	users, err := applyCracks(users)
+	if err != nil {
+		return err
+	}
+
 	// Use batch insert with conflict handling
 	// This will insert records in batches and continue even if some fail
 	const batchSize = 100
@@ -54,5 +79,158 @@ func StoreUsers(users []User) error {
 		}
 	}
 
+	if err := trackRecords(users, userKeys...); err != nil {
+		lastErr = err
+	}
+
 	return lastErr
 }
+
+// StoreCracked stores the plaintext of the stored hashes found in the potfile, marking them cracked by source, and
+// fills it in on the credentials holding the hash. It returns the credentials that gained a password.
+func StoreCracked(pot hashes.Potfile, source string) ([]User, error) {
+	db := GetDB()
+	var (
+		uncracked []User
+		cracked   []User
+		cracks    []Crack
+		seen      = make(map[string]bool)
+	)
+
+	err := db.Where("hashed_password IS NOT NULL AND hashed_password != '' AND (password IS NULL OR password = '')").
+		FindInBatches(&uncracked, 500, func(tx *gorm.DB, batch int) error {
+			for _, u := range uncracked {
+				plain, ok := pot.Plain(u.HashedPassword)
+				if !ok {
+					continue
+				}
+				u.Password = plain
+				cracked = append(cracked, u)
+				if key := hashes.Key(u.HashedPassword); !seen[key] {
+					seen[key] = true
+					cracks = append(cracks, Crack{Hash: key, Password: plain, Source: source})
+				}
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	zap.L().Info("Storing cracked credentials", zap.Int("count", len(cracked)))
+	if len(cracks) > 0 {
+		if err = db.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&cracks, 100).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	var (
+		stored  []User
+		lastErr error
+	)
+	for _, u := range cracked {
+		err = db.Model(&User{}).Where("id = ?", u.ID).Updates(map[string]interface{}{
+			"password":     u.Password,
+			"cracked":      true,
+			"crack_source": source,
+		}).Error
+		if err != nil {
+			// The plaintext is already stored for the account with the same hash, the crack is read from the
+			// cracks table for this row
+			zap.L().Warn("Error storing cracked credential", zap.Uint("id", u.ID), zap.Error(err))
+			lastErr = err
+			continue
+		}
+		u.Cracked = true
+		u.CrackSource = source
+		stored = append(stored, u)
+	}
+
+	if err = trackRecords(stored, userKeys...); err != nil {
+		lastErr = err
+	}
+
+	return stored, lastErr
+}
+
+// GetCracks returns the stored cracks keyed by hash (see hashes.Key)
+func GetCracks() (map[string]Crack, error) {
+	db := GetDB()
+	var cracks []Crack
+	if err := db.Find(&cracks).Error; err != nil {
+		return nil, err
+	}
+	out := make(map[string]Crack, len(cracks))
+	for _, c := range cracks {
+		out[c.Hash] = c
+	}
+	return out, nil
+}
+
+// applyCracks returns the users with the plaintext of already cracked hashes filled in, so a credential stored
+// again does not come back as an uncracked hash
+func applyCracks(users []User) ([]User, error) {
+	var keys []string
+	for _, u := range users {
+		if u.HashedPassword != "" && u.Password == "" {
+			keys = append(keys, hashes.Key(u.HashedPassword))
+		}
+	}
+	if len(keys) == 0 {
+		return users, nil
+	}
+
+	db := GetDB()
+	cracks := make(map[string]Crack)
+	for i := 0; i < len(keys); i += 500 {
+		end := min(i+500, len(keys))
+		var batch []Crack
+		if err := db.Where("hash IN ?", keys[i:end]).Find(&batch).Error; err != nil {
+			return nil, err
+		}
+		for _, c := range batch {
+			cracks[c.Hash] = c
+		}
+	}
+	if len(cracks) == 0 {
+		return users, nil
+	}
+
+	out := make([]User, len(users))
+	copy(out, users)
+	for i, u := range out {
+		if u.HashedPassword == "" || u.Password != "" {
+			continue
+		}
+		if c, ok := cracks[hashes.Key(u.HashedPassword)]; ok {
+			out[i].Password = c.Password
+			out[i].Cracked = true
+			out[i].CrackSource = c.Source
+		}
+	}
+	return out, nil
+}
+
+// backfillCracks stores the cracks of credentials cracked before cracks had their own table
+func backfillCracks(db *gorm.DB) error {
+	var users []User
+	if err := db.Where("cracked = ? AND hashed_password != ''", true).Find(&users).Error; err != nil {
+		return err
+	}
+	var (
+		cracks []Crack
+		seen   = make(map[string]bool)
+	)
+	for _, u := range users {
+		key := hashes.Key(u.HashedPassword)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		cracks = append(cracks, Crack{Hash: key, Password: u.Password, Source: u.CrackSource})
+	}
+	if len(cracks) == 0 {
+		return nil
+	}
+	return db.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&cracks, 100).Error
+}
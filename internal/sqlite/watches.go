@@ -0,0 +1,146 @@
+package sqlite
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// Watch kinds
+const (
+	WatchDehashed   = "dehashed"
+	WatchSubdomains = "subdomains"
+	WatchWhois      = "whois"
+)
+
+// Watch is a recurring Dehashed search, WHOIS subdomain scan or WHOIS lookup, re-run to report the records not
+// stored before and the registration changes
+type Watch struct {
+	gorm.Model
+	Name        string        `json:"name" yaml:"name" xml:"name" gorm:"uniqueIndex"`
+	Kind        string        `json:"kind" yaml:"kind" xml:"kind"`
+	Field       string        `json:"field" yaml:"field" xml:"field"` // Dehashed query field, empty for subdomain scans and WHOIS lookups
+	Query       string        `json:"query" yaml:"query" xml:"query"`
+	Interval    time.Duration `json:"interval" yaml:"interval" xml:"interval"` // 0 runs only on demand
+	MaxRecords  int           `json:"max_records" yaml:"max_records" xml:"max_records"`
+	MaxRequests int           `json:"max_requests" yaml:"max_requests" xml:"max_requests"`
+	LastRun     time.Time     `json:"last_run" yaml:"last_run" xml:"last_run"`
+	LastRunID   uint          `json:"last_run_id" yaml:"last_run_id" xml:"last_run_id"` // Command run of the last run
+	LastNew     int           `json:"last_new" yaml:"last_new" xml:"last_new"`          // New records found by the last run
+	Runs        int           `json:"runs" yaml:"runs" xml:"runs"`
+}
+
+func (Watch) TableName() string {
+	return "watches"
+}
+
+// Due reports whether the watch's interval has passed since it last ran
+func (w Watch) Due(now time.Time) bool {
+	return w.Interval > 0 && (w.LastRun.IsZero() || now.Sub(w.LastRun) >= w.Interval)
+}
+
+// AddWatch stores a new watch
+func AddWatch(watch *Watch) error {
+	db := GetDB()
+	return db.Create(watch).Error
+}
+
+// SaveWatch stores the changes to a watch
+func SaveWatch(watch *Watch) error {
+	db := GetDB()
+	return db.Save(watch).Error
+}
+
+// RemoveWatch removes a watch and returns whether it was stored
+func RemoveWatch(name string) (bool, error) {
+	db := GetDB()
+	result := db.Unscoped().Where("name = ?", name).Delete(&Watch{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// GetWatches returns the watches ordered by name, every watch when no names are given
+func GetWatches(names ...string) ([]Watch, error) {
+	db := GetDB()
+	query := db.Order("name")
+	if len(names) > 0 {
+		query = query.Where("name IN ?", names)
+	}
+	var watches []Watch
+	err := query.Find(&watches).Error
+	return watches, err
+}
+
+// LinkMark returns the ID of the latest record link. Records first linked after the mark were first stored
+// after it was taken, which does not depend on the clock of the machine.
+func LinkMark() (uint, error) {
+	var mark uint
+	err := GetDB().Model(&RecordSource{}).Select("COALESCE(MAX(id), 0)").Scan(&mark).Error
+	return mark, err
+}
+
+// NewDehashed returns the records among ids the current run stored that no run stored before mark
+func NewDehashed(ids []string, mark uint) ([]Result, error) {
+	var results []Result
+	query, err := firstLinkedAfter(&Result{}, mark)
+	if err != nil {
+		return nil, err
+	}
+	err = whereIn(query, "dehashed_id", ids, &results)
+	return results, err
+}
+
+// NewCreds returns the credentials of the records among ids the current run stored that no run stored before mark
+func NewCreds(ids []string, mark uint) ([]User, error) {
+	var users []User
+	query, err := firstLinkedAfter(&User{}, mark)
+	if err != nil {
+		return nil, err
+	}
+	err = whereIn(query, "dehashed_id", ids, &users)
+	return users, err
+}
+
+// NewSubdomains returns the subdomains among names the current run stored that no run stored before mark
+func NewSubdomains(names []string, mark uint) ([]Subdomain, error) {
+	var subs []Subdomain
+	query, err := firstLinkedAfter(&Subdomain{}, mark)
+	if err != nil {
+		return nil, err
+	}
+	err = whereIn(query, "subdomain", names, &subs)
+	return subs, err
+}
+
+// firstLinkedAfter returns a query of the rows of the model's table linked to the current run and to no run
+// before mark. Rows stored before the run started are left out too, as records stored before they were linked
+// to runs have no earlier link.
+func firstLinkedAfter(model interface{}, mark uint) (*gorm.DB, error) {
+	run, err := CurrentCommandRun()
+	if err != nil {
+		return nil, err
+	}
+	db := GetDB()
+	stmt := &gorm.Statement{DB: db}
+	if err = stmt.Parse(model); err != nil {
+		return nil, err
+	}
+	table := stmt.Schema.Table
+
+	return db.Model(model).
+		Where("id IN (SELECT record_id FROM record_sources WHERE record_table = ? AND command_run_id = ?)", table, run.ID).
+		Where("id NOT IN (SELECT record_id FROM record_sources WHERE record_table = ? AND id <= ?)", table, mark).
+		Where("created_at >= ?", run.CreatedAt), nil
+}
+
+// whereIn finds the rows whose column is one of values, in batches that stay under the SQLite variable limit
+func whereIn[T any](query *gorm.DB, column string, values []string, dest *[]T) error {
+	const batchSize = 500
+	for i := 0; i < len(values); i += batchSize {
+		end := min(i+batchSize, len(values))
+		var batch []T
+		if err := query.Session(&gorm.Session{}).Where(column+" IN ?", values[i:end]).Find(&batch).Error; err != nil {
+			return err
+		}
+		*dest = append(*dest, batch...)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package sqlite
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// newRun starts a new command run, as a new process would
+func newRun() {
+	runMu.Lock()
+	defer runMu.Unlock()
+	currentRun = nil
+}
+
+func TestNewSubdomains(t *testing.T) {
+	if _, err := InitDB(t.TempDir()); err != nil {
+		t.Fatalf("InitDB() error = %v", err)
+	}
+	t.Cleanup(func() {
+		newRun()
+		DB = nil
+	})
+
+	// A subdomain stored before records were linked to runs
+	legacy := Subdomain{Model: gorm.Model{CreatedAt: time.Now().Add(-time.Hour)}, Domain: "acme.com", Subdomain: "old.acme.com"}
+	if err := DB.Create(&legacy).Error; err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	runs := []struct {
+		found []string
+		want  []string
+	}{
+		{found: []string{"old.acme.com", "www.acme.com"}, want: []string{"www.acme.com"}},
+		{found: []string{"old.acme.com", "www.acme.com", "vpn.acme.com"}, want: []string{"vpn.acme.com"}},
+		{found: []string{"www.acme.com"}},
+	}
+	for i, run := range runs {
+		newRun()
+		// The watch runner creates the run before taking the mark
+		if _, err := CurrentCommandRun(); err != nil {
+			t.Fatalf("CurrentCommandRun() error = %v", err)
+		}
+		mark, err := LinkMark()
+		if err != nil {
+			t.Fatalf("LinkMark() error = %v", err)
+		}
+
+		var subs []Subdomain
+		for _, name := range run.found {
+			subs = append(subs, Subdomain{Domain: "acme.com", Subdomain: name})
+		}
+		if err = StoreSubdomains(subs); err != nil {
+			t.Fatalf("run %d: StoreSubdomains() error = %v", i+1, err)
+		}
+
+		found, err := NewSubdomains(run.found, mark)
+		if err != nil {
+			t.Fatalf("run %d: NewSubdomains() error = %v", i+1, err)
+		}
+		var got []string
+		for _, sub := range found {
+			got = append(got, sub.Subdomain)
+		}
+		if !slices.Equal(got, run.want) {
+			t.Errorf("run %d: NewSubdomains() = %v, want %v", i+1, got, run.want)
+		}
+	}
+}
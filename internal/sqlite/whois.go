@@ -42,6 +42,19 @@ type WhoisRecord struct {
 	TechnicalContact      Contact      `json:"technicalContact" gorm:"serializer:json"`
 	UpdatedDate           string       `json:"updatedDate"`
 	UpdatedDateNormalized string       `json:"updatedDateNormalized"`
+	OutOfScope            bool         `json:"out_of_scope" gorm:"index"`
+}
+
+// WhoisRecords is a list of WHOIS records written as one file by batch lookups
+type WhoisRecords []WhoisRecord
+
+func (wr WhoisRecords) String() string {
+	var sb strings.Builder
+	for _, record := range wr {
+		sb.WriteString(record.String())
+		sb.WriteString("\n")
+	}
+	return sb.String()
 }
 
 func (w WhoisRecord) String() string {
@@ -287,6 +300,7 @@ type HistoryRecord struct {
 	UpdatedDateRaw        string      `json:"updatedDateRaw"`
 	WhoisServer           string      `json:"whoisServer"`
 	ZoneContact           ContactInfo `json:"zoneContact" gorm:"serializer:json"`
+	OutOfScope            bool        `json:"out_of_scope" gorm:"index"`
 }
 
 func (h HistoryRecord) String() string {
@@ -466,6 +480,7 @@ type LookupResult struct {
 	Name       string `json:"name" gorm:"unique"`
 	SearchTerm string `json:"search_term,omitempty"` // For storing the IP address this domain is associated with
 	Type       string `json:"type,omitempty"`        // For storing the MX address this domain is associated with
+	OutOfScope bool   `json:"out_of_scope" gorm:"index"`
 }
 
 func (LookupResult) TableName() string {
@@ -533,7 +548,39 @@ func StoreWhoisRecord(whoisRecord WhoisRecord) error {
 		return err
 	}
 
-	return nil
+	return trackRecords([]WhoisRecord{whoisRecord}, "domain_name")
+}
+
+// GetWhoisRecord returns the stored WHOIS record of the domain, nil when there is none
+func GetWhoisRecord(domain string) (*WhoisRecord, error) {
+	db := GetDB()
+	var records []WhoisRecord
+	if err := db.Where("domain_name = ?", domain).Limit(1).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	return &records[0], nil
+}
+
+// ReplaceWhoisRecord overwrites the stored WHOIS record of the domain with a newer lookup. StoreWhoisRecord keeps
+// the first record stored for a domain.
+func ReplaceWhoisRecord(whoisRecord WhoisRecord) error {
+	zap.L().Info("Replacing WHOIS record",
+		zap.String("domain", whoisRecord.DomainName))
+
+	db := GetDB()
+	err := db.Model(&WhoisRecord{}).Where("domain_name = ?", whoisRecord.DomainName).
+		Select("*").Omit("id", "created_at", "deleted_at").Updates(&whoisRecord).Error
+	if err != nil {
+		zap.L().Error("replace_whois_record",
+			zap.String("message", "failed to replace whois record"),
+			zap.Error(err))
+		return err
+	}
+
+	return trackRecords([]WhoisRecord{whoisRecord}, "domain_name")
 }
 
 func StoreWhoisHistoryRecords(historyRecords []HistoryRecord) error {
@@ -564,6 +611,10 @@ func StoreWhoisHistoryRecords(historyRecords []HistoryRecord) error {
 		}
 	}
 
+	if err := trackRecords(historyRecords, "domain_name"); err != nil {
+		lastErr = err
+	}
+
 	return lastErr
 }
 
@@ -595,6 +646,10 @@ func StoreWhoisLookup(lookup []LookupResult) error {
 		}
 	}
 
+	if err := trackRecords(lookup, "name"); err != nil {
+		lastErr = err
+	}
+
 	return lastErr
 }
 
@@ -0,0 +1,281 @@
+package watch
+
+import (
+	"crowsnest/internal/files"
+	"crowsnest/internal/httpclient"
+	"crowsnest/internal/lookup"
+	"crowsnest/internal/sqlite"
+	"crowsnest/internal/whois"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Runner re-runs watches and reports the records they found that were not stored before
+type Runner struct {
+	Whois          *whois.DehashedWhoIs
+	DehashedKey    string
+	DehashedClient *httpclient.Client
+	Debug          bool
+}
+
+// Run runs the watch and records the run on it. Records stored by an earlier run, of any command, are left out
+// of the result, so it only holds what is new. WHOIS watches report the registration fields that changed.
+func (r *Runner) Run(w *sqlite.Watch) (*Result, error) {
+	start := time.Now()
+	result := &Result{Watch: w.Name, Kind: w.Kind, Query: w.Query, RanAt: start}
+
+	// Records are new when this run is the first to link them, the mark is the last link made before it. The
+	// command run is created first, as records stored before it started are never new.
+	var mark uint
+	_, err := sqlite.CurrentCommandRun()
+	if err == nil {
+		mark, err = sqlite.LinkMark()
+	}
+	if err == nil {
+		switch w.Kind {
+		case sqlite.WatchDehashed:
+			err = r.dehashed(w, result, mark)
+		case sqlite.WatchSubdomains:
+			err = r.subdomains(w, result, mark)
+		case sqlite.WatchWhois:
+			err = r.whois(w, result)
+		default:
+			err = fmt.Errorf("unknown watch kind %q", w.Kind)
+		}
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	w.LastRun = start
+	w.LastNew = result.New()
+	w.Runs++
+	if run, runErr := sqlite.CurrentCommandRun(); runErr == nil {
+		w.LastRunID = run.ID
+	}
+	if saveErr := sqlite.SaveWatch(w); saveErr != nil && err == nil {
+		err = fmt.Errorf("failed to save watch: %w", saveErr)
+	}
+	return result, err
+}
+
+func (r *Runner) dehashed(w *sqlite.Watch, result *Result, mark uint) error {
+	options := &sqlite.QueryOptions{
+		MaxRecords:   w.MaxRecords,
+		MaxRequests:  w.MaxRequests,
+		StartingPage: 1,
+		OutputFormat: files.JSON,
+		OutputFile:   "watch_" + w.Name,
+		FetchAll:     true,
+		Debug:        r.Debug,
+	}
+	if err := options.SetQuery(w.Field, w.Query); err != nil {
+		return err
+	}
+
+	records, searchErr := lookup.Dehashed(options, r.DehashedKey, r.DehashedClient)
+	result.Total = len(records)
+
+	ids := make([]string, 0, len(records))
+	for _, record := range records {
+		ids = append(ids, record.DehashedId)
+	}
+	var err error
+	if result.Records, err = sqlite.NewDehashed(ids, mark); err != nil {
+		return fmt.Errorf("failed to diff records: %w", err)
+	}
+	if result.Creds, err = sqlite.NewCreds(ids, mark); err != nil {
+		return fmt.Errorf("failed to diff credentials: %w", err)
+	}
+	return searchErr
+}
+
+func (r *Runner) subdomains(w *sqlite.Watch, result *Result, mark uint) error {
+	subs, err := lookup.Subdomains(r.Whois, w.Query)
+	if err != nil {
+		return err
+	}
+	result.Total = len(subs)
+
+	names := make([]string, 0, len(subs))
+	for _, s := range subs {
+		names = append(names, s.Subdomain)
+	}
+	if result.Subdomains, err = sqlite.NewSubdomains(names, mark); err != nil {
+		return fmt.Errorf("failed to diff subdomains: %w", err)
+	}
+	return nil
+}
+
+func (r *Runner) whois(w *sqlite.Watch, result *Result) error {
+	previous, err := sqlite.GetWhoisRecord(w.Query)
+	if err != nil {
+		return fmt.Errorf("failed to load stored WHOIS record: %w", err)
+	}
+	record, err := lookup.Whois(r.Whois, w.Query)
+	if err != nil {
+		return err
+	}
+	result.Total = 1
+
+	if previous == nil {
+		result.Whois = &record
+		return nil
+	}
+	result.Changes = WhoisChanges(*previous, record)
+	if len(result.Changes) == 0 {
+		return nil
+	}
+	if err = sqlite.ReplaceWhoisRecord(record); err != nil {
+		return fmt.Errorf("failed to store WHOIS record: %w", err)
+	}
+	return nil
+}
+
+// WhoisChanges returns the registration fields that differ between two WHOIS records of a domain
+func WhoisChanges(before, after sqlite.WhoisRecord) []Change {
+	fields := []struct {
+		name          string
+		before, after string
+	}{
+		{"registrar", before.RegistrarName, after.RegistrarName},
+		{"registrant", registrant(before), registrant(after)},
+		{"contact_email", before.ContactEmail, after.ContactEmail},
+		{"name_servers", nameServers(before), nameServers(after)},
+		{"status", before.Status, after.Status},
+		{"updated_date", before.UpdatedDate, after.UpdatedDate},
+		{"expires_date", before.ExpiresDate, after.ExpiresDate},
+	}
+	var changes []Change
+	for _, f := range fields {
+		if f.before != f.after {
+			changes = append(changes, Change{Field: f.name, Old: f.before, New: f.after})
+		}
+	}
+	return changes
+}
+
+func registrant(record sqlite.WhoisRecord) string {
+	if record.Registrant.Organization != "" {
+		return record.Registrant.Organization
+	}
+	return record.Registrant.Name
+}
+
+// nameServers returns the name servers of the record sorted, so a change of order is not reported
+func nameServers(record sqlite.WhoisRecord) string {
+	hosts := make([]string, 0, len(record.NameServers.HostNames))
+	for _, h := range record.NameServers.HostNames {
+		hosts = append(hosts, strings.ToLower(strings.TrimSuffix(h, ".")))
+	}
+	sort.Strings(hosts)
+	return strings.Join(hosts, ",")
+}
+
+// ParseInterval parses a Go duration or a number of days or weeks, such as 12h, 30d or 2w. 0 disables the interval.
+func ParseInterval(s string) (time.Duration, error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			count, err := strconv.Atoi(n)
+			if err != nil || count < 0 {
+				return 0, fmt.Errorf("invalid interval %q", s)
+			}
+			return time.Duration(count) * unit, nil
+		}
+	}
+	if s == "0" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid interval %q, expected e.g. 12h, 30d or 2w", s)
+	}
+	return d, nil
+}
+
+// FormatInterval formats an interval in whole days when it is one, "manual" when it is 0
+func FormatInterval(d time.Duration) string {
+	switch {
+	case d == 0:
+		return "manual"
+	case d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	default:
+		return d.String()
+	}
+}
+
+// Result is what one run of a watch found that was not stored before
+type Result struct {
+	Watch      string              `json:"watch" yaml:"watch" xml:"watch,attr"`
+	Kind       string              `json:"kind" yaml:"kind" xml:"kind,attr"`
+	Query      string              `json:"query" yaml:"query" xml:"query"`
+	RanAt      time.Time           `json:"ran_at" yaml:"ran_at" xml:"ran_at"`
+	Total      int                 `json:"total" yaml:"total" xml:"total"` // Records retrieved, new or not
+	Records    []sqlite.Result     `json:"new_records,omitempty" yaml:"new_records,omitempty" xml:"new_records>record,omitempty"`
+	Creds      []sqlite.User       `json:"new_creds,omitempty" yaml:"new_creds,omitempty" xml:"new_creds>cred,omitempty"`
+	Subdomains []sqlite.Subdomain  `json:"new_subdomains,omitempty" yaml:"new_subdomains,omitempty" xml:"new_subdomains>subdomain,omitempty"`
+	Whois      *sqlite.WhoisRecord `json:"new_whois,omitempty" yaml:"new_whois,omitempty" xml:"new_whois,omitempty"` // First WHOIS record stored for the domain
+	Changes    []Change            `json:"whois_changes,omitempty" yaml:"whois_changes,omitempty" xml:"whois_changes>change,omitempty"`
+	Error      string              `json:"error,omitempty" yaml:"error,omitempty" xml:"error,omitempty"`
+}
+
+// Change is a WHOIS registration field that changed since the record was stored
+type Change struct {
+	Field string `json:"field" yaml:"field" xml:"field,attr"`
+	Old   string `json:"old" yaml:"old" xml:"old"`
+	New   string `json:"new" yaml:"new" xml:"new"`
+}
+
+// New returns the number of new records, subdomains and WHOIS changes, the new credentials come from the new records
+func (r *Result) New() int {
+	n := len(r.Records) + len(r.Subdomains) + len(r.Changes)
+	if r.Whois != nil {
+		n++
+	}
+	return n
+}
+
+// Report is the results of the watches run together
+type Report struct {
+	XMLName xml.Name  `json:"-" yaml:"-" xml:"watch_report"`
+	Results []*Result `json:"results" yaml:"results" xml:"result"`
+}
+
+func (r *Report) String() string {
+	var sb strings.Builder
+	for _, result := range r.Results {
+		sb.WriteString(fmt.Sprintf("Watch: %s (%s %s)\nRan At: %s\nRetrieved: %d\nNew: %d\n",
+			result.Watch, result.Kind, result.Query, result.RanAt.Format(time.RFC3339), result.Total, result.New()))
+		if result.Error != "" {
+			sb.WriteString("Error: " + result.Error + "\n")
+		}
+		for _, record := range result.Records {
+			sb.WriteString(fmt.Sprintf("  Record %s: %s %s (%s)\n", record.DehashedId,
+				strings.Join(record.Email, ","), strings.Join(record.Username, ","), record.DatabaseName))
+		}
+		for _, cred := range result.Creds {
+			secret := cred.Password
+			if secret == "" {
+				secret = cred.HashedPassword
+			}
+			sb.WriteString(fmt.Sprintf("  Credential: %s %s:%s\n", cred.Email, cred.Username, secret))
+		}
+		for _, sub := range result.Subdomains {
+			sb.WriteString("  Subdomain: " + sub.Subdomain + "\n")
+		}
+		if result.Whois != nil {
+			sb.WriteString(fmt.Sprintf("  WHOIS: first record stored, registrar %s\n", result.Whois.RegistrarName))
+		}
+		for _, change := range result.Changes {
+			sb.WriteString(fmt.Sprintf("  Changed %s: %s -> %s\n", change.Field, change.Old, change.New))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
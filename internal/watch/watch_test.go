@@ -0,0 +1,112 @@
+package watch
+
+import (
+	"crowsnest/internal/sqlite"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestWhoisChanges(t *testing.T) {
+	base := func() sqlite.WhoisRecord {
+		var r sqlite.WhoisRecord
+		r.DomainName = "acme.com"
+		r.RegistrarName = "Example Registrar"
+		r.Registrant.Organization = "Acme Inc"
+		r.ContactEmail = "hostmaster@acme.com"
+		r.NameServers.HostNames = []string{"ns1.acme.com", "ns2.acme.com"}
+		r.Status = "clientTransferProhibited"
+		r.ExpiresDate = "2027-01-01"
+		return r
+	}
+
+	tests := []struct {
+		name   string
+		change func(r *sqlite.WhoisRecord)
+		want   []Change
+	}{
+		{name: "unchanged", change: func(r *sqlite.WhoisRecord) {}},
+		{
+			name:   "name servers reordered",
+			change: func(r *sqlite.WhoisRecord) { r.NameServers.HostNames = []string{"NS2.acme.com.", "ns1.acme.com"} },
+		},
+		{
+			name:   "name servers moved",
+			change: func(r *sqlite.WhoisRecord) { r.NameServers.HostNames = []string{"a.dns.net", "b.dns.net"} },
+			want:   []Change{{Field: "name_servers", Old: "ns1.acme.com,ns2.acme.com", New: "a.dns.net,b.dns.net"}},
+		},
+		{
+			name: "registrar transfer and renewal",
+			change: func(r *sqlite.WhoisRecord) {
+				r.RegistrarName = "Other Registrar"
+				r.ExpiresDate = "2028-01-01"
+			},
+			want: []Change{
+				{Field: "registrar", Old: "Example Registrar", New: "Other Registrar"},
+				{Field: "expires_date", Old: "2027-01-01", New: "2028-01-01"},
+			},
+		},
+		{
+			name: "registrant redacted",
+			change: func(r *sqlite.WhoisRecord) {
+				r.Registrant.Organization = ""
+				r.Registrant.Name = "REDACTED FOR PRIVACY"
+			},
+			want: []Change{{Field: "registrant", Old: "Acme Inc", New: "REDACTED FOR PRIVACY"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			after := base()
+			tt.change(&after)
+			if got := WhoisChanges(base(), after); !slices.Equal(got, tt.want) {
+				t.Errorf("WhoisChanges() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseInterval(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+		err  bool
+	}{
+		{in: "0", want: 0},
+		{in: "12h", want: 12 * time.Hour},
+		{in: "90m", want: 90 * time.Minute},
+		{in: "30d", want: 30 * 24 * time.Hour},
+		{in: " 2W ", want: 14 * 24 * time.Hour},
+		{in: "0d", want: 0},
+		{in: "-1d", err: true},
+		{in: "-1h", err: true},
+		{in: "1.5d", err: true},
+		{in: "monthly", err: true},
+		{in: "", err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseInterval(tt.in)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("ParseInterval(%q) = %s, %v, want %s, error %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestDue(t *testing.T) {
+	now := time.Date(2026, 1, 31, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		w    sqlite.Watch
+		want bool
+	}{
+		{"never run", sqlite.Watch{Interval: time.Hour}, true},
+		{"on demand", sqlite.Watch{}, false},
+		{"interval passed", sqlite.Watch{Interval: 24 * time.Hour, LastRun: now.Add(-24 * time.Hour)}, true},
+		{"interval not passed", sqlite.Watch{Interval: 24 * time.Hour, LastRun: now.Add(-23 * time.Hour)}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.w.Due(now); got != tt.want {
+			t.Errorf("%s: Due() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
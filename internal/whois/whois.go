@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"crowsnest/internal/debug"
 	"crowsnest/internal/dehashed"
+	"crowsnest/internal/httpclient"
 	"crowsnest/internal/sqlite"
 	"encoding/json"
 	"errors"
@@ -13,6 +14,27 @@ import (
 	"net/http"
 )
 
+// WHOIS operations and their estimated credit cost
+const (
+	OpSearch        = "whois_search"
+	OpHistory       = "whois_history"
+	OpReverse       = "reverse_whois"
+	OpIP            = "whois_ip"
+	OpMX            = "whois_mx"
+	OpNS            = "whois_ns"
+	OpSubdomainScan = "whois_subdomain_scan"
+)
+
+var Costs = map[string]int{
+	OpSearch:        1,
+	OpHistory:       25,
+	OpReverse:       1,
+	OpIP:            1,
+	OpMX:            1,
+	OpNS:            1,
+	OpSubdomainScan: 1,
+}
+
 type DehashedWHOISSearchRequest struct {
 	Include     []string `json:"include,omitempty"`
 	Exclude     []string `json:"exclude,omitempty"`
@@ -28,10 +50,15 @@ type DehashedWhoIs struct {
 	balance int
 	debug   bool
 	apiKey  string
+	client  *httpclient.Client
 }
 
-func NewWhoIs(apiKey string, debug bool) *DehashedWhoIs {
-	return &DehashedWhoIs{apiKey: apiKey, debug: debug, balance: -1}
+// NewWhoIs creates a WHOIS client, falling back to the public Dehashed API when client is nil
+func NewWhoIs(apiKey string, client *httpclient.Client, debug bool) *DehashedWhoIs {
+	if client == nil {
+		client = httpclient.Default(httpclient.Whois, debug)
+	}
+	return &DehashedWhoIs{apiKey: apiKey, debug: debug, balance: -1, client: client}
 }
 
 func (w *DehashedWhoIs) WhoisSearch(domain string) (sqlite.WhoisRecord, error) {
@@ -60,7 +87,7 @@ func (w *DehashedWhoIs) WhoisSearch(domain string) (sqlite.WhoisRecord, error) {
 	}
 
 	reqBody, _ := json.Marshal(whoisSearchRequest)
-	req, err := http.NewRequest("POST", "https://api.dehashed.com/v2/whois/search", bytes.NewReader(reqBody))
+	req, err := http.NewRequest("POST", w.client.URL("/v2/whois/search"), bytes.NewReader(reqBody))
 	if err != nil {
 		return whoisRecord, err
 	}
@@ -84,7 +111,7 @@ func (w *DehashedWhoIs) WhoisSearch(domain string) (sqlite.WhoisRecord, error) {
 		)
 	}
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := w.client.Do(httpclient.WithCost(req, OpSearch, Costs[OpSearch]))
 	if res != nil {
 		defer res.Body.Close()
 	}
@@ -187,7 +214,7 @@ func (w *DehashedWhoIs) WhoisHistory(domain string) ([]sqlite.HistoryRecord, err
 		)
 	}
 
-	req, err := http.NewRequest("POST", "https://api.dehashed.com/v2/whois/search", bytes.NewReader(reqBody))
+	req, err := http.NewRequest("POST", w.client.URL("/v2/whois/search"), bytes.NewReader(reqBody))
 	if err != nil {
 		if w.debug {
 			debug.PrintInfo("failed to create request")
@@ -211,7 +238,7 @@ func (w *DehashedWhoIs) WhoisHistory(domain string) ([]sqlite.HistoryRecord, err
 		)
 	}
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := w.client.Do(httpclient.WithCost(req, OpHistory, Costs[OpHistory]))
 	if res != nil {
 		if w.debug {
 			debug.PrintInfo("response was not nil")
@@ -325,7 +352,7 @@ func (w *DehashedWhoIs) ReverseWHOIS(include []string, exclude []string, reverse
 		)
 	}
 
-	req, err := http.NewRequest("POST", "https://api.dehashed.com/v2/whois/search", bytes.NewReader(reqBody))
+	req, err := http.NewRequest("POST", w.client.URL("/v2/whois/search"), bytes.NewReader(reqBody))
 	if err != nil {
 		zap.L().Error("reverse_whois",
 			zap.String("message", "failed to create request"),
@@ -345,7 +372,7 @@ func (w *DehashedWhoIs) ReverseWHOIS(include []string, exclude []string, reverse
 		)
 	}
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := w.client.Do(httpclient.WithCost(req, OpReverse, Costs[OpReverse]))
 	if res != nil {
 		defer res.Body.Close()
 	}
@@ -460,7 +487,7 @@ func (w *DehashedWhoIs) WhoisIP(ipAddress string) ([]sqlite.LookupResult, error)
 		)
 	}
 
-	req, err := http.NewRequest("POST", "https://api.dehashed.com/v2/whois/search", bytes.NewReader(reqBody))
+	req, err := http.NewRequest("POST", w.client.URL("/v2/whois/search"), bytes.NewReader(reqBody))
 	if err != nil {
 		zap.L().Error("whois_ip",
 			zap.String("message", "failed to create request"),
@@ -480,7 +507,7 @@ func (w *DehashedWhoIs) WhoisIP(ipAddress string) ([]sqlite.LookupResult, error)
 		)
 	}
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := w.client.Do(httpclient.WithCost(req, OpIP, Costs[OpIP]))
 	if res != nil {
 		defer res.Body.Close()
 	}
@@ -565,17 +592,7 @@ func (w *DehashedWhoIs) WhoisIP(ipAddress string) ([]sqlite.LookupResult, error)
 		debug.PrintJson(fmt.Sprintf("Data: %v\n", whois.Data))
 	}
 	w.balance = whois.RemainingCredits
-	var lookups []sqlite.LookupResult
-
-	for _, v := range whois.Data.Result {
-		lookups = append(lookups, sqlite.LookupResult{
-			FirstSeen:  v.FirstSeen,
-			LastVisit:  v.LastVisit,
-			Name:       v.Name,
-			SearchTerm: ipAddress,
-			Type:       "Reverse IP",
-		})
-	}
+	lookups := LookupResults(whois.Data, ipAddress, "Reverse IP")
 
 	sqlite.StoreWhoisLookup(lookups)
 
@@ -610,7 +627,7 @@ func (w *DehashedWhoIs) WhoisMX(mxHostname string) ([]sqlite.LookupResult, error
 		)
 	}
 
-	req, err := http.NewRequest("POST", "https://api.dehashed.com/v2/whois/search", bytes.NewReader(reqBody))
+	req, err := http.NewRequest("POST", w.client.URL("/v2/whois/search"), bytes.NewReader(reqBody))
 	if err != nil {
 		if w.debug {
 			debug.PrintInfo("failed to create request")
@@ -634,7 +651,7 @@ func (w *DehashedWhoIs) WhoisMX(mxHostname string) ([]sqlite.LookupResult, error
 		)
 	}
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := w.client.Do(httpclient.WithCost(req, OpMX, Costs[OpMX]))
 	if res != nil {
 		defer res.Body.Close()
 	}
@@ -715,17 +732,7 @@ func (w *DehashedWhoIs) WhoisMX(mxHostname string) ([]sqlite.LookupResult, error
 		debug.PrintJson(fmt.Sprintf("Data: %v\n", whois.Data))
 	}
 
-	var mxLookups []sqlite.LookupResult
-
-	for _, v := range whois.Data.Result {
-		mxLookups = append(mxLookups, sqlite.LookupResult{
-			FirstSeen:  v.FirstSeen,
-			LastVisit:  v.LastVisit,
-			Name:       v.Name,
-			SearchTerm: mxHostname,
-			Type:       "MX",
-		})
-	}
+	mxLookups := LookupResults(whois.Data, mxHostname, "MX")
 
 	sqlite.StoreWhoisLookup(mxLookups)
 
@@ -761,7 +768,7 @@ func (w *DehashedWhoIs) WhoisNS(nsHostname string) ([]sqlite.LookupResult, error
 		)
 	}
 
-	req, err := http.NewRequest("POST", "https://api.dehashed.com/v2/whois/search", bytes.NewReader(reqBody))
+	req, err := http.NewRequest("POST", w.client.URL("/v2/whois/search"), bytes.NewReader(reqBody))
 	if err != nil {
 		zap.L().Error("whois_ns",
 			zap.String("message", "failed to create request"),
@@ -781,7 +788,7 @@ func (w *DehashedWhoIs) WhoisNS(nsHostname string) ([]sqlite.LookupResult, error
 		)
 	}
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := w.client.Do(httpclient.WithCost(req, OpNS, Costs[OpNS]))
 	if res != nil {
 		defer res.Body.Close()
 	}
@@ -862,17 +869,7 @@ func (w *DehashedWhoIs) WhoisNS(nsHostname string) ([]sqlite.LookupResult, error
 		debug.PrintJson(fmt.Sprintf("Data: %v\n", whois.Data))
 	}
 	w.balance = whois.RemainingCredits
-	var nsLookups []sqlite.LookupResult
-
-	for _, v := range whois.Data.Result {
-		nsLookups = append(nsLookups, sqlite.LookupResult{
-			FirstSeen:  v.FirstSeen,
-			LastVisit:  v.LastVisit,
-			Name:       v.Name,
-			SearchTerm: nsHostname,
-			Type:       "NS",
-		})
-	}
+	nsLookups := LookupResults(whois.Data, nsHostname, "NS")
 
 	sqlite.StoreWhoisLookup(nsLookups)
 
@@ -906,7 +903,7 @@ func (w *DehashedWhoIs) WhoisSubdomainScan(domain string) ([]sqlite.SubdomainRec
 		)
 	}
 
-	req, err := http.NewRequest("POST", "https://api.dehashed.com/v2/whois/search", bytes.NewReader(reqBody))
+	req, err := http.NewRequest("POST", w.client.URL("/v2/whois/search"), bytes.NewReader(reqBody))
 	if err != nil {
 		if w.debug {
 			debug.PrintInfo("failed to create request")
@@ -930,7 +927,7 @@ func (w *DehashedWhoIs) WhoisSubdomainScan(domain string) ([]sqlite.SubdomainRec
 		)
 	}
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := w.client.Do(httpclient.WithCost(req, OpSubdomainScan, Costs[OpSubdomainScan]))
 	if res != nil {
 		defer res.Body.Close()
 	}
@@ -1020,7 +1017,7 @@ func (w *DehashedWhoIs) Balance() (int, error) {
 func (w *DehashedWhoIs) getBalance() (int, error) {
 	var whoisCredits sqlite.WhoIsCredits
 
-	req, err := http.NewRequest("GET", "https://api.dehashed.com/v2/whois/credits", nil)
+	req, err := http.NewRequest("GET", w.client.URL("/v2/whois/credits"), nil)
 	if err != nil {
 		if w.debug {
 			debug.PrintInfo("failed to create request")
@@ -1041,7 +1038,7 @@ func (w *DehashedWhoIs) getBalance() (int, error) {
 		)
 	}
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := w.client.Do(req)
 	if res != nil {
 		defer res.Body.Close()
 	}
@@ -1113,5 +1110,22 @@ func (w *DehashedWhoIs) getBalance() (int, error) {
 		debug.PrintJson(fmt.Sprintf("Remaining Credits: %d\n", whoisCredits.WhoisCredits))
 	}
 
+	w.balance = whoisCredits.WhoisCredits
+	w.client.SetBalance(whoisCredits.WhoisCredits)
 	return whoisCredits.WhoisCredits, nil
 }
+
+// LookupResults converts a reverse IP, MX or NS response into lookup records for the search term
+func LookupResults(data sqlite.IPData, searchTerm, lookupType string) []sqlite.LookupResult {
+	var lookups []sqlite.LookupResult
+	for _, v := range data.Result {
+		lookups = append(lookups, sqlite.LookupResult{
+			FirstSeen:  v.FirstSeen,
+			LastVisit:  v.LastVisit,
+			Name:       v.Name,
+			SearchTerm: searchTerm,
+			Type:       lookupType,
+		})
+	}
+	return lookups
+}
@@ -0,0 +1,65 @@
+package workers
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// Output buffers what each job prints and writes it in job order as the jobs finish, so the output of
+// concurrent jobs is not interleaved. With a single worker the output is written as it is printed.
+type Output struct {
+	mu      sync.Mutex
+	w       io.Writer
+	direct  bool
+	buffers []*bytes.Buffer
+	done    []bool
+	next    int
+}
+
+// NewOutput creates an Output writing the output of n jobs run on workers goroutines to w
+func NewOutput(w io.Writer, n, workers int) *Output {
+	o := &Output{
+		w:       w,
+		direct:  workers <= 1,
+		buffers: make([]*bytes.Buffer, n),
+		done:    make([]bool, n),
+	}
+	for i := range o.buffers {
+		o.buffers[i] = new(bytes.Buffer)
+	}
+	return o
+}
+
+// Job returns the writer of job i, which is only safe to use from the goroutine running the job
+func (o *Output) Job(i int) io.Writer {
+	if o.direct {
+		return o.w
+	}
+	return o.buffers[i]
+}
+
+// Done marks job i finished and writes the output of every finished job no earlier job is still running before
+func (o *Output) Done(i int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.done[i] = true
+	for o.next < len(o.buffers) && o.done[o.next] {
+		o.flush(o.next)
+		o.next++
+	}
+}
+
+// Flush writes the output of the jobs not written yet, once every job has stopped
+func (o *Output) Flush() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for ; o.next < len(o.buffers); o.next++ {
+		o.flush(o.next)
+	}
+}
+
+func (o *Output) flush(i int) {
+	o.w.Write(o.buffers[i].Bytes())
+	o.buffers[i] = nil
+}
@@ -0,0 +1,62 @@
+package workers
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultWorkers is the number of lookups run at once unless configured otherwise
+const DefaultWorkers = 4
+
+// Run calls fn for every index from 0 to n-1 on up to workers goroutines.
+// The first error returned by fn stops new calls from starting and is returned once the
+// running calls finish, so work already paid for is kept. Cancelling ctx aborts the running calls.
+// Failures that should not stop the pool are expected to be handled inside fn.
+func Run(ctx context.Context, workers, n int, fn func(ctx context.Context, i int) error) error {
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > n {
+		workers = n
+	}
+
+	var (
+		jobs     = make(chan int)
+		stop     = make(chan struct{})
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if err := fn(ctx, i); err != nil {
+					once.Do(func() {
+						firstErr = err
+						close(stop)
+					})
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := 0; i < n; i++ {
+		select {
+		case <-ctx.Done():
+			break feed
+		case <-stop:
+			break feed
+		case jobs <- i:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}